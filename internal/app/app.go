@@ -19,21 +19,37 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 
 	docs "github.com/inokone/go-micro-saas/api"
+	"github.com/inokone/go-micro-saas/internal/auth"
 	"github.com/inokone/go-micro-saas/internal/auth/account"
+	"github.com/inokone/go-micro-saas/internal/auth/apikey"
+	"github.com/inokone/go-micro-saas/internal/auth/identity"
+	"github.com/inokone/go-micro-saas/internal/auth/invitation"
+	"github.com/inokone/go-micro-saas/internal/auth/magiclink"
+	"github.com/inokone/go-micro-saas/internal/auth/organization"
+	"github.com/inokone/go-micro-saas/internal/auth/passkey"
+	"github.com/inokone/go-micro-saas/internal/auth/password"
+	"github.com/inokone/go-micro-saas/internal/auth/provider"
+	"github.com/inokone/go-micro-saas/internal/auth/quota"
 	"github.com/inokone/go-micro-saas/internal/auth/role"
+	"github.com/inokone/go-micro-saas/internal/auth/session"
+	"github.com/inokone/go-micro-saas/internal/auth/twofactor"
 	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/billing"
 	"github.com/inokone/go-micro-saas/internal/common"
 	"github.com/inokone/go-micro-saas/internal/db"
 	"github.com/inokone/go-micro-saas/internal/history"
+	"github.com/inokone/go-micro-saas/internal/invoice"
 	"github.com/inokone/go-micro-saas/internal/mail"
 	"github.com/inokone/go-micro-saas/internal/notification"
+	"github.com/inokone/go-micro-saas/internal/ratelimit"
 	"github.com/inokone/go-micro-saas/internal/routes"
 )
 
 var (
-	Config  *common.AppConfig
-	storers routes.Storers
-	DB      *sqlx.DB
+	Config   *common.AppConfig
+	storers  routes.Storers
+	services routes.Services
+	DB       *sqlx.DB
 )
 
 func initStorers() {
@@ -41,6 +57,45 @@ func initStorers() {
 	storers.Users = user.NewPostgresStorer(DB, storers.Roles)
 	storers.Accounts = account.NewPostgresStorer(DB)
 	storers.History = history.NewPostgresStorer(DB)
+	storers.Sessions = session.NewPostgresStorer(DB)
+	storers.Factors = twofactor.NewPostgresStorer(DB)
+	storers.Passkeys = passkey.NewPostgresStorer(DB)
+	storers.Keys = apikey.NewPostgresStorer(DB)
+	storers.Identities = identity.NewPostgresStorer(DB)
+	storers.MagicLinks = magiclink.NewPostgresStorer(DB)
+	storers.Passwords = password.NewPostgresStorer(DB)
+	storers.Organizations = organization.NewPostgresStorer(DB)
+	storers.Invitations = invitation.NewPostgresStorer(DB)
+	storers.Usages = quota.NewPostgresStorer(DB)
+	storers.Subscriptions = billing.NewPostgresStorer(DB)
+	storers.Invoices = invoice.NewPostgresStorer(DB)
+	storers.RateLimits = ratelimit.NewPostgresStorer(DB)
+}
+
+func initServices(ps *pubsub.PubSub[string, common.Event]) {
+	var err error
+	if services.Keys, err = auth.LoadKeySet(Config); err != nil {
+		log.WithError(err).Error("Failed to load JWT keys")
+		os.Exit(1)
+	}
+	if services.Passkeys, err = passkey.NewService(storers.Passkeys, storers.Users, Config.Auth, Config.Mail.ApplicationName); err != nil {
+		log.WithError(err).Error("Failed to set up passkeys")
+		os.Exit(1)
+	}
+	if services.Providers, err = provider.NewRegistry(Config.Auth); err != nil {
+		log.WithError(err).Error("Failed to initialize identity providers")
+		os.Exit(1)
+	}
+	if services.Payments, err = billing.NewProvider(Config.Billing); err != nil {
+		log.WithError(err).Error("Failed to initialize the billing provider")
+		os.Exit(1)
+	}
+	if services.Limiter, err = ratelimit.NewLimiter(Config.RateLimit, storers.RateLimits); err != nil {
+		log.WithError(err).Error("Failed to initialize rate limiting")
+		os.Exit(1)
+	}
+	services.Mailer = mail.NewService(Config.Mail, ps)
+	services.JWT = auth.NewJWTHandler(storers.Users, storers.Sessions, storers.Keys, storers.Organizations, services.Keys, Config.Auth, ps)
 }
 
 func initDB() {
@@ -63,6 +118,7 @@ func App(c *common.AppConfig) {
 	listenOS(cancel)
 
 	ps := pubsub.New[string, common.Event](0)
+	initServices(ps)
 
 	startHistoryService(ctx, ps)
 
@@ -73,7 +129,7 @@ func App(c *common.AppConfig) {
 
 func startNotificationService(ctx context.Context, ps *pubsub.PubSub[string, common.Event]) {
 	ch := ps.Sub(common.NotificationTopic)
-	s := notification.NewService(ch, mail.NewService(Config.Mail, ps))
+	s := notification.NewService(ch, services.Mailer)
 	s.Start(ctx)
 }
 
@@ -121,6 +177,9 @@ func startGin(ps *pubsub.PubSub[string, common.Event]) {
 
 func createRouter(ps *pubsub.PubSub[string, common.Event]) *gin.Engine {
 	router := gin.New()
+	if err := router.SetTrustedProxies(Config.Web.Proxies()); err != nil {
+		log.Fatal("Invalid trusted proxies:", err)
+	}
 	if Config.Log.PrettyLog {
 		router.Use(gin.Logger())
 	} else {
@@ -145,13 +204,28 @@ func setupRoutes(router *gin.Engine, ps *pubsub.PubSub[string, common.Event]) {
 		MaxAge:           12 * time.Hour,
 	}
 
+	hasher, err := password.LoadHasher(Config.Auth)
+	if err != nil {
+		log.WithError(err).Error("Failed to configure password hashing")
+		os.Exit(1)
+	}
+	user.UseHasher(hasher)
+
+	wellKnown := router.Group("/.well-known")
+	wellKnown.Use(cors.Default())
+	routes.InitWellKnown(wellKnown, services.Keys)
+
 	public := router.Group("/api/public/v1")
 	public.Use(cors.Default())
-	routes.InitPublic(public, storers, Config)
+	err = routes.InitPublic(public, storers, services, Config, ps)
+	if err != nil {
+		log.WithError(err).Error("Failed to initialize the public endpoints")
+		os.Exit(1)
+	}
 
 	private := router.Group("/api/v1")
 	private.Use(cors.New(privateCors))
-	err := routes.InitPrivate(private, storers, Config, ps)
+	err = routes.InitPrivate(private, storers, services, Config, ps)
 	if err != nil {
 		log.WithError(err).Error("Failed to initialize the application")
 		os.Exit(1)
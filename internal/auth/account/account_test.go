@@ -0,0 +1,22 @@
+package account
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffLocksByFailures(t *testing.T) {
+	backoff, err := ParseBackoff("0s, 0s, 10s,1h")
+	assert.NoError(t, err)
+
+	assert.Zero(t, backoff.Lock(0))
+	assert.Zero(t, backoff.Lock(2))
+	assert.Equal(t, 10*time.Second, backoff.Lock(3))
+	assert.Equal(t, time.Hour, backoff.Lock(4))
+	assert.Equal(t, time.Hour, backoff.Lock(40))
+
+	_, err = ParseBackoff("10s,1d")
+	assert.Error(t, err)
+}
@@ -4,11 +4,17 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/cskr/pubsub/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/inokone/go-micro-saas/internal/auth/identity"
+	"github.com/inokone/go-micro-saas/internal/auth/invitation"
+	"github.com/inokone/go-micro-saas/internal/auth/organization"
+	"github.com/inokone/go-micro-saas/internal/auth/password"
 	"github.com/inokone/go-micro-saas/internal/auth/role"
+	"github.com/inokone/go-micro-saas/internal/auth/session"
 	"github.com/inokone/go-micro-saas/internal/auth/user"
 	"github.com/inokone/go-micro-saas/internal/common"
 	"github.com/inokone/go-micro-saas/internal/mail"
@@ -23,21 +29,34 @@ var statusBadRequest common.StatusMessage = common.StatusMessage{Message: "Inval
 
 // Handler is a struct for web handles related to authentication and authorization.
 type Handler struct {
-	users    user.Storer
-	accounts Storer
-	sender   *mail.Service
-	config   *common.AuthConfig
-	captcha  *common.RecaptchaValidator
+	users       user.Storer
+	accounts    Storer
+	identities  identity.Storer
+	roles       role.Storer
+	invitations invitation.Storer
+	orgs        organization.Storer
+	passwords   *password.Policy
+	sender      *mail.Service
+	config      *common.AuthConfig
+	captcha     common.CaptchaVerifier
+	ps          *pubsub.PubSub[string, common.Event]
 }
 
-// NewHandler creates a new `Handler`, based on the user persistence and the authentication configuration parameters.
-func NewHandler(users user.Storer, accounts Storer, sender *mail.Service, config *common.AuthConfig, captcha *common.RecaptchaValidator) *Handler {
+// NewHandler creates a new `Handler`, based on the user, role, invitation and organization persistence, the password policy and the authentication configuration parameters.
+// The password changes are published as security alerts.
+func NewHandler(users user.Storer, accounts Storer, identities identity.Storer, roles role.Storer, invitations invitation.Storer, orgs organization.Storer, passwords *password.Policy, sender *mail.Service, config *common.AuthConfig, captcha common.CaptchaVerifier, ps *pubsub.PubSub[string, common.Event]) *Handler {
 	return &Handler{
-		users:    users,
-		accounts: accounts,
-		sender:   sender,
-		config:   config,
-		captcha:  captcha,
+		users:       users,
+		accounts:    accounts,
+		identities:  identities,
+		roles:       roles,
+		invitations: invitations,
+		orgs:        orgs,
+		passwords:   passwords,
+		sender:      sender,
+		config:      config,
+		captcha:     captcha,
+		ps:          ps,
 	}
 }
 
@@ -59,29 +78,18 @@ func (h *Handler) Signup(g *gin.Context) {
 		return
 	}
 
-	if err := h.captcha.Verify(s.Captcha); err != nil {
+	if err := h.captcha.Verify(g.Request.Context(), s.Captcha); err != nil {
 		log.WithError(err).Error("Failed to verify captcha.")
 		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Captcha verification failed!"})
 		return
 	}
 
-	usr, err := user.NewUser(s.Email, s.Password, s.FirstName, s.LastName)
-	if err != nil {
-		log.WithError(err).Error("Could not create new user")
-		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Could not create user."})
-		return
-	}
-	usr.RoleID = role.RoleCustomerUser
-	if err = h.users.Store(usr); err != nil {
-		log.WithError(err).Error("Could not store user")
-		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{
-			Message: "User with this email already exist.",
-		})
+	usr, ok := h.createUser(g, s.Email, s.Password, s.FirstName, s.LastName, user.Registered)
+	if !ok {
 		return
 	}
 
-	err = h.confirmMail(usr)
-	if err != nil {
+	if err := h.confirmMail(usr); err != nil {
 		log.WithError(err).Error("Could not send e-mail confirmation")
 		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{
 			Message: "Could not create user.",
@@ -92,6 +100,50 @@ func (h *Handler) Signup(g *gin.Context) {
 	g.JSON(http.StatusCreated, usr.AsProfile())
 }
 
+// createUser creates a user with credentials, the default role and a personal organization, aborting the request on failure.
+func (h *Handler) createUser(g *gin.Context, email string, password string, firstName string, lastName string, status user.Status) (*user.User, bool) {
+	if err := h.passwords.Validate(uuid.Nil, password); err != nil {
+		abortWithPolicyError(g, err)
+		return nil, false
+	}
+
+	usr, err := user.NewUser(email, password, firstName, lastName)
+	if err != nil {
+		log.WithError(err).Error("Could not create new user")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Could not create user."})
+		return nil, false
+	}
+	defaultRole, err := h.roles.Default()
+	if err != nil {
+		log.WithError(err).Error("Could not load default role")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Could not create user."})
+		return nil, false
+	}
+	usr.RoleID = defaultRole.ID
+	usr.Status = status
+	if err = h.users.Store(usr); err != nil {
+		log.WithError(err).Error("Could not store user")
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{
+			Message: "User with this email already exist.",
+		})
+		return nil, false
+	}
+	if err = h.identities.Store(identity.NewCredentials(usr.ID, usr.Email)); err != nil {
+		log.WithError(err).Error("Could not store credentials of user")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Could not create user."})
+		return nil, false
+	}
+	if err = h.orgs.Store(organization.NewPersonal(usr.ID, usr.Email), usr.ID); err != nil {
+		log.WithError(err).Error("Could not store organization of user")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Could not create user."})
+		return nil, false
+	}
+	if err = h.passwords.Remember(usr.ID, usr.PassHash); err != nil {
+		log.WithError(err).Error("Could not store password history of user")
+	}
+	return usr, true
+}
+
 func (h *Handler) confirmMail(usr *user.User) error {
 	state := Account{
 		UserID:            usr.ID,
@@ -306,6 +358,13 @@ func (h *Handler) ResetPassword(g *gin.Context) {
 		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Expired token, please restart the recovery!"})
 		return
 	}
+
+	// The token is kept until the new password is accepted by the policy, so the user can try again.
+	if err = h.passwords.Validate(state.UserID, reset.Password); err != nil {
+		abortWithPolicyError(g, err)
+		return
+	}
+
 	state.RecoveryToken = ""
 	state.LastRecovery = time.Now()
 	if err = h.accounts.Update(state); err != nil {
@@ -332,6 +391,10 @@ func (h *Handler) ResetPassword(g *gin.Context) {
 		g.AbortWithStatusJSON(http.StatusInternalServerError, statusBadRequest)
 		return
 	}
+	if err = h.passwords.Remember(usr.ID, usr.PassHash); err != nil {
+		log.WithError(err).Error("Failed to store password history of user.")
+	}
+	h.alert(g, usr, common.PasswordReset)
 
 	g.JSON(http.StatusOK, common.StatusMessage{Message: "Password updated!"})
 }
@@ -367,6 +430,11 @@ func (h *Handler) ChangePassword(g *gin.Context) {
 		return
 	}
 
+	if err = h.passwords.Validate(usr.ID, chg.New); err != nil {
+		abortWithPolicyError(g, err)
+		return
+	}
+
 	if err = usr.SetPassword(chg.New); err != nil {
 		log.WithError(err).Error("Failed to set password for the user.")
 		g.AbortWithStatusJSON(http.StatusInternalServerError, statusBadRequest)
@@ -378,6 +446,30 @@ func (h *Handler) ChangePassword(g *gin.Context) {
 		g.AbortWithStatusJSON(http.StatusInternalServerError, statusBadRequest)
 		return
 	}
+	if err = h.passwords.Remember(usr.ID, usr.PassHash); err != nil {
+		log.WithError(err).Error("Failed to store password history of user.")
+	}
+	h.alert(g, usr, common.PasswordChanged)
 
 	g.JSON(http.StatusOK, common.StatusMessage{Message: "Password updated!"})
 }
+
+// alert publishes a security alert of a change of the account of the user, made by the request.
+func (h *Handler) alert(g *gin.Context, usr *user.User, alert string) {
+	h.ps.Pub(common.Event{
+		ID:   uuid.New(),
+		Type: alert,
+		Time: time.Now(),
+		Data: common.SecurityData{Email: usr.Email, IP: g.ClientIP(), Device: session.Device(g.Request.UserAgent())},
+		User: usr.ID,
+	}, common.HistoryTopic, common.NotificationTopic)
+}
+
+func abortWithPolicyError(g *gin.Context, err error) {
+	if _, ok := err.(common.Violations); ok {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+	log.WithError(err).Error("Failed to validate password.")
+	g.AbortWithStatusJSON(http.StatusInternalServerError, statusBadRequest)
+}
@@ -0,0 +1,162 @@
+package account
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inokone/go-micro-saas/internal/auth/invitation"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+// InvitationSignup is a method of `Handler`. Signs the invitee up for the application with username/password
+// credentials and adds the new user to the organization of the invitation. The email address is confirmed by the
+// invitation, no confirmation email is sent.
+// @Summary Invitation signup endpoint
+// @Schemes
+// @Description Signs the invitee up for the application and accepts the invitation
+// @Accept json
+// @Produce json
+// @Param data body account.InvitationSignup true "Invitation token and user data provided for the signup"
+// @Success 201 {object} user.Profile
+// @Failure 400 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/invitation/signup [post]
+func (h *Handler) InvitationSignup(g *gin.Context) {
+	var s InvitationSignup
+	if err := g.ShouldBindJSON(&s); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+
+	if err := h.captcha.Verify(g.Request.Context(), s.Captcha); err != nil {
+		log.WithError(err).Error("Failed to verify captcha.")
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Captcha verification failed!"})
+		return
+	}
+
+	inv, ok := h.invitation(g, s.Token)
+	if !ok {
+		return
+	}
+
+	usr, ok := h.createUser(g, inv.Email, s.Password, s.FirstName, s.LastName, user.Confirmed)
+	if !ok {
+		return
+	}
+
+	state := Account{
+		UserID:    usr.ID,
+		CreatedAt: time.Now(),
+		Confirmed: true,
+	}
+	if err := h.accounts.Store(&state); err != nil {
+		log.WithError(err).Error("Could not store account of user")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Could not create user."})
+		return
+	}
+
+	err := h.invitations.Accept(inv, usr.ID)
+	if errors.Is(err, invitation.ErrNotOpen) {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Invalid token!"})
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to accept invitation.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Could not accept invitation."})
+		return
+	}
+
+	g.JSON(http.StatusCreated, usr.AsProfile())
+}
+
+// AcceptInvitation is a method of `Handler`. Adds the current user to the organization of the invitation for the token
+// provided as URL parameter. The invitation must have been sent to the email address of the user.
+// @Summary Invitation accept endpoint
+// @Schemes
+// @Description Accepts the invitation to an organization with the current user
+// @Accept json
+// @Produce json
+// @Param   token    query     string  true  "Token of the invitation"  Format(uuid)
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 401 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/invitation/accept [put]
+func (h *Handler) AcceptInvitation(g *gin.Context) {
+	inv, ok := h.invitation(g, g.Query("token"))
+	if !ok {
+		return
+	}
+
+	u, _ := g.Get("user")
+	usr := u.(*user.User)
+	if !inv.For(usr.Email) {
+		g.AbortWithStatusJSON(http.StatusForbidden, common.StatusMessage{Message: "Invitation was sent to another email address!"})
+		return
+	}
+
+	err := h.invitations.Accept(inv, usr.ID)
+	if errors.Is(err, invitation.ErrNotOpen) {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Invalid token!"})
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to accept invitation.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Could not accept invitation."})
+		return
+	}
+
+	g.JSON(http.StatusOK, common.StatusMessage{
+		Message: "Invitation accepted!",
+	})
+}
+
+// DeclineInvitation is a method of `Handler`. Declines the invitation for the token provided as URL parameter.
+// @Summary Invitation decline endpoint
+// @Schemes
+// @Description Declines the invitation to an organization
+// @Accept json
+// @Produce json
+// @Param   token    query     string  true  "Token of the invitation"  Format(uuid)
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/invitation/decline [put]
+func (h *Handler) DeclineInvitation(g *gin.Context) {
+	inv, ok := h.invitation(g, g.Query("token"))
+	if !ok {
+		return
+	}
+
+	inv.Decline()
+	if err := h.invitations.Update(inv); err != nil {
+		log.WithError(err).Error("Failed to update invitation.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusBadRequest)
+		return
+	}
+
+	g.JSON(http.StatusOK, common.StatusMessage{
+		Message: "Invitation declined!",
+	})
+}
+
+// invitation loads the open invitation for the token provided, aborting the request for unknown, used and expired
+// tokens the same way as the email confirmation does.
+func (h *Handler) invitation(g *gin.Context, token string) (*invitation.Invitation, bool) {
+	inv, err := h.invitations.ByToken(token)
+	if err != nil || !inv.IsOpen() {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Invalid token!"})
+		return nil, false
+	}
+	if inv.Status() == invitation.Expired {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Expired token, ask for a new invitation please!"})
+		return nil, false
+	}
+	return inv, true
+}
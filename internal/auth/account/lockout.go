@@ -0,0 +1,128 @@
+package account
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+var statusUserNotFound = common.StatusMessage{Message: "User not found!"}
+
+// Unlock is a method of `Handler`. Lifts the sign in lock of an account with the token of the lockout e-mail, when the
+// failed attempts were not made by the owner. The owner is sent a link to change the password, as it may be known by
+// someone else.
+// @Summary Account unlock endpoint
+// @Schemes
+// @Description Clears the failed sign in attempts and the lock of the account of the token, sends a password reset email
+// @Accept json
+// @Produce json
+// @Param data body account.Unlock true "The token of the lockout email"
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/unlock [put]
+func (h *Handler) Unlock(g *gin.Context) {
+	var in Unlock
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+
+	acc, err := h.accounts.ByUnlockToken(in.Token)
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Invalid token!"})
+		return
+	}
+	if !acc.UnlockTTL.Valid || acc.UnlockTTL.Time.Before(time.Now()) {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Expired token!"})
+		return
+	}
+
+	acc.Unlock()
+	if err = h.accounts.Update(acc); err != nil {
+		log.WithError(err).Error("Failed to update account.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+		return
+	}
+
+	usr, err := h.users.ByID(acc.UserID)
+	if err == nil {
+		err = h.recoverMail(usr)
+	}
+	if err != nil {
+		log.WithError(err).Error("Could not send recovery e-mail")
+	}
+
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Account unlocked! We have sent you a link to change your password."})
+}
+
+// Lockout is a method of `Handler`. Returns the failed sign in attempts and the lock of the account of a user.
+// @Summary Account lockout endpoint
+// @Schemes
+// @Description Returns the failed sign in attempts and the lock of the account of the target user
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the user"
+// @Success 200 {object} account.Lockout
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /users/:id/lockout [get]
+func (h *Handler) Lockout(g *gin.Context) {
+	acc, ok := h.accountOf(g)
+	if !ok {
+		return
+	}
+	g.JSON(http.StatusOK, acc.AsLockout())
+}
+
+// ClearLockout is a method of `Handler`. Clears the failed sign in attempts and the lock of the account of a user.
+// @Summary Account lockout clear endpoint
+// @Schemes
+// @Description Clears the failed sign in attempts and the lock of the account of the target user
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the user"
+// @Success 200 {object} account.Lockout
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /users/:id/lockout [delete]
+func (h *Handler) ClearLockout(g *gin.Context) {
+	acc, ok := h.accountOf(g)
+	if !ok {
+		return
+	}
+	acc.Unlock()
+	if err := h.accounts.Update(acc); err != nil {
+		log.WithError(err).Error("Failed to update account.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+		return
+	}
+	g.JSON(http.StatusOK, acc.AsLockout())
+}
+
+// accountOf returns the account of the user in the path, aborting the request if it is not found.
+func (h *Handler) accountOf(g *gin.Context) (*Account, bool) {
+	id, err := uuid.Parse(g.Param("id"))
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, statusUserNotFound)
+		return nil, false
+	}
+	acc, err := h.accounts.ByUser(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		g.AbortWithStatusJSON(http.StatusNotFound, statusUserNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to collect account.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+		return nil, false
+	}
+	return acc, true
+}
@@ -1,6 +1,8 @@
 package account
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,10 +21,86 @@ type Account struct {
 	RecoveryToken      string    `db:"recovery_token"`
 	RecoveryTTL        time.Time `db:"recovery_ttl"`
 	LastRecovery       time.Time `db:"last_recovery"`
+	UnlockToken        string    `db:"unlock_token"`
+	UnlockTTL          null.Time `db:"unlock_ttl"`
 	CreatedAt          time.Time `db:"created_at"`
 	DeletedAt          null.Time `db:"deleted_at"`
 }
 
+// Locked is a method of `Account` telling whether sign in is locked for failed attempts at the time in parameter.
+func (a *Account) Locked(now time.Time) bool {
+	return !a.FailedLoginLock.IsZero() && a.FailedLoginLock.After(now)
+}
+
+// Unlock is a method of `Account` clearing the failed sign in attempts, the lock and the unlock token.
+func (a *Account) Unlock() {
+	a.FailedLoginCounter = 0
+	a.FailedLoginLock = time.Time{} // zero time
+	a.UnlockToken = ""
+	a.UnlockTTL = null.Time{}
+}
+
+// AsLockout is a method of `Account` converting the failed sign in attempts of the account to a `Lockout` view.
+func (a *Account) AsLockout() Lockout {
+	l := Lockout{
+		UserID:       a.UserID.String(),
+		FailedLogins: a.FailedLoginCounter,
+		Locked:       a.Locked(time.Now()),
+	}
+	if !a.LastFailedLogin.IsZero() {
+		l.LastFailedLogin = null.TimeFrom(a.LastFailedLogin)
+	}
+	if l.Locked {
+		l.LockedUntil = null.TimeFrom(a.FailedLoginLock)
+	}
+	return l
+}
+
+// Lockout is the REST view of the failed sign in attempts of an account, for the administrators.
+type Lockout struct {
+	UserID          string    `json:"user_id"`
+	FailedLogins    int       `json:"failed_logins"`
+	LastFailedLogin null.Time `json:"last_failed_login"`
+	Locked          bool      `json:"locked"`
+	LockedUntil     null.Time `json:"locked_until"`
+}
+
+// Backoff is the schedule of the sign in locks of an account, the duration of the lock after each failed attempt in a
+// row. The last duration applies to all further attempts.
+type Backoff []time.Duration
+
+// ParseBackoff is a function parsing the comma separated durations of a backoff schedule, e.g. `0s,0s,10s,1m,1h`.
+// The durations are Go durations, zero for the attempts without a lock.
+func ParseBackoff(spec string) (Backoff, error) {
+	var b Backoff
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid duration of lockout backoff %v", part)
+		}
+		b = append(b, d)
+	}
+	return b, nil
+}
+
+// Lock is a method of `Backoff` returning the duration of the lock after the failed attempt in parameter, counted
+// from one. An empty schedule never locks.
+func (b Backoff) Lock(failures int) time.Duration {
+	if failures < 1 || len(b) == 0 {
+		return 0
+	}
+	return b[min(failures, len(b))-1]
+}
+
+// Unlock is a struct for the message body of REST endpoint unlocking an account with the token of a lockout e-mail
+type Unlock struct {
+	Token string `json:"token" binding:"required,uuid"`
+}
+
 // ConfirmationResend is a struct for the message body of REST endpoint e-mail confirmation resend
 type ConfirmationResend struct {
 	Email string `json:"email" binding:"required,email"`
@@ -45,6 +123,15 @@ type PasswordChange struct {
 	Old string `json:"old" binding:"required"`
 }
 
+// InvitationSignup is a struct for the message body of REST endpoint signup with an invitation to an organization
+type InvitationSignup struct {
+	Token     string `json:"token" binding:"required,uuid"`
+	FirstName string `json:"first_name" binding:"max=255"`
+	LastName  string `json:"last_name" binding:"max=255"`
+	Password  string `json:"password" binding:"required"`
+	Captcha   string `json:"captcha_token" binding:"required"`
+}
+
 // Storer is the interface for `Account` persistence
 type Storer interface {
 	Store(account *Account) error
@@ -52,4 +139,5 @@ type Storer interface {
 	ByUser(userID uuid.UUID) (*Account, error)
 	ByConfirmToken(token string) (*Account, error)
 	ByRecoveryToken(token string) (*Account, error)
+	ByUnlockToken(token string) (*Account, error)
 }
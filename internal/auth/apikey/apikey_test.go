@@ -0,0 +1,87 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockStorer is a mock implementation of the Storer interface
+type MockStorer struct {
+	mock.Mock
+}
+
+func (m *MockStorer) Store(key *Key) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+func (m *MockStorer) ByID(id uuid.UUID) (*Key, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Key), args.Error(1)
+}
+
+func (m *MockStorer) ByUser(userID uuid.UUID) ([]Key, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Key), args.Error(1)
+}
+
+func (m *MockStorer) Touch(id uuid.UUID, lastUsed time.Time) error {
+	args := m.Called(id, lastUsed)
+	return args.Error(0)
+}
+
+func (m *MockStorer) Delete(userID uuid.UUID, id uuid.UUID) (bool, error) {
+	args := m.Called(userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestNewKeyTokenMatchesOnlyItself(t *testing.T) {
+	key, token, err := NewKey(uuid.New(), "CI", []string{ScopeUsers}, null.Time{})
+	assert.NoError(t, err)
+	_, other, err := NewKey(uuid.New(), "CI", []string{ScopeUsers}, null.Time{})
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(token, tokenPrefix))
+	assert.NotContains(t, key.Hash, token)
+	assert.True(t, key.Matches(token))
+	assert.False(t, key.Matches(other))
+}
+
+func TestParseTokenReturnsKeyID(t *testing.T) {
+	key, token, err := NewKey(uuid.New(), "CI", []string{ScopeUsers}, null.Time{})
+	assert.NoError(t, err)
+
+	id, err := ParseToken(token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, key.ID, id)
+
+	for _, malformed := range []string{"", key.ID.String(), tokenPrefix + key.ID.String(), "msk_invalid.secret"} {
+		_, err = ParseToken(malformed)
+		assert.Error(t, err)
+	}
+}
+
+func TestKeyIsActiveAndAllows(t *testing.T) {
+	key := &Key{Scopes: []string{ScopeAccount}}
+
+	assert.True(t, key.IsActive())
+	assert.True(t, key.Allows(ScopeAccount))
+	assert.False(t, key.Allows(ScopeRoles))
+	assert.False(t, key.Allows(""))
+
+	key.ExpiresAt = null.TimeFrom(time.Now().Add(-time.Second))
+	assert.False(t, key.IsActive())
+}
@@ -0,0 +1,135 @@
+package apikey
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+var statusUnknownError = common.StatusMessage{Message: "Unknown error, please contact administrator!"}
+
+// Handler is a struct for web handles related to the personal API keys of the users.
+type Handler struct {
+	keys Storer
+}
+
+// NewHandler creates a new `Handler`, based on the API key persistence.
+func NewHandler(keys Storer) *Handler {
+	return &Handler{
+		keys: keys,
+	}
+}
+
+// List is a method of `Handler`. Lists the API keys of the current user.
+// @Summary API key list endpoint
+// @Schemes
+// @Description Returns the API keys of the current user, without their secrets
+// @Accept json
+// @Produce json
+// @Success 200 {array} apikey.View
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/api-keys [get]
+func (h *Handler) List(g *gin.Context) {
+	usr := currentUser(g)
+
+	keys, err := h.keys.ByUser(usr.ID)
+	if err != nil {
+		log.WithError(err).Error("Failed to list API keys.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+
+	res := make([]View, len(keys))
+	for i, k := range keys {
+		res[i] = k.AsView()
+	}
+	g.JSON(http.StatusOK, res)
+}
+
+// Create is a method of `Handler`. Creates a new API key for the current user.
+// @Summary API key create endpoint
+// @Schemes
+// @Description Creates a new API key with the scopes and expiry provided. The key is returned only once, it can not be retrieved later.
+// @Accept json
+// @Produce json
+// @Param data body apikey.Creation true "Name, scopes and expiry of the key"
+// @Success 201 {object} apikey.Created
+// @Failure 400 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/api-keys [post]
+func (h *Handler) Create(g *gin.Context) {
+	var in Creation
+	usr := currentUser(g)
+
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+
+	var expiresAt null.Time
+	if in.Expires > 0 {
+		expiresAt = null.TimeFrom(time.Unix(in.Expires, 0))
+		if expiresAt.Time.Before(time.Now()) {
+			g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Expiry must be in the future!"})
+			return
+		}
+	}
+
+	key, token, err := NewKey(usr.ID, in.Name, in.Scopes, expiresAt)
+	if err != nil {
+		log.WithError(err).Error("Failed to generate API key.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	if err = h.keys.Store(key); err != nil {
+		log.WithError(err).Error("Failed to store API key.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	g.JSON(http.StatusCreated, Created{View: key.AsView(), Key: token})
+}
+
+// Delete is a method of `Handler`. Revokes an API key of the current user.
+// @Summary API key delete endpoint
+// @Schemes
+// @Description Deletes an API key of the current user, it can not be used anymore
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the API key"
+// @Success 200 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/api-keys/{id} [delete]
+func (h *Handler) Delete(g *gin.Context) {
+	usr := currentUser(g)
+
+	id, err := uuid.Parse(g.Param("id"))
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "API key not found!"})
+		return
+	}
+
+	deleted, err := h.keys.Delete(usr.ID, id)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete API key.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	if !deleted {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "API key not found!"})
+		return
+	}
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "API key revoked!"})
+}
+
+func currentUser(g *gin.Context) *user.User {
+	u, _ := g.Get("user")
+	return u.(*user.User)
+}
@@ -0,0 +1,104 @@
+package apikey
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+)
+
+var testUser = &user.User{
+	ID:      uuid.New(),
+	Email:   "test@example.com",
+	Status:  user.Confirmed,
+	Source:  "credentials",
+	Enabled: true,
+}
+
+func setupTestRouter(h *Handler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	return r
+}
+
+func withUser(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user", testUser)
+		handler(c)
+	}
+}
+
+func TestCreate201ForHappyPath(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	expires := time.Now().Add(24 * time.Hour).Unix()
+	mockStorer.On("Store", mock.MatchedBy(func(k *Key) bool {
+		return k.UserID == testUser.ID && k.Name == "CI" && k.ExpiresAt.Time.Unix() == expires && k.Hash != ""
+	})).Return(nil)
+
+	router.POST("/api-keys", withUser(handler.Create))
+
+	body, _ := json.Marshal(Creation{Name: "CI", Scopes: []string{ScopeUsers}, Expires: expires})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api-keys", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response Created
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response.Key)
+	assert.Equal(t, []string{ScopeUsers}, response.Scopes)
+	assert.Equal(t, int(expires), response.Expires)
+
+	stored := mockStorer.Calls[0].Arguments.Get(0).(*Key)
+	assert.True(t, stored.Matches(response.Key))
+}
+
+func TestCreate400ForUnknownScope(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	router.POST("/api-keys", withUser(handler.Create))
+
+	body, _ := json.Marshal(Creation{Name: "CI", Scopes: []string{"billing"}})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api-keys", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockStorer.AssertNotCalled(t, "Store", mock.Anything)
+}
+
+func TestDelete200ForHappyPath(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	id := uuid.New()
+	mockStorer.On("Delete", testUser.ID, id).Return(true, nil)
+
+	router.DELETE("/api-keys/:id", withUser(handler.Delete))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/api-keys/"+id.String(), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStorer.AssertExpectations(t)
+}
@@ -0,0 +1,145 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+	"github.com/lib/pq"
+)
+
+// tokenPrefix marks the API keys of the application, so they are easy to recognize, e.g. by secret scanners.
+const tokenPrefix = "msk_"
+
+const (
+	// ScopeAccount grants access to the profile of the owner of the key.
+	ScopeAccount = "account"
+	// ScopeUsers grants access to the user management endpoints.
+	ScopeUsers = "users"
+	// ScopeRoles grants access to the role management endpoints.
+	ScopeRoles = "roles"
+)
+
+// Key is a personal API key of a user for database storage. Only the hash of the key is kept.
+type Key struct {
+	ID         uuid.UUID      `db:"key_id"`
+	UserID     uuid.UUID      `db:"user_id"`
+	Name       string         `db:"name"`
+	Hash       string         `db:"key_hash"`
+	Scopes     pq.StringArray `db:"scopes"`
+	CreatedAt  time.Time      `db:"created_at"`
+	ExpiresAt  null.Time      `db:"expires_at"`
+	LastUsedAt null.Time      `db:"last_used_at"`
+}
+
+// NewKey is a function to create a new `Key` for a user, returning the key with its secret token. The token is shown
+// to the user only once.
+func NewKey(userID uuid.UUID, name string, scopes []string, expiresAt null.Time) (*Key, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	k := &Key{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+	token := tokenPrefix + k.ID.String() + "." + base64.RawURLEncoding.EncodeToString(secret)
+	k.Hash = Hash(token)
+	return k, token, nil
+}
+
+// IsActive is a method of `Key` returning whether the key is not expired.
+func (k *Key) IsActive() bool {
+	return !k.ExpiresAt.Valid || k.ExpiresAt.Time.After(time.Now())
+}
+
+// Matches is a method of `Key` returning whether the token provided is the secret of the key.
+func (k *Key) Matches(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(k.Hash), []byte(Hash(token))) == 1
+}
+
+// Allows is a method of `Key` returning whether the key grants the scope provided.
+func (k *Key) Allows(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// Hash is a function returning the hex encoded SHA-256 hash of an API key for storage.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseToken is a function extracting the key ID from an API key.
+func ParseToken(token string) (uuid.UUID, error) {
+	rest, found := strings.CutPrefix(token, tokenPrefix)
+	if !found {
+		return uuid.Nil, errors.New("malformed API key")
+	}
+	id, _, found := strings.Cut(rest, ".")
+	if !found {
+		return uuid.Nil, errors.New("malformed API key")
+	}
+	return uuid.Parse(id)
+}
+
+// AsView is a method of the `Key` struct. It converts a `Key` object into a `View` object.
+func (k *Key) AsView() View {
+	v := View{
+		ID:      k.ID.String(),
+		Name:    k.Name,
+		Scopes:  k.Scopes,
+		Created: int(k.CreatedAt.Unix()),
+	}
+	if k.ExpiresAt.Valid {
+		v.Expires = int(k.ExpiresAt.Time.Unix())
+	}
+	if k.LastUsedAt.Valid {
+		v.LastUsed = int(k.LastUsedAt.Time.Unix())
+	}
+	return v
+}
+
+// View is the JSON representation of an API key, without the secret.
+type View struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Scopes   []string `json:"scopes"`
+	Created  int      `json:"created"`
+	Expires  int      `json:"expires"`
+	LastUsed int      `json:"last_used"`
+}
+
+// Creation is a struct for the message body of creating an API key. The expiry is a Unix timestamp, zero for a key
+// which does not expire.
+type Creation struct {
+	Name    string   `json:"name" binding:"required,max=100"`
+	Scopes  []string `json:"scopes" binding:"required,min=1,dive,oneof=account users roles"`
+	Expires int64    `json:"expires" binding:"min=0"`
+}
+
+// Created is the JSON response of creating an API key, the only time the secret is shown.
+type Created struct {
+	View
+	Key string `json:"key"`
+}
+
+// Storer is the interface for `Key` persistence
+type Storer interface {
+	Store(key *Key) error
+	ByID(id uuid.UUID) (*Key, error)
+	ByUser(userID uuid.UUID) ([]Key, error)
+	Touch(id uuid.UUID, lastUsed time.Time) error
+	Delete(userID uuid.UUID, id uuid.UUID) (bool, error)
+}
@@ -0,0 +1,83 @@
+package apikey
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresStorer is the `Storer` implementation based on sqlx library.
+type PostgresStorer struct {
+	db *sqlx.DB
+}
+
+// NewPostgresStorer creates a new `PostgresStorer` instance based on the sqlx library.
+func NewPostgresStorer(db *sqlx.DB) *PostgresStorer {
+	return &PostgresStorer{
+		db: db,
+	}
+}
+
+// Store is a method of the `PostgresStorer` struct. Takes a `Key` as parameter and persists it.
+func (s *PostgresStorer) Store(key *Key) error {
+	query := `INSERT INTO microsaas.api_keys (key_id, user_id, name, key_hash, scopes, created_at, expires_at, last_used_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := s.db.Exec(
+		query,
+		key.ID,
+		key.UserID,
+		key.Name,
+		key.Hash,
+		key.Scopes,
+		key.CreatedAt,
+		key.ExpiresAt,
+		key.LastUsedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store API key: %w", err)
+	}
+	return nil
+}
+
+// ByID is a method of the `PostgresStorer` struct. Takes a key ID as parameter to load a `Key` object from persistence.
+func (s *PostgresStorer) ByID(id uuid.UUID) (*Key, error) {
+	var key Key
+	query := `SELECT key_id, user_id, name, key_hash, scopes, created_at, expires_at, last_used_at FROM microsaas.api_keys WHERE key_id = $1`
+	if err := s.db.Get(&key, query, id); err != nil {
+		return nil, fmt.Errorf("failed to get API key by ID: %w", err)
+	}
+	return &key, nil
+}
+
+// ByUser is a method of the `PostgresStorer` struct. Takes a user ID as parameter to load all API keys of the user.
+func (s *PostgresStorer) ByUser(userID uuid.UUID) ([]Key, error) {
+	var keys []Key
+	query := `SELECT key_id, user_id, name, key_hash, scopes, created_at, expires_at, last_used_at FROM microsaas.api_keys WHERE user_id = $1 ORDER BY created_at`
+	if err := s.db.Select(&keys, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Touch is a method of the `PostgresStorer` struct. Takes a key ID and a time as parameter and records the last usage of the key.
+func (s *PostgresStorer) Touch(id uuid.UUID, lastUsed time.Time) error {
+	query := `UPDATE microsaas.api_keys SET last_used_at = $1 WHERE key_id = $2`
+	if _, err := s.db.Exec(query, lastUsed, id); err != nil {
+		return fmt.Errorf("failed to touch API key: %w", err)
+	}
+	return nil
+}
+
+// Delete is a method of the `PostgresStorer` struct. Takes a user ID and a key ID as parameter and deletes the key if it
+// belongs to the user. Returns false if there was no such key.
+func (s *PostgresStorer) Delete(userID uuid.UUID, id uuid.UUID) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM microsaas.api_keys WHERE user_id = $1 AND key_id = $2`, userID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete API key: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete API key: %w", err)
+	}
+	return affected == 1, nil
+}
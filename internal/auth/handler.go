@@ -1,54 +1,86 @@
 package auth
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/inokone/go-micro-saas/internal/auth/account"
+	"github.com/inokone/go-micro-saas/internal/auth/identity"
+	"github.com/inokone/go-micro-saas/internal/auth/magiclink"
+	"github.com/inokone/go-micro-saas/internal/auth/passkey"
+	"github.com/inokone/go-micro-saas/internal/auth/twofactor"
 	"github.com/inokone/go-micro-saas/internal/auth/user"
 	"github.com/inokone/go-micro-saas/internal/common"
+	"github.com/inokone/go-micro-saas/internal/mail"
+	"github.com/inokone/go-micro-saas/internal/ratelimit"
 )
 
 const (
-	jwtTokenKey string = "Authorization"
+	jwtTokenKey       string = "Authorization"
+	refreshTokenKey   string = "Refresh"
+	refreshCookiePath string = "/api/v1/auth"
 )
 
 var (
 	statusInvalidCredentials = common.StatusMessage{Message: "User does not exist or password does not match!"}
 	statusBadRequest         = common.StatusMessage{Message: "Invalid user data provided!"}
+	statusInvalidLink        = common.StatusMessage{Message: "Sign in link is invalid or expired!"}
+	statusDeactivated        = common.StatusMessage{Message: "Your account has been deactivated. Please contact our administrators!"}
 )
 
 // Handler is a struct for web handles related to authentication and authorization.
 type Handler struct {
-	users   user.Storer
-	auths   account.Storer
-	jwt     *JWTHandler
-	captcha *common.RecaptchaValidator
-	service *Service
+	users      user.Storer
+	auths      account.Storer
+	identities identity.Storer
+	links      magiclink.Storer
+	passkeys   *passkey.Service
+	jwt        *JWTHandler
+	sender     *mail.Service
+	config     *common.AuthConfig
+	captcha    common.CaptchaVerifier
+	service    *Service
 }
 
-// NewHandler creates a new `Handler`, based on the user persistence.
-func NewHandler(users user.Storer, auths account.Storer, jwt *JWTHandler, captcha *common.RecaptchaValidator) *Handler {
+// NewHandler creates a new `Handler`, based on the user, account, two-factor, identity and sign in link persistence,
+// the passkey service and the mail service. Failed sign in attempts are limited by the limiter and the backoff schedule.
+func NewHandler(users user.Storer, auths account.Storer, factors twofactor.Storer, identities identity.Storer, links magiclink.Storer, passkeys *passkey.Service, jwt *JWTHandler, sender *mail.Service, config *common.AuthConfig, captcha common.CaptchaVerifier, limiter *ratelimit.Limiter, backoff account.Backoff) *Handler {
 	return &Handler{
-		users:   users,
-		auths:   auths,
-		jwt:     jwt,
-		captcha: captcha,
-		service: NewService(users, auths, jwt),
+		users:      users,
+		auths:      auths,
+		identities: identities,
+		links:      links,
+		passkeys:   passkeys,
+		jwt:        jwt,
+		sender:     sender,
+		config:     config,
+		captcha:    captcha,
+		service:    NewService(users, auths, factors, passkeys, jwt, limiter, backoff, sender, config),
 	}
 }
 
+// Lockout returns the lockout of the failed sign in attempts of the handler, for the codes verified outside of sign in.
+func (h *Handler) Lockout() *Service {
+	return h.service
+}
+
 // Signin is a method of `Handler`. Authenticates the user to the application, sets a JWT token on success in the cookies.
+// When the user has two-factor authentication enabled, a challenge token is returned instead, to be used with `SigninSecondFactor`.
 // @Summary User sign in endpoint
 // @Schemes
-// @Description Logs in the user, sets up the JWT authorization
+// @Description Logs in the user, sets up the JWT authorization or returns a challenge token if a second factor is required
 // @Accept json
 // @Produce json
 // @Param data body user.Credentials true "Credentials provided for signing in"
 // @Success 200 {object} common.StatusMessage
+// @Success 202 {object} twofactor.Challenge
 // @Failure 400 {object} common.StatusMessage
 // @Failure 403 {object} common.StatusMessage
 // @Failure 500 {object} common.StatusMessage
@@ -66,14 +98,24 @@ func (h *Handler) Signin(g *gin.Context) {
 		return
 	}
 
-	if err := h.captcha.Verify(s.Captcha); err != nil {
+	if err := h.captcha.Verify(g.Request.Context(), s.Captcha); err != nil {
 		log.WithError(err).Error("Failed to verify captcha.")
 		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Captcha verification failed!"})
 		return
 	}
 
+	ip := g.ClientIP()
+	if secs := h.service.addressTimeout(ip); secs > 0 {
+		abortWithAuthError(g, LockedUser{seconds: secs})
+		return
+	}
+
 	usr, err = h.users.ByEmail(s.Email)
 	if err != nil {
+		// Attempts for unknown addresses are counted too, so passwords can not be sprayed across guessed accounts.
+		if errors.Is(err, sql.ErrNoRows) {
+			h.service.limiter.Fail(ip)
+		}
 		log.WithError(err).Error("Failed to collect user.")
 		g.AbortWithStatusJSON(http.StatusBadRequest, statusInvalidCredentials)
 		return
@@ -84,41 +126,398 @@ func (h *Handler) Signin(g *gin.Context) {
 		return
 	}
 
-	if usr.Source != "credentials" {
+	creds, err := h.identities.ByProvider(usr.ID, identity.Credentials)
+	if errors.Is(err, sql.ErrNoRows) {
 		g.AbortWithStatusJSON(http.StatusUnauthorized, common.StatusMessage{Message: "Your account can not be used with credentials!"})
 		return
 	}
+	if err != nil {
+		log.WithError(err).Error("Failed to collect credentials of user.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+		return
+	}
+
+	if err = h.service.ValidateCredentials(usr, s.Password, ip); err != nil {
+		abortWithAuthError(g, err)
+		return
+	}
+	if err = h.identities.Touch(creds.ID, time.Now()); err != nil {
+		log.WithError(err).Warn("Failed to record credentials usage.")
+	}
+
+	h.finishSignin(g, usr)
+}
+
+// SigninSecondFactor is a method of `Handler`. Finishes the sign in of a user with two-factor authentication enabled,
+// sets a JWT token on success in the cookies.
+// @Summary User sign in second factor endpoint
+// @Schemes
+// @Description Verifies the TOTP or recovery code for the challenge token of the sign in, sets up the JWT authorization
+// @Accept json
+// @Produce json
+// @Param data body twofactor.ChallengeResponse true "Challenge token of the sign in and the TOTP or recovery code"
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 401 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /auth/signin/2fa [post]
+func (h *Handler) SigninSecondFactor(g *gin.Context) {
+	var in twofactor.ChallengeResponse
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, statusBadRequest)
+		return
+	}
+
+	userID, err := h.jwt.ParseChallenge(in.Token)
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, common.StatusMessage{Message: "Sign in expired, please start again!"})
+		return
+	}
+
+	usr, err := h.users.ByID(userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to collect user.")
+		g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
+		return
+	}
+
+	if !usr.Enabled {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, common.StatusMessage{Message: "Your account has been deactivated. Please contact our administrators!"})
+		return
+	}
+
+	if err = h.service.ValidateSecondFactor(usr, in.Code, g.ClientIP()); err != nil {
+		abortWithAuthError(g, err)
+		return
+	}
+
+	if err = h.jwt.Issue(g, usr.ID.String()); err != nil {
+		return
+	}
+
+	g.JSON(http.StatusOK, common.StatusMessage{
+		Message: "Logged in!",
+	})
+}
+
+// PasskeyBegin is a method of `Handler`. Starts a sign in with a passkey, either passwordless or as the second factor
+// of a sign in with a challenge token.
+// @Summary Passkey sign in start endpoint
+// @Schemes
+// @Description Returns the options of a WebAuthn assertion to be signed by the browser. Without a challenge token any discoverable passkey can be used to sign in.
+// @Accept json
+// @Produce json
+// @Param data body passkey.Begin false "Challenge token of the sign in, when the passkey is used as a second factor"
+// @Success 200 {object} passkey.Options
+// @Failure 400 {object} common.StatusMessage
+// @Failure 401 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /auth/passkey [post]
+func (h *Handler) PasskeyBegin(g *gin.Context) {
+	var (
+		in  passkey.Begin
+		usr *user.User
+	)
+	if err := g.ShouldBindJSON(&in); err != nil && !errors.Is(err, io.EOF) {
+		g.AbortWithStatusJSON(http.StatusBadRequest, statusBadRequest)
+		return
+	}
+
+	if in.Token != "" {
+		userID, err := h.jwt.ParseChallenge(in.Token)
+		if err != nil {
+			g.AbortWithStatusJSON(http.StatusUnauthorized, common.StatusMessage{Message: "Sign in expired, please start again!"})
+			return
+		}
+		if usr, err = h.users.ByID(userID); err != nil {
+			log.WithError(err).Error("Failed to collect user.")
+			g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
+			return
+		}
+	}
+
+	options, err := h.passkeys.BeginLogin(usr)
+	if errors.Is(err, passkey.ErrNoPasskey) {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "No passkey registered!"})
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to begin passkey sign in.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error"})
+		return
+	}
+	g.JSON(http.StatusOK, options)
+}
+
+// PasskeySignin is a method of `Handler`. Finishes a sign in with a passkey, sets a JWT token on success in the cookies.
+// @Summary Passkey sign in endpoint
+// @Schemes
+// @Description Verifies the WebAuthn assertion signed by the browser, sets up the JWT authorization
+// @Accept json
+// @Produce json
+// @Param data body passkey.Assertion true "Ceremony and the assertion signed by the browser"
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 401 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /auth/passkey/verify [post]
+func (h *Handler) PasskeySignin(g *gin.Context) {
+	var in passkey.Assertion
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, statusBadRequest)
+		return
+	}
 
-	if err = h.service.ValidateCredentials(usr, s.Password); err != nil {
-		switch e := err.(type) {
-		case InvalidCredentials:
-			g.AbortWithStatusJSON(http.StatusBadRequest, statusInvalidCredentials)
-		case LockedUser:
-			g.AbortWithStatusJSON(http.StatusForbidden, common.StatusMessage{
-				Message: fmt.Sprintf("You have been locked out for failed credentials. You have to wait %v more seconds.", e.seconds),
-			})
-		default:
+	attempt, err := h.passkeys.Identify(in)
+	if errors.Is(err, passkey.ErrInvalidCeremony) {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, common.StatusMessage{Message: "Sign in expired, please start again!"})
+		return
+	}
+	if errors.Is(err, passkey.ErrInvalidCredential) {
+		log.WithError(err).Debug("Failed to identify passkey.")
+		g.AbortWithStatusJSON(http.StatusBadRequest, statusInvalidCredentials)
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to finish passkey sign in.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error"})
+		return
+	}
+
+	if !attempt.User.Enabled {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, common.StatusMessage{Message: "Your account has been deactivated. Please contact our administrators!"})
+		return
+	}
+
+	if err = h.service.ValidatePasskey(attempt, g.ClientIP()); err != nil {
+		abortWithAuthError(g, err)
+		return
+	}
+
+	if err = h.jwt.Issue(g, attempt.User.ID.String()); err != nil {
+		return
+	}
+
+	g.JSON(http.StatusOK, common.StatusMessage{
+		Message: "Logged in!",
+	})
+}
+
+// MagicLinkRequest is a method of `Handler`. Sends a single-use sign in link to the email address of a user. The
+// response is the same whether the address is registered or not, so it can not be used to look up accounts.
+// @Summary Sign in link request endpoint
+// @Schemes
+// @Description Sends a single-use, short-lived sign in link to the email address provided, if it belongs to an active account
+// @Accept json
+// @Produce json
+// @Param data body magiclink.Request true "Email address to send the sign in link to"
+// @Success 202 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Router /auth/magic-link [post]
+func (h *Handler) MagicLinkRequest(g *gin.Context) {
+	if !h.config.MagicLinkEnabled {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "Sign in with email link is not enabled!"})
+		return
+	}
+
+	var in magiclink.Request
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, statusBadRequest)
+		return
+	}
+
+	if err := h.captcha.Verify(g.Request.Context(), in.Captcha); err != nil {
+		log.WithError(err).Error("Failed to verify captcha.")
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Captcha verification failed!"})
+		return
+	}
+
+	if err := h.sendMagicLink(in.Email, g.ClientIP()); err != nil {
+		log.WithError(err).Error("Failed to send sign in link.")
+	}
+
+	g.JSON(http.StatusAccepted, common.StatusMessage{Message: "If the address is registered, a sign in link is on its way!"})
+}
+
+func (h *Handler) sendMagicLink(email string, ip string) error {
+	usr, err := h.users.ByEmail(email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	if !usr.Enabled || usr.Status != user.Confirmed {
+		return nil
+	}
+	if secs, err := h.service.checkTimeout(usr, ip); err != nil || secs > 0 {
+		return err
+	}
+
+	// Only the last link sent can be used.
+	if err = h.links.DeleteForUser(usr.ID); err != nil {
+		return err
+	}
+	link, token, err := magiclink.NewLink(usr.ID, time.Duration(h.config.MagicLinkTTL)*time.Minute)
+	if err != nil {
+		return err
+	}
+	if err = h.links.Store(link); err != nil {
+		return err
+	}
+	return h.sender.MagicLink(usr.Email, h.config.FrontendRoot+"/signin/magic-link?token="+token)
+}
+
+// MagicLinkSignin is a method of `Handler`. Signs in the user with the token of a sign in link, sets a JWT token on
+// success in the cookies. When the user has two-factor authentication enabled, a challenge token is returned instead.
+// @Summary Sign in link endpoint
+// @Schemes
+// @Description Verifies the token of a sign in link, sets up the JWT authorization or returns a challenge token if a second factor is required
+// @Accept json
+// @Produce json
+// @Param data body magiclink.Signin true "Token of the sign in link"
+// @Success 200 {object} common.StatusMessage
+// @Success 202 {object} twofactor.Challenge
+// @Failure 400 {object} common.StatusMessage
+// @Failure 401 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /auth/magic-link/verify [post]
+func (h *Handler) MagicLinkSignin(g *gin.Context) {
+	if !h.config.MagicLinkEnabled {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "Sign in with email link is not enabled!"})
+		return
+	}
+
+	var in magiclink.Signin
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, statusBadRequest)
+		return
+	}
+
+	id, err := magiclink.ParseToken(in.Token)
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, statusInvalidLink)
+		return
+	}
+	link, err := h.links.Take(id)
+	if err != nil {
+		log.WithError(err).Debug("Failed to collect sign in link.")
+		g.AbortWithStatusJSON(http.StatusUnauthorized, statusInvalidLink)
+		return
+	}
+
+	usr, err := h.users.ByID(link.UserID)
+	if err != nil {
+		log.WithError(err).Error("Failed to collect user.")
+		g.AbortWithStatusJSON(http.StatusUnauthorized, statusInvalidLink)
+		return
+	}
+
+	if !usr.Enabled || usr.Status != user.Confirmed {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, statusDeactivated)
+		return
+	}
+
+	if err = h.service.ValidateMagicLink(usr, link, in.Token, g.ClientIP()); err != nil {
+		if _, ok := err.(InvalidCredentials); ok {
+			g.AbortWithStatusJSON(http.StatusUnauthorized, statusInvalidLink)
+			return
+		}
+		if _, ok := err.(LockedUser); ok {
+			h.restoreLink(link)
+		}
+		abortWithAuthError(g, err)
+		return
+	}
+
+	h.finishSignin(g, usr)
+}
+
+// restoreLink stores a sign in link taken again, when the sign in is refused for a temporary lock of the account and not
+// for the link, so the link can still be used once the account is unlocked.
+func (h *Handler) restoreLink(link *magiclink.Link) {
+	if err := h.links.Store(link); err != nil {
+		log.WithError(err).WithField("UserID", link.UserID.String()).Error("Failed to restore sign in link.")
+	}
+}
+
+// finishSignin issues the session of a user who passed the first factor, or a challenge if a second factor is required.
+func (h *Handler) finishSignin(g *gin.Context, usr *user.User) {
+	methods, err := h.service.SecondFactors(usr)
+	if err != nil {
+		log.WithError(err).Error("Failed to collect two-factor settings.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error"})
+		return
+	}
+
+	if len(methods) > 0 {
+		token, err := h.jwt.IssueChallenge(usr.ID)
+		if err != nil {
+			log.WithError(err).Error("Failed to issue two-factor challenge.")
 			g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error"})
+			return
 		}
+		g.JSON(http.StatusAccepted, twofactor.Challenge{
+			Message: "Second factor required!",
+			Token:   token,
+			Methods: methods,
+		})
 		return
 	}
 
-	h.jwt.Issue(g, usr.ID.String())
+	if err = h.jwt.Issue(g, usr.ID.String()); err != nil {
+		return
+	}
 
 	g.JSON(http.StatusOK, common.StatusMessage{
 		Message: "Logged in!",
 	})
 }
 
-// Logout is a method of `Handler`. Clears the JWT token from the cookies thus logging out the current user.
+func abortWithAuthError(g *gin.Context, err error) {
+	switch e := err.(type) {
+	case InvalidCredentials:
+		g.AbortWithStatusJSON(http.StatusBadRequest, statusInvalidCredentials)
+	case LockedUser:
+		g.AbortWithStatusJSON(http.StatusForbidden, common.StatusMessage{
+			Message: fmt.Sprintf("You have been locked out for failed credentials. You have to wait %v more seconds.", e.seconds),
+		})
+	default:
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error"})
+	}
+}
+
+// Refresh is a method of `Handler`. Rotates the refresh token of the current session and issues a new access token.
+// @Summary Token refresh endpoint
+// @Schemes
+// @Description Rotates the refresh token in the cookies and issues a new short-lived access token for the session
+// @Accept json
+// @Produce json
+// @Success 200 {object} common.StatusMessage
+// @Failure 401 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /auth/refresh [post]
+func (h *Handler) Refresh(g *gin.Context) {
+	if err := h.jwt.Refresh(g); err != nil {
+		return
+	}
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Session refreshed!"})
+}
+
+// Signout is a method of `Handler`. Revokes the session on the server and clears the tokens from the cookies thus logging out the current user.
 // @Summary Logout endpoint
 // @Schemes
-// @Description Logs out of the application, deletes the JWT token uased for authorization
+// @Description Logs out of the application, revokes the session and deletes the JWT tokens used for authorization
 // @Accept json
 // @Produce json
 // @Success 200 {object} common.StatusMessage
 // @Router /account/signout [get]
 func (h *Handler) Signout(g *gin.Context) {
-	g.SetCookie(jwtTokenKey, "", 0, "", "", true, true)
+	h.jwt.Revoke(g)
 	g.JSON(http.StatusOK, common.StatusMessage{Message: "Logged out successfully! See you!"})
 }
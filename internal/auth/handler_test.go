@@ -0,0 +1,349 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/account"
+	"github.com/inokone/go-micro-saas/internal/auth/magiclink"
+	"github.com/inokone/go-micro-saas/internal/auth/session"
+	"github.com/inokone/go-micro-saas/internal/auth/twofactor"
+	"github.com/inokone/go-micro-saas/internal/common"
+	"github.com/inokone/go-micro-saas/internal/mail"
+	"github.com/inokone/go-micro-saas/internal/ratelimit"
+)
+
+// MockAccountStorer is a mock implementation of the account.Storer interface
+type MockAccountStorer struct {
+	mock.Mock
+}
+
+func (m *MockAccountStorer) Store(acc *account.Account) error {
+	args := m.Called(acc)
+	return args.Error(0)
+}
+
+func (m *MockAccountStorer) Update(acc *account.Account) error {
+	args := m.Called(acc)
+	return args.Error(0)
+}
+
+func (m *MockAccountStorer) ByUser(userID uuid.UUID) (*account.Account, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*account.Account), args.Error(1)
+}
+
+func (m *MockAccountStorer) ByConfirmToken(token string) (*account.Account, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*account.Account), args.Error(1)
+}
+
+func (m *MockAccountStorer) ByRecoveryToken(token string) (*account.Account, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*account.Account), args.Error(1)
+}
+
+func (m *MockAccountStorer) ByUnlockToken(token string) (*account.Account, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*account.Account), args.Error(1)
+}
+
+// MockFactorStorer is a mock implementation of the twofactor.Storer interface
+type MockFactorStorer struct {
+	mock.Mock
+}
+
+func (m *MockFactorStorer) Store(settings *twofactor.Settings) error {
+	args := m.Called(settings)
+	return args.Error(0)
+}
+
+func (m *MockFactorStorer) Update(settings *twofactor.Settings) error {
+	args := m.Called(settings)
+	return args.Error(0)
+}
+
+func (m *MockFactorStorer) ByUser(userID uuid.UUID) (*twofactor.Settings, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*twofactor.Settings), args.Error(1)
+}
+
+func (m *MockFactorStorer) Delete(userID uuid.UUID) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockFactorStorer) ReplaceRecoveryCodes(userID uuid.UUID, hashes []string) error {
+	args := m.Called(userID, hashes)
+	return args.Error(0)
+}
+
+func (m *MockFactorStorer) UseRecoveryCode(userID uuid.UUID, hash string) (bool, error) {
+	args := m.Called(userID, hash)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockFactorStorer) CountRecoveryCodes(userID uuid.UUID) (int, error) {
+	args := m.Called(userID)
+	return args.Int(0), args.Error(1)
+}
+
+// MockLinkStorer is a mock implementation of the magiclink.Storer interface
+type MockLinkStorer struct {
+	mock.Mock
+}
+
+func (m *MockLinkStorer) Store(link *magiclink.Link) error {
+	args := m.Called(link)
+	return args.Error(0)
+}
+
+func (m *MockLinkStorer) Take(id uuid.UUID) (*magiclink.Link, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*magiclink.Link), args.Error(1)
+}
+
+func (m *MockLinkStorer) DeleteForUser(userID uuid.UUID) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func newTestHandler(users *MockUserStorer, accounts *MockAccountStorer, links *MockLinkStorer, sessions *MockSessionStorer, enabled bool) *Handler {
+	conf := *testConfig
+	conf.MagicLinkEnabled = enabled
+	conf.MagicLinkTTL = 15
+	factors := new(MockFactorStorer)
+	factors.On("ByUser", mock.Anything).Return(nil, sql.ErrNoRows)
+	m := NewJWTHandler(users, sessions, new(MockKeyStorer), new(MockOrganizationStorer), testKeySet, &conf, nil)
+	return NewHandler(users, accounts, factors, new(MockIdentityStorer), links, nil, m, testSender, &conf, common.NoCaptcha{}, testLimiter("ip:3/1h"), nil)
+}
+
+// testSender is a mail service without SMTP, which does not send the e-mails.
+var testSender = mail.NewService(&common.MailConfig{}, nil)
+
+func testLimiter(failures string) *ratelimit.Limiter {
+	l, err := ratelimit.NewLimiter(&common.RateLimitConfig{Failures: failures}, nil)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// rejectingCaptcha is a captcha verifier rejecting every token.
+type rejectingCaptcha struct{}
+
+func (rejectingCaptcha) Verify(_ context.Context, _ string) error {
+	return errors.New("invalid captcha token")
+}
+
+func passwordSignin(h *Handler) *httptest.ResponseRecorder {
+	router := setupTestRouter()
+	router.POST("/auth/signin", h.Signin)
+
+	w := httptest.NewRecorder()
+	body := `{"email":"test@example.com","password":"password","captcha_token":"token"}`
+	req, _ := http.NewRequest("POST", "/auth/signin", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func magicLinkSignin(h *Handler, token string) *httptest.ResponseRecorder {
+	router := setupTestRouter()
+	router.POST("/auth/magic-link/verify", h.MagicLinkSignin)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/auth/magic-link/verify", bytes.NewBufferString(`{"token":"`+token+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestMagicLinkSigninIssuesSession(t *testing.T) {
+	users := new(MockUserStorer)
+	accounts := new(MockAccountStorer)
+	links := new(MockLinkStorer)
+	sessions := new(MockSessionStorer)
+	h := newTestHandler(users, accounts, links, sessions, true)
+	usr := testUser()
+	link, token, _ := magiclink.NewLink(usr.ID, time.Minute)
+
+	links.On("Take", link.ID).Return(link, nil)
+	users.On("ByID", usr.ID).Return(usr, nil)
+	accounts.On("ByUser", usr.ID).Return(&account.Account{UserID: usr.ID}, nil)
+	accounts.On("Update", mock.Anything).Return(nil)
+	sessions.On("Familiar", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+	sessions.On("Store", mock.MatchedBy(func(s *session.Session) bool { return s.UserID == usr.ID })).Return(nil)
+
+	w := magicLinkSignin(h, token)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotNil(t, cookieOf(w, jwtTokenKey))
+	sessions.AssertExpectations(t)
+}
+
+func TestMagicLinkSigninCountsWrongTokenAsFailedSignin(t *testing.T) {
+	users := new(MockUserStorer)
+	accounts := new(MockAccountStorer)
+	links := new(MockLinkStorer)
+	h := newTestHandler(users, accounts, links, new(MockSessionStorer), true)
+	usr := testUser()
+	link, _, _ := magiclink.NewLink(usr.ID, time.Minute)
+
+	links.On("Take", link.ID).Return(link, nil)
+	users.On("ByID", usr.ID).Return(usr, nil)
+	accounts.On("ByUser", usr.ID).Return(&account.Account{UserID: usr.ID}, nil)
+	accounts.On("Update", mock.MatchedBy(func(a *account.Account) bool { return a.FailedLoginCounter == 1 })).Return(nil)
+
+	w := magicLinkSignin(h, link.ID.String()+".forged")
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Nil(t, cookieOf(w, jwtTokenKey))
+	accounts.AssertExpectations(t)
+	links.AssertNotCalled(t, "Store", mock.Anything)
+}
+
+func TestMagicLinkSigninRejectsLockedUser(t *testing.T) {
+	users := new(MockUserStorer)
+	accounts := new(MockAccountStorer)
+	links := new(MockLinkStorer)
+	h := newTestHandler(users, accounts, links, new(MockSessionStorer), true)
+	usr := testUser()
+	link, token, _ := magiclink.NewLink(usr.ID, time.Minute)
+
+	links.On("Take", link.ID).Return(link, nil)
+	users.On("ByID", usr.ID).Return(usr, nil)
+	accounts.On("ByUser", usr.ID).Return(&account.Account{UserID: usr.ID, FailedLoginLock: time.Now().Add(time.Minute)}, nil)
+	links.On("Store", link).Return(nil)
+
+	w := magicLinkSignin(h, token)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Nil(t, cookieOf(w, jwtTokenKey))
+	accounts.AssertNotCalled(t, "Update", mock.Anything)
+	links.AssertCalled(t, "Store", link)
+}
+
+func TestMagicLinkSigninDiscardsLinkOfDisabledUser(t *testing.T) {
+	users := new(MockUserStorer)
+	links := new(MockLinkStorer)
+	h := newTestHandler(users, new(MockAccountStorer), links, new(MockSessionStorer), true)
+	usr := testUser()
+	usr.Enabled = false
+	link, token, _ := magiclink.NewLink(usr.ID, time.Minute)
+
+	links.On("Take", link.ID).Return(link, nil)
+	users.On("ByID", usr.ID).Return(usr, nil)
+
+	w := magicLinkSignin(h, token)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Nil(t, cookieOf(w, jwtTokenKey))
+	links.AssertNotCalled(t, "Store", mock.Anything)
+}
+
+func TestMagicLinkIsDisabledByDefault(t *testing.T) {
+	links := new(MockLinkStorer)
+	h := newTestHandler(new(MockUserStorer), new(MockAccountStorer), links, new(MockSessionStorer), false)
+
+	w := magicLinkSignin(h, uuid.NewString()+".token")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	links.AssertNotCalled(t, "Take", mock.Anything)
+}
+
+func TestSigninRejectsFailedCaptcha(t *testing.T) {
+	users := new(MockUserStorer)
+	h := newTestHandler(users, new(MockAccountStorer), new(MockLinkStorer), new(MockSessionStorer), false)
+	h.captcha = rejectingCaptcha{}
+
+	w := passwordSignin(h)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Captcha verification failed!")
+	users.AssertNotCalled(t, "ByEmail", mock.Anything)
+}
+
+func TestSigninWithoutCaptcha(t *testing.T) {
+	users := new(MockUserStorer)
+	h := newTestHandler(users, new(MockAccountStorer), new(MockLinkStorer), new(MockSessionStorer), false)
+
+	users.On("ByEmail", "test@example.com").Return(nil, sql.ErrNoRows)
+
+	w := passwordSignin(h)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	users.AssertExpectations(t)
+}
+
+func TestSigninBlocksAddressAfterFailures(t *testing.T) {
+	users := new(MockUserStorer)
+	h := newTestHandler(users, new(MockAccountStorer), new(MockLinkStorer), new(MockSessionStorer), false)
+
+	users.On("ByEmail", "test@example.com").Return(nil, sql.ErrNoRows)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusBadRequest, passwordSignin(h).Code)
+	}
+	w := passwordSignin(h)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	users.AssertNumberOfCalls(t, "ByEmail", 3)
+}
+
+func TestSigninIgnoresForgedForwardedFor(t *testing.T) {
+	users := new(MockUserStorer)
+	h := newTestHandler(users, new(MockAccountStorer), new(MockLinkStorer), new(MockSessionStorer), false)
+	router := setupTestRouter()
+	assert.NoError(t, router.SetTrustedProxies((&common.WebConfig{}).Proxies()))
+	router.POST("/auth/signin", h.Signin)
+
+	users.On("ByEmail", "test@example.com").Return(nil, sql.ErrNoRows)
+
+	forged := func(forwarded string) int {
+		w := httptest.NewRecorder()
+		body := `{"email":"test@example.com","password":"password","captcha_token":"token"}`
+		req, _ := http.NewRequest("POST", "/auth/signin", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwarded)
+		req.RemoteAddr = "10.0.0.1:1234"
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, victim := range []string{"192.168.1.1", "192.168.1.2", "192.168.1.3"} {
+		assert.Equal(t, http.StatusBadRequest, forged(victim))
+	}
+
+	assert.Equal(t, http.StatusForbidden, forged("192.168.1.4"))
+	assert.Zero(t, h.service.limiter.Blocked("192.168.1.1"))
+	assert.NotZero(t, h.service.limiter.Blocked("10.0.0.1"))
+}
@@ -0,0 +1,135 @@
+package identity
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/cskr/pubsub/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inokone/go-micro-saas/internal/auth/session"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+var (
+	statusUnknownError = common.StatusMessage{Message: "Unknown error, please contact administrator!"}
+	statusNotFound     = common.StatusMessage{Message: "Sign in method not found!"}
+	statusLastIdentity = common.StatusMessage{Message: "The last sign in method of the account can not be removed!"}
+)
+
+// Handler is a struct for web handles related to the login methods of the users.
+type Handler struct {
+	identities Storer
+	ps         *pubsub.PubSub[string, common.Event]
+}
+
+// NewHandler creates a new `Handler`, based on the identity persistence. The removed login methods are published as
+// security alerts.
+func NewHandler(identities Storer, ps *pubsub.PubSub[string, common.Event]) *Handler {
+	return &Handler{
+		identities: identities,
+		ps:         ps,
+	}
+}
+
+// List is a method of `Handler`. Lists the login methods of the current user.
+// @Summary Identity list endpoint
+// @Schemes
+// @Description Returns the sign in methods of the current user: credentials and the linked single sign-on providers
+// @Accept json
+// @Produce json
+// @Success 200 {array} identity.View
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/identities [get]
+func (h *Handler) List(g *gin.Context) {
+	usr := currentUser(g)
+
+	identities, err := h.identities.ByUser(usr.ID)
+	if err != nil {
+		log.WithError(err).Error("Failed to list identities.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+
+	res := make([]View, len(identities))
+	for i, identity := range identities {
+		res[i] = identity.AsView()
+	}
+	g.JSON(http.StatusOK, res)
+}
+
+// Unlink is a method of `Handler`. Removes a login method of the current user, requires a recent sign in.
+// @Summary Identity unlink endpoint
+// @Schemes
+// @Description Removes a sign in method of the current user. The last sign in method of the account can not be removed.
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the identity"
+// @Success 200 {object} common.StatusMessage
+// @Failure 401 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 409 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/identities/{id} [delete]
+func (h *Handler) Unlink(g *gin.Context) {
+	usr := currentUser(g)
+
+	id, err := uuid.Parse(g.Param("id"))
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, statusNotFound)
+		return
+	}
+
+	identities, err := h.identities.ByUser(usr.ID)
+	if err != nil {
+		log.WithError(err).Error("Failed to list identities.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	removed, ok := find(identities, id)
+	if !ok {
+		g.AbortWithStatusJSON(http.StatusNotFound, statusNotFound)
+		return
+	}
+	if len(identities) == 1 {
+		g.AbortWithStatusJSON(http.StatusConflict, statusLastIdentity)
+		return
+	}
+
+	// The storer checks for the last identity again, an other identity may have been removed in the meantime.
+	deleted, err := h.identities.Delete(usr.ID, id)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete identity.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	if !deleted {
+		g.AbortWithStatusJSON(http.StatusConflict, statusLastIdentity)
+		return
+	}
+	h.ps.Pub(common.Event{
+		ID:   uuid.New(),
+		Type: common.IdentityUnlinked,
+		Time: time.Now(),
+		Data: common.SecurityData{Email: usr.Email, IP: g.ClientIP(), Device: session.Device(g.Request.UserAgent()), Detail: removed.Provider},
+		User: usr.ID,
+	}, common.HistoryTopic, common.NotificationTopic)
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Sign in method removed!"})
+}
+
+func find(identities []Identity, id uuid.UUID) (Identity, bool) {
+	for _, identity := range identities {
+		if identity.ID == id {
+			return identity, true
+		}
+	}
+	return Identity{}, false
+}
+
+func currentUser(g *gin.Context) *user.User {
+	u, _ := g.Get("user")
+	return u.(*user.User)
+}
@@ -0,0 +1,147 @@
+package identity
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cskr/pubsub/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+var testUser = &user.User{
+	ID:      uuid.New(),
+	Email:   "test@example.com",
+	Status:  user.Confirmed,
+	Source:  "credentials",
+	Enabled: true,
+}
+
+func setupTestRouter(h *Handler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	return r
+}
+
+func withUser(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user", testUser)
+		handler(c)
+	}
+}
+
+func TestList200ForHappyPath(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer, pubsub.New[string, common.Event](1))
+	router := setupTestRouter(handler)
+
+	identities := []Identity{
+		*NewCredentials(testUser.ID, testUser.Email),
+		*NewIdentity(testUser.ID, "google", "1234", testUser.Email),
+	}
+	mockStorer.On("ByUser", testUser.ID).Return(identities, nil)
+
+	router.GET("/identities", withUser(handler.List))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/identities", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []View
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response, 2)
+	assert.Equal(t, "google", response[1].Provider)
+	assert.NotContains(t, w.Body.String(), "1234")
+}
+
+func TestUnlink200ForHappyPath(t *testing.T) {
+	mockStorer := new(MockStorer)
+	ps := pubsub.New[string, common.Event](1)
+	ch := ps.Sub(common.NotificationTopic)
+	handler := NewHandler(mockStorer, ps)
+	router := setupTestRouter(handler)
+
+	google := NewIdentity(testUser.ID, "google", "1234", testUser.Email)
+	mockStorer.On("ByUser", testUser.ID).Return([]Identity{*NewCredentials(testUser.ID, testUser.Email), *google}, nil)
+	mockStorer.On("Delete", testUser.ID, google.ID).Return(true, nil)
+
+	router.DELETE("/identities/:id", withUser(handler.Unlink))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/identities/"+google.ID.String(), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStorer.AssertExpectations(t)
+
+	select {
+	case e := <-ch:
+		assert.Equal(t, common.IdentityUnlinked, e.Type)
+		assert.Equal(t, "google", e.Data.(common.SecurityData).Detail)
+	case <-time.After(time.Second):
+		t.Fatal("security alert not published")
+	}
+}
+
+func TestUnlink409ForLastIdentity(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer, pubsub.New[string, common.Event](1))
+	router := setupTestRouter(handler)
+
+	creds := NewCredentials(testUser.ID, testUser.Email)
+	mockStorer.On("ByUser", testUser.ID).Return([]Identity{*creds}, nil)
+
+	router.DELETE("/identities/:id", withUser(handler.Unlink))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/identities/"+creds.ID.String(), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockStorer.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestUnlink409WhenOtherIdentityRemovedMeanwhile(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer, pubsub.New[string, common.Event](1))
+	router := setupTestRouter(handler)
+
+	google := NewIdentity(testUser.ID, "google", "1234", testUser.Email)
+	mockStorer.On("ByUser", testUser.ID).Return([]Identity{*NewCredentials(testUser.ID, testUser.Email), *google}, nil)
+	mockStorer.On("Delete", testUser.ID, google.ID).Return(false, nil)
+
+	router.DELETE("/identities/:id", withUser(handler.Unlink))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/identities/"+google.ID.String(), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestUnlink404ForIdentityOfOtherUser(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer, pubsub.New[string, common.Event](1))
+	router := setupTestRouter(handler)
+
+	mockStorer.On("ByUser", testUser.ID).Return([]Identity{*NewCredentials(testUser.ID, testUser.Email)}, nil)
+
+	router.DELETE("/identities/:id", withUser(handler.Unlink))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/identities/"+uuid.New().String(), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,85 @@
+package identity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockStorer is a mock implementation of the Storer interface
+type MockStorer struct {
+	mock.Mock
+}
+
+func (m *MockStorer) Store(identity *Identity) error {
+	args := m.Called(identity)
+	return args.Error(0)
+}
+
+func (m *MockStorer) Update(identity *Identity) error {
+	args := m.Called(identity)
+	return args.Error(0)
+}
+
+func (m *MockStorer) ByUser(userID uuid.UUID) ([]Identity, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Identity), args.Error(1)
+}
+
+func (m *MockStorer) ByProvider(userID uuid.UUID, provider string) (*Identity, error) {
+	args := m.Called(userID, provider)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Identity), args.Error(1)
+}
+
+func (m *MockStorer) BySubject(provider string, subject string) (*Identity, error) {
+	args := m.Called(provider, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Identity), args.Error(1)
+}
+
+func (m *MockStorer) Touch(id uuid.UUID, lastUsed time.Time) error {
+	args := m.Called(id, lastUsed)
+	return args.Error(0)
+}
+
+func (m *MockStorer) Delete(userID uuid.UUID, id uuid.UUID) (bool, error) {
+	args := m.Called(userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestNewCredentialsIsIdentifiedByUser(t *testing.T) {
+	userID := uuid.New()
+
+	i := NewCredentials(userID, "test@example.com")
+
+	assert.Equal(t, Credentials, i.Provider)
+	assert.Equal(t, null.StringFrom(userID.String()), i.Subject)
+	assert.Equal(t, userID, i.UserID)
+}
+
+func TestAsViewOmitsSubject(t *testing.T) {
+	i := NewIdentity(uuid.New(), "google", "1234", "test@example.com")
+	i.LastUsedAt = null.TimeFrom(time.Unix(1700000000, 0))
+
+	v := i.AsView()
+
+	assert.Equal(t, View{
+		ID:       i.ID.String(),
+		Provider: "google",
+		Email:    "test@example.com",
+		Created:  int(i.CreatedAt.Unix()),
+		LastUsed: 1700000000,
+	}, v)
+}
@@ -0,0 +1,79 @@
+package identity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+)
+
+// Credentials is the provider of the identity for signing in with email address and password.
+const Credentials = "credentials"
+
+// Identity is a login method of a user for database storage: the email/password credentials or an account of a single
+// sign-on provider. A user can have one identity per provider, and at least one identity at all times.
+type Identity struct {
+	ID         uuid.UUID   `db:"identity_id"`
+	UserID     uuid.UUID   `db:"user_id"`
+	Provider   string      `db:"provider"`
+	Subject    null.String `db:"subject"`
+	Email      string      `db:"email"`
+	CreatedAt  time.Time   `db:"created_at"`
+	LastUsedAt null.Time   `db:"last_used_at"`
+}
+
+// NewIdentity is a function to create a new `Identity` of a user, identified by the subject at the provider.
+func NewIdentity(userID uuid.UUID, provider string, subject string, email string) *Identity {
+	return &Identity{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Provider:  provider,
+		Subject:   null.StringFrom(subject),
+		Email:     email,
+		CreatedAt: time.Now(),
+	}
+}
+
+// NewCredentials is a function to create the email/password `Identity` of a user.
+func NewCredentials(userID uuid.UUID, email string) *Identity {
+	return NewIdentity(userID, Credentials, userID.String(), email)
+}
+
+// AsView is a method of the `Identity` struct. It converts an `Identity` object into a `View` object.
+func (i *Identity) AsView() View {
+	v := View{
+		ID:       i.ID.String(),
+		Provider: i.Provider,
+		Email:    i.Email,
+		Created:  int(i.CreatedAt.Unix()),
+	}
+	if i.LastUsedAt.Valid {
+		v.LastUsed = int(i.LastUsedAt.Time.Unix())
+	}
+	return v
+}
+
+// View is the JSON representation of a login method of the user.
+type View struct {
+	ID       string `json:"id"`
+	Provider string `json:"provider"`
+	Email    string `json:"email"`
+	Created  int    `json:"created"`
+	LastUsed int    `json:"last_used"`
+}
+
+// Link is the JSON response of starting to link a single sign-on provider, the URL of the provider to open in the browser.
+type Link struct {
+	URL string `json:"url"`
+}
+
+// Storer is the interface for `Identity` persistence
+type Storer interface {
+	Store(identity *Identity) error
+	Update(identity *Identity) error
+	ByUser(userID uuid.UUID) ([]Identity, error)
+	ByProvider(userID uuid.UUID, provider string) (*Identity, error)
+	BySubject(provider string, subject string) (*Identity, error)
+	Touch(id uuid.UUID, lastUsed time.Time) error
+	Delete(userID uuid.UUID, id uuid.UUID) (bool, error)
+}
@@ -0,0 +1,106 @@
+package identity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresStorer is the `Storer` implementation based on sqlx library.
+type PostgresStorer struct {
+	db *sqlx.DB
+}
+
+// NewPostgresStorer creates a new `PostgresStorer` instance based on the sqlx library.
+func NewPostgresStorer(db *sqlx.DB) *PostgresStorer {
+	return &PostgresStorer{
+		db: db,
+	}
+}
+
+// Store is a method of the `PostgresStorer` struct. Takes an `Identity` as parameter and persists it.
+func (s *PostgresStorer) Store(identity *Identity) error {
+	query := `INSERT INTO microsaas.identities (identity_id, user_id, provider, subject, email, created_at, last_used_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := s.db.Exec(
+		query,
+		identity.ID,
+		identity.UserID,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+		identity.CreatedAt,
+		identity.LastUsedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store identity: %w", err)
+	}
+	return nil
+}
+
+// Update is a method of the `PostgresStorer` struct. Takes an `Identity` as parameter and updates its subject, email and usage.
+func (s *PostgresStorer) Update(identity *Identity) error {
+	query := `UPDATE microsaas.identities SET subject = $1, email = $2, last_used_at = $3 WHERE identity_id = $4`
+	if _, err := s.db.Exec(query, identity.Subject, identity.Email, identity.LastUsedAt, identity.ID); err != nil {
+		return fmt.Errorf("failed to update identity: %w", err)
+	}
+	return nil
+}
+
+// ByUser is a method of the `PostgresStorer` struct. Takes a user ID as parameter to load all identities of the user.
+func (s *PostgresStorer) ByUser(userID uuid.UUID) ([]Identity, error) {
+	var identities []Identity
+	query := `SELECT identity_id, user_id, provider, subject, email, created_at, last_used_at FROM microsaas.identities WHERE user_id = $1 ORDER BY created_at`
+	if err := s.db.Select(&identities, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+	return identities, nil
+}
+
+// ByProvider is a method of the `PostgresStorer` struct. Takes a user ID and a provider name as parameter to load the
+// identity of the user at the provider.
+func (s *PostgresStorer) ByProvider(userID uuid.UUID, provider string) (*Identity, error) {
+	var identity Identity
+	query := `SELECT identity_id, user_id, provider, subject, email, created_at, last_used_at FROM microsaas.identities WHERE user_id = $1 AND provider = $2`
+	if err := s.db.Get(&identity, query, userID, provider); err != nil {
+		return nil, fmt.Errorf("failed to get identity by provider: %w", err)
+	}
+	return &identity, nil
+}
+
+// BySubject is a method of the `PostgresStorer` struct. Takes a provider name and the ID of the user at the provider
+// as parameter to load the identity.
+func (s *PostgresStorer) BySubject(provider string, subject string) (*Identity, error) {
+	var identity Identity
+	query := `SELECT identity_id, user_id, provider, subject, email, created_at, last_used_at FROM microsaas.identities WHERE provider = $1 AND subject = $2`
+	if err := s.db.Get(&identity, query, provider, subject); err != nil {
+		return nil, fmt.Errorf("failed to get identity by subject: %w", err)
+	}
+	return &identity, nil
+}
+
+// Touch is a method of the `PostgresStorer` struct. Takes an identity ID and a time as parameter and records the last
+// sign in with the identity.
+func (s *PostgresStorer) Touch(id uuid.UUID, lastUsed time.Time) error {
+	query := `UPDATE microsaas.identities SET last_used_at = $1 WHERE identity_id = $2`
+	if _, err := s.db.Exec(query, lastUsed, id); err != nil {
+		return fmt.Errorf("failed to touch identity: %w", err)
+	}
+	return nil
+}
+
+// Delete is a method of the `PostgresStorer` struct. Takes a user ID and an identity ID as parameter and deletes the
+// identity if it belongs to the user and is not the last one of the user. Returns false if nothing was deleted.
+func (s *PostgresStorer) Delete(userID uuid.UUID, id uuid.UUID) (bool, error) {
+	query := `DELETE FROM microsaas.identities WHERE user_id = $1 AND identity_id = $2
+		AND (SELECT COUNT(*) FROM microsaas.identities WHERE user_id = $1) > 1`
+	res, err := s.db.Exec(query, userID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete identity: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete identity: %w", err)
+	}
+	return affected == 1, nil
+}
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inokone/go-micro-saas/internal/auth/session"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+// impersonationKey is the key of the impersonation of the request in the Gin context.
+const impersonationKey = "impersonation"
+
+var statusImpersonating = common.StatusMessage{Message: "Not allowed while impersonating a user!"}
+
+// actor is the party acting on behalf of the subject of a token, as the "act" claim of RFC 8693.
+type actor struct {
+	Subject string `json:"sub"`
+}
+
+// impersonation is the state of a request made by an administrator on behalf of a user.
+type impersonation struct {
+	admin     *user.User
+	expiresAt time.Time
+}
+
+// Impersonation is the JSON representation of the impersonation of the current request, for showing a banner.
+type Impersonation struct {
+	Active       bool          `json:"active"`
+	Impersonator *user.Profile `json:"impersonator,omitempty"`
+	User         *user.Profile `json:"user,omitempty"`
+	Expires      int           `json:"expires,omitempty"`
+}
+
+// Impersonate is a method of `JWTHandler`. Issues a time-boxed access token for a user on behalf of an administrator,
+// bound to the session of the administrator. The refresh token of the administrator is kept, so the administrator can
+// return to the own session at the end of the impersonation.
+func (h *JWTHandler) Impersonate(g *gin.Context, admin *user.User, sess *session.Session, target *user.User) (time.Time, error) {
+	ttl := h.impersonationTTL()
+	if err := h.setAccessCookie(g, target.ID, sess, &actor{Subject: admin.ID.String()}, ttl); err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(ttl), nil
+}
+
+// EndImpersonation is a method of `JWTHandler`. Issues a new access token for the administrator of the impersonation
+// in the Gin context provided as a parameter.
+func (h *JWTHandler) EndImpersonation(g *gin.Context) error {
+	imp := impersonationOf(g)
+	sess, _ := g.Get("session")
+	return h.setAccessCookie(g, imp.admin.ID, sess.(*session.Session), nil, h.accessTTL())
+}
+
+// audit publishes the request to the history of the user when an administrator is impersonating the user.
+func (h *JWTHandler) audit(g *gin.Context) {
+	imp := impersonationOf(g)
+	if imp == nil {
+		return
+	}
+	h.publish(common.ImpersonatedRequest, currentUser(g).ID, imp.admin.ID, common.RequestData{
+		Method: g.Request.Method,
+		Path:   g.Request.URL.Path,
+		Status: g.Writer.Status(),
+		IP:     g.ClientIP(),
+	})
+}
+
+func (h *JWTHandler) publish(eventType string, userID uuid.UUID, actorID uuid.UUID, data any) {
+	h.ps.Pub(common.Event{
+		ID:    uuid.New(),
+		Type:  eventType,
+		Time:  time.Now(),
+		Data:  data,
+		User:  userID,
+		Actor: uuid.NullUUID{UUID: actorID, Valid: true},
+	}, common.HistoryTopic)
+}
+
+func (h *JWTHandler) impersonationTTL() time.Duration {
+	return time.Minute * time.Duration(h.conf.ImpersonationTTL)
+}
+
+// Impersonate is a method of `Handler`. Signs the administrator in as the user with the ID provided as URL parameter,
+// for a limited time. Every request of the impersonation is recorded in the history of the user.
+// @Summary User impersonation endpoint
+// @Schemes
+// @Description Sets up a time-boxed JWT authorization of the administrator on behalf of the user, to see what the user sees
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the user"
+// @Success 200 {object} auth.Impersonation
+// @Failure 400 {object} common.StatusMessage
+// @Failure 401 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /users/{id}/impersonate [post]
+func (h *Handler) Impersonate(g *gin.Context) {
+	admin := currentUser(g)
+	sess, ok := g.Get("session")
+	if !ok {
+		g.AbortWithStatusJSON(http.StatusForbidden, common.StatusMessage{Message: "Impersonation requires a signed in session!"})
+		return
+	}
+
+	id, err := uuid.Parse(g.Param("id"))
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "User not found!"})
+		return
+	}
+	target, err := h.users.ByID(id)
+	if err != nil {
+		log.WithError(err).Debug("Failed to collect user.")
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "User not found!"})
+		return
+	}
+	if target.ID == admin.ID || len(target.Permissions()) > 0 {
+		g.AbortWithStatusJSON(http.StatusForbidden, common.StatusMessage{Message: "Privileged users can not be impersonated!"})
+		return
+	}
+	if !target.Enabled {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Deactivated users can not be impersonated!"})
+		return
+	}
+
+	expiresAt, err := h.jwt.Impersonate(g, admin, sess.(*session.Session), target)
+	if err != nil {
+		return
+	}
+	h.jwt.publish(common.ImpersonationStarted, target.ID, admin.ID, nil)
+
+	adminProfile, userProfile := admin.AsProfile(), target.AsProfile()
+	g.JSON(http.StatusOK, Impersonation{
+		Active:       true,
+		Impersonator: &adminProfile,
+		User:         &userProfile,
+		Expires:      int(expiresAt.Unix()),
+	})
+}
+
+// Impersonation is a method of `Handler`. Returns whether the current request is made by an administrator on behalf of
+// the user, so the UI can show a banner.
+// @Summary Impersonation status endpoint
+// @Schemes
+// @Description Returns the administrator and the user of the current impersonation, if any
+// @Accept json
+// @Produce json
+// @Success 200 {object} auth.Impersonation
+// @Failure 401 {object} common.StatusMessage
+// @Router /auth/impersonation [get]
+func (h *Handler) Impersonation(g *gin.Context) {
+	imp := impersonationOf(g)
+	if imp == nil {
+		g.JSON(http.StatusOK, Impersonation{Active: false})
+		return
+	}
+
+	adminProfile, userProfile := imp.admin.AsProfile(), currentUser(g).AsProfile()
+	g.JSON(http.StatusOK, Impersonation{
+		Active:       true,
+		Impersonator: &adminProfile,
+		User:         &userProfile,
+		Expires:      int(imp.expiresAt.Unix()),
+	})
+}
+
+// EndImpersonation is a method of `Handler`. Ends the impersonation of the current request, the administrator is
+// signed in as self again.
+// @Summary Impersonation end endpoint
+// @Schemes
+// @Description Ends the impersonation of a user, sets up the JWT authorization of the administrator
+// @Accept json
+// @Produce json
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 401 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /auth/impersonation [delete]
+func (h *Handler) EndImpersonation(g *gin.Context) {
+	imp := impersonationOf(g)
+	if imp == nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Not impersonating a user!"})
+		return
+	}
+
+	if err := h.jwt.EndImpersonation(g); err != nil {
+		return
+	}
+	h.jwt.publish(common.ImpersonationEnded, currentUser(g).ID, imp.admin.ID, nil)
+
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Impersonation ended!"})
+}
+
+func impersonationOf(g *gin.Context) *impersonation {
+	imp, ok := g.Get(impersonationKey)
+	if !ok {
+		return nil
+	}
+	return imp.(*impersonation)
+}
+
+func currentUser(g *gin.Context) *user.User {
+	u, _ := g.Get("user")
+	return u.(*user.User)
+}
@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cskr/pubsub/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/apikey"
+	"github.com/inokone/go-micro-saas/internal/auth/role"
+	"github.com/inokone/go-micro-saas/internal/auth/session"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+func testAdmin() *user.User {
+	admin := testUser()
+	admin.Email = "admin@example.com"
+	admin.Role = &role.Role{ID: role.RoleAdmin, Permissions: role.Permissions}
+	admin.RoleID = role.RoleAdmin
+	return admin
+}
+
+func newTestImpersonation(users *MockUserStorer, sessions *MockSessionStorer, ps *pubsub.PubSub[string, common.Event]) (*Handler, *JWTHandler) {
+	conf := *testConfig
+	conf.ImpersonationTTL = 30
+	m := NewJWTHandler(users, sessions, new(MockKeyStorer), new(MockOrganizationStorer), testKeySet, &conf, ps)
+	return NewHandler(users, new(MockAccountStorer), new(MockFactorStorer), new(MockIdentityStorer), new(MockLinkStorer), nil, m, nil, &conf, nil, nil, nil), m
+}
+
+// impersonate starts an impersonation of the target by the admin signed in with the session, and returns the response.
+func impersonate(h *Handler, admin *user.User, sess *session.Session, target string) *httptest.ResponseRecorder {
+	router := setupTestRouter()
+	router.POST("/users/:id/impersonate", func(g *gin.Context) {
+		g.Set("user", admin)
+		if sess != nil {
+			g.Set("session", sess)
+		}
+	}, h.Impersonate)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/users/"+target+"/impersonate", nil)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func nextEvent(t *testing.T, ch chan common.Event) common.Event {
+	select {
+	case e := <-ch:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("history event not published")
+		return common.Event{}
+	}
+}
+
+func TestImpersonationIsAuditedWithActor(t *testing.T) {
+	users := new(MockUserStorer)
+	sessions := new(MockSessionStorer)
+	ps := pubsub.New[string, common.Event](1)
+	ch := ps.Sub(common.HistoryTopic)
+	h, m := newTestImpersonation(users, sessions, ps)
+	admin, target := testAdmin(), testUser()
+	sess, _, _ := session.NewSession(admin.ID, "127.0.0.1", "test", time.Hour)
+
+	users.On("ByID", target.ID).Return(target, nil)
+	users.On("ByID", admin.ID).Return(admin, nil)
+	sessions.On("ByID", sess.ID).Return(sess, nil)
+
+	w := impersonate(h, admin, sess, target.ID.String())
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	started := nextEvent(t, ch)
+	assert.Equal(t, common.ImpersonationStarted, started.Type)
+	assert.Equal(t, target.ID, started.User)
+	assert.Equal(t, admin.ID, started.Actor.UUID)
+
+	router := setupTestRouter()
+	router.GET("/profile", m.Validate, func(g *gin.Context) {
+		assert.Equal(t, target.ID, currentUser(g).ID)
+		g.Status(http.StatusOK)
+	})
+	router.GET("/impersonation", m.Validate, h.Impersonation)
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/profile", nil)
+	req.AddCookie(cookieOf(w, jwtTokenKey))
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	audited := nextEvent(t, ch)
+	assert.Equal(t, common.ImpersonatedRequest, audited.Type)
+	assert.Equal(t, target.ID, audited.User)
+	assert.Equal(t, admin.ID, audited.Actor.UUID)
+	assert.Equal(t, common.RequestData{Method: "GET", Path: "/profile", Status: http.StatusOK, IP: ""}, audited.Data)
+
+	res = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/impersonation", nil)
+	req.AddCookie(cookieOf(w, jwtTokenKey))
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), `"active":true`)
+	assert.Contains(t, res.Body.String(), admin.Email)
+}
+
+func TestImpersonationEndsWhenAdminSessionIsRevoked(t *testing.T) {
+	users := new(MockUserStorer)
+	sessions := new(MockSessionStorer)
+	ps := pubsub.New[string, common.Event](1)
+	h, m := newTestImpersonation(users, sessions, ps)
+	admin, target := testAdmin(), testUser()
+	sess, _, _ := session.NewSession(admin.ID, "127.0.0.1", "test", time.Hour)
+
+	users.On("ByID", target.ID).Return(target, nil)
+	w := impersonate(h, admin, sess, target.ID.String())
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	sess.RevokedAt.Valid = true
+	sessions.On("ByID", sess.ID).Return(sess, nil)
+	router := setupTestRouter()
+	router.GET("/profile", m.Validate, func(g *gin.Context) { g.Status(http.StatusOK) })
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/profile", nil)
+	req.AddCookie(cookieOf(w, jwtTokenKey))
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+}
+
+func TestImpersonationRejectsPrivilegedUsers(t *testing.T) {
+	users := new(MockUserStorer)
+	h, _ := newTestImpersonation(users, new(MockSessionStorer), nil)
+	admin, other := testAdmin(), testAdmin()
+	sess, _, _ := session.NewSession(admin.ID, "127.0.0.1", "test", time.Hour)
+
+	users.On("ByID", admin.ID).Return(admin, nil)
+	users.On("ByID", other.ID).Return(other, nil)
+
+	assert.Equal(t, http.StatusForbidden, impersonate(h, admin, sess, admin.ID.String()).Code)
+	assert.Equal(t, http.StatusForbidden, impersonate(h, admin, sess, other.ID.String()).Code)
+}
+
+func TestImpersonationRequiresSession(t *testing.T) {
+	users := new(MockUserStorer)
+	h, _ := newTestImpersonation(users, new(MockSessionStorer), nil)
+	target := testUser()
+
+	w := impersonate(h, testAdmin(), nil, target.ID.String())
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Nil(t, cookieOf(w, jwtTokenKey))
+	users.AssertNotCalled(t, "ByID", target.ID)
+}
+
+func TestValidateRecentRejectsImpersonation(t *testing.T) {
+	m := NewJWTHandler(new(MockUserStorer), new(MockSessionStorer), new(MockKeyStorer), new(MockOrganizationStorer), testKeySet, testConfig, nil)
+	sess, _, _ := session.NewSession(testAdmin().ID, "127.0.0.1", "test", time.Hour)
+	router := setupTestRouter()
+	router.POST("/identities", func(g *gin.Context) {
+		g.Set("session", sess)
+		g.Set(impersonationKey, &impersonation{admin: testAdmin()})
+	}, m.ValidateRecent, func(g *gin.Context) { g.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/identities", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestImpersonationCanNotCreateAPIKeys(t *testing.T) {
+	users := new(MockUserStorer)
+	sessions := new(MockSessionStorer)
+	keys := new(MockKeyStorer)
+	h, m := newTestImpersonation(users, sessions, pubsub.New[string, common.Event](1))
+	admin, target := testAdmin(), testUser()
+	sess, _, _ := session.NewSession(admin.ID, "127.0.0.1", "test", time.Hour)
+
+	users.On("ByID", target.ID).Return(target, nil)
+	users.On("ByID", admin.ID).Return(admin, nil)
+	sessions.On("ByID", sess.ID).Return(sess, nil)
+	w := impersonate(h, admin, sess, target.ID.String())
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	router := setupTestRouter()
+	router.POST("/account/api-keys", m.Validate, m.RejectImpersonation, apikey.NewHandler(keys).Create)
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/account/api-keys", bytes.NewBufferString(`{"name":"backdoor","scopes":["account"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(cookieOf(w, jwtTokenKey))
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusForbidden, res.Code)
+	keys.AssertNotCalled(t, "Store", mock.Anything)
+}
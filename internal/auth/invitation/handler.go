@@ -0,0 +1,220 @@
+package invitation
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inokone/go-micro-saas/internal/auth/organization"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+	"github.com/inokone/go-micro-saas/internal/mail"
+)
+
+var (
+	statusNotFound     = common.StatusMessage{Message: "Invitation not found!"}
+	statusUnknownError = common.StatusMessage{Message: "Unknown error, please contact administrator!"}
+)
+
+// Handler is a struct for web handles related to the invitations of the active organization of the user.
+type Handler struct {
+	invitations Storer
+	orgs        organization.Storer
+	sender      mail.Mailer
+	config      *common.AuthConfig
+}
+
+// NewHandler creates a new `Handler`, based on the invitation and organization persistence, the mail service and the
+// authentication configuration parameters.
+func NewHandler(invitations Storer, orgs organization.Storer, sender mail.Mailer, config *common.AuthConfig) *Handler {
+	return &Handler{
+		invitations: invitations,
+		orgs:        orgs,
+		sender:      sender,
+		config:      config,
+	}
+}
+
+// List is a method of `Handler`. Lists the invitations of the active organization of the current user.
+// @Summary Invitation list endpoint
+// @Schemes
+// @Description Returns the invitations of the active organization, the latest first
+// @Accept json
+// @Produce json
+// @Success 200 {array} invitation.View
+// @Failure 403 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /organization/invitations [get]
+func (h *Handler) List(g *gin.Context) {
+	invitations, err := h.invitations.ByOrganization(organization.Active(g).OrganizationID)
+	if err != nil {
+		log.WithError(err).Error("Failed to list invitations.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+
+	res := make([]View, len(invitations))
+	for i, inv := range invitations {
+		res[i] = inv.AsView()
+	}
+	g.JSON(http.StatusOK, res)
+}
+
+// Create is a method of `Handler`. Invites an email address to the active organization of the current user, and sends
+// the invitation in email. Only owners can invite owners.
+// @Summary Invitation create endpoint
+// @Schemes
+// @Description Invites an email address to the active organization with the role provided
+// @Accept json
+// @Produce json
+// @Param data body invitation.CreateRequest true "The email address to invite and the role of the new member"
+// @Success 201 {object} invitation.View
+// @Failure 400 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /organization/invitations [post]
+func (h *Handler) Create(g *gin.Context) {
+	var in CreateRequest
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+	active := organization.Active(g)
+	if in.Role == organization.RoleOwner && !active.Is(organization.RoleOwner) {
+		g.AbortWithStatusJSON(http.StatusForbidden, common.StatusMessage{Message: "Only owners can invite owners to the organization!"})
+		return
+	}
+
+	invitations, err := h.invitations.ByOrganization(active.OrganizationID)
+	if err != nil {
+		log.WithError(err).Error("Failed to list invitations.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	for _, inv := range invitations {
+		if inv.For(in.Email) && inv.Status() == Pending {
+			g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Email address is already invited!"})
+			return
+		}
+	}
+
+	inv := NewInvitation(active.OrganizationID, in.Email, in.Role, currentUser(g).ID, h.ttl())
+	if err = h.invitations.Store(inv); err != nil {
+		log.WithError(err).Error("Failed to store invitation.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	if err = h.send(g, inv); err != nil {
+		log.WithError(err).Error("Failed to send invitation.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Could not send invitation email."})
+		return
+	}
+	g.JSON(http.StatusCreated, inv.AsView())
+}
+
+// Resend is a method of `Handler`. Sends an open invitation of the active organization again with a new token, the
+// earlier token can not be used anymore.
+// @Summary Invitation resend endpoint
+// @Schemes
+// @Description Sends the invitation again with a new expiry
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the invitation"
+// @Success 200 {object} invitation.View
+// @Failure 400 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /organization/invitations/{id}/resend [put]
+func (h *Handler) Resend(g *gin.Context) {
+	inv, ok := h.open(g)
+	if !ok {
+		return
+	}
+	inv.Renew(h.ttl())
+	if err := h.invitations.Update(inv); err != nil {
+		log.WithError(err).Error("Failed to update invitation.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	if err := h.send(g, inv); err != nil {
+		log.WithError(err).Error("Failed to send invitation.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Could not send invitation email."})
+		return
+	}
+	g.JSON(http.StatusOK, inv.AsView())
+}
+
+// Revoke is a method of `Handler`. Withdraws an open invitation of the active organization.
+// @Summary Invitation revoke endpoint
+// @Schemes
+// @Description Revokes the invitation, it can not be accepted anymore
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the invitation"
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /organization/invitations/{id} [delete]
+func (h *Handler) Revoke(g *gin.Context) {
+	inv, ok := h.open(g)
+	if !ok {
+		return
+	}
+	inv.Revoke()
+	if err := h.invitations.Update(inv); err != nil {
+		log.WithError(err).Error("Failed to update invitation.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Invitation revoked!"})
+}
+
+// open loads the invitation with the ID provided as URL parameter, if it belongs to the active organization and is
+// not answered or revoked yet.
+func (h *Handler) open(g *gin.Context) (*Invitation, bool) {
+	id, err := uuid.Parse(g.Param("id"))
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, statusNotFound)
+		return nil, false
+	}
+	inv, err := h.invitations.ByID(id)
+	if err != nil || inv.OrganizationID != organization.Active(g).OrganizationID {
+		g.AbortWithStatusJSON(http.StatusNotFound, statusNotFound)
+		return nil, false
+	}
+	if !inv.IsOpen() {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Invitation is already " + inv.Status() + "!"})
+		return nil, false
+	}
+	return inv, true
+}
+
+func (h *Handler) send(g *gin.Context, inv *Invitation) error {
+	org, err := h.orgs.ByID(inv.OrganizationID)
+	if err != nil {
+		return err
+	}
+	usr := currentUser(g)
+	inviter := strings.TrimSpace(usr.FirstName + " " + usr.LastName)
+	if inviter == "" {
+		inviter = usr.Email
+	}
+	url := h.config.FrontendRoot + "/invitation?token=" + inv.Token
+	return h.sender.Invitation(inv.Email, org.Name, inviter, url)
+}
+
+func (h *Handler) ttl() time.Duration {
+	return time.Hour * time.Duration(h.config.InvitationTTL)
+}
+
+func currentUser(g *gin.Context) *user.User {
+	u, _ := g.Get("user")
+	return u.(*user.User)
+}
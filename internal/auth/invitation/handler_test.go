@@ -0,0 +1,151 @@
+package invitation
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/organization"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+var (
+	testUser = &user.User{
+		ID:        uuid.New(),
+		Email:     "test@example.com",
+		FirstName: "Test",
+		LastName:  "User",
+		Status:    user.Confirmed,
+		Source:    "credentials",
+		Enabled:   true,
+	}
+	testOrg    = organization.NewOrganization("Acme")
+	testConfig = &common.AuthConfig{FrontendRoot: "http://localhost:3000", InvitationTTL: 24}
+)
+
+func setupTestHandler() (*Handler, *MockStorer, *MockOrganizationStorer, *MockMailer) {
+	invitations := new(MockStorer)
+	orgs := new(MockOrganizationStorer)
+	mailer := new(MockMailer)
+	return NewHandler(invitations, orgs, mailer, testConfig), invitations, orgs, mailer
+}
+
+func asMember(handler gin.HandlerFunc, method string, route string, path string, role string, body any) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(method, route, func(g *gin.Context) {
+		g.Set("user", testUser)
+		organization.SetActive(g, organization.NewMembership(testOrg.ID, testUser.ID, role))
+	}, handler)
+
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreate201SendsInvitation(t *testing.T) {
+	h, invitations, orgs, mailer := setupTestHandler()
+
+	invitations.On("ByOrganization", testOrg.ID).Return([]Invitation{}, nil)
+	invitations.On("Store", mock.MatchedBy(func(inv *Invitation) bool {
+		return inv.OrganizationID == testOrg.ID && inv.Email == "new@example.com" && inv.Role == organization.RoleMember &&
+			inv.InvitedBy == testUser.ID && inv.ExpiresAt.After(time.Now().Add(23*time.Hour))
+	})).Return(nil)
+	orgs.On("ByID", testOrg.ID).Return(testOrg, nil)
+	mailer.On("Invitation", "new@example.com", "Acme", "Test User", mock.MatchedBy(func(url string) bool {
+		return len(url) > len("http://localhost:3000/invitation?token=")
+	})).Return(nil)
+
+	w := asMember(h.Create, http.MethodPost, "/invitations", "/invitations", organization.RoleAdmin,
+		CreateRequest{Email: "new@example.com", Role: organization.RoleMember})
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var res View
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, Pending, res.Status)
+	invitations.AssertExpectations(t)
+	mailer.AssertExpectations(t)
+}
+
+func TestCreate403WhenAdminInvitesOwner(t *testing.T) {
+	h, invitations, _, _ := setupTestHandler()
+
+	w := asMember(h.Create, http.MethodPost, "/invitations", "/invitations", organization.RoleAdmin,
+		CreateRequest{Email: "new@example.com", Role: organization.RoleOwner})
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	invitations.AssertNotCalled(t, "Store", mock.Anything)
+}
+
+func TestCreate400WhenAlreadyInvited(t *testing.T) {
+	h, invitations, _, _ := setupTestHandler()
+	pending := NewInvitation(testOrg.ID, "new@example.com", organization.RoleMember, testUser.ID, time.Hour)
+
+	invitations.On("ByOrganization", testOrg.ID).Return([]Invitation{*pending}, nil)
+
+	w := asMember(h.Create, http.MethodPost, "/invitations", "/invitations", organization.RoleOwner,
+		CreateRequest{Email: "New@example.com", Role: organization.RoleMember})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	invitations.AssertNotCalled(t, "Store", mock.Anything)
+}
+
+func TestResendRenewsToken(t *testing.T) {
+	h, invitations, orgs, mailer := setupTestHandler()
+	inv := NewInvitation(testOrg.ID, "new@example.com", organization.RoleMember, testUser.ID, -time.Hour)
+	token := inv.Token
+
+	invitations.On("ByID", inv.ID).Return(inv, nil)
+	invitations.On("Update", mock.MatchedBy(func(i *Invitation) bool {
+		return i.Token != token && i.Status() == Pending
+	})).Return(nil)
+	orgs.On("ByID", testOrg.ID).Return(testOrg, nil)
+	mailer.On("Invitation", "new@example.com", "Acme", "Test User", mock.Anything).Return(nil)
+
+	w := asMember(h.Resend, http.MethodPut, "/invitations/:id/resend", "/invitations/"+inv.ID.String()+"/resend",
+		organization.RoleAdmin, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	invitations.AssertExpectations(t)
+	mailer.AssertExpectations(t)
+}
+
+func TestRevoke404ForInvitationOfOtherOrganization(t *testing.T) {
+	h, invitations, _, _ := setupTestHandler()
+	inv := NewInvitation(uuid.New(), "new@example.com", organization.RoleMember, testUser.ID, time.Hour)
+
+	invitations.On("ByID", inv.ID).Return(inv, nil)
+
+	w := asMember(h.Revoke, http.MethodDelete, "/invitations/:id", "/invitations/"+inv.ID.String(), organization.RoleOwner, nil)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	invitations.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestRevoke400ForDeclinedInvitation(t *testing.T) {
+	h, invitations, _, _ := setupTestHandler()
+	inv := NewInvitation(testOrg.ID, "new@example.com", organization.RoleMember, testUser.ID, time.Hour)
+	inv.Decline()
+
+	invitations.On("ByID", inv.ID).Return(inv, nil)
+
+	w := asMember(h.Revoke, http.MethodDelete, "/invitations/:id", "/invitations/"+inv.ID.String(), organization.RoleOwner, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	invitations.AssertNotCalled(t, "Update", mock.Anything)
+}
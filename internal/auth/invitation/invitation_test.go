@@ -0,0 +1,196 @@
+package invitation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/organization"
+	"github.com/inokone/go-micro-saas/internal/common"
+	"github.com/inokone/go-micro-saas/internal/mail"
+)
+
+// MockStorer is a mock implementation of the Storer interface
+type MockStorer struct {
+	mock.Mock
+}
+
+func (m *MockStorer) Store(inv *Invitation) error {
+	args := m.Called(inv)
+	return args.Error(0)
+}
+
+func (m *MockStorer) Update(inv *Invitation) error {
+	args := m.Called(inv)
+	return args.Error(0)
+}
+
+func (m *MockStorer) ByID(id uuid.UUID) (*Invitation, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Invitation), args.Error(1)
+}
+
+func (m *MockStorer) ByToken(token string) (*Invitation, error) {
+	args := m.Called(token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Invitation), args.Error(1)
+}
+
+func (m *MockStorer) ByOrganization(organizationID uuid.UUID) ([]Invitation, error) {
+	args := m.Called(organizationID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Invitation), args.Error(1)
+}
+
+func (m *MockStorer) Accept(inv *Invitation, userID uuid.UUID) error {
+	args := m.Called(inv, userID)
+	return args.Error(0)
+}
+
+// MockOrganizationStorer is a mock implementation of the organization.Storer interface
+type MockOrganizationStorer struct {
+	mock.Mock
+}
+
+func (m *MockOrganizationStorer) Store(org *organization.Organization, owner uuid.UUID) error {
+	args := m.Called(org, owner)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationStorer) Rename(id uuid.UUID, name string) error {
+	args := m.Called(id, name)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationStorer) ByID(id uuid.UUID) (*organization.Organization, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*organization.Organization), args.Error(1)
+}
+
+func (m *MockOrganizationStorer) ByUser(userID uuid.UUID) ([]organization.Affiliation, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]organization.Affiliation), args.Error(1)
+}
+
+func (m *MockOrganizationStorer) Membership(id uuid.UUID, userID uuid.UUID) (*organization.Membership, error) {
+	args := m.Called(id, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*organization.Membership), args.Error(1)
+}
+
+func (m *MockOrganizationStorer) Members(id uuid.UUID) ([]organization.Member, error) {
+	args := m.Called(id)
+	return args.Get(0).([]organization.Member), args.Error(1)
+}
+
+func (m *MockOrganizationStorer) AddMember(membership *organization.Membership) error {
+	args := m.Called(membership)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationStorer) SetRole(id uuid.UUID, userID uuid.UUID, role string) error {
+	args := m.Called(id, userID, role)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationStorer) RemoveMember(id uuid.UUID, userID uuid.UUID) error {
+	args := m.Called(id, userID)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationStorer) CountOwners(id uuid.UUID) (int, error) {
+	args := m.Called(id)
+	return args.Int(0), args.Error(1)
+}
+
+// MockMailer is a mock implementation of the mail.Mailer interface
+type MockMailer struct {
+	mock.Mock
+}
+
+func (m *MockMailer) Send(r *mail.SendRequest) error {
+	args := m.Called(r)
+	return args.Error(0)
+}
+
+func (m *MockMailer) EmailConfirmation(recipient string, confirmationURL string) error {
+	args := m.Called(recipient, confirmationURL)
+	return args.Error(0)
+}
+
+func (m *MockMailer) PasswordReset(recipient string, resetURL string) error {
+	args := m.Called(recipient, resetURL)
+	return args.Error(0)
+}
+
+func (m *MockMailer) MagicLink(recipient string, signinURL string) error {
+	args := m.Called(recipient, signinURL)
+	return args.Error(0)
+}
+
+func (m *MockMailer) Invitation(recipient string, organization string, inviter string, invitationURL string) error {
+	args := m.Called(recipient, organization, inviter, invitationURL)
+	return args.Error(0)
+}
+
+func (m *MockMailer) Invoice(userID uuid.UUID, recipient string, number string, invoicesURL string, documents ...mail.Attachment) error {
+	args := m.Called(userID, recipient, number, invoicesURL, documents)
+	return args.Error(0)
+}
+
+func (m *MockMailer) Lockout(userID uuid.UUID, recipient string, ip string, until time.Time, unlockURL string) error {
+	args := m.Called(userID, recipient, ip, until, unlockURL)
+	return args.Error(0)
+}
+
+func (m *MockMailer) SecurityAlert(userID uuid.UUID, alert string, data common.SecurityData, at time.Time) error {
+	args := m.Called(userID, alert, data, at)
+	return args.Error(0)
+}
+
+func TestStatusFollowsLifecycleOfInvitation(t *testing.T) {
+	inv := NewInvitation(uuid.New(), "Test@Example.com", organization.RoleMember, uuid.New(), time.Hour)
+	assert.Equal(t, "test@example.com", inv.Email)
+	assert.True(t, inv.For("TEST@example.com"))
+	assert.Equal(t, Pending, inv.Status())
+	assert.True(t, inv.IsOpen())
+
+	inv.ExpiresAt = time.Now().Add(-time.Minute)
+	assert.Equal(t, Expired, inv.Status())
+	assert.True(t, inv.IsOpen())
+
+	token := inv.Token
+	inv.Renew(time.Hour)
+	assert.NotEqual(t, token, inv.Token)
+	assert.Equal(t, Pending, inv.Status())
+
+	inv.Revoke()
+	assert.Empty(t, inv.Token)
+	assert.Equal(t, Revoked, inv.Status())
+	assert.False(t, inv.IsOpen())
+}
+
+func TestDeclineClearsToken(t *testing.T) {
+	inv := NewInvitation(uuid.New(), "test@example.com", organization.RoleAdmin, uuid.New(), time.Hour)
+
+	inv.Decline()
+
+	assert.Empty(t, inv.Token)
+	assert.Equal(t, Declined, inv.Status())
+	assert.False(t, inv.IsOpen())
+}
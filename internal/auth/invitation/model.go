@@ -0,0 +1,142 @@
+package invitation
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+)
+
+const (
+	// Pending is the status of an invitation waiting for the answer of the invitee.
+	Pending = "pending"
+	// Accepted is the status of an invitation the invitee joined the organization with.
+	Accepted = "accepted"
+	// Declined is the status of an invitation turned down by the invitee.
+	Declined = "declined"
+	// Revoked is the status of an invitation withdrawn by the organization.
+	Revoked = "revoked"
+	// Expired is the status of an invitation not answered in time.
+	Expired = "expired"
+)
+
+// ErrNotOpen is the error of answering an invitation which was answered or revoked already.
+var ErrNotOpen = errors.New("invitation is not open")
+
+// Invitation is a struct representing an invitation of an email address to an organization for database storage.
+type Invitation struct {
+	ID             uuid.UUID `db:"invitation_id"`
+	OrganizationID uuid.UUID `db:"organization_id"`
+	Email          string    `db:"email"`
+	Role           string    `db:"role"`
+	Token          string    `db:"token"`
+	InvitedBy      uuid.UUID `db:"invited_by"`
+	CreatedAt      time.Time `db:"created_at"`
+	ExpiresAt      time.Time `db:"expires_at"`
+	AcceptedAt     null.Time `db:"accepted_at"`
+	DeclinedAt     null.Time `db:"declined_at"`
+	RevokedAt      null.Time `db:"revoked_at"`
+}
+
+// NewInvitation creates a new `Invitation` of the email address to the organization with the role provided, valid for
+// the duration in parameter.
+func NewInvitation(organizationID uuid.UUID, email string, role string, invitedBy uuid.UUID, ttl time.Duration) *Invitation {
+	now := time.Now()
+	return &Invitation{
+		ID:             uuid.New(),
+		OrganizationID: organizationID,
+		Email:          strings.ToLower(email),
+		Role:           role,
+		Token:          uuid.New().String(),
+		InvitedBy:      invitedBy,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(ttl),
+	}
+}
+
+// Status is a method of `Invitation` returning the status of the invitation.
+func (i *Invitation) Status() string {
+	switch {
+	case i.AcceptedAt.Valid:
+		return Accepted
+	case i.DeclinedAt.Valid:
+		return Declined
+	case i.RevokedAt.Valid:
+		return Revoked
+	case i.ExpiresAt.Before(time.Now()):
+		return Expired
+	default:
+		return Pending
+	}
+}
+
+// IsOpen is a method of `Invitation` returning whether the invitation is not answered or revoked yet, so it can be
+// resent or revoked.
+func (i *Invitation) IsOpen() bool {
+	s := i.Status()
+	return s == Pending || s == Expired
+}
+
+// Renew is a method of `Invitation` issuing a new token for the invitation, valid for the duration in parameter. The
+// earlier token can not be used anymore.
+func (i *Invitation) Renew(ttl time.Duration) {
+	i.Token = uuid.New().String()
+	i.ExpiresAt = time.Now().Add(ttl)
+}
+
+// Decline is a method of `Invitation` marking the invitation as declined, the token can not be used anymore.
+func (i *Invitation) Decline() {
+	i.Token = ""
+	i.DeclinedAt = null.TimeFrom(time.Now())
+}
+
+// Revoke is a method of `Invitation` marking the invitation as revoked, the token can not be used anymore.
+func (i *Invitation) Revoke() {
+	i.Token = ""
+	i.RevokedAt = null.TimeFrom(time.Now())
+}
+
+// For is a method of `Invitation` returning whether the invitation was sent to the email address in parameter.
+func (i *Invitation) For(email string) bool {
+	return strings.EqualFold(i.Email, email)
+}
+
+// AsView is a method of the `Invitation` struct. It converts an `Invitation` object into a `View` object.
+func (i *Invitation) AsView() View {
+	return View{
+		ID:      i.ID.String(),
+		Email:   i.Email,
+		Role:    i.Role,
+		Status:  i.Status(),
+		Created: int(i.CreatedAt.Unix()),
+		Expires: int(i.ExpiresAt.Unix()),
+	}
+}
+
+// View is the JSON representation of an invitation of an organization.
+type View struct {
+	ID      string `json:"id"`
+	Email   string `json:"email"`
+	Role    string `json:"role"`
+	Status  string `json:"status"`
+	Created int    `json:"created"`
+	Expires int    `json:"expires"`
+}
+
+// CreateRequest is a struct for the message body of inviting an email address to the active organization.
+type CreateRequest struct {
+	Email string `json:"email" binding:"required,email,max=255"`
+	Role  string `json:"role" binding:"required,oneof=owner admin member"`
+}
+
+// Storer is the interface for `Invitation` persistence
+type Storer interface {
+	Store(inv *Invitation) error
+	Update(inv *Invitation) error
+	ByID(id uuid.UUID) (*Invitation, error)
+	ByToken(token string) (*Invitation, error)
+	ByOrganization(organizationID uuid.UUID) ([]Invitation, error)
+	Accept(inv *Invitation, userID uuid.UUID) error
+}
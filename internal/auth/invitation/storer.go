@@ -0,0 +1,114 @@
+package invitation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+	"github.com/jmoiron/sqlx"
+)
+
+const selectInvitations = `SELECT invitation_id, organization_id, email, role, token, invited_by, created_at, expires_at,
+	accepted_at, declined_at, revoked_at FROM microsaas.invitations`
+
+// PostgresStorer is the `Storer` implementation based on sqlx library.
+type PostgresStorer struct {
+	db *sqlx.DB
+}
+
+// NewPostgresStorer creates a new `PostgresStorer` instance based on the sqlx library.
+func NewPostgresStorer(db *sqlx.DB) *PostgresStorer {
+	return &PostgresStorer{
+		db: db,
+	}
+}
+
+// Store is a method of the `PostgresStorer` struct. Takes an `Invitation` as parameter and persists it.
+func (s *PostgresStorer) Store(inv *Invitation) error {
+	query := `INSERT INTO microsaas.invitations (invitation_id, organization_id, email, role, token, invited_by, created_at,
+		expires_at, accepted_at, declined_at, revoked_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := s.db.Exec(query, inv.ID, inv.OrganizationID, inv.Email, inv.Role, inv.Token, inv.InvitedBy, inv.CreatedAt,
+		inv.ExpiresAt, inv.AcceptedAt, inv.DeclinedAt, inv.RevokedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store invitation: %w", err)
+	}
+	return nil
+}
+
+// Update is a method of the `PostgresStorer` struct. Takes an `Invitation` as parameter and updates its token and
+// status.
+func (s *PostgresStorer) Update(inv *Invitation) error {
+	query := `UPDATE microsaas.invitations SET token = $1, expires_at = $2, accepted_at = $3, declined_at = $4,
+		revoked_at = $5 WHERE invitation_id = $6`
+	if _, err := s.db.Exec(query, inv.Token, inv.ExpiresAt, inv.AcceptedAt, inv.DeclinedAt, inv.RevokedAt, inv.ID); err != nil {
+		return fmt.Errorf("failed to update invitation: %w", err)
+	}
+	return nil
+}
+
+// ByID is a method of the `PostgresStorer` struct. Takes an UUID as parameter to load an `Invitation` from persistence.
+func (s *PostgresStorer) ByID(id uuid.UUID) (*Invitation, error) {
+	var inv Invitation
+	if err := s.db.Get(&inv, selectInvitations+` WHERE invitation_id = $1`, id); err != nil {
+		return nil, fmt.Errorf("failed to get invitation by ID: %w", err)
+	}
+	return &inv, nil
+}
+
+// ByToken is a method of the `PostgresStorer` struct. Takes a token as parameter to load the `Invitation` it was sent
+// with. Answered and revoked invitations have no token, they can not be loaded this way.
+func (s *PostgresStorer) ByToken(token string) (*Invitation, error) {
+	var inv Invitation
+	if err := s.db.Get(&inv, selectInvitations+` WHERE token = $1 AND token <> ''`, token); err != nil {
+		return nil, fmt.Errorf("failed to get invitation by token: %w", err)
+	}
+	return &inv, nil
+}
+
+// ByOrganization is a method of the `PostgresStorer` struct. Loads the invitations of an organization, the latest first.
+func (s *PostgresStorer) ByOrganization(organizationID uuid.UUID) ([]Invitation, error) {
+	var res []Invitation
+	if err := s.db.Select(&res, selectInvitations+` WHERE organization_id = $1 ORDER BY created_at DESC`, organizationID); err != nil {
+		return nil, fmt.Errorf("failed to get invitations of organization: %w", err)
+	}
+	return res, nil
+}
+
+// Accept is a method of the `PostgresStorer` struct. Adds the user in parameter to the organization of the invitation
+// with the role of the invitation, and marks the invitation as accepted. A user already member of the organization
+// keeps the current role. Returns `ErrNotOpen` without adding the member, if the invitation was answered or revoked
+// meanwhile.
+func (s *PostgresStorer) Accept(inv *Invitation, userID uuid.UUID) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	query := `INSERT INTO microsaas.memberships (organization_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING`
+	if _, err = tx.Exec(query, inv.OrganizationID, userID, inv.Role, now); err != nil {
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+	query = `UPDATE microsaas.invitations SET token = '', accepted_at = $1 WHERE invitation_id = $2
+		AND token <> '' AND accepted_at IS NULL AND declined_at IS NULL AND revoked_at IS NULL`
+	res, err := tx.Exec(query, now, inv.ID)
+	if err != nil {
+		return fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotOpen
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	inv.Token = ""
+	inv.AcceptedAt = null.TimeFrom(now)
+	return nil
+}
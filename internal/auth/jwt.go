@@ -1,74 +1,404 @@
 package auth
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/cskr/pubsub/v2"
 	"github.com/gin-gonic/gin"
 	jwt "github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/inokone/go-micro-saas/internal/auth/apikey"
+	"github.com/inokone/go-micro-saas/internal/auth/organization"
+	"github.com/inokone/go-micro-saas/internal/auth/role"
+	"github.com/inokone/go-micro-saas/internal/auth/session"
 	"github.com/inokone/go-micro-saas/internal/auth/user"
 	"github.com/inokone/go-micro-saas/internal/common"
 )
 
+const (
+	// lastSeenResolution is the granularity of recording the last activity of a session, to spare a write on every request.
+	lastSeenResolution = time.Minute
+	// accessAudience is the audience of access tokens, the only tokens accepted for authentication.
+	accessAudience = "access"
+	// challengeAudience is the audience of tokens proving the first factor of a sign in, they can not be used for authentication.
+	challengeAudience = "two-factor"
+	challengeTTL      = 5 * time.Minute
+	// bearerPrefix is the prefix of API keys in the authorization header.
+	bearerPrefix = "Bearer "
+	// scopeKey is the key of the scope an API key needs to reach the route group of the request.
+	scopeKey = "scope"
+	// reauthWindow is how long after signing in a session can change the sign in methods of the account.
+	reauthWindow = 10 * time.Minute
+	// organizationKey is the key of the active organization of the access token in the Gin context.
+	organizationKey = "organization"
+)
+
 var unatuhorized = common.StatusMessage{Message: "Unauthorized!"}
 
+var statusForbidden = common.StatusMessage{Message: "Not allowed!"}
+
+// claims is the content of the access token, the subject is the user, the session is the server-side login it belongs to.
+// When an administrator impersonates the user, the actor is the administrator and the session is the administrator's.
+// The organization is the one the user is working in, as selected for the session. The issuer is the backend root and
+// the audience is "access", other tokens signed with the same keys have a different audience.
+type claims struct {
+	jwt.RegisteredClaims
+	SessionID    string `json:"sid"`
+	Actor        *actor `json:"act,omitempty"`
+	Organization string `json:"org,omitempty"`
+}
+
 // JWTHandler is a struct for issuing and validating JWT tokens.
 type JWTHandler struct {
-	conf  *common.AuthConfig
-	users user.Storer
+	conf     *common.AuthConfig
+	users    user.Storer
+	sessions session.Storer
+	keys     apikey.Storer
+	orgs     organization.Storer
+	keySet   *KeySet
+	ps       *pubsub.PubSub[string, common.Event]
 }
 
-// NewJWTHandler creates a new `JWTHandler`.
-func NewJWTHandler(users user.Storer, conf *common.AuthConfig) *JWTHandler {
+// NewJWTHandler creates a new `JWTHandler`, signing and verifying the tokens with the `KeySet` provided. Requests made
+// while impersonating a user are published to the history topic, sign ins from unfamiliar devices as security alerts.
+func NewJWTHandler(users user.Storer, sessions session.Storer, keys apikey.Storer, orgs organization.Storer, keySet *KeySet, conf *common.AuthConfig, ps *pubsub.PubSub[string, common.Event]) *JWTHandler {
 	return &JWTHandler{
-		conf:  conf,
-		users: users,
+		conf:     conf,
+		users:    users,
+		sessions: sessions,
+		keys:     keys,
+		orgs:     orgs,
+		keySet:   keySet,
+		ps:       ps,
 	}
 }
 
-// Issue is a method of `JWTHandler`. Issues a new authentication token for a user ID into the Gin context provided as parameters.
-// The JWT token is set as a http-only cookie. The JWTSecure option of the AuthConfig controle "secure" option for the cookie.
-// For production deployment this must be set to true.
-func (h *JWTHandler) Issue(g *gin.Context, userID string) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": userID,
-		"exp": time.Now().Add(time.Hour * time.Duration(h.conf.JWTExp)).Unix(),
-	})
-	tokenString, err := token.SignedString([]byte(h.conf.JWTSecret))
+// Issue is a method of `JWTHandler`. Starts a new session for a user ID and issues the authentication tokens for it into the
+// Gin context provided as parameters. A short-lived access token and a rotating refresh token are set as http-only cookies.
+// The JWTSecure option of the AuthConfig controls "secure" option for the cookies. For production deployment this must be set to true.
+// On failure the request is aborted and the error is returned.
+func (h *JWTHandler) Issue(g *gin.Context, userID string) error {
+	id, err := uuid.Parse(userID)
 	if err != nil {
-		log.WithError(err).WithField("User", userID).Warn("JWT token could not be signed!")
+		g.AbortWithStatusJSON(http.StatusBadRequest, statusBadRequest)
+		return err
+	}
+
+	sess, refresh, err := session.NewSession(id, g.ClientIP(), g.Request.UserAgent(), h.sessionTTL())
+	if err != nil {
+		log.WithError(err).WithField("User", userID).Warn("Refresh token could not be generated!")
 		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{
-			Message: "Failed to sign JWT token, please contact administrator!",
+			Message: "Failed to create session, please contact administrator!",
 		})
+		return err
+	}
+	h.alertUnfamiliar(sess)
+	if err = h.sessions.Store(sess); err != nil {
+		log.WithError(err).WithField("User", userID).Warn("Session could not be stored!")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{
+			Message: "Failed to create session, please contact administrator!",
+		})
+		return err
+	}
+	return h.setCookies(g, sess, refresh)
+}
+
+// alertUnfamiliar publishes a security alert of a new session from an IP address or a device the user has not signed in
+// from before. Sign in does not fail on errors, the alert is only skipped.
+func (h *JWTHandler) alertUnfamiliar(sess *session.Session) {
+	familiar, err := h.sessions.Familiar(sess.UserID, sess.IP, sess.UserAgent)
+	if err != nil {
+		log.WithError(err).WithField("User", sess.UserID.String()).Warn("Failed to check familiarity of sign in.")
 		return
 	}
+	if familiar {
+		return
+	}
+	usr, err := h.users.ByID(sess.UserID)
+	if err != nil {
+		log.WithError(err).WithField("User", sess.UserID.String()).Warn("Failed to collect user of sign in.")
+		return
+	}
+	h.ps.Pub(common.Event{
+		ID:   uuid.New(),
+		Type: common.NewSignin,
+		Time: sess.CreatedAt,
+		Data: common.SecurityData{Email: usr.Email, IP: sess.IP, Device: session.Device(sess.UserAgent)},
+		User: usr.ID,
+	}, common.HistoryTopic, common.NotificationTopic)
+}
+
+// Refresh is a method of `JWTHandler`. Rotates the refresh token in the Gin context provided as a parameter and issues a
+// new access token for its session. Presenting a refresh token which was already rotated revokes the whole session, as
+// it means the token was stolen. Tokens never issued for the session are only rejected.
+func (h *JWTHandler) Refresh(g *gin.Context) error {
+	token, err := g.Cookie(refreshTokenKey)
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
+		return err
+	}
 
-	g.SetSameSite(http.SameSiteLaxMode)
-	g.SetCookie(jwtTokenKey, tokenString, 3600*24*30, "", "", h.conf.JWTSecure, true) // Max live time is 30 days
+	sess, err := h.sessionOf(token)
+	if err != nil {
+		h.clearCookies(g)
+		g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
+		return err
+	}
+
+	if sess.Reused(token) {
+		return h.revokeReused(g, sess)
+	}
+	if !sess.Matches(token) {
+		h.clearCookies(g)
+		g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
+		return errors.New("invalid refresh token")
+	}
+
+	usr, err := h.users.ByID(sess.UserID)
+	if err != nil || !usr.Enabled {
+		h.clearCookies(g)
+		g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
+		return errors.New("user of the session is not available")
+	}
+
+	previous := sess.RefreshHash
+	refresh, err := sess.Rotate()
+	if err != nil {
+		log.WithError(err).WithField("Session", sess.ID.String()).Warn("Refresh token could not be generated!")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{
+			Message: "Failed to refresh session, please contact administrator!",
+		})
+		return err
+	}
+	sess.UsedBy(g.ClientIP(), g.Request.UserAgent())
+	rotated, err := h.sessions.Rotate(sess, previous)
+	if err != nil {
+		log.WithError(err).WithField("Session", sess.ID.String()).Warn("Session could not be updated!")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{
+			Message: "Failed to refresh session, please contact administrator!",
+		})
+		return err
+	}
+	if !rotated {
+		return h.revokeReused(g, sess)
+	}
+	return h.setCookies(g, sess, refresh)
+}
+
+// revokeReused revokes the session of a refresh token used after it was rotated, by the same token used concurrently
+// or later, as either use may be of a stolen token.
+func (h *JWTHandler) revokeReused(g *gin.Context, sess *session.Session) error {
+	log.WithField("Session", sess.ID.String()).WithField("User", sess.UserID.String()).Warn("Refresh token reuse detected, revoking session.")
+	if err := h.sessions.Revoke(sess.ID); err != nil {
+		log.WithError(err).Error("Failed to revoke session.")
+	}
+	h.clearCookies(g)
+	g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
+	return errors.New("refresh token reuse")
 }
 
-// Validate is a method of `JWTHandler`. Validates the authentication token in the Gin context provided as a parameter.
+// Revoke is a method of `JWTHandler`. Revokes the session of the refresh token in the Gin context provided as a parameter
+// on the server side, and clears the authentication cookies.
+func (h *JWTHandler) Revoke(g *gin.Context) {
+	defer h.clearCookies(g)
+
+	token, err := g.Cookie(refreshTokenKey)
+	if err != nil {
+		return
+	}
+	sess, err := h.sessionOf(token)
+	if err != nil || !sess.Matches(token) {
+		return
+	}
+	if err = h.sessions.Revoke(sess.ID); err != nil {
+		log.WithError(err).WithField("Session", sess.ID.String()).Error("Failed to revoke session.")
+	}
+}
+
+// IssueChallenge is a method of `JWTHandler`. Issues a short-lived token for a user who passed the first factor of signing in,
+// to be exchanged for a session with a second factor.
+func (h *JWTHandler) IssueChallenge(userID uuid.UUID) (string, error) {
+	now := time.Now()
+	return h.keySet.Sign(jwt.RegisteredClaims{
+		Subject:   userID.String(),
+		Audience:  jwt.ClaimStrings{challengeAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(challengeTTL)),
+	})
+}
+
+// ParseChallenge is a method of `JWTHandler`. Validates a token issued by `IssueChallenge` and returns the user ID of it.
+func (h *JWTHandler) ParseChallenge(tokenString string) (uuid.UUID, error) {
+	var c jwt.RegisteredClaims
+	token, err := jwt.ParseWithClaims(tokenString, &c, h.keySet.Keyfunc, jwt.WithExpirationRequired(), jwt.WithAudience(challengeAudience))
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !token.Valid {
+		return uuid.Nil, errors.New("invalid challenge token")
+	}
+	return uuid.Parse(c.Subject)
+}
+
+// Scope is a method of `JWTHandler`. Returns a middleware marking the routes of a group with the scope an API key needs
+// to reach them. Routes without a scope can not be reached with API keys at all.
+func (h *JWTHandler) Scope(scope string) gin.HandlerFunc {
+	return func(g *gin.Context) {
+		g.Set(scopeKey, scope)
+		g.Next()
+	}
+}
+
+// Validate is a method of `JWTHandler`. Validates the authentication token or API key in the Gin context provided as a parameter.
 func (h *JWTHandler) Validate(g *gin.Context) {
-	h.validateUser(g)
+	if h.validateUser(g) == nil {
+		return
+	}
+	defer h.audit(g)
+	g.Next()
+}
+
+// RejectImpersonation is a method of `JWTHandler`. Rejects requests made by an administrator on behalf of a user, so
+// credentials and sessions of the user can not be created or revoked while impersonating. Must follow `Validate`.
+func (h *JWTHandler) RejectImpersonation(g *gin.Context) {
+	if impersonationOf(g) != nil {
+		g.AbortWithStatusJSON(http.StatusForbidden, statusImpersonating)
+		return
+	}
+	g.Next()
+}
+
+// ValidateRecent is a method of `JWTHandler`. Validates that the session of the request was signed in recently, so
+// sensitive settings can only be changed right after re-authentication. Must follow `Validate`.
+func (h *JWTHandler) ValidateRecent(g *gin.Context) {
+	if _, ok := g.Get(impersonationKey); ok {
+		g.AbortWithStatusJSON(http.StatusForbidden, statusImpersonating)
+		return
+	}
+	sess, ok := g.Get("session")
+	if !ok || time.Since(sess.(*session.Session).CreatedAt) > reauthWindow {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, common.StatusMessage{Message: "Please sign in again to continue!"})
+		return
+	}
 	g.Next()
 }
 
-// ValidateAdmin is a method of `JWTHandler`. Validates the authentication token and administrator authorization in
-// the Gin context provided as a parameter.
-func (h *JWTHandler) ValidateAdmin(g *gin.Context) {
+// RequirePermission is a method of `JWTHandler`. Returns a middleware validating the authentication token or API key in
+// the Gin context and that the role of the user is granted all of the permissions provided.
+func (h *JWTHandler) RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(g *gin.Context) {
+		user := h.validateUser(g)
+		if user == nil {
+			return
+		}
+		defer h.audit(g)
+		for _, p := range permissions {
+			if !user.Can(p) {
+				g.AbortWithStatusJSON(http.StatusForbidden, statusForbidden)
+				return
+			}
+		}
+		g.Next()
+	}
+}
+
+// Organization is a method of `JWTHandler`. Validates the authentication token or API key in the Gin context provided as a
+// parameter, and resolves the membership of the user in the active organization, if the user has any.
+func (h *JWTHandler) Organization(g *gin.Context) {
 	user := h.validateUser(g)
-	if !user.Role.IsAdmin() {
-		g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
+	if user == nil {
+		return
+	}
+	defer h.audit(g)
+	if _, ok := h.activate(g, user); !ok {
 		return
 	}
 	g.Next()
 }
 
+// RequireOrganization is a method of `JWTHandler`. Returns a middleware validating the authentication token or API key
+// in the Gin context, and that the user is a member of the active organization with one of the roles provided. Any
+// member passes if no roles are provided.
+func (h *JWTHandler) RequireOrganization(roles ...string) gin.HandlerFunc {
+	return func(g *gin.Context) {
+		user := h.validateUser(g)
+		if user == nil {
+			return
+		}
+		defer h.audit(g)
+		m, ok := h.activate(g, user)
+		if !ok {
+			return
+		}
+		if m == nil {
+			g.AbortWithStatusJSON(http.StatusForbidden, common.StatusMessage{Message: "Not a member of any organization!"})
+			return
+		}
+		if len(roles) > 0 && !m.Is(roles...) {
+			g.AbortWithStatusJSON(http.StatusForbidden, statusForbidden)
+			return
+		}
+		g.Next()
+	}
+}
+
+// SwitchOrganization is a method of `JWTHandler`. Makes the organization provided the active one of the session in the
+// Gin context, and issues a new access token for it. Requests without a session, e.g. of API keys, are rejected.
+func (h *JWTHandler) SwitchOrganization(g *gin.Context, id uuid.UUID) error {
+	sess, _ := g.Get("session")
+	s, ok := sess.(*session.Session)
+	if !ok {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
+		return errors.New("no session to switch organization of")
+	}
+	s.OrganizationID = uuid.NullUUID{UUID: id, Valid: true}
+	if err := h.sessions.Update(s); err != nil {
+		log.WithError(err).WithField("Session", s.ID.String()).Warn("Session could not be updated!")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{
+			Message: "Failed to switch organization, please contact administrator!",
+		})
+		return err
+	}
+	return h.setAccessCookie(g, s.UserID, s, nil, h.accessTTL())
+}
+
+// activate resolves the membership of the user in the organization of the access token, falling back to the oldest
+// membership of the user, e.g. for API keys or when the user left the organization.
+func (h *JWTHandler) activate(g *gin.Context, user *user.User) (*organization.Membership, bool) {
+	if id, ok := g.Get(organizationKey); ok {
+		if m, err := h.orgs.Membership(id.(uuid.UUID), user.ID); err == nil {
+			organization.SetActive(g, m)
+			return m, true
+		}
+	}
+	affiliations, err := h.orgs.ByUser(user.ID)
+	if err != nil {
+		log.WithError(err).WithField("User", user.ID.String()).Error("Failed to collect organizations.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return nil, false
+	}
+	if len(affiliations) == 0 {
+		return nil, true
+	}
+	m := &organization.Membership{
+		OrganizationID: affiliations[0].ID,
+		UserID:         user.ID,
+		Role:           affiliations[0].Role,
+	}
+	organization.SetActive(g, m)
+	return m, true
+}
+
 func (h *JWTHandler) validateUser(g *gin.Context) *user.User {
+	if token, found := strings.CutPrefix(g.GetHeader("Authorization"), bearerPrefix); found {
+		return h.validateKey(g, token)
+	}
+
 	log.Debug("Validating JWT token...")
 	tokenString, err := g.Cookie(jwtTokenKey)
 	if err != nil {
@@ -76,42 +406,173 @@ func (h *JWTHandler) validateUser(g *gin.Context) *user.User {
 		return nil
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, g.AbortWithError(http.StatusBadRequest, fmt.Errorf("unexpected signing method: %v", token.Header["alg"]))
-		}
-		return []byte(h.conf.JWTSecret), nil
-	})
+	var c claims
+	token, err := jwt.ParseWithClaims(tokenString, &c, h.keySet.Keyfunc, jwt.WithExpirationRequired(),
+		jwt.WithAudience(accessAudience), jwt.WithIssuer(h.conf.BackendRoot))
+	if err != nil || !token.Valid {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
+		return nil
+	}
+
+	userID, err := uuid.Parse(c.Subject)
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
+		return nil
+	}
+
+	sessionID, err := uuid.Parse(c.SessionID)
 	if err != nil {
 		g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
 		return nil
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
+	// The session of an impersonation token belongs to the administrator.
+	owner := userID
+	if c.Actor != nil {
+		if owner, err = uuid.Parse(c.Actor.Subject); err != nil {
+			g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
+			return nil
+		}
+	}
+
+	sess, err := h.sessions.ByID(sessionID)
+	if err != nil || !sess.IsActive() || sess.UserID != owner {
 		g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
 		return nil
 	}
 
-	expired := float64(time.Now().Unix()) > claims["exp"].(float64)
-	if expired {
+	user, err := h.users.ByID(userID)
+	if err != nil || user.Email == "" || !user.Enabled {
 		g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
 		return nil
 	}
 
-	userID := claims["sub"]
-	uuid, err := uuid.Parse(userID.(string))
+	if c.Actor != nil {
+		admin, err := h.users.ByID(owner)
+		if err != nil || !admin.Enabled || !admin.Can(role.PermUsersImpersonate) {
+			g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
+			return nil
+		}
+		g.Set(impersonationKey, &impersonation{admin: admin, expiresAt: c.ExpiresAt.Time})
+		session.SetSubject(g, user.ID)
+	}
+
+	if org, err := uuid.Parse(c.Organization); err == nil {
+		g.Set(organizationKey, org)
+	}
+
+	if time.Since(sess.LastSeenAt) > lastSeenResolution {
+		sess.LastSeenAt = time.Now()
+		if err = h.sessions.Touch(sess.ID, sess.LastSeenAt); err != nil {
+			log.WithError(err).WithField("Session", sess.ID.String()).Warn("Failed to record session activity.")
+		}
+	}
+
+	g.Set("user", user)
+	g.Set("session", sess)
+	return user
+}
+
+func (h *JWTHandler) validateKey(g *gin.Context, token string) *user.User {
+	log.Debug("Validating API key...")
+	id, err := apikey.ParseToken(token)
 	if err != nil {
 		g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
 		return nil
 	}
 
-	user, err := h.users.ByID(uuid)
-	if err != nil || user.Email == "" {
+	key, err := h.keys.ByID(id)
+	if err != nil || !key.Matches(token) || !key.IsActive() {
 		g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
 		return nil
 	}
 
+	if !key.Allows(g.GetString(scopeKey)) {
+		g.AbortWithStatusJSON(http.StatusForbidden, common.StatusMessage{Message: "API key is not allowed to access this resource!"})
+		return nil
+	}
+
+	user, err := h.users.ByID(key.UserID)
+	if err != nil || user.Email == "" || !user.Enabled {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
+		return nil
+	}
+
+	if !key.LastUsedAt.Valid || time.Since(key.LastUsedAt.Time) > lastSeenResolution {
+		if err = h.keys.Touch(key.ID, time.Now()); err != nil {
+			log.WithError(err).WithField("Key", key.ID.String()).Warn("Failed to record API key usage.")
+		}
+	}
+
 	g.Set("user", user)
+	g.Set("apikey", key)
 	return user
 }
+
+func (h *JWTHandler) sessionOf(refreshToken string) (*session.Session, error) {
+	id, err := session.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := h.sessions.ByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if !sess.IsActive() {
+		return nil, errors.New("session is not active")
+	}
+	return sess, nil
+}
+
+func (h *JWTHandler) setCookies(g *gin.Context, sess *session.Session, refresh string) error {
+	if err := h.setAccessCookie(g, sess.UserID, sess, nil, h.accessTTL()); err != nil {
+		return err
+	}
+	g.SetCookie(refreshTokenKey, refresh, int(time.Until(sess.ExpiresAt).Seconds()), refreshCookiePath, "", h.conf.JWTSecure, true)
+	return nil
+}
+
+// setAccessCookie signs an access token of a user for a session, on behalf of the actor if it is not nil.
+func (h *JWTHandler) setAccessCookie(g *gin.Context, userID uuid.UUID, sess *session.Session, act *actor, ttl time.Duration) error {
+	now := time.Now()
+	c := claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    h.conf.BackendRoot,
+			Subject:   userID.String(),
+			Audience:  jwt.ClaimStrings{accessAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		SessionID: sess.ID.String(),
+		Actor:     act,
+	}
+	if sess.OrganizationID.Valid && act == nil {
+		c.Organization = sess.OrganizationID.UUID.String()
+	}
+	tokenString, err := h.keySet.Sign(c)
+	if err != nil {
+		log.WithError(err).WithField("User", userID.String()).Warn("JWT token could not be signed!")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{
+			Message: "Failed to sign JWT token, please contact administrator!",
+		})
+		return err
+	}
+
+	g.SetSameSite(http.SameSiteLaxMode)
+	g.SetCookie(jwtTokenKey, tokenString, int(ttl.Seconds()), "", "", h.conf.JWTSecure, true)
+	return nil
+}
+
+func (h *JWTHandler) clearCookies(g *gin.Context) {
+	g.SetSameSite(http.SameSiteLaxMode)
+	g.SetCookie(jwtTokenKey, "", -1, "", "", h.conf.JWTSecure, true)
+	g.SetCookie(refreshTokenKey, "", -1, refreshCookiePath, "", h.conf.JWTSecure, true)
+}
+
+func (h *JWTHandler) accessTTL() time.Duration {
+	return time.Minute * time.Duration(h.conf.JWTAccessExp)
+}
+
+func (h *JWTHandler) sessionTTL() time.Duration {
+	return time.Hour * time.Duration(h.conf.JWTExp)
+}
@@ -0,0 +1,612 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cskr/pubsub/v2"
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/apikey"
+	"github.com/inokone/go-micro-saas/internal/auth/role"
+	"github.com/inokone/go-micro-saas/internal/auth/session"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+// MockUserStorer is a mock implementation of the user.Storer interface
+type MockUserStorer struct {
+	mock.Mock
+}
+
+func (m *MockUserStorer) Store(usr *user.User) error {
+	args := m.Called(usr)
+	return args.Error(0)
+}
+
+func (m *MockUserStorer) Update(usr *user.User) error {
+	args := m.Called(usr)
+	return args.Error(0)
+}
+
+func (m *MockUserStorer) Patch(usr user.Patch) error {
+	args := m.Called(usr)
+	return args.Error(0)
+}
+
+func (m *MockUserStorer) Delete(email string) error {
+	args := m.Called(email)
+	return args.Error(0)
+}
+
+func (m *MockUserStorer) SetEnabled(id uuid.UUID, enabled bool) error {
+	args := m.Called(id, enabled)
+	return args.Error(0)
+}
+
+func (m *MockUserStorer) SetRole(id uuid.UUID, roleID uuid.UUID) error {
+	args := m.Called(id, roleID)
+	return args.Error(0)
+}
+
+func (m *MockUserStorer) ByEmail(email string) (*user.User, error) {
+	args := m.Called(email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*user.User), args.Error(1)
+}
+
+func (m *MockUserStorer) ByID(id uuid.UUID) (*user.User, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*user.User), args.Error(1)
+}
+
+func (m *MockUserStorer) ListInOrganization(organizationID uuid.UUID) ([]user.User, error) {
+	args := m.Called(organizationID)
+	return args.Get(0).([]user.User), args.Error(1)
+}
+
+func (m *MockUserStorer) List() ([]user.User, error) {
+	args := m.Called()
+	return args.Get(0).([]user.User), args.Error(1)
+}
+
+func (m *MockUserStorer) Stats() (user.Stats, error) {
+	args := m.Called()
+	return args.Get(0).(user.Stats), args.Error(1)
+}
+
+// MockSessionStorer is a mock implementation of the session.Storer interface
+type MockSessionStorer struct {
+	mock.Mock
+}
+
+func (m *MockSessionStorer) Store(s *session.Session) error {
+	args := m.Called(s)
+	return args.Error(0)
+}
+
+func (m *MockSessionStorer) Update(s *session.Session) error {
+	args := m.Called(s)
+	return args.Error(0)
+}
+
+func (m *MockSessionStorer) ByID(id uuid.UUID) (*session.Session, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*session.Session), args.Error(1)
+}
+
+func (m *MockSessionStorer) Revoke(id uuid.UUID) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockSessionStorer) RevokeAll(userID uuid.UUID) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockSessionStorer) Touch(id uuid.UUID, lastSeen time.Time) error {
+	args := m.Called(id, lastSeen)
+	return args.Error(0)
+}
+
+func (m *MockSessionStorer) ListActive(userID uuid.UUID) ([]session.Session, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]session.Session), args.Error(1)
+}
+
+func (m *MockSessionStorer) RevokeOthers(userID uuid.UUID, keep uuid.UUID) error {
+	args := m.Called(userID, keep)
+	return args.Error(0)
+}
+
+func (m *MockSessionStorer) Familiar(userID uuid.UUID, ip string, userAgent string) (bool, error) {
+	args := m.Called(userID, ip, userAgent)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSessionStorer) Rotate(session *session.Session, previous string) (bool, error) {
+	args := m.Called(session, previous)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockKeyStorer is a mock implementation of the apikey.Storer interface
+type MockKeyStorer struct {
+	mock.Mock
+}
+
+func (m *MockKeyStorer) Store(key *apikey.Key) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+func (m *MockKeyStorer) ByID(id uuid.UUID) (*apikey.Key, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*apikey.Key), args.Error(1)
+}
+
+func (m *MockKeyStorer) ByUser(userID uuid.UUID) ([]apikey.Key, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]apikey.Key), args.Error(1)
+}
+
+func (m *MockKeyStorer) Touch(id uuid.UUID, lastUsed time.Time) error {
+	args := m.Called(id, lastUsed)
+	return args.Error(0)
+}
+
+func (m *MockKeyStorer) Delete(userID uuid.UUID, id uuid.UUID) (bool, error) {
+	args := m.Called(userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+var testConfig = &common.AuthConfig{
+	BackendRoot:  "http://localhost:8080",
+	JWTSecret:    "test-secret",
+	JWTExp:       24,
+	JWTAccessExp: 15,
+}
+
+var testKeySet, _ = NewKeySet(testConfig.JWTSecret, nil, nil)
+
+func testUser() *user.User {
+	return &user.User{
+		ID:      uuid.New(),
+		Email:   "test@example.com",
+		Role:    &role.Role{ID: role.RoleCustomerUser},
+		RoleID:  role.RoleCustomerUser,
+		Status:  user.Confirmed,
+		Source:  "credentials",
+		Enabled: true,
+	}
+}
+
+func setupTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	return gin.New()
+}
+
+func cookieOf(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestIssueStoresSessionAndSetsCookies(t *testing.T) {
+	users := new(MockUserStorer)
+	sessions := new(MockSessionStorer)
+	handler := NewJWTHandler(users, sessions, new(MockKeyStorer), new(MockOrganizationStorer), testKeySet, testConfig, nil)
+	router := setupTestRouter()
+	usr := testUser()
+
+	sessions.On("Familiar", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+	sessions.On("Store", mock.MatchedBy(func(s *session.Session) bool {
+		return s.UserID == usr.ID && s.IsActive()
+	})).Return(nil)
+
+	router.GET("/signin", func(c *gin.Context) {
+		assert.NoError(t, handler.Issue(c, usr.ID.String()))
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/signin", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	access := cookieOf(w, jwtTokenKey)
+	assert.NotNil(t, access)
+	var c claims
+	_, err := jwt.ParseWithClaims(access.Value, &c, testKeySet.Keyfunc)
+	assert.NoError(t, err)
+	assert.Equal(t, testConfig.BackendRoot, c.Issuer)
+	assert.Equal(t, jwt.ClaimStrings{accessAudience}, c.Audience)
+	refresh := cookieOf(w, refreshTokenKey)
+	assert.NotNil(t, refresh)
+	assert.Equal(t, refreshCookiePath, refresh.Path)
+	sessions.AssertExpectations(t)
+}
+
+func TestValidateRejectsTokenOfOtherIssuer(t *testing.T) {
+	users := new(MockUserStorer)
+	sessions := new(MockSessionStorer)
+	handler := NewJWTHandler(users, sessions, new(MockKeyStorer), new(MockOrganizationStorer), testKeySet, testConfig, nil)
+	router := setupTestRouter()
+
+	now := time.Now()
+	token, err := testKeySet.Sign(claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "https://other.example.com",
+			Subject:   uuid.NewString(),
+			Audience:  jwt.ClaimStrings{accessAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+		SessionID: uuid.NewString(),
+	})
+	assert.NoError(t, err)
+
+	router.GET("/private", handler.Validate, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/private", nil)
+	req.AddCookie(&http.Cookie{Name: jwtTokenKey, Value: token})
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	sessions.AssertNotCalled(t, "ByID", mock.Anything)
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	users := new(MockUserStorer)
+	sessions := new(MockSessionStorer)
+	handler := NewJWTHandler(users, sessions, new(MockKeyStorer), new(MockOrganizationStorer), testKeySet, testConfig, nil)
+	router := setupTestRouter()
+	usr := testUser()
+
+	sess, token, err := session.NewSession(usr.ID, "", "", time.Hour)
+	assert.NoError(t, err)
+
+	sessions.On("ByID", sess.ID).Return(sess, nil)
+	sessions.On("Rotate", sess, session.Hash(token)).Return(true, nil)
+	users.On("ByID", usr.ID).Return(usr, nil)
+
+	router.POST("/refresh", func(c *gin.Context) {
+		assert.NoError(t, handler.Refresh(c))
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: refreshTokenKey, Value: token})
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	rotated := cookieOf(w, refreshTokenKey)
+	assert.NotNil(t, rotated)
+	assert.NotEqual(t, token, rotated.Value)
+	assert.True(t, sess.Matches(rotated.Value))
+	sessions.AssertNotCalled(t, "Revoke", mock.Anything)
+}
+
+func TestRefreshRevokesSessionForReusedToken(t *testing.T) {
+	users := new(MockUserStorer)
+	sessions := new(MockSessionStorer)
+	handler := NewJWTHandler(users, sessions, new(MockKeyStorer), new(MockOrganizationStorer), testKeySet, testConfig, nil)
+	router := setupTestRouter()
+
+	sess, stolen, err := session.NewSession(uuid.New(), "", "", time.Hour)
+	assert.NoError(t, err)
+	_, err = sess.Rotate()
+	assert.NoError(t, err)
+
+	sessions.On("ByID", sess.ID).Return(sess, nil)
+	sessions.On("Revoke", sess.ID).Return(nil)
+
+	router.POST("/refresh", func(c *gin.Context) {
+		assert.Error(t, handler.Refresh(c))
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: refreshTokenKey, Value: stolen})
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	sessions.AssertCalled(t, "Revoke", sess.ID)
+	sessions.AssertNotCalled(t, "Rotate", mock.Anything, mock.Anything)
+}
+
+func TestRefreshRejectsForgedTokenWithoutRevoking(t *testing.T) {
+	users := new(MockUserStorer)
+	sessions := new(MockSessionStorer)
+	handler := NewJWTHandler(users, sessions, new(MockKeyStorer), new(MockOrganizationStorer), testKeySet, testConfig, nil)
+	router := setupTestRouter()
+
+	sess, _, err := session.NewSession(uuid.New(), "", "", time.Hour)
+	assert.NoError(t, err)
+	_, err = sess.Rotate()
+	assert.NoError(t, err)
+
+	sessions.On("ByID", sess.ID).Return(sess, nil)
+
+	router.POST("/refresh", func(c *gin.Context) {
+		assert.Error(t, handler.Refresh(c))
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: refreshTokenKey, Value: sess.ID.String() + ".garbage"})
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	sessions.AssertNotCalled(t, "Revoke", mock.Anything)
+	sessions.AssertNotCalled(t, "Rotate", mock.Anything, mock.Anything)
+}
+
+func TestRefreshRevokesSessionForConcurrentlyRotatedToken(t *testing.T) {
+	users := new(MockUserStorer)
+	sessions := new(MockSessionStorer)
+	handler := NewJWTHandler(users, sessions, new(MockKeyStorer), new(MockOrganizationStorer), testKeySet, testConfig, nil)
+	router := setupTestRouter()
+	usr := testUser()
+
+	sess, token, err := session.NewSession(usr.ID, "", "", time.Hour)
+	assert.NoError(t, err)
+
+	sessions.On("ByID", sess.ID).Return(sess, nil)
+	sessions.On("Rotate", sess, session.Hash(token)).Return(false, nil)
+	sessions.On("Revoke", sess.ID).Return(nil)
+	users.On("ByID", usr.ID).Return(usr, nil)
+
+	router.POST("/refresh", func(c *gin.Context) {
+		assert.Error(t, handler.Refresh(c))
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: refreshTokenKey, Value: token})
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, cookieOf(w, refreshTokenKey).Value)
+	sessions.AssertCalled(t, "Revoke", sess.ID)
+}
+
+func TestIssueAlertsUnfamiliarSignin(t *testing.T) {
+	users := new(MockUserStorer)
+	sessions := new(MockSessionStorer)
+	ps := pubsub.New[string, common.Event](1)
+	ch := ps.Sub(common.NotificationTopic)
+	handler := NewJWTHandler(users, sessions, new(MockKeyStorer), new(MockOrganizationStorer), testKeySet, testConfig, ps)
+	router := setupTestRouter()
+	usr := testUser()
+
+	sessions.On("Familiar", usr.ID, mock.Anything, "Mozilla/5.0 (X11; Linux x86_64) Firefox/128.0").Return(false, nil)
+	sessions.On("Store", mock.Anything).Return(nil)
+	users.On("ByID", usr.ID).Return(usr, nil)
+	router.GET("/signin", func(c *gin.Context) {
+		assert.NoError(t, handler.Issue(c, usr.ID.String()))
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/signin", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) Firefox/128.0")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	e := nextEvent(t, ch)
+	assert.Equal(t, common.NewSignin, e.Type)
+	assert.Equal(t, usr.ID, e.User)
+	assert.Equal(t, usr.Email, e.Data.(common.SecurityData).Email)
+	assert.Equal(t, "Firefox on Linux", e.Data.(common.SecurityData).Device)
+}
+
+func TestValidateRejectsRevokedSession(t *testing.T) {
+	users := new(MockUserStorer)
+	sessions := new(MockSessionStorer)
+	handler := NewJWTHandler(users, sessions, new(MockKeyStorer), new(MockOrganizationStorer), testKeySet, testConfig, nil)
+	router := setupTestRouter()
+	usr := testUser()
+
+	sessions.On("Familiar", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+	sessions.On("Store", mock.Anything).Return(nil)
+	router.GET("/signin", func(c *gin.Context) {
+		assert.NoError(t, handler.Issue(c, usr.ID.String()))
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/signin", nil)
+	router.ServeHTTP(w, req)
+	access := cookieOf(w, jwtTokenKey)
+	assert.NotNil(t, access)
+
+	stored := sessions.Calls[1].Arguments.Get(0).(*session.Session)
+	stored.RevokedAt.Valid = true
+	sessions.On("ByID", stored.ID).Return(stored, nil)
+
+	router.GET("/private", handler.Validate, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/private", nil)
+	req.AddCookie(&http.Cookie{Name: jwtTokenKey, Value: access.Value})
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	users.AssertNotCalled(t, "ByID", mock.Anything)
+}
+
+func TestChallengeTokenRoundTrip(t *testing.T) {
+	handler := NewJWTHandler(new(MockUserStorer), new(MockSessionStorer), new(MockKeyStorer), new(MockOrganizationStorer), testKeySet, testConfig, nil)
+	userID := uuid.New()
+
+	token, err := handler.IssueChallenge(userID)
+	assert.NoError(t, err)
+
+	parsed, err := handler.ParseChallenge(token)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, parsed)
+}
+
+func TestChallengeTokenIsRejectedForAuthentication(t *testing.T) {
+	users := new(MockUserStorer)
+	sessions := new(MockSessionStorer)
+	handler := NewJWTHandler(users, sessions, new(MockKeyStorer), new(MockOrganizationStorer), testKeySet, testConfig, nil)
+	router := setupTestRouter()
+
+	token, err := handler.IssueChallenge(uuid.New())
+	assert.NoError(t, err)
+
+	router.GET("/private", handler.Validate, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/private", nil)
+	req.AddCookie(&http.Cookie{Name: jwtTokenKey, Value: token})
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	sessions.AssertNotCalled(t, "ByID", mock.Anything)
+}
+
+func TestValidateAcceptsAPIKeyWithScope(t *testing.T) {
+	users := new(MockUserStorer)
+	keys := new(MockKeyStorer)
+	handler := NewJWTHandler(users, new(MockSessionStorer), keys, new(MockOrganizationStorer), testKeySet, testConfig, nil)
+	router := setupTestRouter()
+	usr := testUser()
+
+	key, token, err := apikey.NewKey(usr.ID, "CI", []string{apikey.ScopeUsers}, null.Time{})
+	assert.NoError(t, err)
+	keys.On("ByID", key.ID).Return(key, nil)
+	keys.On("Touch", key.ID, mock.Anything).Return(nil)
+	users.On("ByID", usr.ID).Return(usr, nil)
+
+	router.GET("/users", handler.Scope(apikey.ScopeUsers), handler.Validate, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	keys.AssertExpectations(t)
+}
+
+func TestValidateRejectsAPIKeyOutOfScope(t *testing.T) {
+	users := new(MockUserStorer)
+	keys := new(MockKeyStorer)
+	handler := NewJWTHandler(users, new(MockSessionStorer), keys, new(MockOrganizationStorer), testKeySet, testConfig, nil)
+	router := setupTestRouter()
+
+	key, token, err := apikey.NewKey(uuid.New(), "CI", []string{apikey.ScopeAccount}, null.Time{})
+	assert.NoError(t, err)
+	keys.On("ByID", key.ID).Return(key, nil)
+
+	router.GET("/users", handler.Scope(apikey.ScopeUsers), handler.Validate, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/account/sessions", handler.Validate, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/users", "/account/sessions"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	}
+	users.AssertNotCalled(t, "ByID", mock.Anything)
+}
+
+func TestValidateRejectsExpiredAPIKey(t *testing.T) {
+	users := new(MockUserStorer)
+	keys := new(MockKeyStorer)
+	handler := NewJWTHandler(users, new(MockSessionStorer), keys, new(MockOrganizationStorer), testKeySet, testConfig, nil)
+	router := setupTestRouter()
+
+	key, token, err := apikey.NewKey(uuid.New(), "CI", []string{apikey.ScopeUsers}, null.TimeFrom(time.Now().Add(-time.Minute)))
+	assert.NoError(t, err)
+	keys.On("ByID", key.ID).Return(key, nil)
+
+	router.GET("/users", handler.Scope(apikey.ScopeUsers), handler.Validate, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	users.AssertNotCalled(t, "ByID", mock.Anything)
+}
+
+func TestValidateRecentRequiresFreshSignin(t *testing.T) {
+	handler := NewJWTHandler(new(MockUserStorer), new(MockSessionStorer), new(MockKeyStorer), new(MockOrganizationStorer), testKeySet, testConfig, nil)
+
+	for age, expected := range map[time.Duration]int{time.Minute: http.StatusOK, time.Hour: http.StatusUnauthorized} {
+		sess := &session.Session{ID: uuid.New(), CreatedAt: time.Now().Add(-age)}
+		router := setupTestRouter()
+		router.DELETE("/identities/:id", func(c *gin.Context) {
+			c.Set("session", sess)
+		}, handler.ValidateRecent, func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/identities/1", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, expected, w.Code)
+	}
+}
+
+func TestRequirePermissionChecksRoleOfUser(t *testing.T) {
+	users := new(MockUserStorer)
+	keys := new(MockKeyStorer)
+	handler := NewJWTHandler(users, new(MockSessionStorer), keys, new(MockOrganizationStorer), testKeySet, testConfig, nil)
+	router := setupTestRouter()
+	reader, writer := testUser(), testUser()
+	reader.Role.Permissions = []string{role.PermUsersRead}
+	writer.Role.Permissions = []string{role.PermUsersRead, role.PermUsersWrite}
+
+	router.PATCH("/users", handler.Scope(apikey.ScopeUsers), handler.RequirePermission(role.PermUsersRead, role.PermUsersWrite), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	for usr, expected := range map[*user.User]int{reader: http.StatusForbidden, writer: http.StatusOK} {
+		key, token, err := apikey.NewKey(usr.ID, "CI", []string{apikey.ScopeUsers}, null.Time{})
+		assert.NoError(t, err)
+		keys.On("ByID", key.ID).Return(key, nil)
+		keys.On("Touch", key.ID, mock.Anything).Return(nil)
+		users.On("ByID", usr.ID).Return(usr, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PATCH", "/users", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, expected, w.Code)
+	}
+}
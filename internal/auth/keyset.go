@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+// KeySet is the collection of keys for signing and verifying the JWT tokens of the application. Tokens are signed with
+// a single private key and verified with any of the public keys, so keys can be rotated without invalidating tokens
+// signed earlier. Without a private key the tokens are signed and verified with the shared secret using HS256.
+type KeySet struct {
+	method  jwt.SigningMethod
+	kid     string
+	private crypto.PrivateKey
+	secret  []byte
+	verify  map[string]verificationKey
+}
+
+type verificationKey struct {
+	method jwt.SigningMethod
+	public crypto.PublicKey
+	jwk    JSONWebKey
+}
+
+// JSONWebKey is the JSON representation of a public key of the `KeySet` according to RFC 7517.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JSONWebKeySet is the JSON representation of the public keys of the `KeySet`.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// LoadKeySet is a function creating the `KeySet` of the application from the key files in the configuration.
+func LoadKeySet(c *common.AppConfig) (*KeySet, error) {
+	if c.Auth.JWTSignKey == "" {
+		return NewKeySet(c.Auth.JWTSecret, nil, nil)
+	}
+	signKey, err := os.ReadFile(c.PathFor(c.Auth.JWTSignKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT signing key: %w", err)
+	}
+	var verifyKeys [][]byte
+	for _, path := range strings.Split(c.Auth.JWTVerifyKeys, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		key, err := os.ReadFile(c.PathFor(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT verification key: %w", err)
+		}
+		verifyKeys = append(verifyKeys, key)
+	}
+	return NewKeySet(c.Auth.JWTSecret, signKey, verifyKeys)
+}
+
+// NewKeySet is a function creating a `KeySet` from a PEM encoded RSA or Ed25519 private key for signing, and PEM encoded
+// public keys of earlier signing keys still accepted for verification. Without a private key the secret is used with HS256.
+func NewKeySet(secret string, signKey []byte, verifyKeys [][]byte) (*KeySet, error) {
+	if signKey == nil {
+		if secret == "" {
+			return nil, errors.New("either a JWT signing key or a secret is required")
+		}
+		return &KeySet{
+			method: jwt.SigningMethodHS256,
+			secret: []byte(secret),
+		}, nil
+	}
+
+	private, err := parsePrivateKey(signKey)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported JWT signing key")
+	}
+	current, err := newVerificationKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	k := &KeySet{
+		method:  current.method,
+		kid:     current.jwk.Kid,
+		private: private,
+		verify:  map[string]verificationKey{current.jwk.Kid: current},
+	}
+	for _, pemKey := range verifyKeys {
+		public, err := parsePublicKey(pemKey)
+		if err != nil {
+			return nil, err
+		}
+		key, err := newVerificationKey(public)
+		if err != nil {
+			return nil, err
+		}
+		k.verify[key.jwk.Kid] = key
+	}
+	return k, nil
+}
+
+// Sign is a method of `KeySet` signing the claims with the current key, identified by the `kid` header of the token.
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	if k.secret != nil {
+		return token.SignedString(k.secret)
+	}
+	token.Header["kid"] = k.kid
+	return token.SignedString(k.private)
+}
+
+// Keyfunc is a method of `KeySet` returning the key for verifying a token, based on its `kid` header and algorithm.
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if k.secret != nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return k.secret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.verify[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %v", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// Public is a method of `KeySet` returning the public keys for verifying tokens. Empty when signing with a shared secret.
+func (k *KeySet) Public() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	if current, ok := k.verify[k.kid]; ok {
+		set.Keys = append(set.Keys, current.jwk)
+	}
+	previous := make([]string, 0, len(k.verify))
+	for kid := range k.verify {
+		if kid != k.kid {
+			previous = append(previous, kid)
+		}
+	}
+	sort.Strings(previous)
+	for _, kid := range previous {
+		set.Keys = append(set.Keys, k.verify[kid].jwk)
+	}
+	return set
+}
+
+// JWKS is a method of `KeySet`. Publishes the public keys, so other services can verify the tokens of the application.
+// @Summary JSON Web Key Set endpoint
+// @Schemes
+// @Description Returns the public keys for verifying the JWT tokens issued by the application, the current signing key first
+// @Produce json
+// @Success 200 {object} auth.JSONWebKeySet
+// @Router /.well-known/jwks.json [get]
+func (k *KeySet) JWKS(g *gin.Context) {
+	g.Header("Cache-Control", "public, max-age=3600")
+	g.JSON(http.StatusOK, k.Public())
+}
+
+func newVerificationKey(public crypto.PublicKey) (verificationKey, error) {
+	var (
+		key = verificationKey{public: public}
+		jwk JSONWebKey
+	)
+	switch p := public.(type) {
+	case *rsa.PublicKey:
+		key.method = jwt.SigningMethodRS256
+		jwk = JSONWebKey{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(p.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+		jwk = JSONWebKey{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(p),
+		}
+	default:
+		return key, fmt.Errorf("unsupported JWT key type: %T", public)
+	}
+	jwk.Use = "sig"
+	jwk.Alg = key.method.Alg()
+	jwk.Kid = thumbprint(jwk)
+	key.jwk = jwk
+	return key, nil
+}
+
+// thumbprint is a function returning the RFC 7638 thumbprint of a key, used as its key ID.
+func thumbprint(jwk JSONWebKey) string {
+	var members any
+	if jwk.Kty == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+	b, _ := json.Marshal(members)
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func parsePrivateKey(data []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("JWT signing key is not PEM encoded")
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported JWT signing key: %v", block.Type)
+	}
+}
+
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("JWT verification key is not PEM encoded")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported JWT verification key: %v", block.Type)
+	}
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func privatePEM(t *testing.T, key crypto.PrivateKey) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func publicPEM(t *testing.T, key crypto.PublicKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	assert.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "user",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+}
+
+func TestKeySetSignsAndVerifiesWithEdDSA(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	ks, err := NewKeySet("", privatePEM(t, private), nil)
+	assert.NoError(t, err)
+
+	tokenString, err := ks.Sign(testClaims())
+	assert.NoError(t, err)
+
+	var c jwt.RegisteredClaims
+	token, err := jwt.ParseWithClaims(tokenString, &c, ks.Keyfunc)
+	assert.NoError(t, err)
+	assert.True(t, token.Valid)
+	assert.Equal(t, "EdDSA", token.Method.Alg())
+	assert.Equal(t, ks.Public().Keys[0].Kid, token.Header["kid"])
+}
+
+func TestKeySetVerifiesTokensOfRotatedKey(t *testing.T) {
+	old, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	_, current, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	previous, err := NewKeySet("", privatePEM(t, old), nil)
+	assert.NoError(t, err)
+	tokenString, err := previous.Sign(testClaims())
+	assert.NoError(t, err)
+
+	rotated, err := NewKeySet("", privatePEM(t, current), [][]byte{publicPEM(t, &old.PublicKey)})
+	assert.NoError(t, err)
+
+	token, err := jwt.Parse(tokenString, rotated.Keyfunc)
+	assert.NoError(t, err)
+	assert.True(t, token.Valid)
+	assert.Equal(t, "RS256", token.Method.Alg())
+
+	public := rotated.Public()
+	assert.Len(t, public.Keys, 2)
+	assert.Equal(t, "OKP", public.Keys[0].Kty)
+	assert.Equal(t, "RSA", public.Keys[1].Kty)
+	assert.Equal(t, "AQAB", public.Keys[1].E)
+}
+
+func TestKeySetRejectsUnknownKeyAndSecret(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	ks, err := NewKeySet("test-secret", privatePEM(t, private), nil)
+	assert.NoError(t, err)
+
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	unknown, err := NewKeySet("", privatePEM(t, other), nil)
+	assert.NoError(t, err)
+	legacy, err := NewKeySet("test-secret", nil, nil)
+	assert.NoError(t, err)
+
+	for _, signer := range []*KeySet{unknown, legacy} {
+		tokenString, err := signer.Sign(testClaims())
+		assert.NoError(t, err)
+
+		_, err = jwt.Parse(tokenString, ks.Keyfunc)
+		assert.Error(t, err)
+	}
+}
+
+func TestJWKSPublishesPublicKeys(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	ks, err := NewKeySet("", privatePEM(t, private), nil)
+	assert.NoError(t, err)
+	router := setupTestRouter()
+	router.GET("/.well-known/jwks.json", ks.JWKS)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response JSONWebKeySet
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response.Keys, 1)
+	assert.Equal(t, "Ed25519", response.Keys[0].Crv)
+	assert.Equal(t, "sig", response.Keys[0].Use)
+	assert.NotEmpty(t, response.Keys[0].Kid)
+	assert.NotContains(t, w.Body.String(), "\"d\"")
+}
+
+func TestJWKSIsEmptyForSharedSecret(t *testing.T) {
+	assert.Empty(t, testKeySet.Public().Keys)
+}
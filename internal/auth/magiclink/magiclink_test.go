@@ -0,0 +1,75 @@
+package magiclink
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockStorer is a mock implementation of the Storer interface
+type MockStorer struct {
+	mock.Mock
+}
+
+func (m *MockStorer) Store(link *Link) error {
+	args := m.Called(link)
+	return args.Error(0)
+}
+
+func (m *MockStorer) Take(id uuid.UUID) (*Link, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Link), args.Error(1)
+}
+
+func (m *MockStorer) DeleteForUser(userID uuid.UUID) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func TestNewLinkKeepsOnlyHashOfToken(t *testing.T) {
+	userID := uuid.New()
+
+	link, token, err := NewLink(userID, 15*time.Minute)
+
+	assert.NoError(t, err)
+	assert.Equal(t, userID, link.UserID)
+	assert.NotContains(t, link.Hash, token)
+	assert.True(t, link.Matches(token))
+	assert.False(t, link.Matches(token+"x"))
+	assert.True(t, link.IsActive())
+
+	id, err := ParseToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, link.ID, id)
+}
+
+func TestNewLinkTokensAreUnique(t *testing.T) {
+	userID := uuid.New()
+
+	_, first, err := NewLink(userID, time.Minute)
+	assert.NoError(t, err)
+	_, second, err := NewLink(userID, time.Minute)
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, first, second)
+}
+
+func TestLinkExpires(t *testing.T) {
+	link, _, err := NewLink(uuid.New(), -time.Minute)
+
+	assert.NoError(t, err)
+	assert.False(t, link.IsActive())
+}
+
+func TestParseTokenRejectsMalformedToken(t *testing.T) {
+	for _, token := range []string{"", "token", "not-a-uuid.secret"} {
+		_, err := ParseToken(token)
+		assert.Error(t, err, token)
+	}
+}
@@ -0,0 +1,86 @@
+package magiclink
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Link is a single-use sign in link sent to the email address of a user, for database storage. Only the hash of the
+// token in the link is kept.
+type Link struct {
+	ID        uuid.UUID `db:"link_id"`
+	UserID    uuid.UUID `db:"user_id"`
+	Hash      string    `db:"token_hash"`
+	CreatedAt time.Time `db:"created_at"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+// NewLink is a function to create a new `Link` for a user, returning the link with the token to send. The token
+// identifies the link, so a wrong token can be counted as a failed sign in of the user.
+func NewLink(userID uuid.UUID, ttl time.Duration) (*Link, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	l := &Link{
+		ID:        uuid.New(),
+		UserID:    userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	token := l.ID.String() + "." + base64.RawURLEncoding.EncodeToString(secret)
+	l.Hash = Hash(token)
+	return l, token, nil
+}
+
+// IsActive is a method of `Link` returning whether the link is not expired.
+func (l *Link) IsActive() bool {
+	return l.ExpiresAt.After(time.Now())
+}
+
+// Matches is a method of `Link` returning whether the token provided is the token of the link.
+func (l *Link) Matches(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(l.Hash), []byte(Hash(token))) == 1
+}
+
+// Hash is a function returning the hex encoded SHA-256 hash of a link token for storage.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseToken is a function extracting the link ID from a link token.
+func ParseToken(token string) (uuid.UUID, error) {
+	id, _, found := strings.Cut(token, ".")
+	if !found {
+		return uuid.Nil, errors.New("malformed magic link token")
+	}
+	return uuid.Parse(id)
+}
+
+// Request is a struct for the message body of requesting a sign in link.
+type Request struct {
+	Email   string `json:"email" binding:"required,email"`
+	Captcha string `json:"captcha_token" binding:"required"`
+}
+
+// Signin is a struct for the message body of signing in with the token of a sign in link.
+type Signin struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// Storer is the interface for `Link` persistence
+type Storer interface {
+	Store(link *Link) error
+	Take(id uuid.UUID) (*Link, error)
+	DeleteForUser(userID uuid.UUID) error
+}
@@ -0,0 +1,52 @@
+package magiclink
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresStorer is the `Storer` implementation based on sqlx library.
+type PostgresStorer struct {
+	db *sqlx.DB
+}
+
+// NewPostgresStorer creates a new `PostgresStorer` instance based on the sqlx library.
+func NewPostgresStorer(db *sqlx.DB) *PostgresStorer {
+	return &PostgresStorer{
+		db: db,
+	}
+}
+
+// Store is a method of the `PostgresStorer` struct. Takes a `Link` as parameter and persists it.
+func (s *PostgresStorer) Store(link *Link) error {
+	query := `INSERT INTO microsaas.magic_links (link_id, user_id, token_hash, created_at, expires_at) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := s.db.Exec(query, link.ID, link.UserID, link.Hash, link.CreatedAt, link.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to store magic link: %w", err)
+	}
+	return nil
+}
+
+// Take is a method of the `PostgresStorer` struct. Takes a link ID as parameter, loads the `Link` and deletes it from
+// persistence, so every link can be used only once. Expired links are cleaned up as well.
+func (s *PostgresStorer) Take(id uuid.UUID) (*Link, error) {
+	var link Link
+	query := `DELETE FROM microsaas.magic_links WHERE link_id = $1 RETURNING link_id, user_id, token_hash, created_at, expires_at`
+	if err := s.db.Get(&link, query, id); err != nil {
+		return nil, fmt.Errorf("failed to take magic link: %w", err)
+	}
+	if _, err := s.db.Exec(`DELETE FROM microsaas.magic_links WHERE expires_at < NOW()`); err != nil {
+		return nil, fmt.Errorf("failed to clean up magic links: %w", err)
+	}
+	return &link, nil
+}
+
+// DeleteForUser is a method of the `PostgresStorer` struct. Takes a user ID as parameter and deletes all links of the
+// user, so only the last link sent can be used.
+func (s *PostgresStorer) DeleteForUser(userID uuid.UUID) error {
+	if _, err := s.db.Exec(`DELETE FROM microsaas.magic_links WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete magic links: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,499 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+
+	"github.com/inokone/go-micro-saas/internal/auth/identity"
+	"github.com/inokone/go-micro-saas/internal/auth/organization"
+	"github.com/inokone/go-micro-saas/internal/auth/provider"
+	"github.com/inokone/go-micro-saas/internal/auth/role"
+	"github.com/inokone/go-micro-saas/internal/auth/session"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+const (
+	// oauthStateKey is the cookie binding an authentication request to the browser which started it.
+	oauthStateKey = "oauth_state"
+	// oauthCookiePath limits the state cookie to the endpoints of the identity providers.
+	oauthCookiePath = "/api/public/v1/auth"
+	// oauthAudience is the audience of the state cookie, so it can not be mistaken for any other token.
+	oauthAudience = "oauth"
+	oauthTTL      = 10 * time.Minute
+)
+
+var (
+	errInvalidState      = errors.New("invalid oauth state")
+	statusSomethingWrong = common.StatusMessage{Message: "Something went wrong. Please contact our administrators!"}
+)
+
+// oauthClaims is the content of the state cookie of an authentication request: the random state sent to the provider,
+// the PKCE verifier of the code challenge and the frontend URL to return to. When the request links the provider to a
+// signed in user instead of signing in, the ID of the user is kept as well.
+type oauthClaims struct {
+	jwt.RegisteredClaims
+	Provider string `json:"prv"`
+	State    string `json:"state"`
+	Verifier string `json:"verifier"`
+	ReturnTo string `json:"return_to,omitempty"`
+	Link     string `json:"link,omitempty"`
+}
+
+// OAuthHandler is a handler for endpoints of OAuth2 / OpenID Connect based authentication with the configured identity providers
+type OAuthHandler struct {
+	providers  *provider.Registry
+	users      user.Storer
+	identities identity.Storer
+	roles      role.Storer
+	service    *Service
+	jwt        *JWTHandler
+	frontend   *url.URL
+	successURL string
+	factorURL  string
+	secure     bool
+}
+
+// NewOAuthHandler is a function creating an instance of `OAuthHandler`. The service decides the second factors users
+// have to finish signing in with.
+func NewOAuthHandler(c common.AuthConfig, providers *provider.Registry, users user.Storer, identities identity.Storer, roles role.Storer, service *Service, jwt *JWTHandler) (*OAuthHandler, error) {
+	frontend, err := url.Parse(c.FrontendRoot)
+	if err != nil {
+		return nil, err
+	}
+	return &OAuthHandler{
+		providers:  providers,
+		users:      users,
+		identities: identities,
+		roles:      roles,
+		service:    service,
+		jwt:        jwt,
+		frontend:   frontend,
+		successURL: c.FrontendRoot + "/dashboard",
+		factorURL:  c.FrontendRoot + "/signin/2fa",
+		secure:     c.JWTSecure,
+	}, nil
+}
+
+// List endpoint
+// @Summary List is the endpoint of the identity providers available for single sign-on.
+// @Schemes
+// @Description Returns the identity providers enabled in the configuration, in the order of the configuration.
+// @Produce json
+// @Success 200 {array} provider.View
+// @Router /auth/providers [get]
+func (h *OAuthHandler) List(g *gin.Context) {
+	providers := h.providers.List()
+	res := make([]provider.View, len(providers))
+	for i, p := range providers {
+		res[i] = p.AsView()
+	}
+	g.JSON(http.StatusOK, res)
+}
+
+// Signin endpoint
+// @Summary Signin is the authentication endpoint. Starts the authentication process of the identity provider.
+// @Schemes
+// @Description Starts the authentication process of the identity provider. The request is bound to the browser with a
+// @Description short-lived cookie, so the callback can only be completed where it was started.
+// @Accept json
+// @Produce json
+// @Param provider path string true "Name of the identity provider"
+// @Param return_to query string false "Page of the frontend to return to after signing in"
+// @Failure 400 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /auth/{provider} [get]
+func (h *OAuthHandler) Signin(g *gin.Context) {
+	authURL, ok := h.begin(g, "")
+	if !ok {
+		return
+	}
+	g.Redirect(http.StatusTemporaryRedirect, authURL)
+}
+
+// Link endpoint
+// @Summary Link is the endpoint of linking an identity provider to the current user, requires a recent sign in.
+// @Schemes
+// @Description Starts the authentication process of the identity provider, attaching the account at the provider to the
+// @Description current user as a sign in method. The URL returned is to be opened in the browser.
+// @Accept json
+// @Produce json
+// @Param provider path string true "Name of the identity provider"
+// @Param return_to query string false "Page of the frontend to return to after linking"
+// @Success 200 {object} identity.Link
+// @Failure 400 {object} common.StatusMessage
+// @Failure 401 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/identities/{provider} [post]
+func (h *OAuthHandler) Link(g *gin.Context) {
+	u, _ := g.Get("user")
+	usr := u.(*user.User)
+
+	authURL, ok := h.begin(g, usr.ID.String())
+	if !ok {
+		return
+	}
+	g.JSON(http.StatusOK, identity.Link{URL: authURL})
+}
+
+// Redirect endpoint
+// @Summary Redirect is the authentication callback endpoint. Authenticates/Registers users, sets up JWT token.
+// @Schemes
+// @Description Called by the identity provider when we have a result of the authentication process. Signs the user in
+// @Description with the identity linked to the account at the provider, or links it when the process was started for linking.
+// @Description When the user has two-factor authentication enabled, redirects to the second factor page of the frontend
+// @Description with a challenge token instead of signing in.
+// @Accept json
+// @Produce text/html
+// @Param provider path string true "Name of the identity provider"
+// @Failure 401 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 409 {object} common.StatusMessage
+// @Router /auth/{provider}/redirect [get]
+func (h *OAuthHandler) Redirect(g *gin.Context) {
+	p, ok := h.provider(g)
+	if !ok {
+		return
+	}
+	ident, state, err := h.authenticateCode(g, p)
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: err.Error()})
+		return
+	}
+
+	returnTo := state.ReturnTo
+	if returnTo == "" {
+		returnTo = h.successURL
+	}
+	if state.Link != "" {
+		if h.link(g, p, ident, state.Link) {
+			g.Redirect(http.StatusTemporaryRedirect, returnTo)
+		}
+		return
+	}
+
+	usr, ok := h.userOf(g, p, ident)
+	if !ok {
+		return
+	}
+	if !usr.Enabled {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, common.StatusMessage{Message: "Your account has been deactivated. Please contact our administrators!"})
+		return
+	}
+	h.finishSignin(g, usr, returnTo)
+}
+
+// finishSignin is a method of `OAuthHandler` issuing the session of a user authenticated by the provider, or
+// redirecting to the second factor page of the frontend with a challenge if a second factor is required.
+func (h *OAuthHandler) finishSignin(g *gin.Context, usr *user.User, returnTo string) {
+	methods, err := h.service.SecondFactors(usr)
+	if err != nil {
+		log.WithError(err).Error("Failed to collect two-factor settings.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return
+	}
+
+	if len(methods) > 0 {
+		token, err := h.jwt.IssueChallenge(usr.ID)
+		if err != nil {
+			log.WithError(err).Error("Failed to issue two-factor challenge.")
+			g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+			return
+		}
+		query := url.Values{"token": {token}, "methods": {strings.Join(methods, ",")}, "return_to": {returnTo}}
+		g.Redirect(http.StatusTemporaryRedirect, h.factorURL+"?"+query.Encode())
+		return
+	}
+
+	if err = h.jwt.Issue(g, usr.ID.String()); err != nil {
+		return
+	}
+	g.Redirect(http.StatusTemporaryRedirect, returnTo)
+}
+
+// begin is a method of `OAuthHandler` starting an authentication request with the provider of the route, bound to the
+// browser with the state cookie. Returns the URL of the provider, or aborts the request on failure.
+func (h *OAuthHandler) begin(g *gin.Context, link string) (string, bool) {
+	p, ok := h.provider(g)
+	if !ok {
+		return "", false
+	}
+	returnTo, ok := h.returnURL(g.Query("return_to"))
+	if !ok {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Invalid return URL!"})
+		return "", false
+	}
+
+	state, err := randomState()
+	if err != nil {
+		log.WithError(err).Error("Failed to generate OAuth state.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return "", false
+	}
+	verifier := oauth2.GenerateVerifier()
+	authURL, err := p.AuthCodeURL(g.Request.Context(), state, oauth2.S256ChallengeOption(verifier))
+	if err != nil {
+		log.WithError(err).Error("Failed to start OAuth authentication.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return "", false
+	}
+
+	now := time.Now()
+	cookie, err := h.jwt.keySet.Sign(oauthClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{oauthAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(oauthTTL)),
+		},
+		Provider: p.Name,
+		State:    state,
+		Verifier: verifier,
+		ReturnTo: returnTo,
+		Link:     link,
+	})
+	if err != nil {
+		log.WithError(err).Error("Failed to sign OAuth state.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return "", false
+	}
+	g.SetSameSite(http.SameSiteLaxMode)
+	g.SetCookie(oauthStateKey, cookie, int(oauthTTL.Seconds()), oauthCookiePath, "", h.secure, true)
+	return authURL, true
+}
+
+// userOf is a method of `OAuthHandler` returning the user signing in with the identity at the provider, registering
+// a new user for an unknown email address. An existing user can only sign in with a provider linked to the account.
+func (h *OAuthHandler) userOf(g *gin.Context, p *provider.Provider, ident *provider.Identity) (*user.User, bool) {
+	existing, err := h.identities.BySubject(p.Name, ident.Subject)
+	if err == nil {
+		if err = h.identities.Touch(existing.ID, time.Now()); err != nil {
+			log.WithError(err).WithField("provider", p.Name).Warn("Failed to record identity usage.")
+		}
+		usr, err := h.users.ByID(existing.UserID)
+		if err != nil {
+			log.WithError(err).WithField("provider", p.Name).Error("Can not load OAuth user.")
+			g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+			return nil, false
+		}
+		return usr, true
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.WithError(err).WithField("provider", p.Name).Error("Can not load identity.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return nil, false
+	}
+
+	usr, err := h.users.ByEmail(ident.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return h.register(g, p, ident)
+	}
+	if err != nil {
+		log.WithError(err).WithField("provider", p.Name).Error("Can not load user.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return nil, false
+	}
+
+	// Users registered before identities were introduced have the provider attached without the subject.
+	legacy, err := h.identities.ByProvider(usr.ID, p.Name)
+	if err != nil || legacy.Subject.Valid {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, common.StatusMessage{Message: "The provided email address is registered already with a different sign in method! Please sign in and link the provider in your account settings."})
+		return nil, false
+	}
+	legacy.Subject = null.StringFrom(ident.Subject)
+	legacy.LastUsedAt = null.TimeFrom(time.Now())
+	if err = h.identities.Update(legacy); err != nil {
+		log.WithError(err).WithField("provider", p.Name).Error("Can not update identity.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return nil, false
+	}
+	return usr, true
+}
+
+func (h *OAuthHandler) register(g *gin.Context, p *provider.Provider, ident *provider.Identity) (*user.User, bool) {
+	log.WithField("provider", p.Name).Debug("Populating new user from identity provider.")
+	defaultRole, err := h.roles.Default()
+	if err != nil {
+		log.WithError(err).Error("Can not load default role.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return nil, false
+	}
+	usr := &user.User{
+		ID:        uuid.New(),
+		Email:     ident.Email,
+		PassHash:  "",
+		FirstName: ident.FirstName,
+		LastName:  ident.LastName,
+		Source:    p.DisplayName,
+		RoleID:    defaultRole.ID,
+		Status:    user.Confirmed,
+		Enabled:   true,
+		CreatedAt: time.Now(),
+	}
+	if err = h.users.Store(usr); err != nil {
+		log.WithError(err).WithField("provider", p.Name).Error("Can not store OAuth user.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return nil, false
+	}
+	i := identity.NewIdentity(usr.ID, p.Name, ident.Subject, ident.Email)
+	i.LastUsedAt = null.TimeFrom(i.CreatedAt)
+	if err = h.identities.Store(i); err != nil {
+		log.WithError(err).WithField("provider", p.Name).Error("Can not store OAuth identity.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return nil, false
+	}
+	if err = h.jwt.orgs.Store(organization.NewPersonal(usr.ID, usr.Email), usr.ID); err != nil {
+		log.WithError(err).WithField("provider", p.Name).Error("Can not store organization of OAuth user.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return nil, false
+	}
+	if usr, err = h.users.ByEmail(ident.Email); err != nil {
+		log.WithError(err).WithField("provider", p.Name).Error("Can not load OAuth user.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return nil, false
+	}
+	return usr, true
+}
+
+// link is a method of `OAuthHandler` attaching the identity at the provider to the user who started the linking.
+func (h *OAuthHandler) link(g *gin.Context, p *provider.Provider, ident *provider.Identity, link string) bool {
+	userID, err := uuid.Parse(link)
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, unatuhorized)
+		return false
+	}
+
+	existing, err := h.identities.BySubject(p.Name, ident.Subject)
+	if err == nil {
+		if existing.UserID != userID {
+			g.AbortWithStatusJSON(http.StatusConflict, common.StatusMessage{Message: "The account of the provider is linked to a different user already!"})
+			return false
+		}
+		return true
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.WithError(err).WithField("provider", p.Name).Error("Can not load identity.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return false
+	}
+
+	current, err := h.identities.ByProvider(userID, p.Name)
+	switch {
+	case err == nil && current.Subject.Valid:
+		g.AbortWithStatusJSON(http.StatusConflict, common.StatusMessage{Message: "A different account of the provider is linked already, please remove it first!"})
+		return false
+	case err == nil:
+		current.Subject = null.StringFrom(ident.Subject)
+		current.Email = ident.Email
+		err = h.identities.Update(current)
+	case errors.Is(err, sql.ErrNoRows):
+		err = h.identities.Store(identity.NewIdentity(userID, p.Name, ident.Subject, ident.Email))
+	}
+	if err != nil {
+		log.WithError(err).WithField("provider", p.Name).Error("Can not link identity.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return false
+	}
+	h.alertLinked(g, p, userID)
+	return true
+}
+
+// alertLinked publishes a security alert of the identity of the provider linked to the user. Linking does not fail on
+// errors, the alert is only skipped.
+func (h *OAuthHandler) alertLinked(g *gin.Context, p *provider.Provider, userID uuid.UUID) {
+	usr, err := h.users.ByID(userID)
+	if err != nil {
+		log.WithError(err).WithField("provider", p.Name).Warn("Failed to collect user of linked identity.")
+		return
+	}
+	h.jwt.ps.Pub(common.Event{
+		ID:   uuid.New(),
+		Type: common.IdentityLinked,
+		Time: time.Now(),
+		Data: common.SecurityData{Email: usr.Email, IP: g.ClientIP(), Device: session.Device(g.Request.UserAgent()), Detail: p.Name},
+		User: usr.ID,
+	}, common.HistoryTopic, common.NotificationTopic)
+}
+
+func (h *OAuthHandler) provider(g *gin.Context) (*provider.Provider, bool) {
+	p, ok := h.providers.ByName(g.Param("provider"))
+	if !ok {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "Identity provider not found!"})
+	}
+	return p, ok
+}
+
+// authenticateCode is a method of `OAuthHandler` validating the state of the callback against the state cookie of the
+// browser, and exchanging the code with the PKCE verifier of the request. Returns the identity and the state of the request.
+func (h *OAuthHandler) authenticateCode(g *gin.Context, p *provider.Provider) (*provider.Identity, *oauthClaims, error) {
+	cookie, err := g.Cookie(oauthStateKey)
+	g.SetSameSite(http.SameSiteLaxMode)
+	g.SetCookie(oauthStateKey, "", -1, oauthCookiePath, "", h.secure, true)
+	if err != nil {
+		log.Warn("missing oauth state")
+		return nil, nil, errInvalidState
+	}
+
+	var c oauthClaims
+	token, err := jwt.ParseWithClaims(cookie, &c, h.jwt.keySet.Keyfunc, jwt.WithExpirationRequired(), jwt.WithAudience(oauthAudience))
+	if err != nil || !token.Valid || c.Provider != p.Name || c.State == "" ||
+		subtle.ConstantTimeCompare([]byte(c.State), []byte(g.Query("state"))) != 1 {
+		log.Warn("invalid oauth state")
+		return nil, nil, errInvalidState
+	}
+
+	ident, err := p.Exchange(g.Request.Context(), g.Query("code"), oauth2.VerifierOption(c.Verifier))
+	if err != nil {
+		log.WithError(err).WithField("provider", p.Name).Error("OAuth authentication failed")
+		return nil, nil, err
+	}
+	return ident, &c, nil
+}
+
+// returnURL is a method of `OAuthHandler` resolving the page to return to after signing in. Only pages of the frontend
+// are accepted, so the sign in can not be used as an open redirect. Empty for the default page.
+func (h *OAuthHandler) returnURL(raw string) (string, bool) {
+	if raw == "" {
+		return "", true
+	}
+	if strings.HasPrefix(raw, "//") || strings.Contains(raw, "\\") {
+		return "", false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if !u.IsAbs() {
+		if !strings.HasPrefix(u.Path, "/") || u.Host != "" {
+			return "", false
+		}
+		u = h.frontend.ResolveReference(&url.URL{Path: u.Path, RawQuery: u.RawQuery, Fragment: u.Fragment})
+	}
+	if u.Scheme != h.frontend.Scheme || u.Host != h.frontend.Host || u.User != nil {
+		return "", false
+	}
+	return u.String(), true
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
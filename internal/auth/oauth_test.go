@@ -0,0 +1,495 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cskr/pubsub/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/identity"
+	"github.com/inokone/go-micro-saas/internal/auth/organization"
+	"github.com/inokone/go-micro-saas/internal/auth/passkey"
+	"github.com/inokone/go-micro-saas/internal/auth/provider"
+	"github.com/inokone/go-micro-saas/internal/auth/role"
+	"github.com/inokone/go-micro-saas/internal/auth/session"
+	"github.com/inokone/go-micro-saas/internal/auth/twofactor"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+// MockIdentityStorer is a mock implementation of the identity.Storer interface
+type MockIdentityStorer struct {
+	mock.Mock
+}
+
+func (m *MockIdentityStorer) Store(i *identity.Identity) error {
+	args := m.Called(i)
+	return args.Error(0)
+}
+
+func (m *MockIdentityStorer) Update(i *identity.Identity) error {
+	args := m.Called(i)
+	return args.Error(0)
+}
+
+func (m *MockIdentityStorer) ByUser(userID uuid.UUID) ([]identity.Identity, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]identity.Identity), args.Error(1)
+}
+
+func (m *MockIdentityStorer) ByProvider(userID uuid.UUID, provider string) (*identity.Identity, error) {
+	args := m.Called(userID, provider)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*identity.Identity), args.Error(1)
+}
+
+func (m *MockIdentityStorer) BySubject(provider string, subject string) (*identity.Identity, error) {
+	args := m.Called(provider, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*identity.Identity), args.Error(1)
+}
+
+func (m *MockIdentityStorer) Touch(id uuid.UUID, lastUsed time.Time) error {
+	args := m.Called(id, lastUsed)
+	return args.Error(0)
+}
+
+func (m *MockIdentityStorer) Delete(userID uuid.UUID, id uuid.UUID) (bool, error) {
+	args := m.Called(userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockRoleStorer is a mock implementation of the role.Storer interface
+type MockRoleStorer struct {
+	mock.Mock
+}
+
+func (m *MockRoleStorer) Store(r *role.Role) error {
+	args := m.Called(r)
+	return args.Error(0)
+}
+
+func (m *MockRoleStorer) Update(r role.ProfileRole) error {
+	args := m.Called(r)
+	return args.Error(0)
+}
+
+func (m *MockRoleStorer) Rename(id uuid.UUID, name string) error {
+	args := m.Called(id, name)
+	return args.Error(0)
+}
+
+func (m *MockRoleStorer) SetPermissions(id uuid.UUID, permissions []string) error {
+	args := m.Called(id, permissions)
+	return args.Error(0)
+}
+
+func (m *MockRoleStorer) SetLimits(id uuid.UUID, limits role.Limits) error {
+	args := m.Called(id, limits)
+	return args.Error(0)
+}
+
+func (m *MockRoleStorer) SetDefault(id uuid.UUID) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockRoleStorer) Delete(id uuid.UUID, target uuid.UUID) error {
+	args := m.Called(id, target)
+	return args.Error(0)
+}
+
+func (m *MockRoleStorer) ByID(id uuid.UUID) (*role.Role, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*role.Role), args.Error(1)
+}
+
+func (m *MockRoleStorer) Default() (*role.Role, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*role.Role), args.Error(1)
+}
+
+func (m *MockRoleStorer) List() ([]role.Role, error) {
+	args := m.Called()
+	return args.Get(0).([]role.Role), args.Error(1)
+}
+
+// MockPasskeyStorer is a mock implementation of the passkey.Storer interface
+type MockPasskeyStorer struct {
+	mock.Mock
+}
+
+func (m *MockPasskeyStorer) Store(credential *passkey.Credential) error {
+	args := m.Called(credential)
+	return args.Error(0)
+}
+
+func (m *MockPasskeyStorer) Update(credential *passkey.Credential) error {
+	args := m.Called(credential)
+	return args.Error(0)
+}
+
+func (m *MockPasskeyStorer) ByID(id []byte) (*passkey.Credential, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*passkey.Credential), args.Error(1)
+}
+
+func (m *MockPasskeyStorer) ByUser(userID uuid.UUID) ([]passkey.Credential, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]passkey.Credential), args.Error(1)
+}
+
+func (m *MockPasskeyStorer) Delete(userID uuid.UUID, id []byte) (bool, error) {
+	args := m.Called(userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPasskeyStorer) StoreCeremony(ceremony *passkey.Ceremony) error {
+	args := m.Called(ceremony)
+	return args.Error(0)
+}
+
+func (m *MockPasskeyStorer) TakeCeremony(id uuid.UUID) (*passkey.Ceremony, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*passkey.Ceremony), args.Error(1)
+}
+
+// fakeProvider is an identity provider accepting a single code, bound to the PKCE challenge of the last authorization.
+func fakeProvider(t *testing.T) *httptest.Server {
+	var challenge string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		challenge = r.URL.Query().Get("code_challenge")
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if r.Form.Get("code") != "valid-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"access","token_type":"Bearer"}`))
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"sub": "1234", "email": "test@example.com"})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestOAuthHandler(t *testing.T, srv *httptest.Server, users *MockUserStorer, identities *MockIdentityStorer, sessions *MockSessionStorer) *OAuthHandler {
+	return newTestOAuthHandlerWithRoles(t, srv, users, identities, new(MockRoleStorer), new(MockOrganizationStorer), sessions)
+}
+
+func newTestOAuthHandlerWithRoles(t *testing.T, srv *httptest.Server, users *MockUserStorer, identities *MockIdentityStorer, roles *MockRoleStorer, orgs *MockOrganizationStorer, sessions *MockSessionStorer) *OAuthHandler {
+	factors := new(MockFactorStorer)
+	factors.On("ByUser", mock.Anything).Return(nil, sql.ErrNoRows)
+	return newTestOAuthHandlerWithFactors(t, srv, users, identities, roles, orgs, factors, new(MockPasskeyStorer), sessions)
+}
+
+func newTestOAuthHandlerWithFactors(t *testing.T, srv *httptest.Server, users *MockUserStorer, identities *MockIdentityStorer, roles *MockRoleStorer, orgs *MockOrganizationStorer, factors *MockFactorStorer, passkeys *MockPasskeyStorer, sessions *MockSessionStorer) *OAuthHandler {
+	conf := common.AuthConfig{
+		FrontendRoot: "http://localhost:3000",
+		BackendRoot:  "http://localhost:8080",
+		Providers: []common.ProviderConfig{{
+			Name:        "keycloak",
+			DisplayName: "Keycloak",
+			ClientID:    "client",
+			AuthURL:     srv.URL + "/authorize",
+			TokenURL:    srv.URL + "/token",
+			UserInfoURL: srv.URL + "/userinfo",
+		}},
+	}
+	providers, err := provider.NewRegistry(&conf)
+	assert.NoError(t, err)
+	pks, err := passkey.NewService(passkeys, users, &conf, "Test")
+	assert.NoError(t, err)
+	m := NewJWTHandler(users, sessions, new(MockKeyStorer), orgs, testKeySet, testConfig, pubsub.New[string, common.Event](1))
+	service := NewService(users, new(MockAccountStorer), factors, pks, m, testLimiter("ip:3/1h"), nil, testSender, &conf)
+	h, err := NewOAuthHandler(conf, providers, users, identities, roles, service, m)
+	assert.NoError(t, err)
+	return h
+}
+
+func signin(t *testing.T, h *OAuthHandler, returnTo string) *httptest.ResponseRecorder {
+	router := setupTestRouter()
+	router.GET("/api/public/v1/auth/:provider", h.Signin)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/public/v1/auth/keycloak?return_to="+url.QueryEscape(returnTo), nil)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func callback(h *OAuthHandler, state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	router := setupTestRouter()
+	router.GET("/api/public/v1/auth/:provider/redirect", h.Redirect)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/public/v1/auth/keycloak/redirect?code=valid-code&state="+url.QueryEscape(state), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestOAuthSigninBindsStateAndChallengeToCookie(t *testing.T) {
+	srv := fakeProvider(t)
+	h := newTestOAuthHandler(t, srv, new(MockUserStorer), new(MockIdentityStorer), new(MockSessionStorer))
+
+	first := signin(t, h, "")
+	second := signin(t, h, "")
+
+	assert.Equal(t, http.StatusTemporaryRedirect, first.Code)
+	location, err := url.Parse(first.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, location.Query().Get("state"))
+	assert.Equal(t, "S256", location.Query().Get("code_challenge_method"))
+	assert.NotEmpty(t, location.Query().Get("code_challenge"))
+
+	other, err := url.Parse(second.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, location.Query().Get("state"), other.Query().Get("state"))
+
+	cookie := cookieOf(first, oauthStateKey)
+	assert.NotNil(t, cookie)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, oauthCookiePath, cookie.Path)
+}
+
+func TestOAuthSigninRejectsForeignReturnURL(t *testing.T) {
+	srv := fakeProvider(t)
+	h := newTestOAuthHandler(t, srv, new(MockUserStorer), new(MockIdentityStorer), new(MockSessionStorer))
+
+	for _, returnTo := range []string{"https://evil.com/dashboard", "//evil.com", "/\\evil.com", "javascript:alert(1)", "dashboard"} {
+		w := signin(t, h, returnTo)
+		assert.Equal(t, http.StatusBadRequest, w.Code, returnTo)
+		assert.Nil(t, cookieOf(w, oauthStateKey))
+	}
+}
+
+func TestOAuthRedirectRejectsMismatchedState(t *testing.T) {
+	srv := fakeProvider(t)
+	users := new(MockUserStorer)
+	h := newTestOAuthHandler(t, srv, users, new(MockIdentityStorer), new(MockSessionStorer))
+	w := signin(t, h, "")
+
+	missing := callback(h, "state", nil)
+	mismatched := callback(h, "state", cookieOf(w, oauthStateKey))
+
+	assert.Equal(t, http.StatusInternalServerError, missing.Code)
+	assert.Equal(t, http.StatusInternalServerError, mismatched.Code)
+	users.AssertNotCalled(t, "ByEmail", mock.Anything)
+}
+
+// authorize follows the redirect of the sign in to the provider, which records the PKCE challenge, and returns the state.
+func authorize(t *testing.T, authURL string) string {
+	location, err := url.Parse(authURL)
+	assert.NoError(t, err)
+	_, err = http.Get(location.String())
+	assert.NoError(t, err)
+	return location.Query().Get("state")
+}
+
+func TestOAuthRedirectReturnsToRequestedPage(t *testing.T) {
+	srv := fakeProvider(t)
+	users := new(MockUserStorer)
+	identities := new(MockIdentityStorer)
+	sessions := new(MockSessionStorer)
+	h := newTestOAuthHandler(t, srv, users, identities, sessions)
+	usr := testUser()
+	linked := identity.NewIdentity(usr.ID, "keycloak", "1234", usr.Email)
+
+	identities.On("BySubject", "keycloak", "1234").Return(linked, nil)
+	identities.On("Touch", linked.ID, mock.Anything).Return(nil)
+	users.On("ByID", usr.ID).Return(usr, nil)
+	sessions.On("Familiar", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+	sessions.On("Store", mock.MatchedBy(func(s *session.Session) bool { return s.UserID == usr.ID })).Return(nil)
+
+	w := signin(t, h, "/settings?tab=security")
+	state := authorize(t, w.Header().Get("Location"))
+
+	res := callback(h, state, cookieOf(w, oauthStateKey))
+
+	assert.Equal(t, http.StatusTemporaryRedirect, res.Code)
+	assert.Equal(t, "http://localhost:3000/settings?tab=security", res.Header().Get("Location"))
+	assert.NotNil(t, cookieOf(res, jwtTokenKey))
+	users.AssertExpectations(t)
+	identities.AssertExpectations(t)
+	sessions.AssertExpectations(t)
+}
+
+func TestOAuthRedirectChallengesSecondFactor(t *testing.T) {
+	srv := fakeProvider(t)
+	users := new(MockUserStorer)
+	identities := new(MockIdentityStorer)
+	factors := new(MockFactorStorer)
+	passkeys := new(MockPasskeyStorer)
+	sessions := new(MockSessionStorer)
+	h := newTestOAuthHandlerWithFactors(t, srv, users, identities, new(MockRoleStorer), new(MockOrganizationStorer), factors, passkeys, sessions)
+	usr := testUser()
+	linked := identity.NewIdentity(usr.ID, "keycloak", "1234", usr.Email)
+
+	identities.On("BySubject", "keycloak", "1234").Return(linked, nil)
+	identities.On("Touch", linked.ID, mock.Anything).Return(nil)
+	users.On("ByID", usr.ID).Return(usr, nil)
+	factors.On("ByUser", usr.ID).Return(&twofactor.Settings{UserID: usr.ID, Enabled: true}, nil)
+	passkeys.On("ByUser", usr.ID).Return([]passkey.Credential{}, nil)
+
+	w := signin(t, h, "/settings")
+	state := authorize(t, w.Header().Get("Location"))
+
+	res := callback(h, state, cookieOf(w, oauthStateKey))
+
+	assert.Equal(t, http.StatusTemporaryRedirect, res.Code)
+	location, err := url.Parse(res.Header().Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, "/signin/2fa", location.Path)
+	assert.Equal(t, twofactor.MethodTOTP, location.Query().Get("methods"))
+	assert.Equal(t, "http://localhost:3000/settings", location.Query().Get("return_to"))
+	userID, err := h.jwt.ParseChallenge(location.Query().Get("token"))
+	assert.NoError(t, err)
+	assert.Equal(t, usr.ID, userID)
+	assert.Nil(t, cookieOf(res, jwtTokenKey))
+	assert.Nil(t, cookieOf(res, refreshTokenKey))
+	sessions.AssertNotCalled(t, "Store", mock.Anything)
+}
+
+func TestOAuthRedirectFailsOnUserLookupError(t *testing.T) {
+	srv := fakeProvider(t)
+	users := new(MockUserStorer)
+	identities := new(MockIdentityStorer)
+	roles := new(MockRoleStorer)
+	h := newTestOAuthHandlerWithRoles(t, srv, users, identities, roles, new(MockOrganizationStorer), new(MockSessionStorer))
+
+	identities.On("BySubject", "keycloak", "1234").Return(nil, sql.ErrNoRows)
+	users.On("ByEmail", "test@example.com").Return(nil, sql.ErrConnDone)
+
+	w := signin(t, h, "")
+	state := authorize(t, w.Header().Get("Location"))
+
+	res := callback(h, state, cookieOf(w, oauthStateKey))
+
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+	roles.AssertNotCalled(t, "Default")
+	users.AssertNotCalled(t, "Store", mock.Anything)
+}
+
+func TestOAuthRedirectRejectsUnlinkedUserWithSameEmail(t *testing.T) {
+	srv := fakeProvider(t)
+	users := new(MockUserStorer)
+	identities := new(MockIdentityStorer)
+	h := newTestOAuthHandler(t, srv, users, identities, new(MockSessionStorer))
+	usr := testUser()
+
+	identities.On("BySubject", "keycloak", "1234").Return(nil, sql.ErrNoRows)
+	users.On("ByEmail", "test@example.com").Return(usr, nil)
+	identities.On("ByProvider", usr.ID, "keycloak").Return(nil, sql.ErrNoRows)
+
+	w := signin(t, h, "")
+	state := authorize(t, w.Header().Get("Location"))
+
+	res := callback(h, state, cookieOf(w, oauthStateKey))
+
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Nil(t, cookieOf(res, jwtTokenKey))
+	identities.AssertNotCalled(t, "Store", mock.Anything)
+}
+
+func TestOAuthRedirectRegistersUserWithDefaultRole(t *testing.T) {
+	srv := fakeProvider(t)
+	users := new(MockUserStorer)
+	identities := new(MockIdentityStorer)
+	roles := new(MockRoleStorer)
+	orgs := new(MockOrganizationStorer)
+	sessions := new(MockSessionStorer)
+	h := newTestOAuthHandlerWithRoles(t, srv, users, identities, roles, orgs, sessions)
+	defaultRole := &role.Role{ID: uuid.New(), DisplayName: "Trial", DefaultSignup: true}
+	usr := testUser()
+	usr.RoleID = defaultRole.ID
+
+	identities.On("BySubject", "keycloak", "1234").Return(nil, sql.ErrNoRows)
+	users.On("ByEmail", "test@example.com").Return(nil, sql.ErrNoRows).Once()
+	roles.On("Default").Return(defaultRole, nil)
+	users.On("Store", mock.MatchedBy(func(u *user.User) bool { return u.RoleID == defaultRole.ID })).Return(nil)
+	identities.On("Store", mock.Anything).Return(nil)
+	orgs.On("Store", mock.MatchedBy(func(o *organization.Organization) bool { return o.Name == "test@example.com" }), mock.Anything).Return(nil)
+	users.On("ByEmail", "test@example.com").Return(usr, nil)
+	sessions.On("Familiar", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+	sessions.On("Store", mock.Anything).Return(nil)
+
+	w := signin(t, h, "")
+	state := authorize(t, w.Header().Get("Location"))
+
+	res := callback(h, state, cookieOf(w, oauthStateKey))
+
+	assert.Equal(t, http.StatusTemporaryRedirect, res.Code)
+	users.AssertExpectations(t)
+	roles.AssertExpectations(t)
+	orgs.AssertExpectations(t)
+}
+
+func TestOAuthLinkAttachesIdentityToCurrentUser(t *testing.T) {
+	srv := fakeProvider(t)
+	users := new(MockUserStorer)
+	identities := new(MockIdentityStorer)
+	h := newTestOAuthHandler(t, srv, users, identities, new(MockSessionStorer))
+	usr := testUser()
+
+	users.On("ByID", usr.ID).Return(usr, nil)
+	identities.On("BySubject", "keycloak", "1234").Return(nil, sql.ErrNoRows)
+	identities.On("ByProvider", usr.ID, "keycloak").Return(nil, sql.ErrNoRows)
+	identities.On("Store", mock.MatchedBy(func(i *identity.Identity) bool {
+		return i.UserID == usr.ID && i.Provider == "keycloak" && i.Subject.String == "1234"
+	})).Return(nil)
+
+	router := setupTestRouter()
+	router.POST("/api/v1/account/identities/:provider", func(g *gin.Context) {
+		g.Set("user", usr)
+	}, h.Link)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/account/identities/keycloak", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var link identity.Link
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+	state := authorize(t, link.URL)
+
+	res := callback(h, state, cookieOf(w, oauthStateKey))
+
+	assert.Equal(t, http.StatusTemporaryRedirect, res.Code)
+	assert.Equal(t, "http://localhost:3000/dashboard", res.Header().Get("Location"))
+	assert.Nil(t, cookieOf(res, jwtTokenKey))
+	identities.AssertExpectations(t)
+}
@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inokone/go-micro-saas/internal/auth/organization"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+var statusMemberNotFound = common.StatusMessage{Message: "Member not found!"}
+
+// OrganizationHandler is a struct for web handles related to organizations and their members.
+type OrganizationHandler struct {
+	orgs organization.Storer
+	jwt  *JWTHandler
+}
+
+// NewOrganizationHandler is a function creating an instance of `OrganizationHandler`
+func NewOrganizationHandler(orgs organization.Storer, jwt *JWTHandler) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgs: orgs,
+		jwt:  jwt,
+	}
+}
+
+// List is a method of `OrganizationHandler`. Lists the organizations of the current user, marking the active one.
+// @Summary Organization list endpoint
+// @Schemes
+// @Description Lists the organizations the current user is a member of
+// @Accept json
+// @Produce json
+// @Success 200 {array} organization.View
+// @Failure 401 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /organizations [get]
+func (h *OrganizationHandler) List(g *gin.Context) {
+	usr := currentUser(g)
+	affiliations, err := h.orgs.ByUser(usr.ID)
+	if err != nil {
+		log.WithError(err).Error("Failed to collect organizations.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return
+	}
+	active := organization.Active(g)
+	res := make([]organization.View, 0, len(affiliations))
+	for _, a := range affiliations {
+		res = append(res, a.AsView(active != nil && active.OrganizationID == a.ID))
+	}
+	g.JSON(http.StatusOK, res)
+}
+
+// Create is a method of `OrganizationHandler`. Creates a new organization owned by the current user.
+// @Summary Organization create endpoint
+// @Schemes
+// @Description Creates a new organization, the current user is its owner
+// @Accept json
+// @Produce json
+// @Param data body organization.NameRequest true "The name of the organization"
+// @Success 201 {object} organization.View
+// @Failure 400 {object} common.StatusMessage
+// @Failure 401 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /organizations [post]
+func (h *OrganizationHandler) Create(g *gin.Context) {
+	var in organization.NameRequest
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+	usr := currentUser(g)
+	org := organization.NewOrganization(in.Name)
+	if err := h.orgs.Store(org, usr.ID); err != nil {
+		log.WithError(err).Error("Failed to store organization.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return
+	}
+	a := organization.Affiliation{Organization: *org, Role: organization.RoleOwner}
+	g.JSON(http.StatusCreated, a.AsView(false))
+}
+
+// Switch is a method of `OrganizationHandler`. Makes the organization with the ID provided as URL parameter the active
+// one of the current session.
+// @Summary Organization switch endpoint
+// @Schemes
+// @Description Sets up the JWT authorization of the current user for the organization
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the organization"
+// @Success 200 {object} common.StatusMessage
+// @Failure 401 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /organizations/{id}/active [put]
+func (h *OrganizationHandler) Switch(g *gin.Context) {
+	if impersonationOf(g) != nil {
+		g.AbortWithStatusJSON(http.StatusForbidden, statusImpersonating)
+		return
+	}
+	if _, ok := g.Get("session"); !ok {
+		g.AbortWithStatusJSON(http.StatusForbidden, common.StatusMessage{Message: "Switching organization requires a signed in session!"})
+		return
+	}
+	id, err := uuid.Parse(g.Param("id"))
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "Organization not found!"})
+		return
+	}
+	if _, err = h.orgs.Membership(id, currentUser(g).ID); err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "Organization not found!"})
+		return
+	}
+	if err = h.jwt.SwitchOrganization(g, id); err != nil {
+		return
+	}
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Organization switched!"})
+}
+
+// Rename is a method of `OrganizationHandler`. Renames the active organization of the current user.
+// @Summary Organization rename endpoint
+// @Schemes
+// @Description Renames the active organization
+// @Accept json
+// @Produce json
+// @Param data body organization.NameRequest true "The new name of the organization"
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /organization [put]
+func (h *OrganizationHandler) Rename(g *gin.Context) {
+	var in organization.NameRequest
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+	if err := h.orgs.Rename(organization.Active(g).OrganizationID, in.Name); err != nil {
+		log.WithError(err).Error("Failed to rename organization.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return
+	}
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Organization renamed!"})
+}
+
+// Members is a method of `OrganizationHandler`. Lists the members of the active organization of the current user.
+// @Summary Organization member list endpoint
+// @Schemes
+// @Description Lists the members of the active organization
+// @Accept json
+// @Produce json
+// @Success 200 {array} organization.MemberView
+// @Failure 403 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /organization/members [get]
+func (h *OrganizationHandler) Members(g *gin.Context) {
+	members, err := h.orgs.Members(organization.Active(g).OrganizationID)
+	if err != nil {
+		log.WithError(err).Error("Failed to collect members.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return
+	}
+	res := make([]organization.MemberView, 0, len(members))
+	for _, m := range members {
+		res = append(res, m.AsView())
+	}
+	g.JSON(http.StatusOK, res)
+}
+
+// SetMemberRole is a method of `OrganizationHandler`. Changes the role of a member of the active organization. Only
+// owners can make or unmake owners, and the last owner can not step down.
+// @Summary Organization member role endpoint
+// @Schemes
+// @Description Changes the role of a member of the active organization
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the user"
+// @Param data body organization.RoleRequest true "The new role of the member"
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /organization/members/{id}/role [put]
+func (h *OrganizationHandler) SetMemberRole(g *gin.Context) {
+	var in organization.RoleRequest
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+	active := organization.Active(g)
+	m, ok := h.memberOf(g, active)
+	if !ok {
+		return
+	}
+	if (m.Is(organization.RoleOwner) || in.Role == organization.RoleOwner) && !active.Is(organization.RoleOwner) {
+		g.AbortWithStatusJSON(http.StatusForbidden, common.StatusMessage{Message: "Only owners can change the owners of the organization!"})
+		return
+	}
+	if m.Is(organization.RoleOwner) && in.Role != organization.RoleOwner && !h.hasOtherOwner(g, m) {
+		return
+	}
+	if err := h.orgs.SetRole(m.OrganizationID, m.UserID, in.Role); err != nil {
+		log.WithError(err).Error("Failed to set role of member.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return
+	}
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Member updated!"})
+}
+
+// RemoveMember is a method of `OrganizationHandler`. Removes a user from the active organization. Any member can leave
+// the organization, managing the others needs an owner or admin role.
+// @Summary Organization member remove endpoint
+// @Schemes
+// @Description Removes a member from the active organization
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the user"
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /organization/members/{id} [delete]
+func (h *OrganizationHandler) RemoveMember(g *gin.Context) {
+	active := organization.Active(g)
+	m, ok := h.memberOf(g, active)
+	if !ok {
+		return
+	}
+	if m.UserID != active.UserID && (!active.CanManage() || (m.Is(organization.RoleOwner) && !active.Is(organization.RoleOwner))) {
+		g.AbortWithStatusJSON(http.StatusForbidden, statusForbidden)
+		return
+	}
+	if m.Is(organization.RoleOwner) && !h.hasOtherOwner(g, m) {
+		return
+	}
+	if err := h.orgs.RemoveMember(m.OrganizationID, m.UserID); err != nil {
+		log.WithError(err).Error("Failed to remove member.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return
+	}
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Member removed!"})
+}
+
+// memberOf loads the membership of the user with the ID provided as URL parameter in the active organization.
+func (h *OrganizationHandler) memberOf(g *gin.Context, active *organization.Membership) (*organization.Membership, bool) {
+	id, err := uuid.Parse(g.Param("id"))
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, statusMemberNotFound)
+		return nil, false
+	}
+	m, err := h.orgs.Membership(active.OrganizationID, id)
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, statusMemberNotFound)
+		return nil, false
+	}
+	return m, true
+}
+
+// hasOtherOwner checks that the organization keeps an owner without the member provided.
+func (h *OrganizationHandler) hasOtherOwner(g *gin.Context, m *organization.Membership) bool {
+	owners, err := h.orgs.CountOwners(m.OrganizationID)
+	if err != nil {
+		log.WithError(err).Error("Failed to count owners.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusSomethingWrong)
+		return false
+	}
+	if owners <= 1 {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "The organization needs at least one owner!"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,175 @@
+package organization
+
+import (
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// RoleOwner is the role of the members managing the organization, including its owners.
+	RoleOwner = "owner"
+	// RoleAdmin is the role of the members managing the other members of the organization.
+	RoleAdmin = "admin"
+	// RoleMember is the role of the regular members of the organization.
+	RoleMember = "member"
+)
+
+const (
+	// activeKey is the key of the active membership of the request in the Gin context.
+	activeKey = "membership"
+	// nameLength is the maximum length of the name of an organization.
+	nameLength = 100
+)
+
+// Organization is a struct representing a workspace of users for database storage.
+type Organization struct {
+	ID        uuid.UUID `db:"organization_id"`
+	Name      string    `db:"name"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// NewOrganization creates a new `Organization` with the name provided.
+func NewOrganization(name string) *Organization {
+	return &Organization{
+		ID:        uuid.New(),
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+}
+
+// NewPersonal creates the personal `Organization` of a new user, with the ID of the user and named after the email
+// address of the user, as the organizations of the users registered before organizations were introduced.
+func NewPersonal(userID uuid.UUID, email string) *Organization {
+	name := []rune(email)
+	if len(name) > nameLength {
+		name = name[:nameLength]
+	}
+	return &Organization{
+		ID:        userID,
+		Name:      string(name),
+		CreatedAt: time.Now(),
+	}
+}
+
+// Membership is a struct representing the role of a user in an organization for database storage.
+type Membership struct {
+	OrganizationID uuid.UUID `db:"organization_id"`
+	UserID         uuid.UUID `db:"user_id"`
+	Role           string    `db:"role"`
+	CreatedAt      time.Time `db:"created_at"`
+}
+
+// NewMembership creates a new `Membership` of a user in an organization with the role provided.
+func NewMembership(organizationID uuid.UUID, userID uuid.UUID, role string) *Membership {
+	return &Membership{
+		OrganizationID: organizationID,
+		UserID:         userID,
+		Role:           role,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// Is is a method of `Membership` returning whether the role of the member is one of the roles provided.
+func (m *Membership) Is(roles ...string) bool {
+	return slices.Contains(roles, m.Role)
+}
+
+// CanManage is a method of `Membership` returning whether the member can manage the other members of the organization.
+func (m *Membership) CanManage() bool {
+	return m.Is(RoleOwner, RoleAdmin)
+}
+
+// Member is a struct representing a membership with the details of the user, for listing the members of an organization.
+type Member struct {
+	Membership
+	Email     string `db:"email"`
+	FirstName string `db:"first_name"`
+	LastName  string `db:"last_name"`
+}
+
+// AsView is a method of the `Member` struct. It converts a `Member` object into a `MemberView` object.
+func (m *Member) AsView() MemberView {
+	return MemberView{
+		UserID:    m.UserID.String(),
+		Email:     m.Email,
+		FirstName: m.FirstName,
+		LastName:  m.LastName,
+		Role:      m.Role,
+		Joined:    int(m.CreatedAt.Unix()),
+	}
+}
+
+// MemberView is the JSON representation of a member of an organization.
+type MemberView struct {
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Role      string `json:"role"`
+	Joined    int    `json:"joined"`
+}
+
+// Affiliation is a struct representing an organization with the role of a user in it.
+type Affiliation struct {
+	Organization
+	Role string `db:"role"`
+}
+
+// AsView is a method of the `Affiliation` struct. It converts an `Affiliation` object into a `View` object.
+func (a *Affiliation) AsView(active bool) View {
+	return View{
+		ID:     a.ID.String(),
+		Name:   a.Name,
+		Role:   a.Role,
+		Active: active,
+	}
+}
+
+// View is the JSON representation of an organization of the current user.
+type View struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	Active bool   `json:"active"`
+}
+
+// NameRequest is a struct for the message body of creating or renaming an organization.
+type NameRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// RoleRequest is a struct for the message body of changing the role of a member.
+type RoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner admin member"`
+}
+
+// Storer is the interface for `Organization` and `Membership` persistence
+type Storer interface {
+	Store(org *Organization, owner uuid.UUID) error
+	Rename(id uuid.UUID, name string) error
+	ByID(id uuid.UUID) (*Organization, error)
+	ByUser(userID uuid.UUID) ([]Affiliation, error)
+	Membership(id uuid.UUID, userID uuid.UUID) (*Membership, error)
+	Members(id uuid.UUID) ([]Member, error)
+	AddMember(m *Membership) error
+	SetRole(id uuid.UUID, userID uuid.UUID, role string) error
+	RemoveMember(id uuid.UUID, userID uuid.UUID) error
+	CountOwners(id uuid.UUID) (int, error)
+}
+
+// SetActive is a function setting the membership of the active organization of the request in the Gin context.
+func SetActive(g *gin.Context, m *Membership) {
+	g.Set(activeKey, m)
+}
+
+// Active is a function returning the membership of the active organization of the request, nil if there is none.
+func Active(g *gin.Context) *Membership {
+	m, ok := g.Get(activeKey)
+	if !ok {
+		return nil
+	}
+	return m.(*Membership)
+}
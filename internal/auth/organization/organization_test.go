@@ -0,0 +1,37 @@
+package organization
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanManageOnlyForOwnersAndAdmins(t *testing.T) {
+	orgID, userID := uuid.New(), uuid.New()
+
+	assert.True(t, NewMembership(orgID, userID, RoleOwner).CanManage())
+	assert.True(t, NewMembership(orgID, userID, RoleAdmin).CanManage())
+	assert.False(t, NewMembership(orgID, userID, RoleMember).CanManage())
+}
+
+func TestAffiliationAsViewRetainsFields(t *testing.T) {
+	org := NewOrganization("Acme")
+	a := Affiliation{Organization: *org, Role: RoleAdmin}
+
+	view := a.AsView(true)
+
+	assert.Equal(t, View{ID: org.ID.String(), Name: "Acme", Role: RoleAdmin, Active: true}, view)
+}
+
+func TestNewPersonalTruncatesLongEmail(t *testing.T) {
+	userID := uuid.New()
+	email := strings.Repeat("a", 120) + "@example.com"
+
+	org := NewPersonal(userID, email)
+
+	assert.Equal(t, userID, org.ID)
+	assert.Equal(t, email[:nameLength], org.Name)
+	assert.Equal(t, "test@example.com", NewPersonal(userID, "test@example.com").Name)
+}
@@ -0,0 +1,132 @@
+package organization
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresStorer is the `Storer` implementation based on sqlx library.
+type PostgresStorer struct {
+	db *sqlx.DB
+}
+
+// NewPostgresStorer creates a new `PostgresStorer` instance based on the sqlx library.
+func NewPostgresStorer(db *sqlx.DB) *PostgresStorer {
+	return &PostgresStorer{
+		db: db,
+	}
+}
+
+// Store is a method of the `PostgresStorer` struct. Takes an `Organization` and the ID of its owner as parameters and
+// persists them.
+func (s *PostgresStorer) Store(org *Organization, owner uuid.UUID) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to store organization: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO microsaas.organizations (organization_id, name, created_at) VALUES ($1, $2, $3)`
+	if _, err = tx.Exec(query, org.ID, org.Name, org.CreatedAt); err != nil {
+		return fmt.Errorf("failed to store organization: %w", err)
+	}
+	query = `INSERT INTO microsaas.memberships (organization_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)`
+	if _, err = tx.Exec(query, org.ID, owner, RoleOwner, org.CreatedAt); err != nil {
+		return fmt.Errorf("failed to store owner of organization: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store organization: %w", err)
+	}
+	return nil
+}
+
+// Rename is a method of the `PostgresStorer` struct. Takes an organization ID and a new name for the organization.
+func (s *PostgresStorer) Rename(id uuid.UUID, name string) error {
+	if _, err := s.db.Exec(`UPDATE microsaas.organizations SET name = $1 WHERE organization_id = $2`, name, id); err != nil {
+		return fmt.Errorf("failed to rename organization: %w", err)
+	}
+	return nil
+}
+
+// ByID is a method of the `PostgresStorer` struct. Takes an UUID as parameter to load an `Organization` from persistence.
+func (s *PostgresStorer) ByID(id uuid.UUID) (*Organization, error) {
+	var org Organization
+	query := `SELECT organization_id, name, created_at FROM microsaas.organizations WHERE organization_id = $1`
+	if err := s.db.Get(&org, query, id); err != nil {
+		return nil, fmt.Errorf("failed to get organization by ID: %w", err)
+	}
+	return &org, nil
+}
+
+// ByUser is a method of the `PostgresStorer` struct. Loads the organizations of the user in parameter with the role of
+// the user, the oldest membership first.
+func (s *PostgresStorer) ByUser(userID uuid.UUID) ([]Affiliation, error) {
+	var res []Affiliation
+	query := `SELECT o.organization_id, o.name, o.created_at, m.role FROM microsaas.organizations o
+		JOIN microsaas.memberships m ON m.organization_id = o.organization_id
+		WHERE m.user_id = $1 ORDER BY m.created_at`
+	if err := s.db.Select(&res, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to get organizations of user: %w", err)
+	}
+	return res, nil
+}
+
+// Membership is a method of the `PostgresStorer` struct. Loads the `Membership` of a user in an organization.
+func (s *PostgresStorer) Membership(id uuid.UUID, userID uuid.UUID) (*Membership, error) {
+	var m Membership
+	query := `SELECT organization_id, user_id, role, created_at FROM microsaas.memberships WHERE organization_id = $1 AND user_id = $2`
+	if err := s.db.Get(&m, query, id, userID); err != nil {
+		return nil, fmt.Errorf("failed to get membership: %w", err)
+	}
+	return &m, nil
+}
+
+// Members is a method of the `PostgresStorer` struct. Loads the members of an organization with their details.
+func (s *PostgresStorer) Members(id uuid.UUID) ([]Member, error) {
+	var res []Member
+	query := `SELECT m.organization_id, m.user_id, m.role, m.created_at, u.email, u.first_name, u.last_name
+		FROM microsaas.memberships m JOIN microsaas.users u ON u.user_id = m.user_id
+		WHERE m.organization_id = $1 AND u.deleted_at is null ORDER BY m.created_at`
+	if err := s.db.Select(&res, query, id); err != nil {
+		return nil, fmt.Errorf("failed to get members of organization: %w", err)
+	}
+	return res, nil
+}
+
+// AddMember is a method of the `PostgresStorer` struct. Takes a `Membership` as parameter and persists it.
+func (s *PostgresStorer) AddMember(m *Membership) error {
+	query := `INSERT INTO microsaas.memberships (organization_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)`
+	if _, err := s.db.Exec(query, m.OrganizationID, m.UserID, m.Role, m.CreatedAt); err != nil {
+		return fmt.Errorf("failed to add member: %w", err)
+	}
+	return nil
+}
+
+// SetRole is a method of the `PostgresStorer` struct. Changes the role of a member of an organization.
+func (s *PostgresStorer) SetRole(id uuid.UUID, userID uuid.UUID, role string) error {
+	query := `UPDATE microsaas.memberships SET role = $1 WHERE organization_id = $2 AND user_id = $3`
+	if _, err := s.db.Exec(query, role, id, userID); err != nil {
+		return fmt.Errorf("failed to set role of member: %w", err)
+	}
+	return nil
+}
+
+// RemoveMember is a method of the `PostgresStorer` struct. Removes a user from an organization.
+func (s *PostgresStorer) RemoveMember(id uuid.UUID, userID uuid.UUID) error {
+	if _, err := s.db.Exec(`DELETE FROM microsaas.memberships WHERE organization_id = $1 AND user_id = $2`, id, userID); err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	return nil
+}
+
+// CountOwners is a method of the `PostgresStorer` struct. Counts the owners of an organization.
+func (s *PostgresStorer) CountOwners(id uuid.UUID) (int, error) {
+	var count int
+	query := `SELECT count(*) FROM microsaas.memberships WHERE organization_id = $1 AND role = $2`
+	if err := s.db.Get(&count, query, id, RoleOwner); err != nil {
+		return 0, fmt.Errorf("failed to count owners of organization: %w", err)
+	}
+	return count, nil
+}
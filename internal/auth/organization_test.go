@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/apikey"
+	"github.com/inokone/go-micro-saas/internal/auth/organization"
+	"github.com/inokone/go-micro-saas/internal/auth/session"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+)
+
+// MockOrganizationStorer is a mock implementation of the organization.Storer interface
+type MockOrganizationStorer struct {
+	mock.Mock
+}
+
+func (m *MockOrganizationStorer) Store(org *organization.Organization, owner uuid.UUID) error {
+	args := m.Called(org, owner)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationStorer) Rename(id uuid.UUID, name string) error {
+	args := m.Called(id, name)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationStorer) ByID(id uuid.UUID) (*organization.Organization, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*organization.Organization), args.Error(1)
+}
+
+func (m *MockOrganizationStorer) ByUser(userID uuid.UUID) ([]organization.Affiliation, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]organization.Affiliation), args.Error(1)
+}
+
+func (m *MockOrganizationStorer) Membership(id uuid.UUID, userID uuid.UUID) (*organization.Membership, error) {
+	args := m.Called(id, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*organization.Membership), args.Error(1)
+}
+
+func (m *MockOrganizationStorer) Members(id uuid.UUID) ([]organization.Member, error) {
+	args := m.Called(id)
+	return args.Get(0).([]organization.Member), args.Error(1)
+}
+
+func (m *MockOrganizationStorer) AddMember(membership *organization.Membership) error {
+	args := m.Called(membership)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationStorer) SetRole(id uuid.UUID, userID uuid.UUID, role string) error {
+	args := m.Called(id, userID, role)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationStorer) RemoveMember(id uuid.UUID, userID uuid.UUID) error {
+	args := m.Called(id, userID)
+	return args.Error(0)
+}
+
+func (m *MockOrganizationStorer) CountOwners(id uuid.UUID) (int, error) {
+	args := m.Called(id)
+	return args.Int(0), args.Error(1)
+}
+
+// asMember calls the handler of the route provided as the user with the membership in the active organization.
+func asMember(handler gin.HandlerFunc, method string, route string, path string, usr *user.User, active *organization.Membership, body any) *httptest.ResponseRecorder {
+	router := setupTestRouter()
+	router.Handle(method, route, func(g *gin.Context) {
+		g.Set("user", usr)
+		organization.SetActive(g, active)
+	}, handler)
+
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(payload))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSwitchOrganizationCarriesOrganizationInToken(t *testing.T) {
+	users := new(MockUserStorer)
+	sessions := new(MockSessionStorer)
+	orgs := new(MockOrganizationStorer)
+	m := NewJWTHandler(users, sessions, new(MockKeyStorer), orgs, testKeySet, testConfig, nil)
+	h := NewOrganizationHandler(orgs, m)
+	usr := testUser()
+	sess, _, _ := session.NewSession(usr.ID, "127.0.0.1", "test", time.Hour)
+	org := organization.NewOrganization("Acme")
+	membership := organization.NewMembership(org.ID, usr.ID, organization.RoleAdmin)
+
+	orgs.On("Membership", org.ID, usr.ID).Return(membership, nil)
+	sessions.On("Update", mock.MatchedBy(func(s *session.Session) bool {
+		return s.ID == sess.ID && s.OrganizationID.UUID == org.ID
+	})).Return(nil)
+
+	router := setupTestRouter()
+	router.PUT("/organizations/:id/active", func(g *gin.Context) {
+		g.Set("user", usr)
+		g.Set("session", sess)
+	}, h.Switch)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/organizations/"+org.ID.String()+"/active", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	sessions.AssertExpectations(t)
+
+	sessions.On("ByID", sess.ID).Return(sess, nil)
+	users.On("ByID", usr.ID).Return(usr, nil)
+	router.GET("/organization/members", m.RequireOrganization(organization.RoleOwner, organization.RoleAdmin), func(g *gin.Context) {
+		assert.Equal(t, org.ID, organization.Active(g).OrganizationID)
+		g.Status(http.StatusOK)
+	})
+	res := httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/organization/members", nil)
+	req.AddCookie(cookieOf(w, jwtTokenKey))
+	router.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	orgs.AssertNotCalled(t, "ByUser", mock.Anything)
+}
+
+func TestSwitchOrganizationRejectsNonMembers(t *testing.T) {
+	orgs := new(MockOrganizationStorer)
+	sessions := new(MockSessionStorer)
+	m := NewJWTHandler(new(MockUserStorer), sessions, new(MockKeyStorer), orgs, testKeySet, testConfig, nil)
+	h := NewOrganizationHandler(orgs, m)
+	usr := testUser()
+	sess, _, _ := session.NewSession(usr.ID, "127.0.0.1", "test", time.Hour)
+	orgID := uuid.New()
+
+	orgs.On("Membership", orgID, usr.ID).Return(nil, assert.AnError)
+
+	router := setupTestRouter()
+	router.PUT("/organizations/:id/active", func(g *gin.Context) {
+		g.Set("user", usr)
+		g.Set("session", sess)
+	}, h.Switch)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/organizations/"+orgID.String()+"/active", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	sessions.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestSwitchOrganization401WithoutSession(t *testing.T) {
+	sessions := new(MockSessionStorer)
+	m := NewJWTHandler(new(MockUserStorer), sessions, new(MockKeyStorer), new(MockOrganizationStorer), testKeySet, testConfig, nil)
+	usr := testUser()
+
+	router := setupTestRouter()
+	router.PUT("/organizations/:id/active", func(g *gin.Context) {
+		g.Set("user", usr)
+		assert.Error(t, m.SwitchOrganization(g, uuid.New()))
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/organizations/"+uuid.New().String()+"/active", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	sessions.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestRequireOrganizationFallsBackToOldestMembership(t *testing.T) {
+	users := new(MockUserStorer)
+	keys := new(MockKeyStorer)
+	orgs := new(MockOrganizationStorer)
+	m := NewJWTHandler(users, new(MockSessionStorer), keys, orgs, testKeySet, testConfig, nil)
+	usr := testUser()
+	org := organization.NewOrganization("Acme")
+
+	key, token, err := apikey.NewKey(usr.ID, "CI", []string{apikey.ScopeAccount}, null.Time{})
+	assert.NoError(t, err)
+	keys.On("ByID", key.ID).Return(key, nil)
+	keys.On("Touch", key.ID, mock.Anything).Return(nil)
+	users.On("ByID", usr.ID).Return(usr, nil)
+	orgs.On("ByUser", usr.ID).Return([]organization.Affiliation{{Organization: *org, Role: organization.RoleMember}}, nil)
+
+	router := setupTestRouter()
+	router.GET("/members", m.Scope(apikey.ScopeAccount), m.RequireOrganization(), func(g *gin.Context) { g.Status(http.StatusOK) })
+	router.PUT("/organization", m.Scope(apikey.ScopeAccount), m.RequireOrganization(organization.RoleOwner, organization.RoleAdmin), func(g *gin.Context) { g.Status(http.StatusOK) })
+
+	for method, expected := range map[string]int{"GET": http.StatusOK, "PUT": http.StatusForbidden} {
+		path := "/members"
+		if method == "PUT" {
+			path = "/organization"
+		}
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, expected, w.Code, method)
+	}
+}
+
+func TestSetMemberRoleProtectsOwners(t *testing.T) {
+	orgs := new(MockOrganizationStorer)
+	h := NewOrganizationHandler(orgs, nil)
+	owner, admin := testUser(), testUser()
+	orgID := uuid.New()
+	ownership := organization.NewMembership(orgID, owner.ID, organization.RoleOwner)
+	administration := organization.NewMembership(orgID, admin.ID, organization.RoleAdmin)
+
+	orgs.On("Membership", orgID, owner.ID).Return(ownership, nil)
+	orgs.On("Membership", orgID, admin.ID).Return(administration, nil)
+	orgs.On("CountOwners", orgID).Return(1, nil)
+
+	path := "/organization/members/" + owner.ID.String() + "/role"
+	w := asMember(h.SetMemberRole, "PUT", "/organization/members/:id/role", path, admin, administration, organization.RoleRequest{Role: organization.RoleMember})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = asMember(h.SetMemberRole, "PUT", "/organization/members/:id/role", path, owner, ownership, organization.RoleRequest{Role: organization.RoleMember})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	path = "/organization/members/" + admin.ID.String() + "/role"
+	w = asMember(h.SetMemberRole, "PUT", "/organization/members/:id/role", path, admin, administration, organization.RoleRequest{Role: organization.RoleOwner})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	orgs.AssertNotCalled(t, "SetRole", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRemoveMemberLetsMembersLeaveOnly(t *testing.T) {
+	orgs := new(MockOrganizationStorer)
+	h := NewOrganizationHandler(orgs, nil)
+	member, other := testUser(), testUser()
+	orgID := uuid.New()
+	membership := organization.NewMembership(orgID, member.ID, organization.RoleMember)
+
+	orgs.On("Membership", orgID, member.ID).Return(membership, nil)
+	orgs.On("Membership", orgID, other.ID).Return(organization.NewMembership(orgID, other.ID, organization.RoleMember), nil)
+	orgs.On("RemoveMember", orgID, member.ID).Return(nil)
+
+	w := asMember(h.RemoveMember, "DELETE", "/organization/members/:id", "/organization/members/"+other.ID.String(), member, membership, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = asMember(h.RemoveMember, "DELETE", "/organization/members/:id", "/organization/members/"+member.ID.String(), member, membership, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	orgs.AssertExpectations(t)
+}
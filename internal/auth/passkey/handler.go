@@ -0,0 +1,186 @@
+package passkey
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cskr/pubsub/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inokone/go-micro-saas/internal/auth/session"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+var (
+	statusUnknownError = common.StatusMessage{Message: "Unknown error, please contact administrator!"}
+	statusNotFound     = common.StatusMessage{Message: "Passkey not found!"}
+)
+
+// Handler is a struct for web handles related to the passkeys of the users.
+type Handler struct {
+	credentials Storer
+	service     *Service
+	ps          *pubsub.PubSub[string, common.Event]
+}
+
+// NewHandler creates a new `Handler`, based on the passkey persistence and the service running the WebAuthn ceremonies.
+// The passkeys added and removed are published as security alerts.
+func NewHandler(credentials Storer, service *Service, ps *pubsub.PubSub[string, common.Event]) *Handler {
+	return &Handler{
+		credentials: credentials,
+		service:     service,
+		ps:          ps,
+	}
+}
+
+// List is a method of `Handler`. Lists the passkeys of the current user.
+// @Summary Passkey list endpoint
+// @Schemes
+// @Description Returns the passkeys registered by the current user
+// @Accept json
+// @Produce json
+// @Success 200 {array} passkey.View
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/passkeys [get]
+func (h *Handler) List(g *gin.Context) {
+	usr := currentUser(g)
+
+	credentials, err := h.credentials.ByUser(usr.ID)
+	if err != nil {
+		log.WithError(err).Error("Failed to list passkeys.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+
+	res := make([]View, len(credentials))
+	for i, c := range credentials {
+		res[i] = c.AsView()
+	}
+	g.JSON(http.StatusOK, res)
+}
+
+// BeginRegistration is a method of `Handler`. Starts the registration of a new passkey for the current user.
+// @Summary Passkey registration endpoint
+// @Schemes
+// @Description Returns the options of a new WebAuthn credential to be created by the browser
+// @Accept json
+// @Produce json
+// @Success 200 {object} passkey.Options
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/passkeys [post]
+func (h *Handler) BeginRegistration(g *gin.Context) {
+	usr := currentUser(g)
+
+	options, err := h.service.BeginRegistration(usr)
+	if err != nil {
+		log.WithError(err).Error("Failed to begin passkey registration.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	g.JSON(http.StatusOK, options)
+}
+
+// FinishRegistration is a method of `Handler`. Finishes the registration of a new passkey for the current user.
+// @Summary Passkey registration verification endpoint
+// @Schemes
+// @Description Verifies the WebAuthn credential created by the browser and stores it as a new passkey
+// @Accept json
+// @Produce json
+// @Param data body passkey.Attestation true "Ceremony, name and the credential created by the browser"
+// @Success 201 {object} passkey.View
+// @Failure 400 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/passkeys/verify [post]
+func (h *Handler) FinishRegistration(g *gin.Context) {
+	var in Attestation
+	usr := currentUser(g)
+
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+
+	credential, err := h.service.FinishRegistration(usr, in)
+	if errors.Is(err, ErrInvalidCeremony) {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Passkey registration expired, please start again!"})
+		return
+	}
+	if errors.Is(err, ErrInvalidCredential) {
+		log.WithError(err).Debug("Failed to verify passkey.")
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Invalid passkey!"})
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to finish passkey registration.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	h.alert(g, usr, common.PasskeyAdded, credential.Name)
+	g.JSON(http.StatusCreated, credential.AsView())
+}
+
+// Delete is a method of `Handler`. Removes a passkey of the current user.
+// @Summary Passkey delete endpoint
+// @Schemes
+// @Description Removes a passkey of the current user, it can not be used to sign in anymore
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the passkey"
+// @Success 200 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/passkeys/{id} [delete]
+func (h *Handler) Delete(g *gin.Context) {
+	usr := currentUser(g)
+
+	id, err := DecodeID(g.Param("id"))
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, statusNotFound)
+		return
+	}
+
+	credential, err := h.credentials.ByID(id)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && credential.UserID != usr.ID {
+		g.AbortWithStatusJSON(http.StatusNotFound, statusNotFound)
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to collect passkey.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+
+	deleted, err := h.credentials.Delete(usr.ID, id)
+	if err != nil {
+		log.WithError(err).Error("Failed to delete passkey.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	if !deleted {
+		g.AbortWithStatusJSON(http.StatusNotFound, statusNotFound)
+		return
+	}
+	h.alert(g, usr, common.PasskeyRemoved, credential.Name)
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Passkey removed!"})
+}
+
+// alert publishes a security alert of a passkey of the user added or removed by the request.
+func (h *Handler) alert(g *gin.Context, usr *user.User, alert string, name string) {
+	h.ps.Pub(common.Event{
+		ID:   uuid.New(),
+		Type: alert,
+		Time: time.Now(),
+		Data: common.SecurityData{Email: usr.Email, IP: g.ClientIP(), Device: session.Device(g.Request.UserAgent()), Detail: "passkey " + name},
+		User: usr.ID,
+	}, common.HistoryTopic, common.NotificationTopic)
+}
+
+func currentUser(g *gin.Context) *user.User {
+	u, _ := g.Get("user")
+	return u.(*user.User)
+}
@@ -0,0 +1,160 @@
+package passkey
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cskr/pubsub/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+var testUser = &user.User{
+	ID:      uuid.New(),
+	Email:   "test@example.com",
+	Status:  user.Confirmed,
+	Source:  "credentials",
+	Enabled: true,
+}
+
+func setupTestRouter(h *Handler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	return r
+}
+
+func withUser(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user", testUser)
+		handler(c)
+	}
+}
+
+func TestList200ForHappyPath(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer, newTestService(t, mockStorer), pubsub.New[string, common.Event](1))
+	router := setupTestRouter(handler)
+
+	credentials := []Credential{
+		{ID: []byte{1, 2, 3}, UserID: testUser.ID, Name: "Laptop", CreatedAt: time.Now()},
+	}
+	mockStorer.On("ByUser", testUser.ID).Return(credentials, nil)
+
+	router.GET("/passkeys", withUser(handler.List))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/passkeys", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []View
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response, 1)
+	assert.Equal(t, "AQID", response[0].ID)
+	assert.Equal(t, "Laptop", response[0].Name)
+	mockStorer.AssertExpectations(t)
+}
+
+func TestDelete404ForOtherUsersPasskey(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer, newTestService(t, mockStorer), pubsub.New[string, common.Event](1))
+	router := setupTestRouter(handler)
+
+	mockStorer.On("ByID", []byte{1, 2, 3}).Return(&Credential{ID: []byte{1, 2, 3}, UserID: uuid.New()}, nil)
+
+	router.DELETE("/passkeys/:id", withUser(handler.Delete))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/passkeys/AQID", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockStorer.AssertExpectations(t)
+	mockStorer.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestDeleteAlertsRemovedPasskey(t *testing.T) {
+	mockStorer := new(MockStorer)
+	ps := pubsub.New[string, common.Event](1)
+	ch := ps.Sub(common.NotificationTopic)
+	handler := NewHandler(mockStorer, newTestService(t, mockStorer), ps)
+	router := setupTestRouter(handler)
+
+	mockStorer.On("ByID", []byte{1, 2, 3}).Return(&Credential{ID: []byte{1, 2, 3}, UserID: testUser.ID, Name: "Laptop"}, nil)
+	mockStorer.On("Delete", testUser.ID, []byte{1, 2, 3}).Return(true, nil)
+
+	router.DELETE("/passkeys/:id", withUser(handler.Delete))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/passkeys/AQID", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	select {
+	case e := <-ch:
+		assert.Equal(t, common.PasskeyRemoved, e.Type)
+		assert.Equal(t, testUser.ID, e.User)
+		assert.Equal(t, "passkey Laptop", e.Data.(common.SecurityData).Detail)
+	case <-time.After(time.Second):
+		t.Fatal("security alert not published")
+	}
+}
+
+func TestBeginRegistrationExcludesExistingPasskeys(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer, newTestService(t, mockStorer), pubsub.New[string, common.Event](1))
+	router := setupTestRouter(handler)
+
+	existing := Credential{ID: []byte{1, 2, 3}, UserID: testUser.ID, Authenticator: Authenticator(webauthn.Credential{ID: []byte{1, 2, 3}})}
+	mockStorer.On("ByUser", testUser.ID).Return([]Credential{existing}, nil)
+	mockStorer.On("StoreCeremony", mock.MatchedBy(func(c *Ceremony) bool {
+		return c.Kind == Registration && c.UserID.UUID == testUser.ID
+	})).Return(nil)
+
+	router.POST("/passkeys", withUser(handler.BeginRegistration))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/passkeys", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"excludeCredentials":[{"type":"public-key","id":"AQID"}]`)
+	mockStorer.AssertExpectations(t)
+}
+
+func TestFinishRegistration400ForOtherUsersCeremony(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer, newTestService(t, mockStorer), pubsub.New[string, common.Event](1))
+	router := setupTestRouter(handler)
+
+	ceremony := NewCeremony(Registration, uuid.NullUUID{UUID: uuid.New(), Valid: true}, &webauthn.SessionData{}, time.Minute)
+	mockStorer.On("TakeCeremony", ceremony.ID).Return(ceremony, nil)
+
+	router.POST("/passkeys/verify", withUser(handler.FinishRegistration))
+
+	body, _ := json.Marshal(Attestation{Ceremony: ceremony.ID.String(), Name: "Laptop", Credential: []byte("{}")})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/passkeys/verify", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response common.StatusMessage
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "Passkey registration expired, please start again!", response.Message)
+	mockStorer.AssertNotCalled(t, "Store", mock.Anything)
+}
@@ -0,0 +1,204 @@
+package passkey
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+)
+
+const (
+	// Registration is the kind of a ceremony adding a new passkey to the account of a user.
+	Registration = "registration"
+	// Login is the kind of a ceremony signing in a user with a passkey.
+	Login = "login"
+)
+
+// Credential is a passkey of a user for database storage.
+type Credential struct {
+	ID            []byte        `db:"credential_id"`
+	UserID        uuid.UUID     `db:"user_id"`
+	Name          string        `db:"name"`
+	Authenticator Authenticator `db:"credential"`
+	CreatedAt     time.Time     `db:"created_at"`
+	LastUsedAt    null.Time     `db:"last_used_at"`
+}
+
+// NewCredential is a function to create a new `Credential` for a user from a registered WebAuthn credential.
+func NewCredential(userID uuid.UUID, name string, c *webauthn.Credential) *Credential {
+	return &Credential{
+		ID:            c.ID,
+		UserID:        userID,
+		Name:          name,
+		Authenticator: Authenticator(*c),
+		CreatedAt:     time.Now(),
+	}
+}
+
+// AsView is a method of the `Credential` struct. It converts a `Credential` object into a `View` object.
+func (c *Credential) AsView() View {
+	v := View{
+		ID:      EncodeID(c.ID),
+		Name:    c.Name,
+		Created: int(c.CreatedAt.Unix()),
+	}
+	if c.LastUsedAt.Valid {
+		v.LastUsed = int(c.LastUsedAt.Time.Unix())
+	}
+	return v
+}
+
+// EncodeID is a function returning the base64url representation of a credential ID, as used by browsers.
+func EncodeID(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+// DecodeID is a function parsing the base64url representation of a credential ID.
+func DecodeID(id string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(id)
+}
+
+// Authenticator is the WebAuthn credential record of a passkey, persisted as JSON.
+type Authenticator webauthn.Credential
+
+// Value is a method of `Authenticator` implementing `driver.Valuer` for persistence.
+func (a Authenticator) Value() (driver.Value, error) {
+	b, err := json.Marshal(a)
+	return string(b), err
+}
+
+// Scan is a method of `Authenticator` implementing `sql.Scanner` for persistence.
+func (a *Authenticator) Scan(value any) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	default:
+		return errors.New("unsupported type for passkey credential")
+	}
+}
+
+// Ceremony is a registration or login with a passkey in progress, holding the challenge sent to the browser.
+type Ceremony struct {
+	ID        uuid.UUID     `db:"ceremony_id"`
+	UserID    uuid.NullUUID `db:"user_id"`
+	Kind      string        `db:"kind"`
+	Data      SessionData   `db:"data"`
+	ExpiresAt time.Time     `db:"expires_at"`
+}
+
+// NewCeremony is a function to create a new `Ceremony`. The user is not known for a login with a discoverable passkey.
+func NewCeremony(kind string, userID uuid.NullUUID, data *webauthn.SessionData, ttl time.Duration) *Ceremony {
+	return &Ceremony{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Kind:      kind,
+		Data:      SessionData(*data),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+}
+
+// IsActive is a method of `Ceremony` returning whether the ceremony is of the expected kind and not yet expired.
+func (c *Ceremony) IsActive(kind string) bool {
+	return c.Kind == kind && c.ExpiresAt.After(time.Now())
+}
+
+// SessionData is the WebAuthn session data of a `Ceremony`, persisted as JSON.
+type SessionData webauthn.SessionData
+
+// Value is a method of `SessionData` implementing `driver.Valuer` for persistence.
+func (d SessionData) Value() (driver.Value, error) {
+	b, err := json.Marshal(d)
+	return string(b), err
+}
+
+// Scan is a method of `SessionData` implementing `sql.Scanner` for persistence.
+func (d *SessionData) Scan(value any) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, d)
+	case string:
+		return json.Unmarshal([]byte(v), d)
+	default:
+		return errors.New("unsupported type for passkey ceremony")
+	}
+}
+
+// account is the adapter of a `user.User` and its passkeys to the WebAuthn library.
+type account struct {
+	usr         *user.User
+	credentials []Credential
+}
+
+func (a *account) WebAuthnID() []byte {
+	id, _ := a.usr.ID.MarshalBinary()
+	return id
+}
+
+func (a *account) WebAuthnName() string {
+	return a.usr.Email
+}
+
+func (a *account) WebAuthnDisplayName() string {
+	return a.usr.Email
+}
+
+func (a *account) WebAuthnCredentials() []webauthn.Credential {
+	res := make([]webauthn.Credential, len(a.credentials))
+	for i, c := range a.credentials {
+		res[i] = webauthn.Credential(c.Authenticator)
+	}
+	return res
+}
+
+// View is the JSON representation of a passkey of the user.
+type View struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Created  int    `json:"created"`
+	LastUsed int    `json:"last_used"`
+}
+
+// Options is the JSON response of starting a ceremony, the options are to be passed to the WebAuthn API of the browser.
+type Options struct {
+	Ceremony string `json:"ceremony"`
+	Options  any    `json:"options"`
+}
+
+// Begin is a struct for the message body of starting a passkey sign in. Without a challenge token the sign in is
+// passwordless with a discoverable passkey, otherwise the passkey is the second factor of the sign in.
+type Begin struct {
+	Token string `json:"challenge_token"`
+}
+
+// Attestation is a struct for the message body of finishing a passkey registration.
+type Attestation struct {
+	Ceremony   string          `json:"ceremony" binding:"required"`
+	Name       string          `json:"name" binding:"required,max=100"`
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+// Assertion is a struct for the message body of finishing a passkey sign in.
+type Assertion struct {
+	Ceremony   string          `json:"ceremony" binding:"required"`
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+// Storer is the interface for `Credential` and `Ceremony` persistence
+type Storer interface {
+	Store(credential *Credential) error
+	Update(credential *Credential) error
+	ByID(id []byte) (*Credential, error)
+	ByUser(userID uuid.UUID) ([]Credential, error)
+	Delete(userID uuid.UUID, id []byte) (bool, error)
+	StoreCeremony(ceremony *Ceremony) error
+	TakeCeremony(id uuid.UUID) (*Ceremony, error)
+}
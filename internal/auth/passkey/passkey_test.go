@@ -0,0 +1,147 @@
+package passkey
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+// MockStorer is a mock implementation of the Storer interface
+type MockStorer struct {
+	mock.Mock
+}
+
+func (m *MockStorer) Store(credential *Credential) error {
+	args := m.Called(credential)
+	return args.Error(0)
+}
+
+func (m *MockStorer) Update(credential *Credential) error {
+	args := m.Called(credential)
+	return args.Error(0)
+}
+
+func (m *MockStorer) ByID(id []byte) (*Credential, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Credential), args.Error(1)
+}
+
+func (m *MockStorer) ByUser(userID uuid.UUID) ([]Credential, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Credential), args.Error(1)
+}
+
+func (m *MockStorer) Delete(userID uuid.UUID, id []byte) (bool, error) {
+	args := m.Called(userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStorer) StoreCeremony(ceremony *Ceremony) error {
+	args := m.Called(ceremony)
+	return args.Error(0)
+}
+
+func (m *MockStorer) TakeCeremony(id uuid.UUID) (*Ceremony, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Ceremony), args.Error(1)
+}
+
+func newTestService(t *testing.T, storer Storer) *Service {
+	service, err := NewService(storer, nil, &common.AuthConfig{FrontendRoot: "http://localhost:3000/"}, "Test App")
+	assert.NoError(t, err)
+	return service
+}
+
+func TestAuthenticatorRoundTrip(t *testing.T) {
+	original := Authenticator(webauthn.Credential{
+		ID:        []byte{1, 2, 3},
+		PublicKey: []byte{4, 5, 6},
+		Authenticator: webauthn.Authenticator{
+			SignCount: 42,
+		},
+	})
+
+	value, err := original.Value()
+	assert.NoError(t, err)
+
+	var loaded Authenticator
+	assert.NoError(t, loaded.Scan([]byte(value.(string))))
+	assert.Equal(t, original.ID, loaded.ID)
+	assert.Equal(t, original.PublicKey, loaded.PublicKey)
+	assert.Equal(t, uint32(42), loaded.Authenticator.SignCount)
+}
+
+func TestEncodeIDRoundTrip(t *testing.T) {
+	id := []byte{0xfb, 0xff, 0x00, 0x10}
+
+	decoded, err := DecodeID(EncodeID(id))
+
+	assert.NoError(t, err)
+	assert.Equal(t, id, decoded)
+	assert.NotContains(t, EncodeID(id), "=")
+}
+
+func TestCeremonyIsActive(t *testing.T) {
+	ceremony := NewCeremony(Login, uuid.NullUUID{}, &webauthn.SessionData{Challenge: "challenge"}, time.Minute)
+
+	assert.True(t, ceremony.IsActive(Login))
+	assert.False(t, ceremony.IsActive(Registration))
+
+	ceremony.ExpiresAt = time.Now().Add(-time.Second)
+	assert.False(t, ceremony.IsActive(Login))
+}
+
+func TestBeginLoginStoresDiscoverableCeremony(t *testing.T) {
+	mockStorer := new(MockStorer)
+	service := newTestService(t, mockStorer)
+
+	mockStorer.On("StoreCeremony", mock.MatchedBy(func(c *Ceremony) bool {
+		return c.Kind == Login && !c.UserID.Valid && c.Data.Challenge != ""
+	})).Return(nil)
+
+	options, err := service.BeginLogin(nil)
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, options.Ceremony)
+	mockStorer.AssertExpectations(t)
+}
+
+func TestIdentifyRejectsUnknownCeremony(t *testing.T) {
+	mockStorer := new(MockStorer)
+	service := newTestService(t, mockStorer)
+	id := uuid.New()
+
+	mockStorer.On("TakeCeremony", id).Return(nil, sql.ErrNoRows)
+
+	_, err := service.Identify(Assertion{Ceremony: id.String(), Credential: []byte("{}")})
+
+	assert.ErrorIs(t, err, ErrInvalidCeremony)
+}
+
+func TestIdentifyRejectsRegistrationCeremony(t *testing.T) {
+	mockStorer := new(MockStorer)
+	service := newTestService(t, mockStorer)
+	ceremony := NewCeremony(Registration, uuid.NullUUID{UUID: uuid.New(), Valid: true}, &webauthn.SessionData{}, time.Minute)
+
+	mockStorer.On("TakeCeremony", ceremony.ID).Return(ceremony, nil)
+
+	_, err := service.Identify(Assertion{Ceremony: ceremony.ID.String(), Credential: []byte("{}")})
+
+	assert.ErrorIs(t, err, ErrInvalidCeremony)
+}
@@ -0,0 +1,259 @@
+package passkey
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+const ceremonyTTL = 5 * time.Minute
+
+var (
+	// ErrInvalidCeremony is the error for a ceremony which is unknown, expired or finished already.
+	ErrInvalidCeremony = errors.New("passkey ceremony expired or unknown")
+	// ErrInvalidCredential is the error for a passkey response which does not verify.
+	ErrInvalidCredential = errors.New("invalid passkey")
+	// ErrNoPasskey is the error for starting a passkey sign in for a user without passkeys.
+	ErrNoPasskey = errors.New("no passkey registered")
+)
+
+// Service is a worker for the WebAuthn ceremonies of passkey registration and sign in.
+type Service struct {
+	credentials Storer
+	users       user.Storer
+	webauthn    *webauthn.WebAuthn
+}
+
+// NewService creates a new `Service`, based on the passkey and user persistence. The relying party of the passkeys
+// is the frontend of the application, shown to the users with the name provided.
+func NewService(credentials Storer, users user.Storer, conf *common.AuthConfig, name string) (*Service, error) {
+	origin := strings.TrimSuffix(conf.FrontendRoot, "/")
+	u, err := url.Parse(origin)
+	if err != nil {
+		return nil, fmt.Errorf("invalid frontend root for passkeys: %w", err)
+	}
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          u.Hostname(),
+		RPDisplayName: name,
+		RPOrigins:     []string{origin},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up passkeys: %w", err)
+	}
+	return &Service{
+		credentials: credentials,
+		users:       users,
+		webauthn:    w,
+	}, nil
+}
+
+// Attempt is a passkey sign in of a known user waiting for verification.
+type Attempt struct {
+	User         *user.User
+	SecondFactor bool
+	ceremony     *Ceremony
+	assertion    *protocol.ParsedCredentialAssertionData
+}
+
+// Registered is a method of `Service` returning whether the user has at least one passkey.
+func (s *Service) Registered(userID uuid.UUID) (bool, error) {
+	credentials, err := s.credentials.ByUser(userID)
+	if err != nil {
+		return false, err
+	}
+	return len(credentials) > 0, nil
+}
+
+// BeginRegistration is a method of `Service` starting the registration of a new passkey for the user.
+func (s *Service) BeginRegistration(usr *user.User) (*Options, error) {
+	acc, err := s.account(usr)
+	if err != nil {
+		return nil, err
+	}
+	exclusions := make([]protocol.CredentialDescriptor, len(acc.credentials))
+	for i, c := range acc.WebAuthnCredentials() {
+		exclusions[i] = c.Descriptor()
+	}
+
+	creation, data, err := s.webauthn.BeginRegistration(acc,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred))
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin passkey registration: %w", err)
+	}
+	return s.begin(Registration, uuid.NullUUID{UUID: usr.ID, Valid: true}, data, creation)
+}
+
+// FinishRegistration is a method of `Service` verifying the attestation of the browser for a registration started by
+// the same user, and storing the new passkey.
+func (s *Service) FinishRegistration(usr *user.User, in Attestation) (*Credential, error) {
+	ceremony, err := s.ceremony(in.Ceremony, Registration)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.UserID.UUID != usr.ID {
+		return nil, ErrInvalidCeremony
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(in.Credential)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+	}
+	acc, err := s.account(usr)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.webauthn.CreateCredential(acc, webauthn.SessionData(ceremony.Data), parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+	}
+
+	credential := NewCredential(usr.ID, in.Name, c)
+	if err = s.credentials.Store(credential); err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+// BeginLogin is a method of `Service` starting a passkey sign in. Without a user the sign in is passwordless with a
+// discoverable passkey and requires user verification, otherwise any passkey of the user is accepted as a second factor.
+func (s *Service) BeginLogin(usr *user.User) (*Options, error) {
+	if usr == nil {
+		assertion, data, err := s.webauthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+		if err != nil {
+			return nil, fmt.Errorf("failed to begin passkey sign in: %w", err)
+		}
+		return s.begin(Login, uuid.NullUUID{}, data, assertion)
+	}
+
+	acc, err := s.account(usr)
+	if err != nil {
+		return nil, err
+	}
+	if len(acc.credentials) == 0 {
+		return nil, ErrNoPasskey
+	}
+	assertion, data, err := s.webauthn.BeginLogin(acc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin passkey sign in: %w", err)
+	}
+	return s.begin(Login, uuid.NullUUID{UUID: usr.ID, Valid: true}, data, assertion)
+}
+
+// Identify is a method of `Service` finishing the ceremony of a passkey sign in and resolving the user signing in,
+// without verifying the passkey yet. This allows the caller to check the account before any verification is attempted.
+func (s *Service) Identify(in Assertion) (*Attempt, error) {
+	ceremony, err := s.ceremony(in.Ceremony, Login)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(in.Credential)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+	}
+
+	userID := ceremony.UserID.UUID
+	if !ceremony.UserID.Valid {
+		if userID, err = uuid.FromBytes(parsed.Response.UserHandle); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+		}
+	}
+	usr, err := s.users.ByID(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidCredential
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Attempt{
+		User:         usr,
+		SecondFactor: ceremony.UserID.Valid,
+		ceremony:     ceremony,
+		assertion:    parsed,
+	}, nil
+}
+
+// Verify is a method of `Service` validating the passkey of a sign in attempt. Updates the signature counter of the
+// passkey on success, and rejects passkeys which seem to be cloned.
+func (s *Service) Verify(attempt *Attempt) error {
+	acc, err := s.account(attempt.User)
+	if err != nil {
+		return err
+	}
+
+	var c *webauthn.Credential
+	data := webauthn.SessionData(attempt.ceremony.Data)
+	if attempt.SecondFactor {
+		c, err = s.webauthn.ValidateLogin(acc, data, attempt.assertion)
+	} else {
+		c, err = s.webauthn.ValidateDiscoverableLogin(func(_, _ []byte) (webauthn.User, error) {
+			return acc, nil
+		}, data, attempt.assertion)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCredential, err)
+	}
+	if c.Authenticator.CloneWarning {
+		return fmt.Errorf("%w: signature counter of the authenticator went backwards", ErrInvalidCredential)
+	}
+
+	for _, credential := range acc.credentials {
+		if bytes.Equal(credential.ID, c.ID) {
+			credential.Authenticator = Authenticator(*c)
+			credential.LastUsedAt = null.TimeFrom(time.Now())
+			return s.credentials.Update(&credential)
+		}
+	}
+	return ErrInvalidCredential
+}
+
+func (s *Service) account(usr *user.User) (*account, error) {
+	credentials, err := s.credentials.ByUser(usr.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &account{usr: usr, credentials: credentials}, nil
+}
+
+func (s *Service) begin(kind string, userID uuid.NullUUID, data *webauthn.SessionData, options any) (*Options, error) {
+	ceremony := NewCeremony(kind, userID, data, ceremonyTTL)
+	if err := s.credentials.StoreCeremony(ceremony); err != nil {
+		return nil, err
+	}
+	return &Options{
+		Ceremony: ceremony.ID.String(),
+		Options:  options,
+	}, nil
+}
+
+func (s *Service) ceremony(id string, kind string) (*Ceremony, error) {
+	ceremonyID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrInvalidCeremony
+	}
+	ceremony, err := s.credentials.TakeCeremony(ceremonyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidCeremony
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ceremony.IsActive(kind) {
+		return nil, ErrInvalidCeremony
+	}
+	return ceremony, nil
+}
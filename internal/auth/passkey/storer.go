@@ -0,0 +1,105 @@
+package passkey
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresStorer is the `Storer` implementation based on sqlx library.
+type PostgresStorer struct {
+	db *sqlx.DB
+}
+
+// NewPostgresStorer creates a new `PostgresStorer` instance based on the sqlx library.
+func NewPostgresStorer(db *sqlx.DB) *PostgresStorer {
+	return &PostgresStorer{
+		db: db,
+	}
+}
+
+// Store is a method of the `PostgresStorer` struct. Takes a `Credential` as parameter and persists it.
+func (s *PostgresStorer) Store(credential *Credential) error {
+	query := `INSERT INTO microsaas.passkeys (credential_id, user_id, name, credential, created_at, last_used_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := s.db.Exec(
+		query,
+		credential.ID,
+		credential.UserID,
+		credential.Name,
+		credential.Authenticator,
+		credential.CreatedAt,
+		credential.LastUsedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store passkey: %w", err)
+	}
+	return nil
+}
+
+// Update is a method of the `PostgresStorer` struct. Takes a `Credential` as parameter and updates its record and usage.
+func (s *PostgresStorer) Update(credential *Credential) error {
+	query := `UPDATE microsaas.passkeys SET name = $1, credential = $2, last_used_at = $3 WHERE credential_id = $4`
+	_, err := s.db.Exec(query, credential.Name, credential.Authenticator, credential.LastUsedAt, credential.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update passkey: %w", err)
+	}
+	return nil
+}
+
+// ByID is a method of the `PostgresStorer` struct. Takes a credential ID as parameter to load a `Credential` from persistence.
+func (s *PostgresStorer) ByID(id []byte) (*Credential, error) {
+	var credential Credential
+	query := `SELECT credential_id, user_id, name, credential, created_at, last_used_at FROM microsaas.passkeys WHERE credential_id = $1`
+	if err := s.db.Get(&credential, query, id); err != nil {
+		return nil, fmt.Errorf("failed to get passkey: %w", err)
+	}
+	return &credential, nil
+}
+
+// ByUser is a method of the `PostgresStorer` struct. Takes a user ID as parameter to load all passkeys of the user.
+func (s *PostgresStorer) ByUser(userID uuid.UUID) ([]Credential, error) {
+	var credentials []Credential
+	query := `SELECT credential_id, user_id, name, credential, created_at, last_used_at FROM microsaas.passkeys WHERE user_id = $1 ORDER BY created_at`
+	if err := s.db.Select(&credentials, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to list passkeys: %w", err)
+	}
+	return credentials, nil
+}
+
+// Delete is a method of the `PostgresStorer` struct. Takes a user ID and a credential ID as parameter and deletes the
+// passkey if it belongs to the user. Returns false if there was no such passkey.
+func (s *PostgresStorer) Delete(userID uuid.UUID, id []byte) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM microsaas.passkeys WHERE user_id = $1 AND credential_id = $2`, userID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete passkey: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete passkey: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// StoreCeremony is a method of the `PostgresStorer` struct. Takes a `Ceremony` as parameter and persists it.
+func (s *PostgresStorer) StoreCeremony(ceremony *Ceremony) error {
+	query := `INSERT INTO microsaas.passkey_ceremonies (ceremony_id, user_id, kind, data, expires_at) VALUES ($1, $2, $3, $4, $5)`
+	_, err := s.db.Exec(query, ceremony.ID, ceremony.UserID, ceremony.Kind, ceremony.Data, ceremony.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to store passkey ceremony: %w", err)
+	}
+	return nil
+}
+
+// TakeCeremony is a method of the `PostgresStorer` struct. Takes a ceremony ID as parameter, loads the `Ceremony` and
+// deletes it from persistence, so every ceremony can be finished only once. Expired ceremonies are cleaned up as well.
+func (s *PostgresStorer) TakeCeremony(id uuid.UUID) (*Ceremony, error) {
+	var ceremony Ceremony
+	query := `DELETE FROM microsaas.passkey_ceremonies WHERE ceremony_id = $1 RETURNING ceremony_id, user_id, kind, data, expires_at`
+	if err := s.db.Get(&ceremony, query, id); err != nil {
+		return nil, fmt.Errorf("failed to take passkey ceremony: %w", err)
+	}
+	if _, err := s.db.Exec(`DELETE FROM microsaas.passkey_ceremonies WHERE expires_at < NOW()`); err != nil {
+		return nil, fmt.Errorf("failed to clean up passkey ceremonies: %w", err)
+	}
+	return &ceremony, nil
+}
@@ -0,0 +1,221 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+const (
+	// AlgorithmArgon2id is the name of the argon2id password hashing algorithm in the configuration.
+	AlgorithmArgon2id = "argon2id"
+	// AlgorithmBcrypt is the name of the bcrypt password hashing algorithm in the configuration.
+	AlgorithmBcrypt = "bcrypt"
+
+	argon2idPrefix = "$argon2id$"
+)
+
+// errMalformedHash is the error for an encoded password hash not matching the format of its algorithm.
+var errMalformedHash = errors.New("malformed password hash")
+
+// Algorithm is a password hashing algorithm with its parameters. The hashes are encoded with the algorithm and the
+// parameters, so they can be verified after the parameters are changed.
+type Algorithm interface {
+	// Hash returns the encoded hash of a password with a random salt.
+	Hash(password string) (string, error)
+	// Verify returns whether the password matches the encoded hash.
+	Verify(encoded string, password string) (bool, error)
+	// Identifies returns whether the encoded hash was created by the algorithm.
+	Identifies(encoded string) bool
+	// Outdated returns whether the encoded hash was created with different parameters.
+	Outdated(encoded string) bool
+}
+
+// Argon2id is the argon2id `Algorithm`, encoding hashes in the PHC string format.
+type Argon2id struct {
+	Memory     uint32
+	Iterations uint32
+	Threads    uint8
+	KeyLength  uint32
+	SaltLength uint32
+}
+
+// Hash is a method of `Argon2id` returning the encoded hash of a password with a random salt.
+func (a Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Threads, a.KeyLength)
+	return fmt.Sprintf("%vv=%d$m=%d,t=%d,p=%d$%v$%v", argon2idPrefix, argon2.Version, a.Memory, a.Iterations, a.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify is a method of `Argon2id` returning whether the password matches the encoded hash, using the parameters of the hash.
+func (a Argon2id) Verify(encoded string, password string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// Identifies is a method of `Argon2id` returning whether the encoded hash is an argon2id hash.
+func (a Argon2id) Identifies(encoded string) bool {
+	return strings.HasPrefix(encoded, argon2idPrefix)
+}
+
+// Outdated is a method of `Argon2id` returning whether the encoded hash was created with different parameters.
+func (a Argon2id) Outdated(encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.Memory != a.Memory || params.Iterations != a.Iterations || params.Threads != a.Threads ||
+		uint32(len(key)) != a.KeyLength || uint32(len(salt)) != a.SaltLength
+}
+
+func decodeArgon2id(encoded string) (*Argon2id, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return nil, nil, nil, errMalformedHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, errMalformedHash
+	}
+	params := &Argon2id{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Threads); err != nil {
+		return nil, nil, nil, errMalformedHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, errMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, errMalformedHash
+	}
+	return params, salt, key, nil
+}
+
+// Bcrypt is the bcrypt `Algorithm` with a tunable cost, using the modular crypt format of bcrypt.
+type Bcrypt struct {
+	Cost int
+}
+
+// Hash is a method of `Bcrypt` returning the encoded hash of a password with a random salt.
+func (b Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify is a method of `Bcrypt` returning whether the password matches the encoded hash, using the cost of the hash.
+func (b Bcrypt) Verify(encoded string, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Identifies is a method of `Bcrypt` returning whether the encoded hash is a bcrypt hash.
+func (b Bcrypt) Identifies(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// Outdated is a method of `Bcrypt` returning whether the encoded hash was created with a different cost.
+func (b Bcrypt) Outdated(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != b.Cost
+}
+
+// algorithms are the supported password hashing algorithms, their hashes are verified with the parameters encoded.
+var algorithms = []Algorithm{Argon2id{}, Bcrypt{}}
+
+// Verify is a function returning whether the password matches the encoded hash of any supported algorithm.
+func Verify(encoded string, password string) bool {
+	for _, a := range algorithms {
+		if a.Identifies(encoded) {
+			verified, err := a.Verify(encoded, password)
+			return err == nil && verified
+		}
+	}
+	return false
+}
+
+// Hasher hashes new passwords with the configured `Algorithm`, and verifies passwords hashed with any of the
+// supported algorithms and parameters.
+type Hasher struct {
+	current Algorithm
+}
+
+// NewHasher is a function creating a `Hasher` hashing new passwords with the algorithm provided.
+func NewHasher(current Algorithm) *Hasher {
+	return &Hasher{
+		current: current,
+	}
+}
+
+// DefaultHasher is a function creating a `Hasher` with argon2id and the parameters recommended by OWASP.
+func DefaultHasher() *Hasher {
+	return NewHasher(Argon2id{
+		Memory:     19 * 1024,
+		Iterations: 2,
+		Threads:    1,
+		KeyLength:  32,
+		SaltLength: 16,
+	})
+}
+
+// LoadHasher is a function creating the `Hasher` of the application from the configuration.
+func LoadHasher(c *common.AuthConfig) (*Hasher, error) {
+	switch c.PasswordAlgorithm {
+	case AlgorithmArgon2id:
+		if c.Argon2Memory <= 0 || c.Argon2Iterations <= 0 || c.Argon2Threads <= 0 || c.Argon2Threads > 255 {
+			return nil, errors.New("argon2id memory, iterations and threads must be positive, threads at most 255")
+		}
+		return NewHasher(Argon2id{
+			Memory:     uint32(c.Argon2Memory),
+			Iterations: uint32(c.Argon2Iterations),
+			Threads:    uint8(c.Argon2Threads),
+			KeyLength:  32,
+			SaltLength: 16,
+		}), nil
+	case AlgorithmBcrypt:
+		if c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %v and %v, got %v", bcrypt.MinCost, bcrypt.MaxCost, c.BcryptCost)
+		}
+		return NewHasher(Bcrypt{Cost: c.BcryptCost}), nil
+	default:
+		return nil, fmt.Errorf("unknown password hashing algorithm %v", c.PasswordAlgorithm)
+	}
+}
+
+// Hash is a method of `Hasher` returning the encoded hash of a password with the configured algorithm.
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+// Verify is a method of `Hasher` returning whether the password matches the encoded hash of any supported algorithm.
+func (h *Hasher) Verify(encoded string, password string) bool {
+	return Verify(encoded, password)
+}
+
+// Outdated is a method of `Hasher` returning whether the encoded hash was created with an other algorithm or with
+// other parameters than the configured ones, so it should be replaced at the next successful sign in.
+func (h *Hasher) Outdated(encoded string) bool {
+	return !h.current.Identifies(encoded) || h.current.Outdated(encoded)
+}
@@ -0,0 +1,31 @@
+package password
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Entry is a password used by a user earlier, for database storage. Only the hash of the password is kept.
+type Entry struct {
+	ID        uuid.UUID `db:"history_id"`
+	UserID    uuid.UUID `db:"user_id"`
+	Hash      string    `db:"pass_hash"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// NewEntry is a function to create a new `Entry` for the password hash of a user.
+func NewEntry(userID uuid.UUID, hash string) *Entry {
+	return &Entry{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Hash:      hash,
+		CreatedAt: time.Now(),
+	}
+}
+
+// Storer is the interface for `Entry` persistence
+type Storer interface {
+	Store(entry *Entry) error
+	ByUser(userID uuid.UUID, limit int) ([]Entry, error)
+}
@@ -0,0 +1,189 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+// MockStorer is a mock implementation of the Storer interface
+type MockStorer struct {
+	mock.Mock
+}
+
+func (m *MockStorer) Store(entry *Entry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *MockStorer) ByUser(userID uuid.UUID, limit int) ([]Entry, error) {
+	args := m.Called(userID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]Entry), args.Error(1)
+}
+
+func breachList(passwords ...string) []byte {
+	var lines []string
+	for _, p := range passwords {
+		sum := sha1.Sum([]byte(p))
+		lines = append(lines, strings.ToUpper(hex.EncodeToString(sum[:]))+":42")
+	}
+	return []byte(strings.Join(lines, "\r\n"))
+}
+
+func hash(t *testing.T, password string) string {
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	assert.NoError(t, err)
+	return string(h)
+}
+
+func TestPolicyReportsEveryBrokenRule(t *testing.T) {
+	p, err := NewPolicy(&common.AuthConfig{PasswordMinLength: 12, PasswordClasses: 3}, breachList("password"), new(MockStorer))
+	assert.NoError(t, err)
+
+	err = p.Validate(uuid.Nil, "password")
+
+	var violations common.Violations
+	assert.ErrorAs(t, err, &violations)
+	assert.Equal(t, common.Violations{
+		"Password must be at least 12 characters long.",
+		"Password must contain at least 3 of the following: lowercase letters, uppercase letters, digits and symbols.",
+		"Password has appeared in a data breach, please choose another one.",
+	}, violations)
+	assert.Equal(t, strings.Join(violations, " "), common.ValidationMessage(err).Message)
+}
+
+func TestPolicyAcceptsStrongPassword(t *testing.T) {
+	p, err := NewPolicy(&common.AuthConfig{PasswordMinLength: 12, PasswordClasses: 3}, breachList("password"), new(MockStorer))
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.Validate(uuid.Nil, "Correct-horse-battery"))
+}
+
+func TestPolicyRejectsRecentPassword(t *testing.T) {
+	entries := new(MockStorer)
+	p, err := NewPolicy(&common.AuthConfig{PasswordHistory: 2}, nil, entries)
+	assert.NoError(t, err)
+	userID := uuid.New()
+	entries.On("ByUser", userID, 2).Return([]Entry{
+		{UserID: userID, Hash: hash(t, "second-password")},
+		{UserID: userID, Hash: hash(t, "first-password")},
+	}, nil)
+
+	err = p.Validate(userID, "first-password")
+	assert.Equal(t, common.Violations{"Password must not match any of your last 2 passwords."}, err)
+
+	assert.NoError(t, p.Validate(userID, "third-password"))
+}
+
+func TestPolicySkipsHistoryOfNewUser(t *testing.T) {
+	entries := new(MockStorer)
+	p, err := NewPolicy(&common.AuthConfig{PasswordHistory: 5}, nil, entries)
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.Validate(uuid.Nil, "any-password"))
+	entries.AssertNotCalled(t, "ByUser", mock.Anything, mock.Anything)
+}
+
+func TestPolicyReturnsStorageError(t *testing.T) {
+	entries := new(MockStorer)
+	p, err := NewPolicy(&common.AuthConfig{PasswordHistory: 5}, nil, entries)
+	assert.NoError(t, err)
+	userID := uuid.New()
+	entries.On("ByUser", userID, 5).Return(nil, errors.New("connection lost"))
+
+	err = p.Validate(userID, "any-password")
+
+	assert.Error(t, err)
+	_, violated := err.(common.Violations)
+	assert.False(t, violated)
+}
+
+func TestPolicyRejectsInvalidConfiguration(t *testing.T) {
+	_, err := NewPolicy(&common.AuthConfig{PasswordClasses: 5}, nil, new(MockStorer))
+	assert.Error(t, err)
+
+	_, err = NewPolicy(&common.AuthConfig{}, []byte("not-a-hash\n"), new(MockStorer))
+	assert.Error(t, err)
+}
+
+var testArgon2id = Argon2id{Memory: 1024, Iterations: 1, Threads: 1, KeyLength: 32, SaltLength: 16}
+
+func TestArgon2idHashEncodesParameters(t *testing.T) {
+	hash, err := testArgon2id.Hash("password")
+	assert.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	assert.True(t, Verify(hash, "password"))
+	assert.False(t, Verify(hash, "other-password"))
+
+	other, err := testArgon2id.Hash("password")
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+}
+
+func TestVerifyUsesParametersOfHash(t *testing.T) {
+	argon, err := Argon2id{Memory: 2048, Iterations: 2, Threads: 2, KeyLength: 16, SaltLength: 8}.Hash("password")
+	assert.NoError(t, err)
+	bcrypted, err := Bcrypt{Cost: bcrypt.MinCost}.Hash("password")
+	assert.NoError(t, err)
+
+	assert.True(t, Verify(argon, "password"))
+	assert.True(t, Verify(bcrypted, "password"))
+	assert.False(t, Verify("", "password"))
+	assert.False(t, Verify("$argon2id$v=19$m=1024$broken", "password"))
+}
+
+func TestHasherReportsOutdatedHash(t *testing.T) {
+	h := NewHasher(testArgon2id)
+	current, err := h.Hash("password")
+	assert.NoError(t, err)
+	weaker, err := Argon2id{Memory: 512, Iterations: 1, Threads: 1, KeyLength: 32, SaltLength: 16}.Hash("password")
+	assert.NoError(t, err)
+	bcrypted, err := Bcrypt{Cost: bcrypt.MinCost}.Hash("password")
+	assert.NoError(t, err)
+
+	assert.False(t, h.Outdated(current))
+	assert.True(t, h.Outdated(weaker))
+	assert.True(t, h.Outdated(bcrypted))
+
+	h = NewHasher(Bcrypt{Cost: bcrypt.MinCost + 1})
+	assert.True(t, h.Outdated(bcrypted))
+	assert.True(t, h.Outdated(current))
+}
+
+func TestLoadHasherValidatesConfiguration(t *testing.T) {
+	_, err := LoadHasher(&common.AuthConfig{PasswordAlgorithm: AlgorithmArgon2id, Argon2Memory: 19456, Argon2Iterations: 2, Argon2Threads: 1})
+	assert.NoError(t, err)
+	_, err = LoadHasher(&common.AuthConfig{PasswordAlgorithm: AlgorithmBcrypt, BcryptCost: 12})
+	assert.NoError(t, err)
+
+	_, err = LoadHasher(&common.AuthConfig{PasswordAlgorithm: AlgorithmBcrypt, BcryptCost: 2})
+	assert.Error(t, err)
+	_, err = LoadHasher(&common.AuthConfig{PasswordAlgorithm: AlgorithmArgon2id})
+	assert.Error(t, err)
+	_, err = LoadHasher(&common.AuthConfig{PasswordAlgorithm: "md5"})
+	assert.Error(t, err)
+}
+
+func TestRememberStoresHash(t *testing.T) {
+	entries := new(MockStorer)
+	p, err := NewPolicy(&common.AuthConfig{}, nil, entries)
+	assert.NoError(t, err)
+	userID := uuid.New()
+	entries.On("Store", mock.MatchedBy(func(e *Entry) bool { return e.UserID == userID && e.Hash == "hash" })).Return(nil)
+
+	assert.NoError(t, p.Remember(userID, "hash"))
+	entries.AssertExpectations(t)
+}
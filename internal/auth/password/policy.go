@@ -0,0 +1,140 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+// Policy is the set of rules new passwords of the users have to follow.
+type Policy struct {
+	minLength int
+	classes   int
+	history   int
+	breached  map[[sha1.Size]byte]struct{}
+	entries   Storer
+}
+
+// LoadPolicy is a function creating the `Policy` of the application from the configuration, reading the breached
+// password list file if one is set.
+func LoadPolicy(c *common.AppConfig, entries Storer) (*Policy, error) {
+	var breached []byte
+	if c.Auth.PasswordBreachList != "" {
+		content, err := os.ReadFile(c.PathFor(c.Auth.PasswordBreachList))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read breached password list: %w", err)
+		}
+		breached = content
+	}
+	return NewPolicy(c.Auth, breached, entries)
+}
+
+// NewPolicy is a function creating a `Policy` from the configuration and the content of a breached password list. The
+// list has a hex encoded SHA-1 hash of a password in each line, optionally followed by a colon and the number of
+// occurrences, as in the downloads of Have I Been Pwned.
+func NewPolicy(c *common.AuthConfig, breached []byte, entries Storer) (*Policy, error) {
+	if c.PasswordClasses < 0 || c.PasswordClasses > 4 {
+		return nil, fmt.Errorf("required password character classes must be between 0 and 4, got %v", c.PasswordClasses)
+	}
+	p := &Policy{
+		minLength: c.PasswordMinLength,
+		classes:   c.PasswordClasses,
+		history:   c.PasswordHistory,
+		breached:  make(map[[sha1.Size]byte]struct{}),
+		entries:   entries,
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(breached))
+	for line := 1; scanner.Scan(); line++ {
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if hash == "" {
+			continue
+		}
+		var sum [sha1.Size]byte
+		if n, err := hex.Decode(sum[:], []byte(hash)); err != nil || n != sha1.Size {
+			return nil, fmt.Errorf("invalid SHA-1 hash in line %v of breached password list", line)
+		}
+		p.breached[sum] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+	return p, nil
+}
+
+// Validate is a method of `Policy` checking a new password of a user, `uuid.Nil` for a user signing up. Returns
+// `common.Violations` with a message for each rule broken, or an error if the earlier passwords could not be loaded.
+func (p *Policy) Validate(userID uuid.UUID, password string) error {
+	var violations common.Violations
+	if len([]rune(password)) < p.minLength {
+		violations = append(violations, fmt.Sprintf("Password must be at least %v characters long.", p.minLength))
+	}
+	if classes(password) < p.classes {
+		violations = append(violations, fmt.Sprintf("Password must contain at least %v of the following: lowercase letters, uppercase letters, digits and symbols.", p.classes))
+	}
+	if p.isBreached(password) {
+		violations = append(violations, "Password has appeared in a data breach, please choose another one.")
+	}
+	if len(violations) == 0 && p.history > 0 && userID != uuid.Nil {
+		reused, err := p.isReused(userID, password)
+		if err != nil {
+			return err
+		}
+		if reused {
+			violations = append(violations, fmt.Sprintf("Password must not match any of your last %v passwords.", p.history))
+		}
+	}
+	if len(violations) > 0 {
+		return violations
+	}
+	return nil
+}
+
+// Remember is a method of `Policy` adding the password hash of a user to the history, so it can not be reused.
+func (p *Policy) Remember(userID uuid.UUID, hash string) error {
+	return p.entries.Store(NewEntry(userID, hash))
+}
+
+func (p *Policy) isBreached(password string) bool {
+	_, ok := p.breached[sha1.Sum([]byte(password))]
+	return ok
+}
+
+func (p *Policy) isReused(userID uuid.UUID, password string) (bool, error) {
+	entries, err := p.entries.ByUser(userID, p.history)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if Verify(entry.Hash, password) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func classes(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
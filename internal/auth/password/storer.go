@@ -0,0 +1,40 @@
+package password
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresStorer is the `Storer` implementation based on sqlx library.
+type PostgresStorer struct {
+	db *sqlx.DB
+}
+
+// NewPostgresStorer creates a new `PostgresStorer` instance based on the sqlx library.
+func NewPostgresStorer(db *sqlx.DB) *PostgresStorer {
+	return &PostgresStorer{
+		db: db,
+	}
+}
+
+// Store is a method of the `PostgresStorer` struct. Takes an `Entry` as parameter and persists it.
+func (s *PostgresStorer) Store(entry *Entry) error {
+	query := `INSERT INTO microsaas.password_history (history_id, user_id, pass_hash, created_at) VALUES ($1, $2, $3, $4)`
+	if _, err := s.db.Exec(query, entry.ID, entry.UserID, entry.Hash, entry.CreatedAt); err != nil {
+		return fmt.Errorf("failed to store password history: %w", err)
+	}
+	return nil
+}
+
+// ByUser is a method of the `PostgresStorer` struct. Takes a user ID and a limit as parameter to load the last
+// passwords of the user, the most recent first.
+func (s *PostgresStorer) ByUser(userID uuid.UUID, limit int) ([]Entry, error) {
+	var entries []Entry
+	query := `SELECT history_id, user_id, pass_hash, created_at FROM microsaas.password_history WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`
+	if err := s.db.Select(&entries, query, userID, limit); err != nil {
+		return nil, fmt.Errorf("failed to list password history: %w", err)
+	}
+	return entries, nil
+}
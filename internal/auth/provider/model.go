@@ -0,0 +1,52 @@
+package provider
+
+import (
+	"golang.org/x/oauth2/facebook"
+	"golang.org/x/oauth2/google"
+
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+// presets are the defaults of well known identity providers, so only the client credentials have to be configured for them.
+var presets = map[string]common.ProviderConfig{
+	"google": {
+		DisplayName:    "Google",
+		AuthURL:        google.Endpoint.AuthURL,
+		TokenURL:       google.Endpoint.TokenURL,
+		UserInfoURL:    "https://openidconnect.googleapis.com/v1/userinfo",
+		Scopes:         []string{"openid", "email", "profile"},
+		FirstNameClaim: "given_name",
+		LastNameClaim:  "family_name",
+	},
+	"facebook": {
+		DisplayName:    "Facebook",
+		AuthURL:        facebook.Endpoint.AuthURL,
+		TokenURL:       facebook.Endpoint.TokenURL,
+		UserInfoURL:    "https://graph.facebook.com/me?fields=id,first_name,last_name,email",
+		Scopes:         []string{"email", "public_profile"},
+		FirstNameClaim: "first_name",
+		LastNameClaim:  "last_name",
+	},
+}
+
+// Identity is a user as identified by an identity provider.
+type Identity struct {
+	Subject   string
+	Email     string
+	FirstName string
+	LastName  string
+}
+
+// View is the JSON representation of an identity provider, for listing the sign in options.
+type View struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// discovery is the part of an OpenID Connect discovery document used by the application.
+type discovery struct {
+	Issuer           string `json:"issuer"`
+	AuthEndpoint     string `json:"authorization_endpoint"`
+	TokenEndpoint    string `json:"token_endpoint"`
+	UserInfoEndpoint string `json:"userinfo_endpoint"`
+}
@@ -0,0 +1,289 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/oauth2"
+
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+// ErrUnverifiedEmail is the error for an identity provider reporting the email address of the user as not verified.
+var ErrUnverifiedEmail = errors.New("email address is not verified by the identity provider")
+
+// Provider is an OAuth2 / OpenID Connect identity provider for single sign-on.
+type Provider struct {
+	Name        string
+	DisplayName string
+	conf        common.ProviderConfig
+	redirectURL string
+
+	mu       sync.Mutex
+	resolved *oauth2.Config
+	userInfo string
+}
+
+// New is a function creating a `Provider` from its configuration, filling the missing settings of well known
+// providers from their presets. The endpoints are discovered on first use when an issuer or discovery URL is set.
+func New(conf common.ProviderConfig, redirectURL string) (*Provider, error) {
+	if preset, ok := presets[conf.Name]; ok {
+		conf = withDefaults(conf, preset)
+	}
+	if conf.EmailClaim == "" {
+		conf.EmailClaim = "email"
+	}
+	if conf.EmailVerifiedClaim == "" {
+		conf.EmailVerifiedClaim = "email_verified"
+	}
+	if conf.FirstNameClaim == "" {
+		conf.FirstNameClaim = "given_name"
+	}
+	if conf.LastNameClaim == "" {
+		conf.LastNameClaim = "family_name"
+	}
+	if len(conf.Scopes) == 0 {
+		conf.Scopes = []string{"openid", "email", "profile"}
+	}
+	if conf.DisplayName == "" {
+		conf.DisplayName = conf.Name
+	}
+
+	if conf.ClientID == "" {
+		return nil, fmt.Errorf("client ID of identity provider %v is not set", conf.Name)
+	}
+	discoverable := conf.Issuer != "" || conf.DiscoveryURL != ""
+	if !discoverable && (conf.AuthURL == "" || conf.TokenURL == "" || conf.UserInfoURL == "") {
+		return nil, fmt.Errorf("identity provider %v needs an issuer, a discovery URL or all of its endpoints", conf.Name)
+	}
+	return &Provider{
+		Name:        conf.Name,
+		DisplayName: conf.DisplayName,
+		conf:        conf,
+		redirectURL: redirectURL,
+	}, nil
+}
+
+// AuthCodeURL is a method of `Provider` returning the URL of the consent page of the provider to redirect the user to.
+func (p *Provider) AuthCodeURL(ctx context.Context, state string, opts ...oauth2.AuthCodeOption) (string, error) {
+	c, _, err := p.config(ctx)
+	if err != nil {
+		return "", err
+	}
+	return c.AuthCodeURL(state, opts...), nil
+}
+
+// Exchange is a method of `Provider` exchanging the authorization code of a callback for a token, and loading the
+// identity of the user with it.
+func (p *Provider) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*Identity, error) {
+	c, userInfo, err := p.config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := c.Exchange(ctx, code, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange error: %w", err)
+	}
+
+	res, err := c.Client(ctx, token).Get(userInfo)
+	if err != nil {
+		return nil, fmt.Errorf("error getting userinfo: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error getting userinfo: %v", res.Status)
+	}
+
+	var claims map[string]any
+	if err = json.NewDecoder(res.Body).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("user details invalid: %w", err)
+	}
+	return p.identity(claims)
+}
+
+// AsView is a method of the `Provider` struct. It converts a `Provider` object into a `View` object.
+func (p *Provider) AsView() View {
+	return View{
+		Name:        p.Name,
+		DisplayName: p.DisplayName,
+	}
+}
+
+func (p *Provider) identity(claims map[string]any) (*Identity, error) {
+	i := &Identity{
+		Subject:   claim(claims, "sub"),
+		Email:     claim(claims, p.conf.EmailClaim),
+		FirstName: claim(claims, p.conf.FirstNameClaim),
+		LastName:  claim(claims, p.conf.LastNameClaim),
+	}
+	if i.Subject == "" {
+		i.Subject = claim(claims, "id")
+	}
+	if i.Subject == "" {
+		return nil, errors.New("user details invalid: no subject provided")
+	}
+	if i.Email == "" {
+		return nil, errors.New("user details invalid: no email address provided")
+	}
+	if verified, ok := claims[p.conf.EmailVerifiedClaim]; ok && fmt.Sprint(verified) != "true" {
+		return nil, ErrUnverifiedEmail
+	}
+	return i, nil
+}
+
+// config is a method of `Provider` returning the OAuth2 configuration and the userinfo endpoint of the provider,
+// discovering the endpoints at the first call if needed.
+func (p *Provider) config(ctx context.Context) (*oauth2.Config, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resolved != nil {
+		return p.resolved, p.userInfo, nil
+	}
+
+	endpoint := oauth2.Endpoint{AuthURL: p.conf.AuthURL, TokenURL: p.conf.TokenURL}
+	userInfo := p.conf.UserInfoURL
+	if p.conf.Issuer != "" || p.conf.DiscoveryURL != "" {
+		d, err := p.discover(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		if endpoint.AuthURL == "" {
+			endpoint.AuthURL = d.AuthEndpoint
+		}
+		if endpoint.TokenURL == "" {
+			endpoint.TokenURL = d.TokenEndpoint
+		}
+		if userInfo == "" {
+			userInfo = d.UserInfoEndpoint
+		}
+	}
+	if endpoint.AuthURL == "" || endpoint.TokenURL == "" || userInfo == "" {
+		return nil, "", fmt.Errorf("endpoints of identity provider %v are not configured", p.Name)
+	}
+
+	p.resolved = &oauth2.Config{
+		ClientID:     p.conf.ClientID,
+		ClientSecret: p.conf.ClientSecret,
+		RedirectURL:  p.redirectURL,
+		Scopes:       p.conf.Scopes,
+		Endpoint:     endpoint,
+	}
+	p.userInfo = userInfo
+	return p.resolved, p.userInfo, nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	url := p.conf.DiscoveryURL
+	if url == "" {
+		url = strings.TrimSuffix(p.conf.Issuer, "/") + "/.well-known/openid-configuration"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover identity provider %v: %w", p.Name, err)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover identity provider %v: %w", p.Name, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to discover identity provider %v: %v", p.Name, res.Status)
+	}
+
+	var d discovery
+	if err = json.NewDecoder(res.Body).Decode(&d); err != nil {
+		return nil, fmt.Errorf("failed to discover identity provider %v: %w", p.Name, err)
+	}
+	if p.conf.Issuer != "" && strings.TrimSuffix(d.Issuer, "/") != strings.TrimSuffix(p.conf.Issuer, "/") {
+		return nil, fmt.Errorf("issuer mismatch of identity provider %v: %v", p.Name, d.Issuer)
+	}
+	return &d, nil
+}
+
+// reserved are the names identity providers can not have, as they are taken by routes or by the password login.
+var reserved = []string{"providers", "credentials"}
+
+// Registry is the collection of the identity providers enabled in the configuration.
+type Registry struct {
+	providers map[string]*Provider
+	ordered   []*Provider
+}
+
+// NewRegistry is a function creating a `Registry` of the identity providers in the configuration. The callback of each
+// provider is `/api/public/v1/auth/<name>/redirect` on the backend.
+func NewRegistry(c *common.AuthConfig) (*Registry, error) {
+	r := &Registry{
+		providers: make(map[string]*Provider),
+		ordered:   []*Provider{},
+	}
+	for _, conf := range c.Providers {
+		if slices.Contains(reserved, conf.Name) {
+			return nil, fmt.Errorf("identity provider name %v is reserved", conf.Name)
+		}
+		if _, ok := r.providers[conf.Name]; ok {
+			return nil, fmt.Errorf("identity provider %v is configured more than once", conf.Name)
+		}
+		p, err := New(conf, c.BackendRoot+"/api/public/v1/auth/"+conf.Name+"/redirect")
+		if err != nil {
+			return nil, err
+		}
+		r.providers[p.Name] = p
+		r.ordered = append(r.ordered, p)
+	}
+	return r, nil
+}
+
+// ByName is a method of `Registry` returning the identity provider with the name provided.
+func (r *Registry) ByName(name string) (*Provider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// List is a method of `Registry` returning the identity providers in the order of the configuration.
+func (r *Registry) List() []*Provider {
+	return r.ordered
+}
+
+func withDefaults(conf common.ProviderConfig, preset common.ProviderConfig) common.ProviderConfig {
+	if conf.DisplayName == "" {
+		conf.DisplayName = preset.DisplayName
+	}
+	if conf.Issuer == "" && conf.DiscoveryURL == "" {
+		if conf.AuthURL == "" {
+			conf.AuthURL = preset.AuthURL
+		}
+		if conf.TokenURL == "" {
+			conf.TokenURL = preset.TokenURL
+		}
+		if conf.UserInfoURL == "" {
+			conf.UserInfoURL = preset.UserInfoURL
+		}
+	}
+	if len(conf.Scopes) == 0 {
+		conf.Scopes = preset.Scopes
+	}
+	if conf.FirstNameClaim == "" {
+		conf.FirstNameClaim = preset.FirstNameClaim
+	}
+	if conf.LastNameClaim == "" {
+		conf.LastNameClaim = preset.LastNameClaim
+	}
+	return conf
+}
+
+func claim(claims map[string]any, name string) string {
+	switch v := claims[name].(type) {
+	case string:
+		return v
+	case float64:
+		return fmt.Sprint(int64(v))
+	default:
+		return ""
+	}
+}
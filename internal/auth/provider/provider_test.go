@@ -0,0 +1,152 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+func fakeIssuer(t *testing.T, claims map[string]any) *httptest.Server {
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(discovery{
+			Issuer:           srv.URL,
+			AuthEndpoint:     srv.URL + "/authorize",
+			TokenEndpoint:    srv.URL + "/token",
+			UserInfoEndpoint: srv.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		if r.Form.Get("code") != "valid-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"access","token_type":"Bearer","expires_in":3600}`))
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(claims)
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProviderDiscoversEndpointsAndLoadsIdentity(t *testing.T) {
+	srv := fakeIssuer(t, map[string]any{
+		"sub":            "1234",
+		"email":          "test@test.com",
+		"email_verified": true,
+		"given_name":     "Test",
+		"family_name":    "User",
+	})
+	p, err := New(common.ProviderConfig{Name: "keycloak", ClientID: "client", Issuer: srv.URL}, "http://localhost/redirect")
+	assert.NoError(t, err)
+
+	authURL, err := p.AuthCodeURL(context.Background(), "state")
+	assert.NoError(t, err)
+	u, err := url.Parse(authURL)
+	assert.NoError(t, err)
+	assert.Equal(t, "/authorize", u.Path)
+	assert.Equal(t, "client", u.Query().Get("client_id"))
+	assert.Equal(t, "http://localhost/redirect", u.Query().Get("redirect_uri"))
+	assert.Equal(t, "openid email profile", u.Query().Get("scope"))
+
+	identity, err := p.Exchange(context.Background(), "valid-code")
+	assert.NoError(t, err)
+	assert.Equal(t, &Identity{Subject: "1234", Email: "test@test.com", FirstName: "Test", LastName: "User"}, identity)
+
+	_, err = p.Exchange(context.Background(), "invalid-code")
+	assert.Error(t, err)
+}
+
+func TestProviderRejectsUnverifiedEmail(t *testing.T) {
+	srv := fakeIssuer(t, map[string]any{
+		"sub":            "1234",
+		"email":          "test@test.com",
+		"email_verified": false,
+	})
+	p, err := New(common.ProviderConfig{Name: "keycloak", ClientID: "client", Issuer: srv.URL}, "http://localhost/redirect")
+	assert.NoError(t, err)
+
+	_, err = p.Exchange(context.Background(), "valid-code")
+	assert.ErrorIs(t, err, ErrUnverifiedEmail)
+}
+
+func TestProviderMapsCustomClaims(t *testing.T) {
+	srv := fakeIssuer(t, map[string]any{
+		"id":    float64(1234),
+		"mail":  "test@test.com",
+		"first": "Test",
+	})
+	p, err := New(common.ProviderConfig{
+		Name:           "custom",
+		ClientID:       "client",
+		AuthURL:        srv.URL + "/authorize",
+		TokenURL:       srv.URL + "/token",
+		UserInfoURL:    srv.URL + "/userinfo",
+		EmailClaim:     "mail",
+		FirstNameClaim: "first",
+	}, "http://localhost/redirect")
+	assert.NoError(t, err)
+
+	identity, err := p.Exchange(context.Background(), "valid-code")
+	assert.NoError(t, err)
+	assert.Equal(t, &Identity{Subject: "1234", Email: "test@test.com", FirstName: "Test"}, identity)
+}
+
+func TestRegistryUsesPresetsAndConfigurationOrder(t *testing.T) {
+	r, err := NewRegistry(&common.AuthConfig{
+		BackendRoot: "http://localhost:8080",
+		Providers: []common.ProviderConfig{
+			{Name: "facebook", ClientID: "fb"},
+			{Name: "google", ClientID: "google"},
+		},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []View{{Name: "facebook", DisplayName: "Facebook"}, {Name: "google", DisplayName: "Google"}},
+		[]View{r.List()[0].AsView(), r.List()[1].AsView()})
+
+	p, ok := r.ByName("google")
+	assert.True(t, ok)
+	authURL, err := p.AuthCodeURL(context.Background(), "state")
+	assert.NoError(t, err)
+	u, err := url.Parse(authURL)
+	assert.NoError(t, err)
+	assert.Equal(t, "accounts.google.com", u.Host)
+	assert.Equal(t, "http://localhost:8080/api/public/v1/auth/google/redirect", u.Query().Get("redirect_uri"))
+
+	_, ok = r.ByName("github")
+	assert.False(t, ok)
+}
+
+func TestRegistryRejectsIncompleteProvider(t *testing.T) {
+	_, err := NewRegistry(&common.AuthConfig{
+		Providers: []common.ProviderConfig{{Name: "gitlab", ClientID: "client"}},
+	})
+	assert.Error(t, err)
+
+	_, err = NewRegistry(&common.AuthConfig{
+		Providers: []common.ProviderConfig{{Name: "gitlab", Issuer: "https://gitlab.com"}},
+	})
+	assert.Error(t, err)
+
+	_, err = NewRegistry(&common.AuthConfig{
+		Providers: []common.ProviderConfig{{Name: "credentials", ClientID: "client", Issuer: "https://gitlab.com"}},
+	})
+	assert.Error(t, err)
+}
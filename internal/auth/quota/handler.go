@@ -0,0 +1,176 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inokone/go-micro-saas/internal/auth/organization"
+	"github.com/inokone/go-micro-saas/internal/auth/role"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+var statusUnknownError = common.StatusMessage{Message: "Unknown error, please contact administrator!"}
+
+// Handler is a struct for the middleware enforcing the limits of the roles, and web handles related to the usage.
+type Handler struct {
+	service *Service
+	users   user.Storer
+	orgs    organization.Storer
+}
+
+// NewHandler creates a new `Handler`, based on the quota service, the user and the organization persistence.
+func NewHandler(service *Service, users user.Storer, orgs organization.Storer) *Handler {
+	return &Handler{
+		service: service,
+		users:   users,
+		orgs:    orgs,
+	}
+}
+
+// Consume is a method of `Handler`. Returns a middleware counting the request against the limit provided, before the
+// request is handled. Requests over the limit are rejected with 402 for limits on the resources and with 429 for
+// monthly limits, reporting the current usage. The count is given back if the request fails. Must follow `Validate`,
+// or `RequireOrganization` for organization limits.
+func (h *Handler) Consume(d Definition) gin.HandlerFunc {
+	return func(g *gin.Context) {
+		subjectID, ok := subjectOf(g, d)
+		if !ok {
+			g.AbortWithStatusJSON(http.StatusForbidden, common.StatusMessage{Message: "Not a member of any organization!"})
+			return
+		}
+		r, err := h.roleOf(g, d)
+		if err != nil {
+			log.WithError(err).Error("Failed to collect role for quota.")
+			g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+			return
+		}
+		usage, err := h.service.Consume(subjectID, r, d)
+		if errors.Is(err, ErrExceeded) {
+			abortExceeded(g, usage)
+			return
+		}
+		if err != nil {
+			log.WithError(err).Error("Failed to consume quota.")
+			g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+			return
+		}
+
+		g.Next()
+
+		if g.Writer.Status() >= http.StatusBadRequest {
+			if err = h.service.Release(subjectID, d); err != nil {
+				log.WithError(err).Error("Failed to release quota.")
+			}
+		}
+	}
+}
+
+// Release is a method of `Handler`. Returns a middleware giving back a count of the limit provided, after the request
+// deleting a resource is handled successfully.
+func (h *Handler) Release(d Definition) gin.HandlerFunc {
+	return func(g *gin.Context) {
+		g.Next()
+
+		if g.Writer.Status() >= http.StatusMultipleChoices {
+			return
+		}
+		if subjectID, ok := subjectOf(g, d); ok {
+			if err := h.service.Release(subjectID, d); err != nil {
+				log.WithError(err).Error("Failed to release quota.")
+			}
+		}
+	}
+}
+
+// List is a method of `Handler`. Lists the usage of the current user and the active organization with their limits.
+// @Summary Usage list endpoint
+// @Schemes
+// @Description Returns the usage of the limits of the current user and the active organization, -1 limit meaning unlimited
+// @Accept json
+// @Produce json
+// @Success 200 {array} quota.View
+// @Failure 401 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/usage [get]
+func (h *Handler) List(g *gin.Context) {
+	res := make([]View, 0, len(Definitions))
+	for _, d := range Definitions {
+		subjectID, ok := subjectOf(g, d)
+		if !ok {
+			continue
+		}
+		r, err := h.roleOf(g, d)
+		if err != nil {
+			log.WithError(err).Error("Failed to collect role for quota.")
+			g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+			return
+		}
+		usage, err := h.service.Usage(subjectID, r, d)
+		if err != nil {
+			log.WithError(err).Error("Failed to collect usage.")
+			g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+			return
+		}
+		res = append(res, usage.AsView())
+	}
+	g.JSON(http.StatusOK, res)
+}
+
+// roleOf returns the role the limits are taken from: the role of the current user for user limits, and the role of the
+// first owner of the active organization for organization limits.
+func (h *Handler) roleOf(g *gin.Context, d Definition) (*role.Role, error) {
+	if d.Scope == User {
+		return currentUser(g).Role, nil
+	}
+	members, err := h.orgs.Members(organization.Active(g).OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		if m.Is(organization.RoleOwner) {
+			owner, err := h.users.ByID(m.UserID)
+			if err != nil {
+				return nil, err
+			}
+			return owner.Role, nil
+		}
+	}
+	return nil, fmt.Errorf("organization %v has no owner", organization.Active(g).OrganizationID)
+}
+
+// subjectOf returns the ID of the user or the organization the usage of the limit is counted for.
+func subjectOf(g *gin.Context, d Definition) (uuid.UUID, bool) {
+	if d.Scope == User {
+		return currentUser(g).ID, true
+	}
+	active := organization.Active(g)
+	if active == nil {
+		return uuid.Nil, false
+	}
+	return active.OrganizationID, true
+}
+
+func abortExceeded(g *gin.Context, usage Usage) {
+	status := http.StatusPaymentRequired
+	if !usage.Resets.IsZero() {
+		status = http.StatusTooManyRequests
+		g.Header("Retry-After", strconv.Itoa(int(time.Until(usage.Resets).Seconds())+1))
+	}
+	g.AbortWithStatusJSON(status, ExceededMessage{
+		Message: "Limit of " + usage.Name + " is reached!",
+		Quota:   usage.AsView(),
+	})
+}
+
+func currentUser(g *gin.Context) *user.User {
+	u, _ := g.Get("user")
+	return u.(*user.User)
+}
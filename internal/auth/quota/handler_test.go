@@ -0,0 +1,161 @@
+package quota
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/organization"
+	"github.com/inokone/go-micro-saas/internal/auth/role"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+)
+
+// MockUserStorer is a mock of the user.Storer interface, implementing the methods the quotas need.
+type MockUserStorer struct {
+	mock.Mock
+	user.Storer
+}
+
+func (m *MockUserStorer) ByID(id uuid.UUID) (*user.User, error) {
+	args := m.Called(id)
+	return args.Get(0).(*user.User), args.Error(1)
+}
+
+// MockOrganizationStorer is a mock of the organization.Storer interface, implementing the methods the quotas need.
+type MockOrganizationStorer struct {
+	mock.Mock
+	organization.Storer
+}
+
+func (m *MockOrganizationStorer) Members(id uuid.UUID) ([]organization.Member, error) {
+	args := m.Called(id)
+	return args.Get(0).([]organization.Member), args.Error(1)
+}
+
+func testUser(limits role.Limits) *user.User {
+	r := role.NewRole("Free", 1, nil)
+	r.Limits = limits
+	return &user.User{ID: uuid.New(), Email: "test@example.com", Role: r, RoleID: r.ID, Enabled: true}
+}
+
+func serve(handlers ...gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/", handlers...)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/", nil)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func withUser(usr *user.User, active *organization.Membership) gin.HandlerFunc {
+	return func(g *gin.Context) {
+		g.Set("user", usr)
+		if active != nil {
+			organization.SetActive(g, active)
+		}
+	}
+}
+
+func respond(status int) gin.HandlerFunc {
+	return func(g *gin.Context) {
+		g.Status(status)
+	}
+}
+
+func TestConsume402WhenLimitOfResourcesReached(t *testing.T) {
+	usages := new(MockStorer)
+	h := NewHandler(NewService(usages), nil, nil)
+	usr := testUser(role.Limits{role.LimitAPIKeys: 2})
+
+	usages.On("Consume", User, usr.ID, role.LimitAPIKeys, mock.Anything, 2).Return(0, ErrExceeded)
+	usages.On("Used", User, usr.ID, role.LimitAPIKeys, mock.Anything).Return(2, nil)
+
+	w := serve(withUser(usr, nil), h.Consume(APIKeys), respond(http.StatusCreated))
+
+	assert.Equal(t, http.StatusPaymentRequired, w.Code)
+	var res ExceededMessage
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, View{Name: role.LimitAPIKeys, Scope: "user", Used: 2, Limit: 2}, res.Quota)
+}
+
+func TestConsumeReleasesWhenRequestFails(t *testing.T) {
+	usages := new(MockStorer)
+	h := NewHandler(NewService(usages), nil, nil)
+	usr := testUser(role.Limits{role.LimitAPIKeys: 2})
+
+	usages.On("Consume", User, usr.ID, role.LimitAPIKeys, mock.Anything, 2).Return(1, nil)
+	usages.On("Release", User, usr.ID, role.LimitAPIKeys, mock.Anything).Return(nil)
+
+	w := serve(withUser(usr, nil), h.Consume(APIKeys), respond(http.StatusBadRequest))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	usages.AssertExpectations(t)
+}
+
+func TestConsume429WithOrganizationLimitOfOwner(t *testing.T) {
+	usages := new(MockStorer)
+	users := new(MockUserStorer)
+	orgs := new(MockOrganizationStorer)
+	h := NewHandler(NewService(usages), users, orgs)
+	owner := testUser(role.Limits{role.LimitInvitations: 5})
+	admin := testUser(role.Limits{role.LimitInvitations: role.Unlimited})
+	orgID := uuid.New()
+	active := organization.NewMembership(orgID, admin.ID, organization.RoleAdmin)
+
+	orgs.On("Members", orgID).Return([]organization.Member{
+		{Membership: *organization.NewMembership(orgID, owner.ID, organization.RoleOwner)},
+		{Membership: *active},
+	}, nil)
+	users.On("ByID", owner.ID).Return(owner, nil)
+	usages.On("Consume", Organization, orgID, role.LimitInvitations, mock.Anything, 5).Return(0, ErrExceeded)
+	usages.On("Used", Organization, orgID, role.LimitInvitations, mock.Anything).Return(5, nil)
+
+	w := serve(withUser(admin, active), h.Consume(Invitations), respond(http.StatusCreated))
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.Greater(t, retry, 0)
+	assert.LessOrEqual(t, retry, int((32 * 24 * time.Hour).Seconds()))
+}
+
+func TestReleaseOnlyAfterSuccess(t *testing.T) {
+	usages := new(MockStorer)
+	h := NewHandler(NewService(usages), nil, nil)
+	usr := testUser(role.Limits{})
+
+	w := serve(withUser(usr, nil), h.Release(APIKeys), respond(http.StatusNotFound))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	usages.AssertNotCalled(t, "Release", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	usages.On("Release", User, usr.ID, role.LimitAPIKeys, mock.Anything).Return(nil)
+	w = serve(withUser(usr, nil), h.Release(APIKeys), respond(http.StatusOK))
+	assert.Equal(t, http.StatusOK, w.Code)
+	usages.AssertExpectations(t)
+}
+
+func TestListSkipsOrganizationLimitsWithoutActiveOrganization(t *testing.T) {
+	usages := new(MockStorer)
+	h := NewHandler(NewService(usages), nil, nil)
+	usr := testUser(role.Limits{role.LimitAPIKeys: 3})
+
+	usages.On("Used", mock.Anything, usr.ID, mock.Anything, mock.Anything).Return(1, nil)
+
+	w := serve(withUser(usr, nil), h.List)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var res []View
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Len(t, res, 2)
+	assert.Contains(t, res, View{Name: role.LimitAPIKeys, Scope: "user", Used: 1, Limit: 3})
+	assert.Contains(t, res, View{Name: role.LimitOrganizations, Scope: "user", Used: 1, Limit: role.Unlimited})
+}
@@ -0,0 +1,107 @@
+package quota
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/inokone/go-micro-saas/internal/auth/role"
+)
+
+// ErrExceeded is the error for an operation over the limit of the role.
+var ErrExceeded = errors.New("quota exceeded")
+
+// Scope is the owner of the usage counters of a limit.
+type Scope string
+
+const (
+	// User is the scope of the limits counted for each user.
+	User Scope = "user"
+	// Organization is the scope of the limits counted for each organization, with the limits of its first owner.
+	Organization Scope = "organization"
+)
+
+// Period is the counting period of a limit.
+type Period string
+
+const (
+	// Total is the period of the limits on the resources a user or an organization has at a time.
+	Total Period = "total"
+	// Monthly is the period of the limits reset at the beginning of every month.
+	Monthly Period = "monthly"
+)
+
+// Definition is a struct describing how the usage of a named limit of the roles is counted.
+type Definition struct {
+	Name   string
+	Scope  Scope
+	Period Period
+}
+
+var (
+	// APIKeys is the limit of the API keys of a user.
+	APIKeys = Definition{Name: role.LimitAPIKeys, Scope: User, Period: Total}
+	// Organizations is the limit of the organizations a user can create.
+	Organizations = Definition{Name: role.LimitOrganizations, Scope: User, Period: Total}
+	// Invitations is the limit of the invitations an organization can send in a month.
+	Invitations = Definition{Name: role.LimitInvitations, Scope: Organization, Period: Monthly}
+)
+
+// Definitions are all limits the usage is counted for.
+var Definitions = []Definition{APIKeys, Organizations, Invitations}
+
+// Window is a method of `Definition` returning the start of the counting period at the time provided, and the time
+// the usage is reset. Limits with `Total` period are never reset, the reset time is zero for them.
+func (d Definition) Window(t time.Time) (time.Time, time.Time) {
+	if d.Period != Monthly {
+		return time.Unix(0, 0).UTC(), time.Time{}
+	}
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// Usage is a struct representing the usage of a limit in the current counting period.
+type Usage struct {
+	Definition
+	Used   int
+	Limit  int
+	Resets time.Time
+}
+
+// AsView is a method of the `Usage` struct. It converts a `Usage` object into a `View` object.
+func (u Usage) AsView() View {
+	v := View{
+		Name:  u.Name,
+		Scope: string(u.Scope),
+		Used:  u.Used,
+		Limit: u.Limit,
+	}
+	if !u.Resets.IsZero() {
+		v.Resets = int(u.Resets.Unix())
+	}
+	return v
+}
+
+// View is the JSON representation of the usage of a limit.
+type View struct {
+	Name   string `json:"name"`
+	Scope  string `json:"scope"`
+	Used   int    `json:"used"`
+	Limit  int    `json:"limit"`
+	Resets int    `json:"resets,omitempty"`
+}
+
+// ExceededMessage is the JSON response of an operation rejected for exceeding a limit, reporting the current usage.
+type ExceededMessage struct {
+	Message string `json:"message"`
+	Quota   View   `json:"quota"`
+}
+
+// Storer is the interface for the persistence of the usage counters, kept apart for each scope
+type Storer interface {
+	Consume(scope Scope, subjectID uuid.UUID, name string, periodStart time.Time, limit int) (int, error)
+	Release(scope Scope, subjectID uuid.UUID, name string, periodStart time.Time) error
+	Used(scope Scope, subjectID uuid.UUID, name string, periodStart time.Time) (int, error)
+}
@@ -0,0 +1,78 @@
+package quota
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/role"
+)
+
+// MockStorer is a mock implementation of the Storer interface
+type MockStorer struct {
+	mock.Mock
+}
+
+func (m *MockStorer) Consume(scope Scope, subjectID uuid.UUID, name string, periodStart time.Time, limit int) (int, error) {
+	args := m.Called(scope, subjectID, name, periodStart, limit)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockStorer) Release(scope Scope, subjectID uuid.UUID, name string, periodStart time.Time) error {
+	args := m.Called(scope, subjectID, name, periodStart)
+	return args.Error(0)
+}
+
+func (m *MockStorer) Used(scope Scope, subjectID uuid.UUID, name string, periodStart time.Time) (int, error) {
+	args := m.Called(scope, subjectID, name, periodStart)
+	return args.Int(0), args.Error(1)
+}
+
+func TestWindowOfMonthlyLimitIsCalendarMonth(t *testing.T) {
+	start, resets := Invitations.Window(time.Date(2024, time.February, 17, 13, 45, 0, 0, time.UTC))
+
+	assert.Equal(t, time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), resets)
+}
+
+func TestWindowOfTotalLimitNeverResets(t *testing.T) {
+	start, resets := APIKeys.Window(time.Now())
+
+	assert.Equal(t, time.Unix(0, 0).UTC(), start)
+	assert.True(t, resets.IsZero())
+}
+
+func TestConsumeReportsUsageWhenExceeded(t *testing.T) {
+	usages := new(MockStorer)
+	s := NewService(usages)
+	subjectID := uuid.New()
+	r := role.NewRole("Free", 1, nil)
+	r.Limits[role.LimitAPIKeys] = 3
+	start, _ := APIKeys.Window(time.Now())
+
+	usages.On("Consume", User, subjectID, role.LimitAPIKeys, start, 3).Return(0, ErrExceeded)
+	usages.On("Used", User, subjectID, role.LimitAPIKeys, start).Return(3, nil)
+
+	usage, err := s.Consume(subjectID, r, APIKeys)
+
+	assert.ErrorIs(t, err, ErrExceeded)
+	assert.Equal(t, View{Name: role.LimitAPIKeys, Scope: "user", Used: 3, Limit: 3}, usage.AsView())
+}
+
+func TestConsumeIsUnlimitedWithoutLimitOfRole(t *testing.T) {
+	usages := new(MockStorer)
+	s := NewService(usages)
+	subjectID := uuid.New()
+	start, _ := Organizations.Window(time.Now())
+
+	usages.On("Consume", User, subjectID, role.LimitOrganizations, start, role.Unlimited).Return(8, nil)
+
+	usage, err := s.Consume(subjectID, role.NewRole("Custom", 1, nil), Organizations)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 8, usage.Used)
+	assert.Equal(t, role.Unlimited, usage.Limit)
+}
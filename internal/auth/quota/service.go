@@ -0,0 +1,65 @@
+package quota
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/inokone/go-micro-saas/internal/auth/role"
+)
+
+// Service is a struct enforcing the limits of the roles on the usage of users and organizations.
+type Service struct {
+	usages Storer
+}
+
+// NewService creates a new `Service`, based on the usage persistence.
+func NewService(usages Storer) *Service {
+	return &Service{
+		usages: usages,
+	}
+}
+
+// Consume is a method of `Service`. Counts an operation of the subject against the limit of the role provided.
+// Returns `ErrExceeded` with the current usage when the limit is reached.
+func (s *Service) Consume(subjectID uuid.UUID, r *role.Role, d Definition) (Usage, error) {
+	start, resets := d.Window(time.Now())
+	usage := Usage{Definition: d, Limit: limitOf(r, d), Resets: resets}
+	used, err := s.usages.Consume(d.Scope, subjectID, d.Name, start, usage.Limit)
+	if errors.Is(err, ErrExceeded) {
+		if usage.Used, err = s.usages.Used(d.Scope, subjectID, d.Name, start); err != nil {
+			return usage, err
+		}
+		return usage, ErrExceeded
+	}
+	if err != nil {
+		return usage, err
+	}
+	usage.Used = used
+	return usage, nil
+}
+
+// Release is a method of `Service`. Gives back an operation of the subject counted earlier.
+func (s *Service) Release(subjectID uuid.UUID, d Definition) error {
+	start, _ := d.Window(time.Now())
+	return s.usages.Release(d.Scope, subjectID, d.Name, start)
+}
+
+// Usage is a method of `Service`. Returns the usage of the subject in the current counting period, with the limit of
+// the role provided.
+func (s *Service) Usage(subjectID uuid.UUID, r *role.Role, d Definition) (Usage, error) {
+	start, resets := d.Window(time.Now())
+	used, err := s.usages.Used(d.Scope, subjectID, d.Name, start)
+	if err != nil {
+		return Usage{}, err
+	}
+	return Usage{Definition: d, Used: used, Limit: limitOf(r, d), Resets: resets}, nil
+}
+
+func limitOf(r *role.Role, d Definition) int {
+	if r == nil {
+		return role.Unlimited
+	}
+	return r.Limit(d.Name)
+}
@@ -0,0 +1,69 @@
+package quota
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresStorer is the `Storer` implementation based on sqlx library.
+type PostgresStorer struct {
+	db *sqlx.DB
+}
+
+// NewPostgresStorer creates a new `PostgresStorer` instance based on the sqlx library.
+func NewPostgresStorer(db *sqlx.DB) *PostgresStorer {
+	return &PostgresStorer{
+		db: db,
+	}
+}
+
+// Consume is a method of the `PostgresStorer` struct. Increments the usage counter of the subject of the scope in the
+// counting period, if the counter is below the limit provided, and returns the new usage. The check and the increment are a
+// single statement, so concurrent requests can not exceed the limit. Returns `ErrExceeded` when the limit is reached.
+func (s *PostgresStorer) Consume(scope Scope, subjectID uuid.UUID, name string, periodStart time.Time, limit int) (int, error) {
+	if limit == 0 {
+		return 0, ErrExceeded
+	}
+	var used int
+	query := `INSERT INTO microsaas.usages (scope, subject_id, name, period_start, used) VALUES ($1, $2, $3, $4, 1)
+		ON CONFLICT (scope, subject_id, name, period_start) DO UPDATE SET used = usages.used + 1
+		WHERE $5 < 0 OR usages.used < $5 RETURNING used`
+	err := s.db.Get(&used, query, scope, subjectID, name, periodStart, limit)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrExceeded
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to consume quota: %w", err)
+	}
+	return used, nil
+}
+
+// Release is a method of the `PostgresStorer` struct. Decrements the usage counter of the subject of the scope in the
+// counting period, for a resource deleted or an operation failed.
+func (s *PostgresStorer) Release(scope Scope, subjectID uuid.UUID, name string, periodStart time.Time) error {
+	query := `UPDATE microsaas.usages SET used = used - 1 WHERE scope = $1 AND subject_id = $2 AND name = $3 AND period_start = $4 AND used > 0`
+	if _, err := s.db.Exec(query, scope, subjectID, name, periodStart); err != nil {
+		return fmt.Errorf("failed to release quota: %w", err)
+	}
+	return nil
+}
+
+// Used is a method of the `PostgresStorer` struct. Loads the usage counter of the subject of the scope in the counting
+// period.
+func (s *PostgresStorer) Used(scope Scope, subjectID uuid.UUID, name string, periodStart time.Time) (int, error) {
+	var used int
+	query := `SELECT used FROM microsaas.usages WHERE scope = $1 AND subject_id = $2 AND name = $3 AND period_start = $4`
+	err := s.db.Get(&used, query, scope, subjectID, name, periodStart)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get usage: %w", err)
+	}
+	return used, nil
+}
@@ -1,14 +1,23 @@
 package role
 
 import (
+	"errors"
 	"net/http"
+	"slices"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/inokone/go-micro-saas/internal/common"
 )
 
+var (
+	statusNotFound  = common.StatusMessage{Message: "Role not found!"}
+	statusNameTaken = common.StatusMessage{Message: "Role with this name already exists."}
+	statusUnknown   = common.StatusMessage{Message: "Unknown error, please contact administrator!"}
+)
+
 // Handler is a struct for web handles related to roles.
 type Handler struct {
 	roles Storer
@@ -79,3 +88,278 @@ func (h *Handler) Update(g *gin.Context) {
 	}
 	g.JSON(http.StatusOK, common.StatusMessage{Message: "Role patched!"})
 }
+
+// SetPermissions replaces the permissions granted to a user role. The permissions of the administrator role can not be
+// changed, so that there is always a role managing the others.
+// @Summary Role permissions endpoint
+// @Schemes
+// @Description Replaces the permissions granted to a role
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the role"
+// @Param data body role.PermissionsUpdate true "The permissions to grant the role"
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /roles/:id/permissions [put]
+func (h *Handler) SetPermissions(g *gin.Context) {
+	var in PermissionsUpdate
+	r, ok := h.roleOf(g)
+	if !ok {
+		return
+	}
+	if r.ID == RoleAdmin {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "The permissions of the administrator role can not be changed!"})
+		return
+	}
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Malformed permission data"})
+		return
+	}
+	if !validPermissions(g, in.Permissions) {
+		return
+	}
+	if err := h.roles.SetPermissions(r.ID, in.Permissions); err != nil {
+		log.WithError(err).Error("Failed to set role permissions")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknown)
+		return
+	}
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Role permissions updated!"})
+}
+
+// SetLimits replaces the limits of a user role. Limits not provided are unlimited for the role.
+// @Summary Role limits endpoint
+// @Schemes
+// @Description Replaces the named limits of a role, -1 meaning unlimited
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the role"
+// @Param data body role.LimitsUpdate true "The limits of the role"
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /roles/:id/limits [put]
+func (h *Handler) SetLimits(g *gin.Context) {
+	var in LimitsUpdate
+	r, ok := h.roleOf(g)
+	if !ok {
+		return
+	}
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Malformed limit data"})
+		return
+	}
+	if !validLimits(g, in.Limits) {
+		return
+	}
+	if err := h.roles.SetLimits(r.ID, in.Limits); err != nil {
+		log.WithError(err).Error("Failed to set role limits")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknown)
+		return
+	}
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Role limits updated!"})
+}
+
+// Create creates a new user role.
+// @Summary Role create endpoint
+// @Schemes
+// @Description Creates a new role with the settings and permissions provided
+// @Accept json
+// @Produce json
+// @Param data body role.CreateRequest true "The settings of the new role"
+// @Success 201 {object} role.ProfileRole
+// @Failure 400 {object} common.StatusMessage
+// @Router /roles/ [post]
+func (h *Handler) Create(g *gin.Context) {
+	var in CreateRequest
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+	if !validPermissions(g, in.Permissions) || !validLimits(g, in.Limits) {
+		return
+	}
+	r := NewRole(in.Name, in.Quota, in.Permissions)
+	for name, value := range in.Limits {
+		r.Limits[name] = value
+	}
+	h.store(g, r)
+}
+
+// Clone creates a new user role with the settings and permissions of an existing one.
+// @Summary Role clone endpoint
+// @Schemes
+// @Description Creates a new role with the settings and permissions of the role
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the role to clone"
+// @Param data body role.NameRequest true "The name of the new role"
+// @Success 201 {object} role.ProfileRole
+// @Failure 400 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Router /roles/:id/clone [post]
+func (h *Handler) Clone(g *gin.Context) {
+	var in NameRequest
+	r, ok := h.roleOf(g)
+	if !ok {
+		return
+	}
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+	h.store(g, r.Clone(in.Name))
+}
+
+// Rename changes the display name of a user role.
+// @Summary Role rename endpoint
+// @Schemes
+// @Description Changes the display name of a role
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the role"
+// @Param data body role.NameRequest true "The new name of the role"
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Router /roles/:id/name [put]
+func (h *Handler) Rename(g *gin.Context) {
+	var in NameRequest
+	r, ok := h.roleOf(g)
+	if !ok {
+		return
+	}
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+	if err := h.roles.Rename(r.ID, in.Name); err != nil {
+		log.WithError(err).Debug("Failed to rename role")
+		g.AbortWithStatusJSON(http.StatusBadRequest, statusNameTaken)
+		return
+	}
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Role renamed!"})
+}
+
+// SetDefault makes a user role the one assigned to the users signing up.
+// @Summary Role default endpoint
+// @Schemes
+// @Description Makes the role the one assigned to new users at signup
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the role"
+// @Success 200 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /roles/:id/default [put]
+func (h *Handler) SetDefault(g *gin.Context) {
+	r, ok := h.roleOf(g)
+	if !ok {
+		return
+	}
+	if err := h.roles.SetDefault(r.ID); err != nil {
+		log.WithError(err).Error("Failed to set default role")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknown)
+		return
+	}
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Default role set!"})
+}
+
+// Delete deletes a user role, moving its users to the target role provided as query parameter and signing them out.
+// Roles granted by subscription plans can not be deleted.
+// @Summary Role delete endpoint
+// @Schemes
+// @Description Deletes a role, the users of the role get the target role
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the role to delete"
+// @Param target query string true "ID of the role the users of the deleted role get"
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /roles/:id [delete]
+func (h *Handler) Delete(g *gin.Context) {
+	r, ok := h.roleOf(g)
+	if !ok {
+		return
+	}
+	if r.ID == RoleAdmin {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "The administrator role can not be deleted!"})
+		return
+	}
+	if r.DefaultSignup {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "The default role of signup can not be deleted! Please choose another default role first."})
+		return
+	}
+	targetID, err := uuid.Parse(g.Query("target"))
+	if err != nil || targetID == r.ID {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Please choose another role for the users of the role!"})
+		return
+	}
+	if _, err = h.roles.ByID(targetID); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Target role not found!"})
+		return
+	}
+	err = h.roles.Delete(r.ID, targetID)
+	if errors.Is(err, ErrInPlans) {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "The role is granted by subscription plans! Please change the plans first."})
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to delete role")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknown)
+		return
+	}
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Role deleted!"})
+}
+
+func (h *Handler) store(g *gin.Context, r *Role) {
+	if err := h.roles.Store(r); err != nil {
+		log.WithError(err).Debug("Failed to store role")
+		g.AbortWithStatusJSON(http.StatusBadRequest, statusNameTaken)
+		return
+	}
+	g.JSON(http.StatusCreated, r.AsProfileRole())
+}
+
+// roleOf loads the role with the ID provided as URL parameter, aborting with not found if there is none.
+func (h *Handler) roleOf(g *gin.Context) (*Role, bool) {
+	id, err := uuid.Parse(g.Param("id"))
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, statusNotFound)
+		return nil, false
+	}
+	r, err := h.roles.ByID(id)
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, statusNotFound)
+		return nil, false
+	}
+	return r, true
+}
+
+func validPermissions(g *gin.Context, permissions []string) bool {
+	for _, p := range permissions {
+		if !slices.Contains(Permissions, p) {
+			g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Unknown permission: " + p})
+			return false
+		}
+	}
+	return true
+}
+
+func validLimits(g *gin.Context, limits Limits) bool {
+	for name, value := range limits {
+		if !slices.Contains(LimitNames, name) {
+			g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Unknown limit: " + name})
+			return false
+		}
+		if value < Unlimited {
+			g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Invalid value of limit: " + name})
+			return false
+		}
+	}
+	return true
+}
@@ -114,3 +114,242 @@ func TestUpdate400ForInvalidInput(t *testing.T) {
 
 	mockStorer.AssertNotCalled(t, "Update")
 }
+
+func TestSetPermissions200ForKnownPermissions(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	roleID := uuid.New()
+	permissions := []string{PermUsersRead, PermHistoryReadAny}
+	mockStorer.On("ByID", roleID).Return(&Role{ID: roleID}, nil)
+	mockStorer.On("SetPermissions", roleID, permissions).Return(nil)
+
+	router.PUT("/roles/:id/permissions", handler.SetPermissions)
+
+	body, _ := json.Marshal(PermissionsUpdate{Permissions: permissions})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/roles/"+roleID.String()+"/permissions", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStorer.AssertExpectations(t)
+}
+
+func TestSetPermissions400ForUnknownPermission(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	roleID := uuid.New()
+	mockStorer.On("ByID", roleID).Return(&Role{ID: roleID}, nil)
+
+	router.PUT("/roles/:id/permissions", handler.SetPermissions)
+
+	body, _ := json.Marshal(PermissionsUpdate{Permissions: []string{PermUsersRead, "users:delete"}})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/roles/"+roleID.String()+"/permissions", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockStorer.AssertNotCalled(t, "SetPermissions", mock.Anything, mock.Anything)
+}
+
+func TestSetPermissions400ForAdministratorRole(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	mockStorer.On("ByID", RoleAdmin).Return(&Role{ID: RoleAdmin, Permissions: Permissions}, nil)
+
+	router.PUT("/roles/:id/permissions", handler.SetPermissions)
+
+	body, _ := json.Marshal(PermissionsUpdate{Permissions: []string{PermUsersRead}})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/roles/"+RoleAdmin.String()+"/permissions", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockStorer.AssertNotCalled(t, "SetPermissions", mock.Anything, mock.Anything)
+}
+
+func TestCreate201ForHappyPath(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	mockStorer.On("Store", mock.MatchedBy(func(r *Role) bool {
+		return r.DisplayName == "Support" && r.AppointmentQuota == 5 && !r.DefaultSignup
+	})).Return(nil)
+
+	router.POST("/roles", handler.Create)
+
+	body, _ := json.Marshal(CreateRequest{Name: "Support", Quota: 5, Permissions: []string{PermUsersRead}})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/roles", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response ProfileRole
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Support", response.DisplayName)
+	assert.Equal(t, []string{PermUsersRead}, response.Permissions)
+	mockStorer.AssertExpectations(t)
+}
+
+func TestClone201CopiesPermissions(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	original := &Role{ID: uuid.New(), AppointmentQuota: 5, DisplayName: "Support", Permissions: []string{PermUsersRead}}
+	mockStorer.On("ByID", original.ID).Return(original, nil)
+	mockStorer.On("Store", mock.MatchedBy(func(r *Role) bool {
+		return r.ID != original.ID && r.DisplayName == "Support copy" && r.Can(PermUsersRead)
+	})).Return(nil)
+
+	router.POST("/roles/:id/clone", handler.Clone)
+
+	body, _ := json.Marshal(NameRequest{Name: "Support copy"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/roles/"+original.ID.String()+"/clone", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockStorer.AssertExpectations(t)
+}
+
+func TestRename400ForTakenName(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	roleID := uuid.New()
+	mockStorer.On("ByID", roleID).Return(&Role{ID: roleID}, nil)
+	mockStorer.On("Rename", roleID, "Admin").Return(assert.AnError)
+
+	router.PUT("/roles/:id/name", handler.Rename)
+
+	body, _ := json.Marshal(NameRequest{Name: "Admin"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/roles/"+roleID.String()+"/name", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDeleteMovesUsersToTargetRole(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	roleID, targetID := uuid.New(), uuid.New()
+	mockStorer.On("ByID", roleID).Return(&Role{ID: roleID}, nil)
+	mockStorer.On("ByID", targetID).Return(&Role{ID: targetID}, nil)
+	mockStorer.On("Delete", roleID, targetID).Return(nil)
+
+	router.DELETE("/roles/:id", handler.Delete)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/roles/"+roleID.String()+"?target="+targetID.String(), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStorer.AssertExpectations(t)
+}
+
+func TestDelete400WithoutTargetOrForProtectedRoles(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	roleID, defaultID := uuid.New(), uuid.New()
+	mockStorer.On("ByID", roleID).Return(&Role{ID: roleID}, nil)
+	mockStorer.On("ByID", defaultID).Return(&Role{ID: defaultID, DefaultSignup: true}, nil)
+	mockStorer.On("ByID", RoleAdmin).Return(&Role{ID: RoleAdmin}, nil)
+
+	router.DELETE("/roles/:id", handler.Delete)
+
+	for _, url := range []string{
+		"/roles/" + roleID.String(),
+		"/roles/" + roleID.String() + "?target=" + roleID.String(),
+		"/roles/" + defaultID.String() + "?target=" + roleID.String(),
+		"/roles/" + RoleAdmin.String() + "?target=" + roleID.String(),
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", url, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, url)
+	}
+	mockStorer.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestDelete400ForRoleOfPlans(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	roleID, targetID := uuid.New(), uuid.New()
+	mockStorer.On("ByID", roleID).Return(&Role{ID: roleID}, nil)
+	mockStorer.On("ByID", targetID).Return(&Role{ID: targetID}, nil)
+	mockStorer.On("Delete", roleID, targetID).Return(ErrInPlans)
+
+	router.DELETE("/roles/:id", handler.Delete)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/roles/"+roleID.String()+"?target="+targetID.String(), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockStorer.AssertExpectations(t)
+}
+
+func TestSetLimits200ForKnownLimits(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	roleID := uuid.New()
+	limits := Limits{LimitOrganizations: 5, LimitAPIKeys: Unlimited}
+	mockStorer.On("ByID", roleID).Return(&Role{ID: roleID}, nil)
+	mockStorer.On("SetLimits", roleID, limits).Return(nil)
+
+	router.PUT("/roles/:id/limits", handler.SetLimits)
+
+	body, _ := json.Marshal(LimitsUpdate{Limits: limits})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/roles/"+roleID.String()+"/limits", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStorer.AssertExpectations(t)
+}
+
+func TestSetLimits400ForUnknownOrNegativeLimit(t *testing.T) {
+	for _, limits := range []Limits{{"storage": 10}, {"appointments": 100}, {LimitAPIKeys: -2}} {
+		mockStorer := new(MockStorer)
+		handler := NewHandler(mockStorer)
+		router := setupTestRouter(handler)
+
+		roleID := uuid.New()
+		mockStorer.On("ByID", roleID).Return(&Role{ID: roleID}, nil)
+
+		router.PUT("/roles/:id/limits", handler.SetLimits)
+
+		body, _ := json.Marshal(LimitsUpdate{Limits: limits})
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/roles/"+roleID.String()+"/limits", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockStorer.AssertNotCalled(t, "SetLimits", mock.Anything, mock.Anything)
+	}
+}
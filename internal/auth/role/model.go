@@ -1,7 +1,14 @@
 package role
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 var (
@@ -13,36 +20,178 @@ var (
 	RoleCustomerUser = uuid.MustParse("0d83a7d4-24e3-4dd4-9b0a-d65379225abc")
 )
 
+// ErrInPlans is the error of deleting a role which subscription plans still grant.
+var ErrInPlans = errors.New("role is granted by subscription plans")
+
+const (
+	// PermUsersRead is the permission to list the users and their sessions.
+	PermUsersRead = "users:read"
+	// PermUsersWrite is the permission to change the settings of any user and sign them out.
+	PermUsersWrite = "users:write"
+	// PermUsersImpersonate is the permission to sign in on behalf of a user.
+	PermUsersImpersonate = "users:impersonate"
+	// PermRolesManage is the permission to change the roles and their permissions.
+	PermRolesManage = "roles:manage"
+	// PermHistoryReadAny is the permission to read the history of any user.
+	PermHistoryReadAny = "history:read:any"
+	// PermBillingManage is the permission to manage the subscription plans.
+	PermBillingManage = "billing:manage"
+)
+
+// Permissions are all permissions roles can be granted.
+var Permissions = []string{PermUsersRead, PermUsersWrite, PermUsersImpersonate, PermRolesManage, PermHistoryReadAny, PermBillingManage}
+
+const (
+	// LimitAPIKeys is the limit of the API keys of a user.
+	LimitAPIKeys = "api_keys"
+	// LimitOrganizations is the limit of the organizations a user can create.
+	LimitOrganizations = "organizations"
+	// LimitInvitations is the limit of the invitations an organization can send in a month.
+	LimitInvitations = "invitations"
+)
+
+// Unlimited is the value of a limit not restricting the usage, limits not set for a role are unlimited as well.
+const Unlimited = -1
+
+// LimitNames are all limits roles can define.
+var LimitNames = []string{LimitAPIKeys, LimitOrganizations, LimitInvitations}
+
+// Limits are the named limits of a role, loaded from the JSON object aggregated by the database.
+type Limits map[string]int
+
+// Scan is a method of `Limits` implementing the `sql.Scanner` interface.
+func (l *Limits) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*l = Limits{}
+		return nil
+	default:
+		return fmt.Errorf("unsupported type of limits: %T", src)
+	}
+	res := Limits{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return fmt.Errorf("failed to parse limits: %w", err)
+	}
+	*l = res
+	return nil
+}
+
 // Role is a struct representing the user role representation for database storage.
 type Role struct {
-	ID               uuid.UUID `db:"role_id"`
-	AppointmentQuota int       `db:"appointment_quota"`
-	DisplayName      string    `db:"display_name"`
+	ID               uuid.UUID      `db:"role_id"`
+	AppointmentQuota int            `db:"appointment_quota"`
+	DisplayName      string         `db:"display_name"`
+	Permissions      pq.StringArray `db:"permissions"`
+	Limits           Limits         `db:"limits"`
+	DefaultSignup    bool           `db:"default_signup"`
+}
+
+// NewRole creates a new `Role` with the display name, quota and permissions provided.
+func NewRole(name string, quota int, permissions []string) *Role {
+	return &Role{
+		ID:               uuid.New(),
+		AppointmentQuota: quota,
+		DisplayName:      name,
+		Permissions:      permissions,
+		Limits:           Limits{},
+	}
 }
 
-func (r Role) IsAdmin() bool {
-	return r.ID == RoleAdmin
+// Clone is a method of `Role` creating a new role with the settings and permissions of the role, and the display name
+// provided. The clone is never the default role of signup.
+func (r Role) Clone(name string) *Role {
+	clone := NewRole(name, r.AppointmentQuota, slices.Clone(r.Permissions))
+	clone.Limits = maps.Clone(r.Limits)
+	return clone
+}
+
+// Can is a method of `Role` returning whether the role is granted the permission provided.
+func (r Role) Can(permission string) bool {
+	return slices.Contains(r.Permissions, permission)
+}
+
+// Limit is a method of `Role` returning the value of the limit with the name provided, `Unlimited` when the role does
+// not set the limit.
+func (r Role) Limit(name string) int {
+	if v, ok := r.Limits[name]; ok {
+		return v
+	}
+	return Unlimited
+}
+
+// AllLimits is a method of `Role` returning the value of every limit of the role.
+func (r Role) AllLimits() map[string]int {
+	res := make(map[string]int, len(LimitNames))
+	for _, name := range LimitNames {
+		res[name] = r.Limit(name)
+	}
+	return res
 }
 
 // ProfileRole is a struct, the JSON representation of the `Role` entity for profile and admin views.
 type ProfileRole struct {
-	ID               string `json:"id"`
-	AppointmentQuota int    `json:"quota"`
-	DisplayName      string `json:"name" binding:"required,max=100"`
+	ID               string         `json:"id"`
+	AppointmentQuota int            `json:"quota"`
+	DisplayName      string         `json:"name" binding:"required,max=100"`
+	Permissions      []string       `json:"permissions"`
+	Limits           map[string]int `json:"limits"`
+	DefaultSignup    bool           `json:"default_signup"`
 }
 
 // AsProfileRole is a method of the `Role` struct. It converts a `Role` object into a `ProfileRole` object.
 func (u *Role) AsProfileRole() ProfileRole {
+	permissions := []string{}
+	if u.Permissions != nil {
+		permissions = u.Permissions
+	}
 	return ProfileRole{
 		ID:               u.ID.String(),
 		AppointmentQuota: u.AppointmentQuota,
 		DisplayName:      u.DisplayName,
+		Permissions:      permissions,
+		Limits:           u.AllLimits(),
+		DefaultSignup:    u.DefaultSignup,
 	}
 }
 
+// CreateRequest is a struct for the message body of creating a role.
+type CreateRequest struct {
+	Name        string   `json:"name" binding:"required,max=100"`
+	Quota       int      `json:"quota"`
+	Permissions []string `json:"permissions"`
+	Limits      Limits   `json:"limits"`
+}
+
+// NameRequest is a struct for the message body of renaming or cloning a role.
+type NameRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// PermissionsUpdate is a struct for the message body of granting permissions to a role.
+type PermissionsUpdate struct {
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// LimitsUpdate is a struct for the message body of setting the limits of a role.
+type LimitsUpdate struct {
+	Limits Limits `json:"limits" binding:"required"`
+}
+
 // Storer is the interface for `Role` persistence
 type Storer interface {
+	Store(role *Role) error
 	Update(role ProfileRole) error
+	Rename(id uuid.UUID, name string) error
+	SetPermissions(id uuid.UUID, permissions []string) error
+	SetLimits(id uuid.UUID, limits Limits) error
+	SetDefault(id uuid.UUID) error
+	Delete(id uuid.UUID, target uuid.UUID) error
 	ByID(id uuid.UUID) (*Role, error)
+	Default() (*Role, error)
 	List() ([]Role, error)
 }
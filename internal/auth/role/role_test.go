@@ -13,11 +13,49 @@ type MockStorer struct {
 	mock.Mock
 }
 
+func (m *MockStorer) Store(role *Role) error {
+	args := m.Called(role)
+	return args.Error(0)
+}
+
+func (m *MockStorer) Rename(id uuid.UUID, name string) error {
+	args := m.Called(id, name)
+	return args.Error(0)
+}
+
+func (m *MockStorer) SetDefault(id uuid.UUID) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockStorer) Delete(id uuid.UUID, target uuid.UUID) error {
+	args := m.Called(id, target)
+	return args.Error(0)
+}
+
+func (m *MockStorer) Default() (*Role, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Role), args.Error(1)
+}
+
 func (m *MockStorer) Update(role ProfileRole) error {
 	args := m.Called(role)
 	return args.Error(0)
 }
 
+func (m *MockStorer) SetPermissions(id uuid.UUID, permissions []string) error {
+	args := m.Called(id, permissions)
+	return args.Error(0)
+}
+
+func (m *MockStorer) SetLimits(id uuid.UUID, limits Limits) error {
+	args := m.Called(id, limits)
+	return args.Error(0)
+}
+
 func (m *MockStorer) ByID(id uuid.UUID) (*Role, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
@@ -31,38 +69,36 @@ func (m *MockStorer) List() ([]Role, error) {
 	return args.Get(0).([]Role), args.Error(1)
 }
 
-func TestIsAdminReturnsTrueOnlyForAdminRole(t *testing.T) {
+func TestCanReturnsTrueOnlyForGrantedPermissions(t *testing.T) {
 	tests := []struct {
-		name     string
-		role     Role
-		expected bool
+		name       string
+		role       Role
+		permission string
+		expected   bool
 	}{
 		{
-			name: "Admin role",
-			role: Role{
-				ID: RoleAdmin,
-			},
-			expected: true,
+			name:       "Granted permission",
+			role:       Role{ID: RoleAdmin, Permissions: Permissions},
+			permission: PermUsersWrite,
+			expected:   true,
 		},
 		{
-			name: "Customer admin role",
-			role: Role{
-				ID: RoleCustomerAdmin,
-			},
-			expected: false,
+			name:       "Missing permission",
+			role:       Role{ID: RoleCustomerAdmin, Permissions: []string{PermUsersRead}},
+			permission: PermUsersWrite,
+			expected:   false,
 		},
 		{
-			name: "Customer user role",
-			role: Role{
-				ID: RoleCustomerUser,
-			},
-			expected: false,
+			name:       "No permissions",
+			role:       Role{ID: RoleCustomerUser},
+			permission: PermUsersRead,
+			expected:   false,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			assert.Equal(t, tt.expected, tt.role.IsAdmin())
+			assert.Equal(t, tt.expected, tt.role.Can(tt.permission))
 		})
 	}
 }
@@ -80,6 +116,7 @@ func TestAsProfileRoleRetainsFields(t *testing.T) {
 	assert.Equal(t, roleID.String(), profileRole.ID)
 	assert.Equal(t, testRole.AppointmentQuota, profileRole.AppointmentQuota)
 	assert.Equal(t, testRole.DisplayName, profileRole.DisplayName)
+	assert.Equal(t, []string{}, profileRole.Permissions)
 }
 
 func TestPredefinedRolesUnchanged(t *testing.T) {
@@ -88,3 +125,38 @@ func TestPredefinedRolesUnchanged(t *testing.T) {
 	assert.Equal(t, "3dae67da-21bd-4c1f-ac35-b3e79c4a4225", RoleCustomerAdmin.String())
 	assert.Equal(t, "0d83a7d4-24e3-4dd4-9b0a-d65379225abc", RoleCustomerUser.String())
 }
+
+func TestCloneCopiesSettingsButNotDefault(t *testing.T) {
+	original := Role{ID: uuid.New(), AppointmentQuota: 5, DisplayName: "Support", Permissions: []string{PermUsersRead}, DefaultSignup: true}
+
+	clone := original.Clone("Support 2")
+
+	assert.NotEqual(t, original.ID, clone.ID)
+	assert.Equal(t, "Support 2", clone.DisplayName)
+	assert.Equal(t, original.AppointmentQuota, clone.AppointmentQuota)
+	assert.Equal(t, original.Permissions, clone.Permissions)
+	assert.False(t, clone.DefaultSignup)
+
+	clone.Permissions[0] = PermUsersWrite
+	assert.Equal(t, PermUsersRead, original.Permissions[0])
+}
+
+func TestLimitDefaultsToUnlimited(t *testing.T) {
+	r := Role{AppointmentQuota: 5, Limits: Limits{LimitAPIKeys: 3}}
+
+	assert.Equal(t, 3, r.Limit(LimitAPIKeys))
+	assert.Equal(t, Unlimited, r.Limit(LimitOrganizations))
+	assert.Equal(t, map[string]int{LimitAPIKeys: 3, LimitOrganizations: -1, LimitInvitations: -1}, r.AllLimits())
+}
+
+func TestLimitsScanJSONObject(t *testing.T) {
+	var l Limits
+
+	assert.NoError(t, l.Scan([]byte(`{"api_keys": 3, "invitations": -1}`)))
+	assert.Equal(t, Limits{LimitAPIKeys: 3, LimitInvitations: -1}, l)
+
+	assert.NoError(t, l.Scan(nil))
+	assert.Equal(t, Limits{}, l)
+
+	assert.Error(t, l.Scan(42))
+}
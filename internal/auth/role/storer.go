@@ -1,10 +1,13 @@
 package role
 
 import (
+	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // PostgresStorer is the `Storer` implementation based on pq library.
@@ -19,9 +22,17 @@ func NewPostgresStorer(db *sqlx.DB) *PostgresStorer {
 	}
 }
 
+// selectRoles is the query of roles, the permissions of each role collected into an array and the limits into a JSON
+// object.
+const selectRoles = `SELECT role_id, appointment_quota, display_name, default_signup,
+	ARRAY(SELECT p.permission FROM microsaas.role_permissions p WHERE p.role_id = r.role_id ORDER BY p.permission) AS permissions,
+	(SELECT COALESCE(jsonb_object_agg(l.name, l.value), '{}') FROM microsaas.role_limits l WHERE l.role_id = r.role_id) AS limits
+	FROM microsaas.roles r`
+
+// ByID is a method of the `PostgresStorer` struct. Takes an UUID as parameter to load a `Role` with its permissions.
 func (s *PostgresStorer) ByID(id uuid.UUID) (*Role, error) {
 	var role Role
-	query := `SELECT role_id, appointment_quota, display_name FROM microsaas.roles WHERE role_id = $1`
+	query := selectRoles + ` WHERE role_id = $1`
 	err := s.db.Get(&role, query, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get role by ID: %w", err)
@@ -29,6 +40,114 @@ func (s *PostgresStorer) ByID(id uuid.UUID) (*Role, error) {
 	return &role, nil
 }
 
+// Default is a method of the `PostgresStorer` struct. Loads the `Role` assigned to the users signing up.
+func (s *PostgresStorer) Default() (*Role, error) {
+	var role Role
+	if err := s.db.Get(&role, selectRoles+` WHERE default_signup`); err != nil {
+		return nil, fmt.Errorf("failed to get default role: %w", err)
+	}
+	return &role, nil
+}
+
+// Store is a method of the `PostgresStorer` struct. Takes a `Role` as parameter and persists it with its permissions.
+func (s *PostgresStorer) Store(role *Role) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to store role: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO microsaas.roles (role_id, display_name, appointment_quota) VALUES ($1, $2, $3)`
+	if _, err = tx.Exec(query, role.ID, role.DisplayName, role.AppointmentQuota); err != nil {
+		return fmt.Errorf("failed to store role: %w", err)
+	}
+	if err = grant(tx, role.ID, role.Permissions); err != nil {
+		return fmt.Errorf("failed to store role: %w", err)
+	}
+	if err = limit(tx, role.ID, role.Limits); err != nil {
+		return fmt.Errorf("failed to store role: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store role: %w", err)
+	}
+	return nil
+}
+
+// Rename is a method of the `PostgresStorer` struct. Takes a role ID and a new display name for the role.
+func (s *PostgresStorer) Rename(id uuid.UUID, name string) error {
+	res, err := s.db.Exec(`UPDATE microsaas.roles SET display_name = $1 WHERE role_id = $2`, name, id)
+	if err != nil {
+		return fmt.Errorf("failed to rename role: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("failed to rename role: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// SetDefault is a method of the `PostgresStorer` struct. Takes a role ID and makes the role the one assigned to the
+// users signing up, instead of the previous one.
+func (s *PostgresStorer) SetDefault(id uuid.UUID) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to set default role: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`UPDATE microsaas.roles SET default_signup = FALSE WHERE default_signup`); err != nil {
+		return fmt.Errorf("failed to set default role: %w", err)
+	}
+	res, err := tx.Exec(`UPDATE microsaas.roles SET default_signup = TRUE WHERE role_id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to set default role: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("failed to set default role: %w", sql.ErrNoRows)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to set default role: %w", err)
+	}
+	return nil
+}
+
+// Delete is a method of the `PostgresStorer` struct. Takes the ID of the role to delete and the ID of the role its
+// users are moved to. The sessions of the moved users are revoked. Returns `ErrInPlans` without deleting, if
+// subscription plans still grant the role.
+func (s *PostgresStorer) Delete(id uuid.UUID, target uuid.UUID) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	defer tx.Rollback()
+
+	var planned bool
+	if err = tx.Get(&planned, `SELECT EXISTS (SELECT 1 FROM microsaas.plans WHERE role_id = $1)`, id); err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	if planned {
+		return ErrInPlans
+	}
+	if err = revokeSessions(tx, id); err != nil {
+		return fmt.Errorf("failed to revoke sessions of role: %w", err)
+	}
+	if _, err = tx.Exec(`UPDATE microsaas.users SET role_id = $1 WHERE role_id = $2`, target, id); err != nil {
+		return fmt.Errorf("failed to move users of role: %w", err)
+	}
+	if _, err = tx.Exec(`DELETE FROM microsaas.role_permissions WHERE role_id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	if _, err = tx.Exec(`DELETE FROM microsaas.role_limits WHERE role_id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	if _, err = tx.Exec(`DELETE FROM microsaas.roles WHERE role_id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	return nil
+}
+
 // Update is a method of the `PostgresStorer` struct. Takes a `Role` and updates settings (quota and display name) for it.
 func (s *PostgresStorer) Update(role ProfileRole) error {
 	query := `UPDATE microsaas.roles SET appointment_quota = $1, display_name = $2 WHERE role_id = $3`
@@ -39,13 +158,88 @@ func (s *PostgresStorer) Update(role ProfileRole) error {
 	return nil
 }
 
+// SetPermissions is a method of the `PostgresStorer` struct. Takes a role ID and the permissions to grant, replacing
+// all permissions of the role. When a permission is taken away, the sessions of the users of the role are revoked.
+func (s *PostgresStorer) SetPermissions(id uuid.UUID, permissions []string) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to set role permissions: %w", err)
+	}
+	defer tx.Rollback()
+
+	var shrinks bool
+	query := `SELECT EXISTS (SELECT 1 FROM microsaas.role_permissions WHERE role_id = $1 AND NOT (permission = ANY($2::VARCHAR[])))`
+	if err = tx.Get(&shrinks, query, id, pq.StringArray(permissions)); err != nil {
+		return fmt.Errorf("failed to set role permissions: %w", err)
+	}
+	if shrinks {
+		if err = revokeSessions(tx, id); err != nil {
+			return fmt.Errorf("failed to revoke sessions of role: %w", err)
+		}
+	}
+	if _, err = tx.Exec(`DELETE FROM microsaas.role_permissions WHERE role_id = $1`, id); err != nil {
+		return fmt.Errorf("failed to set role permissions: %w", err)
+	}
+	if err = grant(tx, id, permissions); err != nil {
+		return fmt.Errorf("failed to set role permissions: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to set role permissions: %w", err)
+	}
+	return nil
+}
+
+// SetLimits is a method of the `PostgresStorer` struct. Takes a role ID and the limits of the role, replacing all
+// limits of the role.
+func (s *PostgresStorer) SetLimits(id uuid.UUID, limits Limits) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to set role limits: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM microsaas.role_limits WHERE role_id = $1`, id); err != nil {
+		return fmt.Errorf("failed to set role limits: %w", err)
+	}
+	if err = limit(tx, id, limits); err != nil {
+		return fmt.Errorf("failed to set role limits: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to set role limits: %w", err)
+	}
+	return nil
+}
+
 // List is a method of the `PostgresStorer` struct. Loads all `Role` objects from persistence.
 func (s *PostgresStorer) List() ([]Role, error) {
 	var roles []Role
-	query := `SELECT role_id, appointment_quota, display_name FROM microsaas.roles`
-	err := s.db.Select(&roles, query)
+	err := s.db.Select(&roles, selectRoles)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all roles: %w", err)
 	}
 	return roles, nil
 }
+
+// revokeSessions revokes the active sessions of the users of the role, so they sign in again with the new settings.
+func revokeSessions(tx *sqlx.Tx, id uuid.UUID) error {
+	query := `UPDATE microsaas.sessions SET revoked_at = $1 WHERE revoked_at is null
+		AND user_id IN (SELECT user_id FROM microsaas.users WHERE role_id = $2)`
+	_, err := tx.Exec(query, time.Now(), id)
+	return err
+}
+
+func grant(tx *sqlx.Tx, id uuid.UUID, permissions []string) error {
+	query := `INSERT INTO microsaas.role_permissions (role_id, permission) SELECT $1, unnest($2::VARCHAR[])`
+	_, err := tx.Exec(query, id, pq.StringArray(permissions))
+	return err
+}
+
+func limit(tx *sqlx.Tx, id uuid.UUID, limits Limits) error {
+	query := `INSERT INTO microsaas.role_limits (role_id, name, value) VALUES ($1, $2, $3)`
+	for name, value := range limits {
+		if _, err := tx.Exec(query, id, name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,29 +1,54 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/guregu/null"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/inokone/go-micro-saas/internal/auth/account"
+	"github.com/inokone/go-micro-saas/internal/auth/magiclink"
+	"github.com/inokone/go-micro-saas/internal/auth/passkey"
+	"github.com/inokone/go-micro-saas/internal/auth/twofactor"
 	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+	"github.com/inokone/go-micro-saas/internal/mail"
+	"github.com/inokone/go-micro-saas/internal/ratelimit"
 )
 
+const unlockTTL = 24 * time.Hour
+
 // Service is a worker for authentication and authorization.
 type Service struct {
 	users    user.Storer
 	accounts account.Storer
+	factors  *twofactor.Service
+	passkeys *passkey.Service
 	jwt      *JWTHandler
+	limiter  *ratelimit.Limiter
+	backoff  account.Backoff
+	sender   *mail.Service
+	config   *common.AuthConfig
 }
 
-// NewService creates a new `Service`, based on the user and account persistence.
-func NewService(users user.Storer, auths account.Storer, jwt *JWTHandler) *Service {
+// NewService creates a new `Service`, based on the user, account and two-factor persistence and the passkey service.
+// Failed sign in attempts are counted by IP address and subnet with the limiter, and lock the account by the backoff
+// schedule, notifying the owner by the mail service.
+func NewService(users user.Storer, auths account.Storer, factors twofactor.Storer, passkeys *passkey.Service, jwt *JWTHandler, limiter *ratelimit.Limiter, backoff account.Backoff, sender *mail.Service, config *common.AuthConfig) *Service {
 	return &Service{
 		users:    users,
 		accounts: auths,
+		factors:  twofactor.NewService(factors),
+		passkeys: passkeys,
 		jwt:      jwt,
+		limiter:  limiter,
+		backoff:  backoff,
+		sender:   sender,
+		config:   config,
 	}
 }
 
@@ -42,8 +67,8 @@ type LockedUser struct {
 func (e LockedUser) Error() string { return fmt.Sprintf("%v", e.seconds) }
 
 // ValidateCredentials validates the user credentials sets and clears retry timeout for failed creds
-func (s *Service) ValidateCredentials(usr *user.User, password string) error {
-	secs, err := s.checkTimeout(usr)
+func (s *Service) ValidateCredentials(usr *user.User, password string, ip string) error {
+	secs, err := s.checkTimeout(usr, ip)
 	if err != nil {
 		log.WithError(err).WithField("UserID", usr.ID.String()).Error("Failed to collect login timeout.")
 		return InvalidCredentials("")
@@ -56,38 +81,196 @@ func (s *Service) ValidateCredentials(usr *user.User, password string) error {
 
 	verified := usr.VerifyPassword(password)
 	if !verified {
-		err = s.increaseTimeout(usr)
+		err = s.increaseTimeout(usr, ip)
 		if err != nil {
 			log.WithField("user", usr.ID.String()).Error("Failed to increase timeout for user")
 		}
 		return InvalidCredentials("")
 	}
+	if usr.PasswordOutdated() {
+		s.rehash(usr, password)
+	}
+	return s.clearTimeout(usr)
+}
+
+// rehash replaces the outdated password hash of the user with a hash of the configured algorithm and parameters. The
+// sign in does not fail on errors, the old hash is still valid.
+func (s *Service) rehash(usr *user.User, password string) {
+	if err := usr.SetPassword(password); err != nil {
+		log.WithError(err).WithField("UserID", usr.ID.String()).Error("Failed to rehash password.")
+		return
+	}
+	if err := s.users.Update(usr); err != nil {
+		log.WithError(err).WithField("UserID", usr.ID.String()).Error("Failed to store rehashed password.")
+	}
+}
+
+// SecondFactors returns the methods the user can finish signing in with, empty if no second factor is required.
+// Two-factor authentication is turned on with TOTP, passkeys of the user are accepted as an alternative to the code.
+func (s *Service) SecondFactors(usr *user.User) ([]string, error) {
+	required, err := s.factors.Required(usr.ID)
+	if err != nil || !required {
+		return nil, err
+	}
+	methods := []string{twofactor.MethodTOTP}
+	registered, err := s.passkeys.Registered(usr.ID)
+	if err != nil {
+		return nil, err
+	}
+	if registered {
+		methods = append(methods, twofactor.MethodPasskey)
+	}
+	return methods, nil
+}
+
+// ValidateSecondFactor validates a TOTP or recovery code of the user, with the same retry timeout as the credentials
+func (s *Service) ValidateSecondFactor(usr *user.User, code string, ip string) error {
+	secs, err := s.checkTimeout(usr, ip)
+	if err != nil {
+		log.WithError(err).WithField("UserID", usr.ID.String()).Error("Failed to collect login timeout.")
+		return InvalidCredentials("")
+	}
+	if secs > 0 {
+		return LockedUser{
+			seconds: secs,
+		}
+	}
+
+	err = s.factors.Verify(usr.ID, code)
+	if errors.Is(err, twofactor.ErrInvalidCode) {
+		if err = s.increaseTimeout(usr, ip); err != nil {
+			log.WithField("user", usr.ID.String()).Error("Failed to increase timeout for user")
+		}
+		return InvalidCredentials("")
+	}
+	if err != nil {
+		return err
+	}
 	return s.clearTimeout(usr)
 }
 
-func (s *Service) checkTimeout(usr *user.User) (int64, error) {
+// ValidatePasskey verifies the passkey of a sign in attempt, with the same retry timeout as the credentials
+func (s *Service) ValidatePasskey(attempt *passkey.Attempt, ip string) error {
+	usr := attempt.User
+	secs, err := s.checkTimeout(usr, ip)
+	if err != nil {
+		log.WithError(err).WithField("UserID", usr.ID.String()).Error("Failed to collect login timeout.")
+		return InvalidCredentials("")
+	}
+	if secs > 0 {
+		return LockedUser{
+			seconds: secs,
+		}
+	}
+
+	err = s.passkeys.Verify(attempt)
+	if errors.Is(err, passkey.ErrInvalidCredential) {
+		log.WithError(err).WithField("UserID", usr.ID.String()).Debug("Failed to verify passkey.")
+		if err = s.increaseTimeout(usr, ip); err != nil {
+			log.WithField("user", usr.ID.String()).Error("Failed to increase timeout for user")
+		}
+		return InvalidCredentials("")
+	}
+	if err != nil {
+		return err
+	}
+	return s.clearTimeout(usr)
+}
+
+// ValidateMagicLink validates the token of a sign in link, with the same retry timeout as the credentials
+func (s *Service) ValidateMagicLink(usr *user.User, link *magiclink.Link, token string, ip string) error {
+	secs, err := s.checkTimeout(usr, ip)
+	if err != nil {
+		log.WithError(err).WithField("UserID", usr.ID.String()).Error("Failed to collect login timeout.")
+		return InvalidCredentials("")
+	}
+	if secs > 0 {
+		return LockedUser{
+			seconds: secs,
+		}
+	}
+
+	if !link.Matches(token) {
+		if err = s.increaseTimeout(usr, ip); err != nil {
+			log.WithField("user", usr.ID.String()).Error("Failed to increase timeout for user")
+		}
+		return InvalidCredentials("")
+	}
+	if !link.IsActive() {
+		return InvalidCredentials("")
+	}
+	return s.clearTimeout(usr)
+}
+
+// Timeout returns the seconds until the user can sign in again from the IP address, zero when not locked. Together with
+// `Fail` and `Clear` it is the `twofactor.Guard` of the codes verified by signed in users.
+func (s *Service) Timeout(usr *user.User, ip string) (int64, error) {
+	return s.checkTimeout(usr, ip)
+}
+
+// Fail counts a failed attempt of the user from the IP address, locking the account by the backoff schedule.
+func (s *Service) Fail(usr *user.User, ip string) error {
+	return s.increaseTimeout(usr, ip)
+}
+
+// Clear clears the failed attempts and the lock of the account of the user.
+func (s *Service) Clear(usr *user.User) error {
+	return s.clearTimeout(usr)
+}
+
+// checkTimeout returns the seconds until the IP address or the account can be used to sign in again, zero when
+// neither is locked.
+func (s *Service) checkTimeout(usr *user.User, ip string) (int64, error) {
+	if secs := s.addressTimeout(ip); secs > 0 {
+		return secs, nil
+	}
 	acc, err := s.accounts.ByUser(usr.ID)
 	if err != nil {
 		return 0, err
 	}
-	if !acc.FailedLoginLock.IsZero() && acc.FailedLoginLock.After(time.Now()) {
-		return acc.FailedLoginLock.Unix() - time.Now().Unix(), nil
+	if now := time.Now(); acc.Locked(now) {
+		return acc.FailedLoginLock.Unix() - now.Unix(), nil
 	}
 	return 0, nil
 }
 
-func (s *Service) increaseTimeout(usr *user.User) error {
+// addressTimeout returns the seconds until the IP address is blocked for the failed attempts of the address or its
+// subnet, zero when it is not blocked.
+func (s *Service) addressTimeout(ip string) int64 {
+	return int64(math.Ceil(s.limiter.Blocked(ip).Seconds()))
+}
+
+// increaseTimeout counts a failed attempt of the IP address and the account, and locks the account by the backoff
+// schedule. The owner is notified of the first lock of the failed attempts in a row, with a link to unlock the account
+// if the attempts were not made by the owner.
+func (s *Service) increaseTimeout(usr *user.User, ip string) error {
+	s.limiter.Fail(ip)
 	acc, err := s.accounts.ByUser(usr.ID)
 	if err != nil {
 		return err
 	}
 	acc.FailedLoginCounter++
 	acc.LastFailedLogin = time.Now()
-	if acc.FailedLoginCounter > 2 {
-		timeout := int(math.Pow(10, float64(acc.FailedLoginCounter-2))) // exponential backoff - 10 sec, 10 sec, 1000 sec, ...
-		acc.FailedLoginLock = time.Now().Add(time.Second * time.Duration(timeout))
+	lock := s.backoff.Lock(acc.FailedLoginCounter)
+	if lock == 0 {
+		return s.accounts.Update(acc)
 	}
-	return s.accounts.Update(acc)
+	acc.FailedLoginLock = acc.LastFailedLogin.Add(lock)
+	first := s.backoff.Lock(acc.FailedLoginCounter-1) == 0
+	if first {
+		acc.UnlockToken = uuid.New().String()
+		acc.UnlockTTL = null.TimeFrom(acc.LastFailedLogin.Add(unlockTTL))
+	}
+	if err = s.accounts.Update(acc); err != nil {
+		return err
+	}
+	if first {
+		url := s.config.FrontendRoot + "/account/unlock?token=" + acc.UnlockToken
+		if err = s.sender.Lockout(usr.ID, usr.Email, ip, acc.FailedLoginLock, url); err != nil {
+			log.WithError(err).WithField("UserID", usr.ID.String()).Error("Failed to send lockout notification.")
+		}
+	}
+	return nil
 }
 
 func (s *Service) clearTimeout(usr *user.User) error {
@@ -95,7 +278,6 @@ func (s *Service) clearTimeout(usr *user.User) error {
 	if err != nil {
 		return err
 	}
-	acc.FailedLoginCounter = 0
-	acc.FailedLoginLock = time.Time{} // zero time
+	acc.Unlock()
 	return s.accounts.Update(acc)
 }
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/inokone/go-micro-saas/internal/auth/account"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+)
+
+func TestValidateCredentialsRehashesOutdatedPassword(t *testing.T) {
+	users := new(MockUserStorer)
+	accounts := new(MockAccountStorer)
+	s := NewService(users, accounts, new(MockFactorStorer), nil, nil, testLimiter(""), nil, testSender, testConfig)
+	usr := testUser()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	usr.PassHash = string(hash)
+
+	accounts.On("ByUser", usr.ID).Return(&account.Account{UserID: usr.ID}, nil)
+	accounts.On("Update", mock.Anything).Return(nil)
+	users.On("Update", mock.MatchedBy(func(u *user.User) bool { return u.PassHash != string(hash) })).Return(nil)
+
+	assert.NoError(t, s.ValidateCredentials(usr, "password", "10.0.0.1"))
+	assert.True(t, usr.VerifyPassword("password"))
+	assert.False(t, usr.PasswordOutdated())
+	users.AssertExpectations(t)
+}
+
+func TestValidateCredentialsKeepsCurrentPassword(t *testing.T) {
+	users := new(MockUserStorer)
+	accounts := new(MockAccountStorer)
+	s := NewService(users, accounts, new(MockFactorStorer), nil, nil, testLimiter(""), nil, testSender, testConfig)
+	usr := testUser()
+	assert.NoError(t, usr.SetPassword("password"))
+
+	accounts.On("ByUser", usr.ID).Return(&account.Account{UserID: usr.ID}, nil)
+	accounts.On("Update", mock.Anything).Return(nil)
+
+	assert.NoError(t, s.ValidateCredentials(usr, "password", "10.0.0.1"))
+	users.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestValidateCredentialsLocksAccountByBackoff(t *testing.T) {
+	accounts := new(MockAccountStorer)
+	backoff, _ := account.ParseBackoff("0s,1m,1h")
+	s := NewService(new(MockUserStorer), accounts, new(MockFactorStorer), nil, nil, testLimiter(""), backoff, testSender, testConfig)
+	usr := testUser()
+	assert.NoError(t, usr.SetPassword("password"))
+	acc := &account.Account{UserID: usr.ID}
+
+	accounts.On("ByUser", usr.ID).Return(acc, nil)
+	accounts.On("Update", acc).Return(nil)
+
+	assert.IsType(t, InvalidCredentials(""), s.ValidateCredentials(usr, "wrong", "10.0.0.1"))
+	assert.False(t, acc.Locked(time.Now()))
+	assert.Empty(t, acc.UnlockToken)
+
+	assert.IsType(t, InvalidCredentials(""), s.ValidateCredentials(usr, "wrong", "10.0.0.1"))
+	assert.WithinDuration(t, time.Now().Add(time.Minute), acc.FailedLoginLock, time.Second)
+	assert.NotEmpty(t, acc.UnlockToken)
+	assert.True(t, acc.UnlockTTL.Valid)
+
+	assert.IsType(t, LockedUser{}, s.ValidateCredentials(usr, "password", "10.0.0.1"))
+}
+
+func TestValidateCredentialsRejectsBlockedAddress(t *testing.T) {
+	accounts := new(MockAccountStorer)
+	limiter := testLimiter("ip:1/1h")
+	s := NewService(new(MockUserStorer), accounts, new(MockFactorStorer), nil, nil, limiter, nil, testSender, testConfig)
+	usr := testUser()
+	assert.NoError(t, usr.SetPassword("password"))
+
+	limiter.Fail("10.0.0.1")
+
+	assert.IsType(t, LockedUser{}, s.ValidateCredentials(usr, "password", "10.0.0.1"))
+	accounts.AssertNotCalled(t, "ByUser", mock.Anything)
+}
@@ -0,0 +1,181 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+// Handler is a struct for web handles related to the sessions of the users.
+type Handler struct {
+	sessions Storer
+}
+
+// NewHandler creates a new `Handler`, based on the session persistence.
+func NewHandler(sessions Storer) *Handler {
+	return &Handler{
+		sessions: sessions,
+	}
+}
+
+// List is a method of `Handler`. Lists the active sessions of the current user. While an administrator impersonates
+// the user, the sessions of the user are listed, none of them marked as current.
+// @Summary List sessions endpoint
+// @Schemes
+// @Description Lists the active logins of the current user
+// @Accept json
+// @Produce json
+// @Success 200 {array} session.View
+// @Failure 401 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/sessions [get]
+func (h *Handler) List(g *gin.Context) {
+	current, err := currentSession(g)
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, common.StatusMessage{Message: "Not authorized!"})
+		return
+	}
+	if subject := Subject(g, current); subject != current.UserID {
+		h.list(g, subject, uuid.Nil)
+		return
+	}
+	h.list(g, current.UserID, current.ID)
+}
+
+// Revoke is a method of `Handler`. Revokes a session of the current user, signing out the device of the session.
+// @Summary Revoke session endpoint
+// @Schemes
+// @Description Signs out one of the logins of the current user
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the session to revoke"
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 401 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Router /account/sessions/:id [delete]
+func (h *Handler) Revoke(g *gin.Context) {
+	current, err := currentSession(g)
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, common.StatusMessage{Message: "Not authorized!"})
+		return
+	}
+
+	id, err := uuid.Parse(g.Param("id"))
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Invalid session ID provided!"})
+		return
+	}
+
+	target, err := h.sessions.ByID(id)
+	if err != nil || target.UserID != current.UserID {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "Session not found!"})
+		return
+	}
+
+	if err = h.sessions.Revoke(target.ID); err != nil {
+		log.WithError(err).Error("Failed to revoke session.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+		return
+	}
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Session revoked!"})
+}
+
+// RevokeOthers is a method of `Handler`. Revokes all sessions of the current user except the one of the request.
+// @Summary Revoke other sessions endpoint
+// @Schemes
+// @Description Signs out all other logins of the current user
+// @Accept json
+// @Produce json
+// @Success 200 {object} common.StatusMessage
+// @Failure 401 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/sessions [delete]
+func (h *Handler) RevokeOthers(g *gin.Context) {
+	current, err := currentSession(g)
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusUnauthorized, common.StatusMessage{Message: "Not authorized!"})
+		return
+	}
+
+	if err = h.sessions.RevokeOthers(current.UserID, current.ID); err != nil {
+		log.WithError(err).Error("Failed to revoke sessions.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+		return
+	}
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Other sessions revoked!"})
+}
+
+// ListForUser is a method of `Handler`. Lists the active sessions of the user in the path, for administrators.
+// @Summary List user sessions endpoint
+// @Schemes
+// @Description Lists the active logins of a user
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the user"
+// @Success 200 {array} session.View
+// @Failure 400 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /users/:id/sessions [get]
+func (h *Handler) ListForUser(g *gin.Context) {
+	id, err := uuid.Parse(g.Param("id"))
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Invalid user ID provided!"})
+		return
+	}
+	h.list(g, id, uuid.Nil)
+}
+
+// RevokeForUser is a method of `Handler`. Revokes all sessions of the user in the path, for administrators.
+// @Summary Revoke user sessions endpoint
+// @Schemes
+// @Description Signs out a user from all devices
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the user"
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /users/:id/sessions [delete]
+func (h *Handler) RevokeForUser(g *gin.Context) {
+	id, err := uuid.Parse(g.Param("id"))
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Invalid user ID provided!"})
+		return
+	}
+
+	if err = h.sessions.RevokeAll(id); err != nil {
+		log.WithError(err).Error("Failed to revoke sessions.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+		return
+	}
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Sessions revoked!"})
+}
+
+func (h *Handler) list(g *gin.Context, userID uuid.UUID, current uuid.UUID) {
+	sessions, err := h.sessions.ListActive(userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to list sessions.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+		return
+	}
+
+	res := make([]View, 0)
+	for _, s := range sessions {
+		res = append(res, s.AsView(current))
+	}
+	g.JSON(http.StatusOK, res)
+}
+
+func currentSession(g *gin.Context) (*Session, error) {
+	s, ok := g.Get("session")
+	if !ok {
+		return nil, errors.New("session could not be extracted from context")
+	}
+	return s.(*Session), nil
+}
@@ -0,0 +1,156 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+func setupTestRouter(h *Handler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	return r
+}
+
+func testSession(userID uuid.UUID) *Session {
+	return &Session{
+		ID:         uuid.New(),
+		UserID:     userID,
+		IP:         "127.0.0.1",
+		UserAgent:  "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36",
+		CreatedAt:  time.Now(),
+		LastSeenAt: time.Now(),
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+}
+
+func TestList200ForHappyPath(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	current := testSession(uuid.New())
+	other := testSession(current.UserID)
+	mockStorer.On("ListActive", current.UserID).Return([]Session{*current, *other}, nil)
+
+	router.GET("/sessions", func(c *gin.Context) {
+		c.Set("session", current)
+		handler.List(c)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/sessions", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []View
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response, 2)
+	assert.True(t, response[0].Current)
+	assert.False(t, response[1].Current)
+	assert.Equal(t, "Chrome on Windows", response[0].Device)
+	mockStorer.AssertExpectations(t)
+}
+
+func TestListShowsSessionsOfImpersonatedUser(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	admin := testSession(uuid.New())
+	subject := testSession(uuid.New())
+	mockStorer.On("ListActive", subject.UserID).Return([]Session{*subject}, nil)
+
+	router.GET("/sessions", func(c *gin.Context) {
+		c.Set("session", admin)
+		SetSubject(c, subject.UserID)
+		handler.List(c)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/sessions", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response []View
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response, 1)
+	assert.Equal(t, subject.ID.String(), response[0].ID)
+	assert.False(t, response[0].Current)
+	mockStorer.AssertExpectations(t)
+	mockStorer.AssertNotCalled(t, "ListActive", admin.UserID)
+}
+
+func TestRevoke404ForSessionOfOtherUser(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	current := testSession(uuid.New())
+	foreign := testSession(uuid.New())
+	mockStorer.On("ByID", foreign.ID).Return(foreign, nil)
+
+	router.DELETE("/sessions/:id", func(c *gin.Context) {
+		c.Set("session", current)
+		handler.Revoke(c)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/sessions/"+foreign.ID.String(), nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockStorer.AssertNotCalled(t, "Revoke", foreign.ID)
+}
+
+func TestRevokeOthersKeepsCurrentSession(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	current := testSession(uuid.New())
+	mockStorer.On("RevokeOthers", current.UserID, current.ID).Return(nil)
+
+	router.DELETE("/sessions", func(c *gin.Context) {
+		c.Set("session", current)
+		handler.RevokeOthers(c)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/sessions", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response common.StatusMessage
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "Other sessions revoked!", response.Message)
+	mockStorer.AssertExpectations(t)
+}
+
+func TestList401WithoutSession(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+
+	router.GET("/sessions", handler.List)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/sessions", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,202 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+)
+
+const (
+	// maxUserAgent is the length of the user agent stored for a session, in characters.
+	maxUserAgent = 512
+	// subjectKey is the key of the ID of the user the request is made for in the Gin context.
+	subjectKey = "subject"
+)
+
+// Session is a server-side login of a user, the family of all refresh tokens rotated from a single sign in.
+type Session struct {
+	ID             uuid.UUID     `db:"session_id"`
+	UserID         uuid.UUID     `db:"user_id"`
+	RefreshHash    string        `db:"refresh_hash"`
+	PreviousHash   string        `db:"previous_hash"`
+	IP             string        `db:"ip_address"`
+	UserAgent      string        `db:"user_agent"`
+	CreatedAt      time.Time     `db:"created_at"`
+	LastSeenAt     time.Time     `db:"last_seen_at"`
+	ExpiresAt      time.Time     `db:"expires_at"`
+	RevokedAt      null.Time     `db:"revoked_at"`
+	OrganizationID uuid.NullUUID `db:"organization_id"`
+}
+
+// NewSession is a function to create a new `Session` for a user, returning the session with the first refresh token of the family.
+func NewSession(userID uuid.UUID, ip string, userAgent string, ttl time.Duration) (*Session, string, error) {
+	now := time.Now()
+	s := &Session{
+		ID:         uuid.New(),
+		UserID:     userID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+	s.UsedBy(ip, userAgent)
+	token, err := s.Rotate()
+	return s, token, err
+}
+
+// IsActive is a method of `Session` returning whether the session is neither revoked nor expired.
+func (s *Session) IsActive() bool {
+	return !s.RevokedAt.Valid && s.ExpiresAt.After(time.Now())
+}
+
+// UsedBy is a method of `Session` recording the IP address and the user agent of the client using the session. The user
+// agent is truncated to the length stored, as it is set by the client.
+func (s *Session) UsedBy(ip string, userAgent string) {
+	s.IP = ip
+	s.UserAgent = userAgent
+	if utf8.RuneCountInString(userAgent) > maxUserAgent {
+		s.UserAgent = string([]rune(userAgent)[:maxUserAgent])
+	}
+}
+
+// Rotate is a method of `Session` generating a new refresh token for the session. Only the hash of the token is kept,
+// all refresh tokens issued earlier for the session become invalid. The hash of the token replaced is kept as well, to
+// recognize when it is used again.
+func (s *Session) Rotate() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := s.ID.String() + "." + base64.RawURLEncoding.EncodeToString(secret)
+	s.PreviousHash = s.RefreshHash
+	s.RefreshHash = Hash(token)
+	s.LastSeenAt = time.Now()
+	return token, nil
+}
+
+// Matches is a method of `Session` returning whether the refresh token provided is the current one of the session.
+func (s *Session) Matches(token string) bool {
+	return s.RefreshHash == Hash(token)
+}
+
+// Reused is a method of `Session` returning whether the refresh token provided is the one replaced by the last rotation,
+// which is only presented again when the token was stolen. Tokens never issued for the session are not reused.
+func (s *Session) Reused(token string) bool {
+	return s.PreviousHash != "" && s.PreviousHash == Hash(token)
+}
+
+// Hash is a function returning the hex encoded SHA-256 hash of a refresh token for storage.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseRefreshToken is a function extracting the session ID from a refresh token.
+func ParseRefreshToken(token string) (uuid.UUID, error) {
+	id, _, found := strings.Cut(token, ".")
+	if !found {
+		return uuid.Nil, errors.New("malformed refresh token")
+	}
+	return uuid.Parse(id)
+}
+
+// AsView is a method of the `Session` struct. It converts a `Session` object into a `View` object.
+func (s *Session) AsView(current uuid.UUID) View {
+	return View{
+		ID:        s.ID.String(),
+		Device:    Device(s.UserAgent),
+		IP:        s.IP,
+		UserAgent: s.UserAgent,
+		Created:   int(s.CreatedAt.Unix()),
+		LastSeen:  int(s.LastSeenAt.Unix()),
+		Current:   s.ID == current,
+	}
+}
+
+// Device is a function returning a human readable description of the device based on the user agent of a session.
+func Device(userAgent string) string {
+	var browser, os string
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"), strings.Contains(userAgent, "Opera"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"), strings.Contains(userAgent, "CriOS/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	}
+	switch {
+	case strings.Contains(userAgent, "Android"):
+		os = "Android"
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		os = "iOS"
+	case strings.Contains(userAgent, "Windows"):
+		os = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"), strings.Contains(userAgent, "Macintosh"):
+		os = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		os = "Linux"
+	}
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	default:
+		return "Unknown device"
+	}
+}
+
+// View is the JSON representation of an active `Session` for the users and administrators.
+type View struct {
+	ID        string `json:"id"`
+	Device    string `json:"device"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	Created   int    `json:"created"`
+	LastSeen  int    `json:"last_seen"`
+	Current   bool   `json:"current"`
+}
+
+// Storer is the interface for `Session` persistence
+type Storer interface {
+	Store(session *Session) error
+	Update(session *Session) error
+	Rotate(session *Session, previous string) (bool, error)
+	Touch(id uuid.UUID, lastSeen time.Time) error
+	ByID(id uuid.UUID) (*Session, error)
+	ListActive(userID uuid.UUID) ([]Session, error)
+	Revoke(id uuid.UUID) error
+	RevokeAll(userID uuid.UUID) error
+	RevokeOthers(userID uuid.UUID, keep uuid.UUID) error
+	Familiar(userID uuid.UUID, ip string, userAgent string) (bool, error)
+}
+
+// SetSubject is a function setting the ID of the user the request is made for in the Gin context, when it is not the
+// user of the session, e.g. when an administrator impersonates the user.
+func SetSubject(g *gin.Context, userID uuid.UUID) {
+	g.Set(subjectKey, userID)
+}
+
+// Subject is a function returning the ID of the user the request is made for, the user of the session in parameter if
+// there is no other subject set.
+func Subject(g *gin.Context, s *Session) uuid.UUID {
+	id, ok := g.Get(subjectKey)
+	if !ok {
+		return s.UserID
+	}
+	return id.(uuid.UUID)
+}
@@ -0,0 +1,179 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockStorer is a mock implementation of the Storer interface
+type MockStorer struct {
+	mock.Mock
+}
+
+func (m *MockStorer) Store(session *Session) error {
+	args := m.Called(session)
+	return args.Error(0)
+}
+
+func (m *MockStorer) Update(session *Session) error {
+	args := m.Called(session)
+	return args.Error(0)
+}
+
+func (m *MockStorer) ByID(id uuid.UUID) (*Session, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Session), args.Error(1)
+}
+
+func (m *MockStorer) Revoke(id uuid.UUID) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockStorer) RevokeAll(userID uuid.UUID) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockStorer) Touch(id uuid.UUID, lastSeen time.Time) error {
+	args := m.Called(id, lastSeen)
+	return args.Error(0)
+}
+
+func (m *MockStorer) ListActive(userID uuid.UUID) ([]Session, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]Session), args.Error(1)
+}
+
+func (m *MockStorer) RevokeOthers(userID uuid.UUID, keep uuid.UUID) error {
+	args := m.Called(userID, keep)
+	return args.Error(0)
+}
+
+func (m *MockStorer) Familiar(userID uuid.UUID, ip string, userAgent string) (bool, error) {
+	args := m.Called(userID, ip, userAgent)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStorer) Rotate(session *Session, previous string) (bool, error) {
+	args := m.Called(session, previous)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestNewSessionSetsMembers(t *testing.T) {
+	userID := uuid.New()
+
+	s, token, err := NewSession(userID, "127.0.0.1", "test-agent", time.Hour)
+
+	assert.NoError(t, err)
+	assert.Equal(t, userID, s.UserID)
+	assert.Equal(t, "127.0.0.1", s.IP)
+	assert.Equal(t, "test-agent", s.UserAgent)
+	assert.True(t, s.ExpiresAt.After(time.Now()))
+	assert.True(t, s.IsActive())
+	assert.True(t, s.Matches(token))
+	assert.NotEqual(t, token, s.RefreshHash)
+}
+
+func TestNewSessionTruncatesUserAgent(t *testing.T) {
+	agent := strings.Repeat("é", maxUserAgent+10)
+
+	s, _, err := NewSession(uuid.New(), "127.0.0.1", agent, time.Hour)
+
+	assert.NoError(t, err)
+	assert.Equal(t, maxUserAgent, utf8.RuneCountInString(s.UserAgent))
+	assert.True(t, strings.HasPrefix(agent, s.UserAgent))
+}
+
+func TestRotateInvalidatesPreviousToken(t *testing.T) {
+	s, first, err := NewSession(uuid.New(), "", "", time.Hour)
+	assert.NoError(t, err)
+
+	second, err := s.Rotate()
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, second)
+	assert.False(t, s.Matches(first))
+	assert.True(t, s.Matches(second))
+}
+
+func TestReusedOnlyForPreviousToken(t *testing.T) {
+	s, first, err := NewSession(uuid.New(), "", "", time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, s.Reused(first))
+
+	second, err := s.Rotate()
+
+	assert.NoError(t, err)
+	assert.True(t, s.Reused(first))
+	assert.False(t, s.Reused(second))
+	assert.False(t, s.Reused(s.ID.String()+".garbage"))
+}
+
+func TestParseRefreshTokenReturnsSessionID(t *testing.T) {
+	s, token, err := NewSession(uuid.New(), "", "", time.Hour)
+	assert.NoError(t, err)
+
+	id, err := ParseRefreshToken(token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, s.ID, id)
+}
+
+func TestParseRefreshTokenFailsForMalformedToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "Empty token", token: ""},
+		{name: "Missing secret", token: uuid.New().String()},
+		{name: "Invalid session ID", token: "not-a-uuid.secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRefreshToken(tt.token)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestIsActiveReturnsFalseForRevokedOrExpired(t *testing.T) {
+	tests := []struct {
+		name     string
+		session  Session
+		expected bool
+	}{
+		{
+			name:     "Active session",
+			session:  Session{ExpiresAt: time.Now().Add(time.Hour)},
+			expected: true,
+		},
+		{
+			name:     "Expired session",
+			session:  Session{ExpiresAt: time.Now().Add(-time.Hour)},
+			expected: false,
+		},
+		{
+			name:     "Revoked session",
+			session:  Session{ExpiresAt: time.Now().Add(time.Hour), RevokedAt: null.TimeFrom(time.Now())},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.session.IsActive())
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package session
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresStorer is the `Storer` implementation based on sqlx library.
+type PostgresStorer struct {
+	db *sqlx.DB
+}
+
+// NewPostgresStorer creates a new `PostgresStorer` instance based on the sqlx library.
+func NewPostgresStorer(db *sqlx.DB) *PostgresStorer {
+	return &PostgresStorer{
+		db: db,
+	}
+}
+
+// Store is a method of the `PostgresStorer` struct. Takes a `Session` as parameter and persists it.
+func (s *PostgresStorer) Store(session *Session) error {
+	query := `INSERT INTO microsaas.sessions (session_id, user_id, refresh_hash, previous_hash, ip_address, user_agent, created_at, last_seen_at, expires_at, revoked_at, organization_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := s.db.Exec(
+		query,
+		session.ID,
+		session.UserID,
+		session.RefreshHash,
+		session.PreviousHash,
+		session.IP,
+		session.UserAgent,
+		session.CreatedAt,
+		session.LastSeenAt,
+		session.ExpiresAt,
+		session.RevokedAt,
+		session.OrganizationID)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// Update is a method of the `PostgresStorer` struct. Takes a `Session` as parameter and updates its activity and organization.
+// The refresh token is only changed by `Rotate`.
+func (s *PostgresStorer) Update(session *Session) error {
+	query := `UPDATE microsaas.sessions SET ip_address = $1, user_agent = $2, last_seen_at = $3, expires_at = $4, organization_id = $5 WHERE session_id = $6`
+	_, err := s.db.Exec(query,
+		session.IP,
+		session.UserAgent,
+		session.LastSeenAt,
+		session.ExpiresAt,
+		session.OrganizationID,
+		session.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	return nil
+}
+
+// Rotate is a method of the `PostgresStorer` struct. Takes a `Session` with a new refresh token and the hash of the previous
+// token as parameter, and updates the refresh token and activity of the session, if the previous token is still the
+// current one. Returns false if it is not, as the token was rotated by a concurrent request meanwhile.
+func (s *PostgresStorer) Rotate(session *Session, previous string) (bool, error) {
+	query := `UPDATE microsaas.sessions SET refresh_hash = $1, previous_hash = $2, ip_address = $3, user_agent = $4, last_seen_at = $5, expires_at = $6 WHERE session_id = $7 AND refresh_hash = $8`
+	res, err := s.db.Exec(query,
+		session.RefreshHash,
+		session.PreviousHash,
+		session.IP,
+		session.UserAgent,
+		session.LastSeenAt,
+		session.ExpiresAt,
+		session.ID,
+		previous)
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate session: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate session: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// Touch is a method of the `PostgresStorer` struct. Takes a session ID and a time as parameter and records the last activity of the session.
+func (s *PostgresStorer) Touch(id uuid.UUID, lastSeen time.Time) error {
+	query := `UPDATE microsaas.sessions SET last_seen_at = $1 WHERE session_id = $2`
+	_, err := s.db.Exec(query, lastSeen, id)
+	if err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+	return nil
+}
+
+// ByID is a method of the `PostgresStorer` struct. Takes a session ID as parameter to load a `Session` object from persistence.
+func (s *PostgresStorer) ByID(id uuid.UUID) (*Session, error) {
+	var session Session
+	query := `SELECT session_id, user_id, refresh_hash, previous_hash, ip_address, user_agent, created_at, last_seen_at, expires_at, revoked_at, organization_id FROM microsaas.sessions WHERE session_id = $1`
+	err := s.db.Get(&session, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session by ID: %w", err)
+	}
+	return &session, nil
+}
+
+// ListActive is a method of the `PostgresStorer` struct. Loads all sessions of the user in parameter, which are neither revoked nor expired.
+func (s *PostgresStorer) ListActive(userID uuid.UUID) ([]Session, error) {
+	sessions := make([]Session, 0)
+	query := `SELECT session_id, user_id, refresh_hash, previous_hash, ip_address, user_agent, created_at, last_seen_at, expires_at, revoked_at, organization_id FROM microsaas.sessions WHERE user_id = $1 AND revoked_at is null AND expires_at > $2 ORDER BY last_seen_at desc`
+	err := s.db.Select(&sessions, query, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// Revoke is a method of the `PostgresStorer` struct. Takes a session ID as parameter and revokes the session with all its refresh tokens.
+func (s *PostgresStorer) Revoke(id uuid.UUID) error {
+	query := `UPDATE microsaas.sessions SET revoked_at = $1 WHERE session_id = $2 AND revoked_at is null`
+	_, err := s.db.Exec(query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeAll is a method of the `PostgresStorer` struct. Takes a user ID as parameter and revokes all sessions of the user.
+func (s *PostgresStorer) RevokeAll(userID uuid.UUID) error {
+	query := `UPDATE microsaas.sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at is null`
+	_, err := s.db.Exec(query, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// RevokeOthers is a method of the `PostgresStorer` struct. Takes a user ID and a session ID as parameter and revokes all sessions
+// of the user except the one to keep.
+func (s *PostgresStorer) RevokeOthers(userID uuid.UUID, keep uuid.UUID) error {
+	query := `UPDATE microsaas.sessions SET revoked_at = $1 WHERE user_id = $2 AND session_id <> $3 AND revoked_at is null`
+	_, err := s.db.Exec(query, time.Now(), userID, keep)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// Familiar is a method of the `PostgresStorer` struct. Tells whether the user has signed in from the IP address and
+// with the user agent before, in any earlier session, including revoked and expired ones. The first sign in of a user is
+// familiar, there is nothing to compare it to.
+func (s *PostgresStorer) Familiar(userID uuid.UUID, ip string, userAgent string) (bool, error) {
+	var familiar bool
+	query := `SELECT COUNT(*) = 0 OR (bool_or(ip_address = $2) AND bool_or(user_agent = $3)) FROM microsaas.sessions WHERE user_id = $1`
+	if err := s.db.Get(&familiar, query, userID, ip, userAgent); err != nil {
+		return false, fmt.Errorf("failed to check sessions of user: %w", err)
+	}
+	return familiar, nil
+}
@@ -0,0 +1,309 @@
+package twofactor
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cskr/pubsub/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inokone/go-micro-saas/internal/auth/session"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+var statusUnknownError = common.StatusMessage{Message: "Unknown error, please contact administrator!"}
+
+// Handler is a struct for web handles related to two-factor authentication.
+type Handler struct {
+	factors Storer
+	service *Service
+	issuer  string
+	guard   Guard
+	ps      *pubsub.PubSub[string, common.Event]
+}
+
+// NewHandler creates a new `Handler`, based on the two-factor persistence and the issuer name shown in authenticator apps.
+// The failed codes of the settings are counted by the lockout guard of sign in, the changes of the two-factor settings
+// are published as security alerts.
+func NewHandler(factors Storer, issuer string, guard Guard, ps *pubsub.PubSub[string, common.Event]) *Handler {
+	return &Handler{
+		factors: factors,
+		service: NewService(factors),
+		issuer:  issuer,
+		guard:   guard,
+		ps:      ps,
+	}
+}
+
+// Status is a method of `Handler`. Returns whether two-factor authentication is enabled for the current user.
+// @Summary Two-factor status endpoint
+// @Schemes
+// @Description Returns whether two-factor authentication is enabled and the number of unused recovery codes
+// @Accept json
+// @Produce json
+// @Success 200 {object} twofactor.Status
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/2fa [get]
+func (h *Handler) Status(g *gin.Context) {
+	usr := currentUser(g)
+
+	enabled, err := h.service.Required(usr.ID)
+	if err != nil {
+		log.WithError(err).Error("Failed to collect two-factor settings.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	count := 0
+	if enabled {
+		if count, err = h.factors.CountRecoveryCodes(usr.ID); err != nil {
+			log.WithError(err).Error("Failed to count recovery codes.")
+			g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+			return
+		}
+	}
+	g.JSON(http.StatusOK, Status{Enabled: enabled, RecoveryCodes: count})
+}
+
+// Enroll is a method of `Handler`. Starts the TOTP enrollment of the current user with a new secret, requires a recent sign in.
+// @Summary Two-factor enrollment endpoint
+// @Schemes
+// @Description Generates a new TOTP secret and its provisioning URI to be shown as a QR code. Two-factor authentication is enabled only after verifying a code.
+// @Accept json
+// @Produce json
+// @Success 201 {object} twofactor.Enrollment
+// @Failure 400 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/2fa [post]
+func (h *Handler) Enroll(g *gin.Context) {
+	usr := currentUser(g)
+
+	enabled, err := h.service.Required(usr.ID)
+	if err != nil {
+		log.WithError(err).Error("Failed to collect two-factor settings.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	if enabled {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Two-factor authentication is already enabled!"})
+		return
+	}
+
+	settings, err := NewSettings(usr.ID)
+	if err != nil {
+		log.WithError(err).Error("Failed to generate TOTP secret.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	if err = h.factors.Store(settings); err != nil {
+		log.WithError(err).Error("Failed to store two-factor settings.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+
+	g.JSON(http.StatusCreated, Enrollment{
+		Secret: settings.Secret,
+		URI:    ProvisioningURI(h.issuer, usr.Email, settings.Secret),
+	})
+}
+
+// Activate is a method of `Handler`. Finishes the TOTP enrollment of the current user by verifying a code from the authenticator app.
+// @Summary Two-factor activation endpoint
+// @Schemes
+// @Description Verifies a TOTP code of the pending enrollment, enables two-factor authentication and returns the one-time recovery codes
+// @Accept json
+// @Produce json
+// @Param data body twofactor.Verification true "TOTP code from the authenticator app"
+// @Success 200 {object} twofactor.RecoveryCodes
+// @Failure 400 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/2fa/verify [post]
+func (h *Handler) Activate(g *gin.Context) {
+	var in Verification
+	usr := currentUser(g)
+
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+	if !h.unlocked(g, usr) {
+		return
+	}
+
+	settings, err := h.factors.ByUser(usr.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Two-factor enrollment is not started!"})
+		return
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to collect two-factor settings.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	if settings.Enabled {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Two-factor authentication is already enabled!"})
+		return
+	}
+
+	step, ok := Match(settings.Secret, in.Code, time.Now())
+	if !ok {
+		err = ErrInvalidCode
+	}
+	if !h.counted(g, usr, err) {
+		return
+	}
+	settings.Enabled = true
+	settings.LastUsedStep = step
+	settings.ConfirmedAt = null.TimeFrom(time.Now())
+	if err = h.factors.Update(settings); err != nil {
+		log.WithError(err).Error("Failed to update two-factor settings.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+
+	h.issueRecoveryCodes(g, usr, common.TwoFactorEnabled)
+}
+
+// Disable is a method of `Handler`. Turns off two-factor authentication for the current user.
+// @Summary Two-factor disable endpoint
+// @Schemes
+// @Description Disables two-factor authentication after verifying a TOTP or recovery code
+// @Accept json
+// @Produce json
+// @Param data body twofactor.Verification true "TOTP or recovery code"
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/2fa [delete]
+func (h *Handler) Disable(g *gin.Context) {
+	usr, ok := h.verified(g)
+	if !ok {
+		return
+	}
+
+	if err := h.factors.Delete(usr.ID); err != nil {
+		log.WithError(err).Error("Failed to delete two-factor settings.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	h.alert(g, usr, common.TwoFactorDisabled)
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Two-factor authentication disabled!"})
+}
+
+// RegenerateRecoveryCodes is a method of `Handler`. Replaces the recovery codes of the current user with new ones.
+// @Summary Recovery code regeneration endpoint
+// @Schemes
+// @Description Invalidates all recovery codes and returns a new set, after verifying a TOTP or recovery code
+// @Accept json
+// @Produce json
+// @Param data body twofactor.Verification true "TOTP or recovery code"
+// @Success 200 {object} twofactor.RecoveryCodes
+// @Failure 400 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/2fa/recovery-codes [post]
+func (h *Handler) RegenerateRecoveryCodes(g *gin.Context) {
+	usr, ok := h.verified(g)
+	if !ok {
+		return
+	}
+	h.issueRecoveryCodes(g, usr, common.RecoveryCodesRegenerated)
+}
+
+// verified verifies the TOTP or recovery code of the current user in the request, with the same lockout as the codes of
+// sign in, aborting the request if the code is not valid.
+func (h *Handler) verified(g *gin.Context) (*user.User, bool) {
+	var in Verification
+	usr := currentUser(g)
+
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return nil, false
+	}
+	if !h.unlocked(g, usr) {
+		return nil, false
+	}
+	if !h.counted(g, usr, h.service.Verify(usr.ID, in.Code)) {
+		return nil, false
+	}
+	return usr, true
+}
+
+// unlocked checks the lockout of the current user for failed codes, aborting the request if the user is locked out.
+func (h *Handler) unlocked(g *gin.Context, usr *user.User) bool {
+	secs, err := h.guard.Timeout(usr, g.ClientIP())
+	if err != nil {
+		log.WithError(err).Error("Failed to collect login timeout.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return false
+	}
+	if secs > 0 {
+		g.AbortWithStatusJSON(http.StatusForbidden, common.StatusMessage{
+			Message: fmt.Sprintf("You have been locked out for failed credentials. You have to wait %v more seconds.", secs),
+		})
+		return false
+	}
+	return true
+}
+
+// counted records the result of verifying a code of the current user with the lockout guard, failing an invalid code
+// and clearing the failures on success. Aborts the request unless the code was valid.
+func (h *Handler) counted(g *gin.Context, usr *user.User, err error) bool {
+	if errors.Is(err, ErrInvalidCode) {
+		if err = h.guard.Fail(usr, g.ClientIP()); err != nil {
+			log.WithError(err).Error("Failed to increase timeout for user")
+		}
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Invalid code!"})
+		return false
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to verify two-factor code.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return false
+	}
+	if err = h.guard.Clear(usr); err != nil {
+		log.WithError(err).Error("Failed to clear timeout for user")
+	}
+	return true
+}
+
+// issueRecoveryCodes replaces the recovery codes of the user and publishes the security alert of the change in parameter.
+func (h *Handler) issueRecoveryCodes(g *gin.Context, usr *user.User, alert string) {
+	codes, hashes, err := NewRecoveryCodes()
+	if err != nil {
+		log.WithError(err).Error("Failed to generate recovery codes.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	if err = h.factors.ReplaceRecoveryCodes(usr.ID, hashes); err != nil {
+		log.WithError(err).Error("Failed to store recovery codes.")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, statusUnknownError)
+		return
+	}
+	h.alert(g, usr, alert)
+	g.JSON(http.StatusOK, RecoveryCodes{Codes: codes})
+}
+
+// alert publishes a security alert of a change of the two-factor settings of the user, made by the request.
+func (h *Handler) alert(g *gin.Context, usr *user.User, alert string) {
+	h.ps.Pub(common.Event{
+		ID:   uuid.New(),
+		Type: alert,
+		Time: time.Now(),
+		Data: common.SecurityData{Email: usr.Email, IP: g.ClientIP(), Device: session.Device(g.Request.UserAgent())},
+		User: usr.ID,
+	}, common.HistoryTopic, common.NotificationTopic)
+}
+
+func currentUser(g *gin.Context) *user.User {
+	u, _ := g.Get("user")
+	return u.(*user.User)
+}
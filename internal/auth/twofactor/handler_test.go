@@ -0,0 +1,230 @@
+package twofactor
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cskr/pubsub/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+var testUser = &user.User{
+	ID:      uuid.New(),
+	Email:   "test@example.com",
+	Status:  user.Confirmed,
+	Source:  "credentials",
+	Enabled: true,
+}
+
+func setupTestRouter(h *Handler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	return r
+}
+
+func withUser(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user", testUser)
+		handler(c)
+	}
+}
+
+func TestEnroll201ForHappyPath(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer, "Test App", new(MockGuard), pubsub.New[string, common.Event](1))
+	router := setupTestRouter(handler)
+
+	mockStorer.On("ByUser", testUser.ID).Return(nil, sql.ErrNoRows)
+	mockStorer.On("Store", mock.MatchedBy(func(s *Settings) bool {
+		return s.UserID == testUser.ID && !s.Enabled && s.Secret != ""
+	})).Return(nil)
+
+	router.POST("/2fa", withUser(handler.Enroll))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/2fa", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response Enrollment
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response.Secret)
+	assert.Contains(t, response.URI, "otpauth://totp/Test%20App:")
+	mockStorer.AssertExpectations(t)
+}
+
+func TestEnroll400WhenAlreadyEnabled(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer, "Test App", new(MockGuard), pubsub.New[string, common.Event](1))
+	router := setupTestRouter(handler)
+
+	mockStorer.On("ByUser", testUser.ID).Return(&Settings{UserID: testUser.ID, Enabled: true}, nil)
+
+	router.POST("/2fa", withUser(handler.Enroll))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/2fa", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockStorer.AssertNotCalled(t, "Store", mock.Anything)
+}
+
+func TestActivateReturnsRecoveryCodes(t *testing.T) {
+	mockStorer, guard := new(MockStorer), new(MockGuard)
+	handler := NewHandler(mockStorer, "Test App", guard, pubsub.New[string, common.Event](1))
+	router := setupTestRouter(handler)
+
+	settings := &Settings{UserID: testUser.ID, Secret: rfcSecret}
+	code, err := Code(rfcSecret, Step(time.Now()))
+	assert.NoError(t, err)
+
+	mockStorer.On("ByUser", testUser.ID).Return(settings, nil)
+	mockStorer.On("Update", settings).Return(nil)
+	mockStorer.On("ReplaceRecoveryCodes", testUser.ID, mock.Anything).Return(nil)
+	guard.On("Timeout", testUser, mock.Anything).Return(int64(0), nil)
+	guard.On("Clear", testUser).Return(nil)
+
+	router.POST("/2fa/verify", withUser(handler.Activate))
+
+	body, _ := json.Marshal(Verification{Code: code})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/2fa/verify", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response RecoveryCodes
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Len(t, response.Codes, recoveryCodeCount)
+	assert.True(t, settings.Enabled)
+	mockStorer.AssertExpectations(t)
+}
+
+func TestActivate400ForInvalidCode(t *testing.T) {
+	mockStorer, guard := new(MockStorer), new(MockGuard)
+	handler := NewHandler(mockStorer, "Test App", guard, pubsub.New[string, common.Event](1))
+	router := setupTestRouter(handler)
+
+	settings := &Settings{UserID: testUser.ID, Secret: rfcSecret}
+	mockStorer.On("ByUser", testUser.ID).Return(settings, nil)
+	guard.On("Timeout", testUser, mock.Anything).Return(int64(0), nil)
+	guard.On("Fail", testUser, mock.Anything).Return(nil)
+
+	router.POST("/2fa/verify", withUser(handler.Activate))
+
+	body, _ := json.Marshal(Verification{Code: "000000"})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/2fa/verify", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response common.StatusMessage
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "Invalid code!", response.Message)
+	assert.False(t, settings.Enabled)
+	guard.AssertCalled(t, "Fail", testUser, mock.Anything)
+	mockStorer.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestActivate403WhenLockedOut(t *testing.T) {
+	mockStorer, guard := new(MockStorer), new(MockGuard)
+	handler := NewHandler(mockStorer, "Test App", guard, pubsub.New[string, common.Event](1))
+	router := setupTestRouter(handler)
+	code, err := Code(rfcSecret, Step(time.Now()))
+	assert.NoError(t, err)
+
+	guard.On("Timeout", testUser, mock.Anything).Return(int64(100), nil)
+
+	router.POST("/2fa/verify", withUser(handler.Activate))
+
+	body, _ := json.Marshal(Verification{Code: code})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/2fa/verify", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockStorer.AssertNotCalled(t, "ByUser", mock.Anything)
+	mockStorer.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func disable(h *Handler, code string) *httptest.ResponseRecorder {
+	router := setupTestRouter(h)
+	router.DELETE("/2fa", withUser(h.Disable))
+
+	body, _ := json.Marshal(Verification{Code: code})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/2fa", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestDisableCountsInvalidCodeAsFailedAttempt(t *testing.T) {
+	mockStorer, guard := new(MockStorer), new(MockGuard)
+	handler := NewHandler(mockStorer, "Test App", guard, pubsub.New[string, common.Event](1))
+
+	mockStorer.On("ByUser", testUser.ID).Return(&Settings{UserID: testUser.ID, Secret: rfcSecret, Enabled: true}, nil)
+	mockStorer.On("UseRecoveryCode", testUser.ID, mock.Anything).Return(false, nil)
+	guard.On("Timeout", testUser, mock.Anything).Return(int64(0), nil)
+	guard.On("Fail", testUser, mock.Anything).Return(nil)
+
+	w := disable(handler, "000000")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	guard.AssertCalled(t, "Fail", testUser, mock.Anything)
+	mockStorer.AssertNotCalled(t, "Delete", mock.Anything)
+}
+
+func TestDisable403WhenLockedOut(t *testing.T) {
+	mockStorer, guard := new(MockStorer), new(MockGuard)
+	handler := NewHandler(mockStorer, "Test App", guard, pubsub.New[string, common.Event](1))
+	code, err := Code(rfcSecret, Step(time.Now()))
+	assert.NoError(t, err)
+
+	guard.On("Timeout", testUser, mock.Anything).Return(int64(100), nil)
+
+	w := disable(handler, code)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockStorer.AssertNotCalled(t, "ByUser", mock.Anything)
+	mockStorer.AssertNotCalled(t, "Delete", mock.Anything)
+}
+
+func TestDisableClearsFailedAttempts(t *testing.T) {
+	mockStorer, guard := new(MockStorer), new(MockGuard)
+	handler := NewHandler(mockStorer, "Test App", guard, pubsub.New[string, common.Event](1))
+	code, err := Code(rfcSecret, Step(time.Now()))
+	assert.NoError(t, err)
+
+	mockStorer.On("ByUser", testUser.ID).Return(&Settings{UserID: testUser.ID, Secret: rfcSecret, Enabled: true}, nil)
+	mockStorer.On("Update", mock.Anything).Return(nil)
+	mockStorer.On("Delete", testUser.ID).Return(nil)
+	guard.On("Timeout", testUser, mock.Anything).Return(int64(0), nil)
+	guard.On("Clear", testUser).Return(nil)
+
+	w := disable(handler, code)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	guard.AssertExpectations(t)
+	mockStorer.AssertCalled(t, "Delete", testUser.ID)
+}
@@ -0,0 +1,124 @@
+package twofactor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+)
+
+const recoveryCodeCount = 10
+
+const (
+	// MethodTOTP is the second factor of a TOTP or recovery code.
+	MethodTOTP = "totp"
+	// MethodPasskey is the second factor of a passkey of the user.
+	MethodPasskey = "passkey"
+)
+
+// Settings is the two-factor authentication setup of a user for database storage.
+type Settings struct {
+	UserID       uuid.UUID `db:"user_id"`
+	Secret       string    `db:"secret"`
+	Enabled      bool      `db:"enabled"`
+	LastUsedStep int64     `db:"last_used_step"`
+	ConfirmedAt  null.Time `db:"confirmed_at"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// NewSettings is a function to create a new, not yet enabled `Settings` instance with a fresh TOTP secret.
+func NewSettings(userID uuid.UUID) (*Settings, error) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	return &Settings{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// NewRecoveryCodes is a function generating a new set of one-time recovery codes. Returns the codes to show to the user
+// and their hashes for storage.
+func NewRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(hex.EncodeToString(raw))
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = HashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode is a function returning the hex encoded SHA-256 hash of a normalized recovery code for storage.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// Status is the JSON representation of the two-factor authentication setup of a user.
+type Status struct {
+	Enabled       bool `json:"enabled"`
+	RecoveryCodes int  `json:"recovery_codes"`
+}
+
+// Enrollment is the JSON response of starting a TOTP enrollment, the URI is to be rendered as a QR code.
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// Verification is a struct for the message body of REST endpoints requiring a TOTP or a recovery code.
+type Verification struct {
+	Code string `json:"code" binding:"required,max=20"`
+}
+
+// RecoveryCodes is the JSON response containing newly generated one-time recovery codes.
+type RecoveryCodes struct {
+	Codes []string `json:"codes"`
+}
+
+// Challenge is the JSON response of a sign in, when a second factor is required to finish it.
+type Challenge struct {
+	Message string   `json:"message"`
+	Token   string   `json:"challenge_token"`
+	Methods []string `json:"methods"`
+}
+
+// ChallengeResponse is a struct for the message body of the second step of a sign in.
+type ChallengeResponse struct {
+	Token string `json:"challenge_token" binding:"required"`
+	Code  string `json:"code" binding:"required,max=20"`
+}
+
+// Storer is the interface for `Settings` and recovery code persistence
+type Storer interface {
+	Store(settings *Settings) error
+	Update(settings *Settings) error
+	ByUser(userID uuid.UUID) (*Settings, error)
+	Delete(userID uuid.UUID) error
+	ReplaceRecoveryCodes(userID uuid.UUID, hashes []string) error
+	UseRecoveryCode(userID uuid.UUID, hash string) (bool, error)
+	CountRecoveryCodes(userID uuid.UUID) (int, error)
+}
+
+// Guard is the interface of the lockout of failed sign in attempts. The codes verified by signed in users to change the
+// two-factor settings are counted by it as well, so they can not be guessed with a stolen session.
+type Guard interface {
+	Timeout(usr *user.User, ip string) (int64, error)
+	Fail(usr *user.User, ip string) error
+	Clear(usr *user.User) error
+}
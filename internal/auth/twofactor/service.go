@@ -0,0 +1,68 @@
+package twofactor
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCode is the error for a TOTP or recovery code which does not match, or was used already.
+var ErrInvalidCode = errors.New("invalid two-factor code")
+
+// Service is a worker for verifying the second factor of the users.
+type Service struct {
+	factors Storer
+}
+
+// NewService creates a new `Service`, based on the two-factor persistence.
+func NewService(factors Storer) *Service {
+	return &Service{
+		factors: factors,
+	}
+}
+
+// Required is a method of `Service` returning whether the user has two-factor authentication enabled.
+func (s *Service) Required(userID uuid.UUID) (bool, error) {
+	settings, err := s.factors.ByUser(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return settings.Enabled, nil
+}
+
+// Verify is a method of `Service` validating a TOTP code or a one-time recovery code of a user with two-factor
+// authentication enabled. A TOTP code is accepted only once, a recovery code is used up on success.
+func (s *Service) Verify(userID uuid.UUID, code string) error {
+	settings, err := s.factors.ByUser(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidCode
+	}
+	if err != nil {
+		return err
+	}
+	if !settings.Enabled {
+		return ErrInvalidCode
+	}
+
+	if step, ok := Match(settings.Secret, code, time.Now()); ok {
+		if step <= settings.LastUsedStep {
+			return ErrInvalidCode
+		}
+		settings.LastUsedStep = step
+		return s.factors.Update(settings)
+	}
+
+	used, err := s.factors.UseRecoveryCode(userID, HashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package twofactor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresStorer is the `Storer` implementation based on sqlx library.
+type PostgresStorer struct {
+	db *sqlx.DB
+}
+
+// NewPostgresStorer creates a new `PostgresStorer` instance based on the sqlx library.
+func NewPostgresStorer(db *sqlx.DB) *PostgresStorer {
+	return &PostgresStorer{
+		db: db,
+	}
+}
+
+// Store is a method of the `PostgresStorer` struct. Takes a `Settings` as parameter and persists it, replacing an unfinished enrollment.
+func (s *PostgresStorer) Store(settings *Settings) error {
+	query := `INSERT INTO microsaas.two_factor (user_id, secret, enabled, last_used_step, confirmed_at, created_at) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, enabled = EXCLUDED.enabled, last_used_step = EXCLUDED.last_used_step, confirmed_at = EXCLUDED.confirmed_at, created_at = EXCLUDED.created_at`
+	_, err := s.db.Exec(
+		query,
+		settings.UserID,
+		settings.Secret,
+		settings.Enabled,
+		settings.LastUsedStep,
+		settings.ConfirmedAt,
+		settings.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store two-factor settings: %w", err)
+	}
+	return nil
+}
+
+// Update is a method of the `PostgresStorer` struct. Takes a `Settings` as parameter and updates it.
+func (s *PostgresStorer) Update(settings *Settings) error {
+	query := `UPDATE microsaas.two_factor SET enabled = $1, last_used_step = $2, confirmed_at = $3 WHERE user_id = $4`
+	_, err := s.db.Exec(query, settings.Enabled, settings.LastUsedStep, settings.ConfirmedAt, settings.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to update two-factor settings: %w", err)
+	}
+	return nil
+}
+
+// ByUser is a method of the `PostgresStorer` struct. Takes a user ID as parameter to load the `Settings` of the user from persistence.
+func (s *PostgresStorer) ByUser(userID uuid.UUID) (*Settings, error) {
+	var settings Settings
+	query := `SELECT user_id, secret, enabled, last_used_step, confirmed_at, created_at FROM microsaas.two_factor WHERE user_id = $1`
+	if err := s.db.Get(&settings, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to get two-factor settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// Delete is a method of the `PostgresStorer` struct. Takes a user ID as parameter and deletes the two-factor settings and
+// recovery codes of the user.
+func (s *PostgresStorer) Delete(userID uuid.UUID) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to delete two-factor settings: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.Exec(`DELETE FROM microsaas.recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	if _, err = tx.Exec(`DELETE FROM microsaas.two_factor WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete two-factor settings: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes is a method of the `PostgresStorer` struct. Takes a user ID and recovery code hashes as parameter,
+// replaces all recovery codes of the user with the new ones.
+func (s *PostgresStorer) ReplaceRecoveryCodes(userID uuid.UUID, hashes []string) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.Exec(`DELETE FROM microsaas.recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+	now := time.Now()
+	for _, hash := range hashes {
+		if _, err = tx.Exec(`INSERT INTO microsaas.recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)`, userID, hash, now); err != nil {
+			return fmt.Errorf("failed to replace recovery codes: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode is a method of the `PostgresStorer` struct. Takes a user ID and a recovery code hash as parameter, marks
+// the code used. Returns false if the code does not exist or was used already.
+func (s *PostgresStorer) UseRecoveryCode(userID uuid.UUID, hash string) (bool, error) {
+	query := `UPDATE microsaas.recovery_codes SET used_at = $1 WHERE user_id = $2 AND code_hash = $3 AND used_at is null`
+	res, err := s.db.Exec(query, time.Now(), userID, hash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// CountRecoveryCodes is a method of the `PostgresStorer` struct. Takes a user ID as parameter and returns the number of unused recovery codes.
+func (s *PostgresStorer) CountRecoveryCodes(userID uuid.UUID) (int, error) {
+	var count int
+	query := `SELECT count(*) FROM microsaas.recovery_codes WHERE user_id = $1 AND used_at is null`
+	if err := s.db.Get(&count, query, userID); err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,186 @@
+package twofactor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+)
+
+// rfcSecret is the base32 encoded shared secret of the RFC 6238 test vectors
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// MockStorer is a mock implementation of the Storer interface
+type MockStorer struct {
+	mock.Mock
+}
+
+func (m *MockStorer) Store(settings *Settings) error {
+	args := m.Called(settings)
+	return args.Error(0)
+}
+
+func (m *MockStorer) Update(settings *Settings) error {
+	args := m.Called(settings)
+	return args.Error(0)
+}
+
+func (m *MockStorer) ByUser(userID uuid.UUID) (*Settings, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Settings), args.Error(1)
+}
+
+func (m *MockStorer) Delete(userID uuid.UUID) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockStorer) ReplaceRecoveryCodes(userID uuid.UUID, hashes []string) error {
+	args := m.Called(userID, hashes)
+	return args.Error(0)
+}
+
+func (m *MockStorer) UseRecoveryCode(userID uuid.UUID, hash string) (bool, error) {
+	args := m.Called(userID, hash)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStorer) CountRecoveryCodes(userID uuid.UUID) (int, error) {
+	args := m.Called(userID)
+	return args.Int(0), args.Error(1)
+}
+
+// MockGuard is a mock of the Guard interface
+type MockGuard struct {
+	mock.Mock
+}
+
+func (m *MockGuard) Timeout(usr *user.User, ip string) (int64, error) {
+	args := m.Called(usr, ip)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockGuard) Fail(usr *user.User, ip string) error {
+	args := m.Called(usr, ip)
+	return args.Error(0)
+}
+
+func (m *MockGuard) Clear(usr *user.User) error {
+	args := m.Called(usr)
+	return args.Error(0)
+}
+
+func TestCodeMatchesRFCVectors(t *testing.T) {
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{unix: 59, expected: "287082"},
+		{unix: 1111111109, expected: "081804"},
+		{unix: 1234567890, expected: "005924"},
+		{unix: 2000000000, expected: "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, code)
+	}
+}
+
+func TestMatchAcceptsClockSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, err := Code(rfcSecret, Step(now)-1)
+	assert.NoError(t, err)
+
+	step, ok := Match(rfcSecret, code, now)
+
+	assert.True(t, ok)
+	assert.Equal(t, Step(now)-1, step)
+}
+
+func TestMatchRejectsOldAndMalformedCodes(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	old, err := Code(rfcSecret, Step(now)-5)
+	assert.NoError(t, err)
+
+	for _, code := range []string{old, "", "12345", "abcdef"} {
+		_, ok := Match(rfcSecret, code, now)
+		assert.False(t, ok)
+	}
+}
+
+func TestProvisioningURIContainsSecretAndIssuer(t *testing.T) {
+	uri := ProvisioningURI("Micro SaaS", "test@example.com", rfcSecret)
+
+	assert.Contains(t, uri, "otpauth://totp/Micro%20SaaS:test@example.com?")
+	assert.Contains(t, uri, "secret="+rfcSecret)
+	assert.Contains(t, uri, "issuer=Micro+SaaS")
+}
+
+func TestNewRecoveryCodesAreUniqueAndHashed(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes()
+
+	assert.NoError(t, err)
+	assert.Len(t, codes, recoveryCodeCount)
+	seen := make(map[string]bool)
+	for i, code := range codes {
+		assert.False(t, seen[code])
+		seen[code] = true
+		assert.Equal(t, HashRecoveryCode(code), hashes[i])
+		assert.Equal(t, HashRecoveryCode(code), HashRecoveryCode(" "+code[:5]+code[6:]+" "))
+	}
+}
+
+func TestVerifyRejectsReusedTOTPCode(t *testing.T) {
+	mockStorer := new(MockStorer)
+	service := NewService(mockStorer)
+	userID := uuid.New()
+	code, err := Code(rfcSecret, Step(time.Now()))
+	assert.NoError(t, err)
+
+	settings := &Settings{UserID: userID, Secret: rfcSecret, Enabled: true, LastUsedStep: Step(time.Now())}
+	mockStorer.On("ByUser", userID).Return(settings, nil)
+	mockStorer.On("UseRecoveryCode", userID, HashRecoveryCode(code)).Return(false, nil)
+
+	err = service.Verify(userID, code)
+
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	mockStorer.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestVerifyAcceptsFreshTOTPCode(t *testing.T) {
+	mockStorer := new(MockStorer)
+	service := NewService(mockStorer)
+	userID := uuid.New()
+	code, err := Code(rfcSecret, Step(time.Now()))
+	assert.NoError(t, err)
+
+	settings := &Settings{UserID: userID, Secret: rfcSecret, Enabled: true}
+	mockStorer.On("ByUser", userID).Return(settings, nil)
+	mockStorer.On("Update", settings).Return(nil)
+
+	err = service.Verify(userID, code)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Step(time.Now()), settings.LastUsedStep)
+}
+
+func TestVerifyAcceptsUnusedRecoveryCode(t *testing.T) {
+	mockStorer := new(MockStorer)
+	service := NewService(mockStorer)
+	userID := uuid.New()
+
+	settings := &Settings{UserID: userID, Secret: rfcSecret, Enabled: true}
+	mockStorer.On("ByUser", userID).Return(settings, nil)
+	mockStorer.On("UseRecoveryCode", userID, HashRecoveryCode("abcde-12345")).Return(true, nil)
+
+	assert.NoError(t, service.Verify(userID, "abcde-12345"))
+}
@@ -1,24 +1,37 @@
 package user
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
+	"github.com/cskr/pubsub/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/inokone/go-micro-saas/internal/auth/organization"
+	"github.com/inokone/go-micro-saas/internal/auth/role"
+	"github.com/inokone/go-micro-saas/internal/auth/session"
 	"github.com/inokone/go-micro-saas/internal/common"
 )
 
 // Handler is a struct for web handles related to application users.
 type Handler struct {
-	users Storer
+	users    Storer
+	roles    role.Storer
+	sessions session.Storer
+	ps       *pubsub.PubSub[string, common.Event]
 }
 
-// NewHandler creates a new `Handler`, based on the user persistence.
-func NewHandler(users Storer) *Handler {
+// NewHandler creates a new `Handler`, based on the user, role and session persistence and the publisher of history
+// events.
+func NewHandler(users Storer, roles role.Storer, sessions session.Storer, ps *pubsub.PubSub[string, common.Event]) *Handler {
 	return &Handler{
-		users: users,
+		users:    users,
+		roles:    roles,
+		sessions: sessions,
+		ps:       ps,
 	}
 }
 
@@ -37,7 +50,8 @@ func (h *Handler) Profile(g *gin.Context) {
 	g.JSON(http.StatusOK, usr.AsProfile())
 }
 
-// List lists the users of the application.
+// List lists the users of the application. Platform administrators see all users, owners and admins of an organization
+// see the members of their active organization.
 // @Summary List users endpoint
 // @Schemes
 // @Description Lists the users of the application.
@@ -45,6 +59,7 @@ func (h *Handler) Profile(g *gin.Context) {
 // @Produce json
 // @Success 200 {object} common.StatusMessage
 // @Failure 400 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
 // @Router /users [get]
 func (h *Handler) List(g *gin.Context) {
 	var (
@@ -52,7 +67,16 @@ func (h *Handler) List(g *gin.Context) {
 		err   error
 		res   []AdminView
 	)
-	users, err = h.users.List()
+	u, _ := g.Get("user")
+	usr := u.(*User)
+	if usr.Can(role.PermUsersRead) {
+		users, err = h.users.List()
+	} else if m := organization.Active(g); m != nil && m.CanManage() {
+		users, err = h.users.ListInOrganization(m.OrganizationID)
+	} else {
+		g.AbortWithStatusJSON(http.StatusForbidden, common.StatusMessage{Message: "Not allowed!"})
+		return
+	}
 	if err != nil {
 		log.WithError(err).Error("Failed to list users")
 		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{
@@ -69,7 +93,7 @@ func (h *Handler) List(g *gin.Context) {
 	g.JSON(http.StatusOK, res)
 }
 
-// Patch updates settings (e.g. role) for a user.
+// Patch updates settings (names) for a user. Users are enabled and disabled with `SetEnabled`.
 // @Summary User update endpoint
 // @Schemes
 // @Description Updates the target user
@@ -97,10 +121,10 @@ func (h *Handler) Patch(g *gin.Context) {
 	})
 }
 
-// SetEnabled enables/disables a user for login.
+// SetEnabled enables/disables a user for login. Disabling a user also signs them out on all devices.
 // @Summary User enable/disable endpoint
 // @Schemes
-// @Description Updates the target user
+// @Description Updates the target user, revokes all sessions of a disabled user
 // @Accept json
 // @Produce json
 // @Param id path int true "ID of the user information to patch"
@@ -127,6 +151,115 @@ func (h *Handler) SetEnabled(g *gin.Context) {
 		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Invalid parameters provided!"})
 		return
 	}
+	if !in.Enabled {
+		if err = h.sessions.RevokeAll(id); err != nil {
+			log.WithError(err).Error("Failed to revoke sessions of disabled user")
+			g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+			return
+		}
+		h.alertDisabled(g, id)
+	}
+	g.JSON(http.StatusOK, common.StatusMessage{
+		Message: "User updated!",
+	})
+}
+
+// alertDisabled publishes a security alert of the account of the user disabled by the administrator of the request.
+// The account is disabled already on errors, the alert is only skipped.
+func (h *Handler) alertDisabled(g *gin.Context, id uuid.UUID) {
+	target, err := h.users.ByID(id)
+	if err != nil {
+		log.WithError(err).Warn("Failed to collect disabled user")
+		return
+	}
+	u, _ := g.Get("user")
+	actor := u.(*User)
+	h.ps.Pub(common.Event{
+		ID:    uuid.New(),
+		Type:  common.AccountDisabled,
+		Time:  time.Now(),
+		Data:  common.SecurityData{Email: target.Email, IP: g.ClientIP()},
+		User:  target.ID,
+		Actor: uuid.NullUUID{UUID: actor.ID, Valid: true},
+	}, common.HistoryTopic, common.NotificationTopic)
+}
+
+// SetRole assigns a role to a user. The user is signed out on all devices, so the new permissions apply right away.
+// @Summary User role endpoint
+// @Schemes
+// @Description Assigns a role to the target user, revokes all sessions of the user
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the user"
+// @Param data body user.SetRole true "The role to assign to the user"
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /users/:id/role [put]
+func (h *Handler) SetRole(g *gin.Context) {
+	var in SetRole
+	u, _ := g.Get("user")
+	actor := u.(*User)
+
+	id, err := uuid.Parse(g.Param("id"))
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "User not found!"})
+		return
+	}
+	if err = g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+	target, err := h.users.ByID(id)
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "User not found!"})
+		return
+	}
+	assigned, err := h.roles.ByID(uuid.MustParse(in.RoleID))
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Role not found!"})
+		return
+	}
+	if assigned.ID == target.RoleID {
+		g.JSON(http.StatusOK, common.StatusMessage{Message: "User updated!"})
+		return
+	}
+	for _, p := range assigned.Permissions {
+		if !actor.Can(p) {
+			g.AbortWithStatusJSON(http.StatusForbidden, common.StatusMessage{Message: "Roles granting permissions you do not have can not be assigned!"})
+			return
+		}
+	}
+	err = h.users.SetRole(target.ID, assigned.ID)
+	if errors.Is(err, ErrLastAdmin) {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "The last administrator can not be demoted!"})
+		return
+	}
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Invalid parameters provided!"})
+		return
+	}
+	if err = h.sessions.RevokeAll(target.ID); err != nil {
+		log.WithError(err).Error("Failed to revoke sessions of user with new role")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+		return
+	}
+
+	change := common.RoleChangeData{From: target.RoleID.String(), To: assigned.ID.String(), ToName: assigned.DisplayName}
+	if target.Role != nil {
+		change.FromName = target.Role.DisplayName
+	}
+	h.ps.Pub(common.Event{
+		ID:    uuid.New(),
+		Type:  common.RoleChanged,
+		Time:  time.Now(),
+		Data:  change,
+		User:  target.ID,
+		Actor: uuid.NullUUID{UUID: actor.ID, Valid: true},
+	}, common.HistoryTopic)
+
 	g.JSON(http.StatusOK, common.StatusMessage{
 		Message: "User updated!",
 	})
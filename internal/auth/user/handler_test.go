@@ -6,16 +6,139 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/cskr/pubsub/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/inokone/go-micro-saas/internal/auth/organization"
 	"github.com/inokone/go-micro-saas/internal/auth/role"
+	"github.com/inokone/go-micro-saas/internal/auth/session"
 	"github.com/inokone/go-micro-saas/internal/common"
 )
 
+// MockSessionStorer is a mock implementation of the session.Storer interface
+type MockSessionStorer struct {
+	mock.Mock
+}
+
+func (m *MockSessionStorer) Store(s *session.Session) error {
+	args := m.Called(s)
+	return args.Error(0)
+}
+
+func (m *MockSessionStorer) Update(s *session.Session) error {
+	args := m.Called(s)
+	return args.Error(0)
+}
+
+func (m *MockSessionStorer) Touch(id uuid.UUID, lastSeen time.Time) error {
+	args := m.Called(id, lastSeen)
+	return args.Error(0)
+}
+
+func (m *MockSessionStorer) ByID(id uuid.UUID) (*session.Session, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*session.Session), args.Error(1)
+}
+
+func (m *MockSessionStorer) ListActive(userID uuid.UUID) ([]session.Session, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]session.Session), args.Error(1)
+}
+
+func (m *MockSessionStorer) Revoke(id uuid.UUID) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockSessionStorer) RevokeAll(userID uuid.UUID) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockSessionStorer) RevokeOthers(userID uuid.UUID, keep uuid.UUID) error {
+	args := m.Called(userID, keep)
+	return args.Error(0)
+}
+
+func (m *MockSessionStorer) Familiar(userID uuid.UUID, ip string, userAgent string) (bool, error) {
+	args := m.Called(userID, ip, userAgent)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSessionStorer) Rotate(session *session.Session, previous string) (bool, error) {
+	args := m.Called(session, previous)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockRoleStorer is a mock implementation of the role.Storer interface
+type MockRoleStorer struct {
+	mock.Mock
+}
+
+func (m *MockRoleStorer) Store(r *role.Role) error {
+	args := m.Called(r)
+	return args.Error(0)
+}
+
+func (m *MockRoleStorer) Update(r role.ProfileRole) error {
+	args := m.Called(r)
+	return args.Error(0)
+}
+
+func (m *MockRoleStorer) Rename(id uuid.UUID, name string) error {
+	args := m.Called(id, name)
+	return args.Error(0)
+}
+
+func (m *MockRoleStorer) SetPermissions(id uuid.UUID, permissions []string) error {
+	args := m.Called(id, permissions)
+	return args.Error(0)
+}
+
+func (m *MockRoleStorer) SetLimits(id uuid.UUID, limits role.Limits) error {
+	args := m.Called(id, limits)
+	return args.Error(0)
+}
+
+func (m *MockRoleStorer) SetDefault(id uuid.UUID) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockRoleStorer) Delete(id uuid.UUID, target uuid.UUID) error {
+	args := m.Called(id, target)
+	return args.Error(0)
+}
+
+func (m *MockRoleStorer) ByID(id uuid.UUID) (*role.Role, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*role.Role), args.Error(1)
+}
+
+func (m *MockRoleStorer) Default() (*role.Role, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*role.Role), args.Error(1)
+}
+
+func (m *MockRoleStorer) List() ([]role.Role, error) {
+	args := m.Called()
+	return args.Get(0).([]role.Role), args.Error(1)
+}
+
 func setupTestRouter(h *Handler) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
@@ -24,7 +147,7 @@ func setupTestRouter(h *Handler) *gin.Engine {
 
 func TestProfile200ForHappyPath(t *testing.T) {
 	mockStorer := new(MockStorer)
-	handler := NewHandler(mockStorer)
+	handler := NewHandler(mockStorer, new(MockRoleStorer), new(MockSessionStorer), nil)
 	router := setupTestRouter(handler)
 
 	userID := uuid.New()
@@ -64,7 +187,7 @@ func TestProfile200ForHappyPath(t *testing.T) {
 
 func TestList200ForHappyPath(t *testing.T) {
 	mockStorer := new(MockStorer)
-	handler := NewHandler(mockStorer)
+	handler := NewHandler(mockStorer, new(MockRoleStorer), new(MockSessionStorer), nil)
 	router := setupTestRouter(handler)
 
 	userID := uuid.New()
@@ -87,7 +210,9 @@ func TestList200ForHappyPath(t *testing.T) {
 
 	mockStorer.On("List").Return(testUsers, nil)
 
-	router.GET("/users", handler.List)
+	router.GET("/users", func(c *gin.Context) {
+		c.Set("user", roleUser(adminRole))
+	}, handler.List)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/users", nil)
@@ -107,21 +232,19 @@ func TestList200ForHappyPath(t *testing.T) {
 
 func TestPatch200ForHappyPath(t *testing.T) {
 	mockStorer := new(MockStorer)
-	handler := NewHandler(mockStorer)
+	handler := NewHandler(mockStorer, new(MockRoleStorer), new(MockSessionStorer), nil)
 	router := setupTestRouter(handler)
 
 	testPatch := Patch{
 		ID:        uuid.New().String(),
 		FirstName: "Updated",
 		LastName:  "Name",
-		Enabled:   true,
 	}
 
 	mockStorer.On("Patch", mock.MatchedBy(func(p Patch) bool {
 		return p.ID == testPatch.ID &&
 			p.FirstName == testPatch.FirstName &&
-			p.LastName == testPatch.LastName &&
-			p.Enabled == testPatch.Enabled
+			p.LastName == testPatch.LastName
 	})).Return(nil)
 
 	router.PATCH("/users/:id", handler.Patch)
@@ -142,9 +265,34 @@ func TestPatch200ForHappyPath(t *testing.T) {
 	mockStorer.AssertExpectations(t)
 }
 
+func TestPatchDoesNotDisableUser(t *testing.T) {
+	mockStorer := new(MockStorer)
+	mockSessions := new(MockSessionStorer)
+	handler := NewHandler(mockStorer, new(MockRoleStorer), mockSessions, nil)
+	router := setupTestRouter(handler)
+
+	userID := uuid.New().String()
+	mockStorer.On("Patch", mock.MatchedBy(func(p Patch) bool {
+		return p.ID == userID && p.FirstName == "Updated"
+	})).Return(nil)
+
+	router.PATCH("/users/:id", handler.Patch)
+
+	body := []byte(`{"id":"` + userID + `","first_name":"Updated","last_name":"Name","enabled":false}`)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/users/"+userID, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStorer.AssertExpectations(t)
+	mockStorer.AssertNotCalled(t, "SetEnabled", mock.Anything, mock.Anything)
+	mockSessions.AssertNotCalled(t, "RevokeAll", mock.Anything)
+}
+
 func TestSetEnabled200ForHappyPath(t *testing.T) {
 	mockStorer := new(MockStorer)
-	handler := NewHandler(mockStorer)
+	handler := NewHandler(mockStorer, new(MockRoleStorer), new(MockSessionStorer), nil)
 	router := setupTestRouter(handler)
 
 	userID := uuid.New()
@@ -172,3 +320,176 @@ func TestSetEnabled200ForHappyPath(t *testing.T) {
 
 	mockStorer.AssertExpectations(t)
 }
+
+func TestSetEnabledRevokesSessionsOfDisabledUser(t *testing.T) {
+	mockStorer := new(MockStorer)
+	mockSessions := new(MockSessionStorer)
+	ps := pubsub.New[string, common.Event](1)
+	ch := ps.Sub(common.NotificationTopic)
+	handler := NewHandler(mockStorer, new(MockRoleStorer), mockSessions, ps)
+	router := setupTestRouter(handler)
+
+	admin, target := roleUser(adminRole), roleUser(userRole)
+	testEnabled := SetEnabled{
+		ID:      target.ID.String(),
+		Enabled: false,
+	}
+
+	mockStorer.On("SetEnabled", target.ID, false).Return(nil)
+	mockStorer.On("ByID", target.ID).Return(target, nil)
+	mockSessions.On("RevokeAll", target.ID).Return(nil)
+
+	router.PUT("/users/:id/enabled", func(c *gin.Context) {
+		c.Set("user", admin)
+	}, handler.SetEnabled)
+
+	body, _ := json.Marshal(testEnabled)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/users/"+target.ID.String()+"/enabled", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockStorer.AssertExpectations(t)
+	mockSessions.AssertExpectations(t)
+	event := <-ch
+	assert.Equal(t, common.AccountDisabled, event.Type)
+	assert.Equal(t, target.ID, event.User)
+	assert.Equal(t, admin.ID, event.Actor.UUID)
+	assert.Equal(t, target.Email, event.Data.(common.SecurityData).Email)
+}
+
+var (
+	adminRole = &role.Role{ID: role.RoleAdmin, DisplayName: "Admin", Permissions: role.Permissions}
+	userRole  = &role.Role{ID: role.RoleCustomerUser, DisplayName: "Customer User"}
+)
+
+func roleUser(r *role.Role) *User {
+	return &User{ID: uuid.New(), Email: "test@example.com", Role: r, RoleID: r.ID, Enabled: true}
+}
+
+func setRole(h *Handler, actor *User, target uuid.UUID, roleID uuid.UUID) *httptest.ResponseRecorder {
+	router := setupTestRouter(h)
+	router.PUT("/users/:id/role", func(c *gin.Context) {
+		c.Set("user", actor)
+	}, h.SetRole)
+
+	body, _ := json.Marshal(SetRole{RoleID: roleID.String()})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/users/"+target.String()+"/role", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSetRoleRevokesSessionsAndRecordsChange(t *testing.T) {
+	users, roles, sessions := new(MockStorer), new(MockRoleStorer), new(MockSessionStorer)
+	ps := pubsub.New[string, common.Event](1)
+	ch := ps.Sub(common.HistoryTopic)
+	handler := NewHandler(users, roles, sessions, ps)
+	admin, target := roleUser(adminRole), roleUser(userRole)
+
+	users.On("ByID", target.ID).Return(target, nil)
+	roles.On("ByID", role.RoleAdmin).Return(adminRole, nil)
+	users.On("SetRole", target.ID, role.RoleAdmin).Return(nil)
+	sessions.On("RevokeAll", target.ID).Return(nil)
+
+	w := setRole(handler, admin, target.ID, role.RoleAdmin)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	users.AssertExpectations(t)
+	sessions.AssertExpectations(t)
+	event := <-ch
+	assert.Equal(t, common.RoleChanged, event.Type)
+	assert.Equal(t, target.ID, event.User)
+	assert.Equal(t, admin.ID, event.Actor.UUID)
+	assert.Equal(t, common.RoleChangeData{
+		From:     role.RoleCustomerUser.String(),
+		FromName: "Customer User",
+		To:       role.RoleAdmin.String(),
+		ToName:   "Admin",
+	}, event.Data)
+}
+
+func TestSetRole400ForUnknownRole(t *testing.T) {
+	users, roles := new(MockStorer), new(MockRoleStorer)
+	handler := NewHandler(users, roles, new(MockSessionStorer), nil)
+	admin, target := roleUser(adminRole), roleUser(userRole)
+	roleID := uuid.New()
+
+	users.On("ByID", target.ID).Return(target, nil)
+	roles.On("ByID", roleID).Return(nil, assert.AnError)
+
+	w := setRole(handler, admin, target.ID, roleID)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	users.AssertNotCalled(t, "SetRole", mock.Anything, mock.Anything)
+}
+
+func TestSetRoleRejectsDemotionOfLastAdmin(t *testing.T) {
+	users, roles, sessions := new(MockStorer), new(MockRoleStorer), new(MockSessionStorer)
+	handler := NewHandler(users, roles, sessions, nil)
+	admin := roleUser(adminRole)
+
+	users.On("ByID", admin.ID).Return(admin, nil)
+	roles.On("ByID", role.RoleCustomerUser).Return(userRole, nil)
+	users.On("SetRole", admin.ID, role.RoleCustomerUser).Return(ErrLastAdmin)
+
+	w := setRole(handler, admin, admin.ID, role.RoleCustomerUser)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "The last administrator can not be demoted!")
+	sessions.AssertNotCalled(t, "RevokeAll", mock.Anything)
+}
+
+func TestSetRoleRejectsGrantingMissingPermissions(t *testing.T) {
+	users, roles := new(MockStorer), new(MockRoleStorer)
+	handler := NewHandler(users, roles, new(MockSessionStorer), nil)
+	manager := roleUser(&role.Role{ID: uuid.New(), Permissions: []string{role.PermUsersRead, role.PermUsersWrite}})
+	target := roleUser(userRole)
+
+	users.On("ByID", target.ID).Return(target, nil)
+	roles.On("ByID", role.RoleAdmin).Return(adminRole, nil)
+
+	w := setRole(handler, manager, target.ID, role.RoleAdmin)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	users.AssertNotCalled(t, "SetRole", mock.Anything, mock.Anything)
+}
+
+func TestListShowsOnlyActiveOrganizationOfManagers(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer, new(MockRoleStorer), new(MockSessionStorer), nil)
+	router := setupTestRouter(handler)
+	manager, member := roleUser(userRole), roleUser(userRole)
+	orgID := uuid.New()
+
+	mockStorer.On("ListInOrganization", orgID).Return([]User{*manager, *member}, nil)
+
+	router.GET("/users", func(c *gin.Context) {
+		usr := manager
+		orgRole := organization.RoleAdmin
+		if c.Query("as") == "member" {
+			usr, orgRole = member, organization.RoleMember
+		}
+		c.Set("user", usr)
+		organization.SetActive(c, organization.NewMembership(orgID, usr.ID, orgRole))
+	}, handler.List)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response []AdminView
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response, 2)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/users?as=member", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockStorer.AssertNotCalled(t, "List")
+	mockStorer.AssertNumberOfCalls(t, "ListInOrganization", 1)
+}
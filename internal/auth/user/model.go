@@ -1,14 +1,26 @@
 package user
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/guregu/null"
+	"github.com/inokone/go-micro-saas/internal/auth/password"
 	"github.com/inokone/go-micro-saas/internal/auth/role"
-	"golang.org/x/crypto/bcrypt"
 )
 
+// hasher is the password hasher of the users, replaced with the configured one at startup by `UseHasher`.
+var hasher = password.DefaultHasher()
+
+// UseHasher is a function to set the password hasher used for new passwords of the users.
+func UseHasher(h *password.Hasher) {
+	hasher = h
+}
+
+// ErrLastAdmin is the error of taking the role managing permission from the last enabled user granted it.
+var ErrLastAdmin = errors.New("last administrator can not be demoted")
+
 // User is the user representation for database storage.
 type User struct {
 	ID        uuid.UUID `db:"user_id"`
@@ -54,20 +66,24 @@ func NewUser(email string, password string, firstName string, lastName string) (
 
 // SetPassword sets the password of the target user.
 func (u *User) SetPassword(password string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hash, err := hasher.Hash(password)
 	if err != nil {
 		return err
 	}
-	u.PassHash = string(hash)
+	u.PassHash = hash
 	return nil
 }
 
 // VerifyPassword is a method of the `User` struct. It takes a password string as input
 // and compares it with the hashed password stored in the `PassHash` field of the `User` struct.
 func (u *User) VerifyPassword(password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(u.PassHash), []byte(password))
-	print(err)
-	return err == nil
+	return hasher.Verify(u.PassHash, password)
+}
+
+// PasswordOutdated is a method of the `User` struct. It returns whether the password hash of the user was created
+// with an other algorithm or parameters than the configured ones, and should be replaced at the next sign in.
+func (u *User) PasswordOutdated() bool {
+	return hasher.Outdated(u.PassHash)
 }
 
 // IsActive is a method of `User` returning whether the user is enabled, confirmed and can store data
@@ -75,6 +91,19 @@ func (u *User) IsActive() bool {
 	return u.Enabled && u.Status == Confirmed
 }
 
+// Permissions is a method of the `User` struct returning the effective permissions of the user, granted by the role.
+func (u *User) Permissions() []string {
+	if u.Role == nil || u.Role.Permissions == nil {
+		return []string{}
+	}
+	return u.Role.Permissions
+}
+
+// Can is a method of the `User` struct returning whether the role of the user is granted the permission provided.
+func (u *User) Can(permission string) bool {
+	return u.Role != nil && u.Role.Can(permission)
+}
+
 // AsProfile is a method of the `User` struct. It converts a `User` object into a `Profile` object.
 func (u *User) AsProfile() Profile {
 	var r role.ProfileRole
@@ -88,13 +117,14 @@ func (u *User) AsProfile() Profile {
 	}
 
 	return Profile{
-		ID:        u.ID.String(),
-		Email:     u.Email,
-		FirstName: u.FirstName,
-		LastName:  u.LastName,
-		Role:      r,
-		Status:    string(u.Status),
-		Source:    u.Source,
+		ID:          u.ID.String(),
+		Email:       u.Email,
+		FirstName:   u.FirstName,
+		LastName:    u.LastName,
+		Role:        r,
+		Permissions: u.Permissions(),
+		Status:      string(u.Status),
+		Source:      u.Source,
 	}
 }
 
@@ -129,13 +159,14 @@ type Credentials struct {
 
 // Profile is the JSON user representation for authenticated users
 type Profile struct {
-	ID        string           `json:"id"`
-	Email     string           `json:"email" binding:"required,email,max=255"`
-	FirstName string           `json:"first_name" binding:"max=255"`
-	LastName  string           `json:"last_name" binding:"max=255"`
-	Role      role.ProfileRole `json:"role"`
-	Status    string           `json:"status" binding:"max=100"`
-	Source    string           `json:"source" binding:"max=100"`
+	ID          string           `json:"id"`
+	Email       string           `json:"email" binding:"required,email,max=255"`
+	FirstName   string           `json:"first_name" binding:"max=255"`
+	LastName    string           `json:"last_name" binding:"max=255"`
+	Role        role.ProfileRole `json:"role"`
+	Permissions []string         `json:"permissions"`
+	Status      string           `json:"status" binding:"max=100"`
+	Source      string           `json:"source" binding:"max=100"`
 }
 
 // SignupRequest is the JSON user representation for signup process
@@ -161,13 +192,17 @@ type AdminView struct {
 	Deleted   int              `json:"deleted"`
 }
 
-// Patch is the user representation for patching an admin view of the application.
+// Patch is the user representation for patching an admin view of the application. Users are enabled and disabled with
+// `SetEnabled` only, so the sessions of a disabled user are revoked.
 type Patch struct {
 	ID        string `json:"id"`
 	FirstName string `json:"first_name" binding:"max=255"`
 	LastName  string `json:"last_name" binding:"max=255"`
-	//	Role      role.ProfileRole `json:"role"` TODO: fix role patching
-	Enabled bool `json:"enabled"`
+}
+
+// SetRole is the user representation for assigning a role to a user.
+type SetRole struct {
+	RoleID string `json:"role_id" binding:"required,uuid"`
 }
 
 // SetEnabled is the user representation for enabling/disabling user authentication.
@@ -195,8 +230,10 @@ type Storer interface {
 	Patch(usr Patch) error
 	Delete(email string) error
 	SetEnabled(id uuid.UUID, enabled bool) error
+	SetRole(id uuid.UUID, roleID uuid.UUID) error
 	ByEmail(email string) (*User, error)
 	ByID(id uuid.UUID) (*User, error)
 	List() ([]User, error)
+	ListInOrganization(organizationID uuid.UUID) ([]User, error)
 	Stats() (Stats, error)
 }
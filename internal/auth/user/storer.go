@@ -1,7 +1,9 @@
 package user
 
 import (
+	"database/sql"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -86,15 +88,35 @@ func (s *PostgresStorer) attachRole(user *User) error {
 
 // List is a method of the `PostgresStorer` struct. Loads all `User` objects from persistence.
 func (s *PostgresStorer) List() ([]User, error) {
-	roleMap, err := s.mapRoles()
+	query := `SELECT user_id, email, pass_hash, first_name, last_name, role_id, enabled, status, source, created_at, deleted_at FROM microsaas.users WHERE deleted_at is null`
+	users, err := s.list(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all users: %w", err)
 	}
-	var users []User
-	query := `SELECT user_id, email, pass_hash, first_name, last_name, role_id, enabled, status, source, created_at, deleted_at FROM microsaas.users WHERE deleted_at is null`
-	err = s.db.Select(&users, query)
+	return users, nil
+}
+
+// ListInOrganization is a method of the `PostgresStorer` struct. Loads the `User` objects of the members of an
+// organization from persistence.
+func (s *PostgresStorer) ListInOrganization(organizationID uuid.UUID) ([]User, error) {
+	query := `SELECT u.user_id, u.email, u.pass_hash, u.first_name, u.last_name, u.role_id, u.enabled, u.status, u.source, u.created_at, u.deleted_at
+		FROM microsaas.users u JOIN microsaas.memberships m ON m.user_id = u.user_id
+		WHERE m.organization_id = $1 AND u.deleted_at is null`
+	users, err := s.list(query, organizationID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get all users: %w", err)
+		return nil, fmt.Errorf("failed to get users of organization: %w", err)
+	}
+	return users, nil
+}
+
+func (s *PostgresStorer) list(query string, args ...any) ([]User, error) {
+	roleMap, err := s.mapRoles()
+	if err != nil {
+		return nil, err
+	}
+	var users []User
+	if err = s.db.Select(&users, query, args...); err != nil {
+		return nil, err
 	}
 	for i := 0; i < len(users); i++ {
 		if role, ok := roleMap[users[i].RoleID.String()]; ok {
@@ -128,8 +150,8 @@ func (s *PostgresStorer) Delete(email string) error {
 
 // Patch is a method of the `PostgresStorer` struct. Takes a `Patch` and updates settings for it.
 func (s *PostgresStorer) Patch(usr Patch) error {
-	query := `UPDATE microsaas.users SET first_name = $1, last_name = $2, enabled = $3 WHERE user_id = $4`
-	_, err := s.db.Exec(query, usr.FirstName, usr.LastName, usr.Enabled, usr.ID)
+	query := `UPDATE microsaas.users SET first_name = $1, last_name = $2 WHERE user_id = $3`
+	_, err := s.db.Exec(query, usr.FirstName, usr.LastName, usr.ID)
 	if err != nil {
 		return fmt.Errorf("failed to patch user: %w", err)
 	}
@@ -146,18 +168,60 @@ func (s *PostgresStorer) SetEnabled(id uuid.UUID, enabled bool) error {
 	return nil
 }
 
+// SetRole is a method of the `PostgresStorer` struct. Takes a user ID and the ID of the role to assign to the user.
+// Returns `ErrLastAdmin` without changing the role, if the user is the last enabled one who can manage roles and the
+// role assigned can not.
+func (s *PostgresStorer) SetRole(id uuid.UUID, roleID uuid.UUID) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to set role of user: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The administrators are locked, so concurrent demotions wait for each other and see the outcome of the other.
+	var admins []uuid.UUID
+	query := `SELECT u.user_id FROM microsaas.users u JOIN microsaas.role_permissions p ON p.role_id = u.role_id
+		WHERE p.permission = $1 AND u.enabled AND u.deleted_at is null FOR UPDATE OF u`
+	if err = tx.Select(&admins, query, role.PermRolesManage); err != nil {
+		return fmt.Errorf("failed to set role of user: %w", err)
+	}
+	if len(admins) <= 1 && slices.Contains(admins, id) {
+		var keeps bool
+		query = `SELECT EXISTS (SELECT 1 FROM microsaas.role_permissions WHERE role_id = $1 AND permission = $2)`
+		if err = tx.Get(&keeps, query, roleID, role.PermRolesManage); err != nil {
+			return fmt.Errorf("failed to set role of user: %w", err)
+		}
+		if !keeps {
+			return ErrLastAdmin
+		}
+	}
+
+	res, err := tx.Exec(`UPDATE microsaas.users SET role_id = $1 WHERE user_id = $2 AND deleted_at is null`, roleID, id)
+	if err != nil {
+		return fmt.Errorf("failed to set role of user: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("failed to set role of user: %w", sql.ErrNoRows)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to set role of user: %w", err)
+	}
+	return nil
+}
+
 // Update is a method of the `PostgresStorer` struct. Takes a `User` and updates it.
 func (s *PostgresStorer) Update(usr *User) error {
 	values := map[string]interface{}{
 		"user_id":    usr.ID,
 		"first_name": usr.FirstName,
 		"last_name":  usr.LastName,
+		"pass_hash":  usr.PassHash,
 		"role_id":    usr.RoleID,
 		"enabled":    usr.Enabled,
 		"status":     usr.Status,
 		"source":     usr.Source,
 	}
-	query := "UPDATE microsaas.users SET user_id = :user_id, first_name = :first_name, last_name = :last_name, role_id = :role_id, enabled = :enabled, status = :status, source = :source WHERE user_id = :user_id"
+	query := "UPDATE microsaas.users SET user_id = :user_id, first_name = :first_name, last_name = :last_name, pass_hash = :pass_hash, role_id = :role_id, enabled = :enabled, status = :status, source = :source WHERE user_id = :user_id"
 	_, err := s.db.NamedExec(query, values)
 	if err != nil {
 		return fmt.Errorf("failed to update user: %w", err)
@@ -8,6 +8,7 @@ import (
 	"github.com/guregu/null"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/inokone/go-micro-saas/internal/auth/role"
 )
@@ -42,6 +43,11 @@ func (m *MockStorer) SetEnabled(id uuid.UUID, enabled bool) error {
 	return args.Error(0)
 }
 
+func (m *MockStorer) SetRole(id uuid.UUID, roleID uuid.UUID) error {
+	args := m.Called(id, roleID)
+	return args.Error(0)
+}
+
 func (m *MockStorer) ByEmail(email string) (*User, error) {
 	args := m.Called(email)
 	if args.Get(0) == nil {
@@ -58,6 +64,11 @@ func (m *MockStorer) ByID(id uuid.UUID) (*User, error) {
 	return args.Get(0).(*User), args.Error(1)
 }
 
+func (m *MockStorer) ListInOrganization(organizationID uuid.UUID) ([]User, error) {
+	args := m.Called(organizationID)
+	return args.Get(0).([]User), args.Error(1)
+}
+
 func (m *MockStorer) List() ([]User, error) {
 	args := m.Called()
 	return args.Get(0).([]User), args.Error(1)
@@ -125,6 +136,19 @@ func TestVerifyPasswordFailsForNonMatching(t *testing.T) {
 	assert.False(t, user.VerifyPassword("wrongpassword"))
 }
 
+func TestVerifyPasswordAcceptsLegacyBcryptHash(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("testpassword123"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	user := &User{PassHash: string(hash)}
+
+	assert.True(t, user.VerifyPassword("testpassword123"))
+	assert.True(t, user.PasswordOutdated())
+
+	err = user.SetPassword("testpassword123")
+	assert.NoError(t, err)
+	assert.False(t, user.PasswordOutdated())
+}
+
 func TestIsActiveReturnsTrueForEnabledAndConfirmedUser(t *testing.T) {
 	tests := []struct {
 		name     string
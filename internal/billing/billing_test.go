@@ -0,0 +1,123 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+// MockStorer is a mock of the Storer interface
+type MockStorer struct {
+	mock.Mock
+}
+
+func (m *MockStorer) StorePlan(plan *Plan) error {
+	args := m.Called(plan)
+	return args.Error(0)
+}
+
+func (m *MockStorer) UpdatePlan(plan *Plan) error {
+	args := m.Called(plan)
+	return args.Error(0)
+}
+
+func (m *MockStorer) PlanByID(id uuid.UUID) (*Plan, error) {
+	args := m.Called(id)
+	return args.Get(0).(*Plan), args.Error(1)
+}
+
+func (m *MockStorer) PlanByPrice(priceID string) (*Plan, error) {
+	args := m.Called(priceID)
+	return args.Get(0).(*Plan), args.Error(1)
+}
+
+func (m *MockStorer) Plans() ([]Plan, error) {
+	args := m.Called()
+	return args.Get(0).([]Plan), args.Error(1)
+}
+
+func (m *MockStorer) Store(sub *Subscription) error {
+	args := m.Called(sub)
+	return args.Error(0)
+}
+
+func (m *MockStorer) Update(sub *Subscription) error {
+	args := m.Called(sub)
+	return args.Error(0)
+}
+
+func (m *MockStorer) ByUser(userID uuid.UUID) (*Subscription, error) {
+	args := m.Called(userID)
+	return args.Get(0).(*Subscription), args.Error(1)
+}
+
+func (m *MockStorer) ByProviderID(providerID string) (*Subscription, error) {
+	args := m.Called(providerID)
+	return args.Get(0).(*Subscription), args.Error(1)
+}
+
+func TestStatusEntitled(t *testing.T) {
+	assert.True(t, Trialing.Entitled())
+	assert.True(t, Active.Entitled())
+	assert.True(t, PastDue.Entitled())
+	assert.False(t, Canceled.Entitled())
+}
+
+func TestPlanApply(t *testing.T) {
+	roleID := uuid.New()
+	p := &Plan{ID: uuid.New()}
+	p.Apply(PlanRequest{Name: "Pro", RoleID: roleID.String(), PriceID: "price_1", Amount: 900, Currency: "EUR", Interval: "month", TrialDays: 14, Active: true})
+
+	assert.Equal(t, "Pro", p.Name)
+	assert.Equal(t, roleID, p.RoleID)
+	assert.Equal(t, "eur", p.Currency)
+	assert.Equal(t, 14, p.TrialDays)
+	assert.True(t, p.Active)
+}
+
+func TestSubscriptionApply(t *testing.T) {
+	p := &Plan{ID: uuid.New()}
+	sub := NewSubscription(uuid.New(), "sub_1")
+	created := time.Now().Add(-time.Minute)
+
+	sub.Apply(&Event{Created: created, CustomerID: "cus_1", Status: Active, PeriodEnd: created.Add(30 * 24 * time.Hour)}, p)
+
+	assert.Equal(t, p.ID, sub.PlanID)
+	assert.Equal(t, Active, sub.Status)
+	assert.Equal(t, "cus_1", sub.CustomerID)
+	assert.True(t, sub.PeriodEnd.Valid)
+	assert.Equal(t, created, sub.UpdatedAt)
+
+	sub.Apply(&Event{Created: created, Status: Canceled}, p)
+	assert.False(t, sub.PeriodEnd.Valid)
+	assert.Zero(t, sub.AsView(p).PeriodEnd)
+}
+
+func TestFakeParseEvent(t *testing.T) {
+	f := NewFake("secret")
+	payload := []byte(`{"SubscriptionID":"sub_1","Status":"active"}`)
+
+	e, err := f.ParseEvent(payload, f.Sign(payload))
+	assert.NoError(t, err)
+	assert.Equal(t, "sub_1", e.SubscriptionID)
+	assert.Equal(t, Active, e.Status)
+
+	_, err = f.ParseEvent(payload, NewFake("other").Sign(payload))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestNewProviderRequiresWebhookSecret(t *testing.T) {
+	for _, name := range []string{"stripe", "fake"} {
+		_, err := NewProvider(&common.BillingConfig{Provider: name, StripeKey: "sk_test"})
+		assert.Error(t, err, name)
+	}
+
+	p, err := NewProvider(&common.BillingConfig{Provider: "fake", WebhookSecret: "secret"})
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+}
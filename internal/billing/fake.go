@@ -0,0 +1,59 @@
+package billing
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Fake is an in-memory `Provider` implementation for tests and local development. The webhook events are the JSON
+// encoded `Event` objects, signed with `Sign`.
+type Fake struct {
+	secret    string
+	mutex     sync.Mutex
+	Checkouts []Checkout
+}
+
+// NewFake creates a new `Fake` provider signing the webhook events with the secret in parameter.
+func NewFake(secret string) *Fake {
+	return &Fake{secret: secret}
+}
+
+// Checkout is a method of `Fake` recording the checkout and redirecting to the success URL right away.
+func (f *Fake) Checkout(_ context.Context, req Checkout) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.Checkouts = append(f.Checkouts, req)
+	return fmt.Sprintf("%v?session_id=%v", req.SuccessURL, uuid.NewString()), nil
+}
+
+// Portal is a method of `Fake` redirecting back to the return URL.
+func (f *Fake) Portal(_ context.Context, _ string, returnURL string) (string, error) {
+	return returnURL, nil
+}
+
+// SignatureHeader is a method of `Fake` returning the header of the webhook signature.
+func (f *Fake) SignatureHeader() string {
+	return "X-Signature"
+}
+
+// ParseEvent is a method of `Fake` verifying the signature of the payload and decoding the `Event` from it.
+func (f *Fake) ParseEvent(payload []byte, signature string) (*Event, error) {
+	if !hmac.Equal([]byte(signature), []byte(f.Sign(payload))) {
+		return nil, ErrInvalidSignature
+	}
+	var e Event
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, fmt.Errorf("failed to parse event: %w", err)
+	}
+	return &e, nil
+}
+
+// Sign is a method of `Fake` returning the signature of a webhook payload.
+func (f *Fake) Sign(payload []byte) string {
+	return sign(f.secret, string(payload))
+}
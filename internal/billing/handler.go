@@ -0,0 +1,452 @@
+package billing
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cskr/pubsub/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inokone/go-micro-saas/internal/auth/role"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+	"github.com/inokone/go-micro-saas/internal/invoice"
+)
+
+// Invoicer is the interface for issuing the invoices of the subscription periods.
+type Invoicer interface {
+	Subscription(usr *user.User, subscriptionID uuid.UUID, periodEnd time.Time, currency string, lines ...invoice.Line) error
+}
+
+// Handler is a struct for web handles related to subscription plans and the billing of the subscriptions.
+type Handler struct {
+	subs     Storer
+	users    user.Storer
+	roles    role.Storer
+	provider Provider
+	invoices Invoicer
+	config   *common.AuthConfig
+	ps       *pubsub.PubSub[string, common.Event]
+}
+
+// NewHandler creates a new `Handler`, based on the plan, subscription, user and role persistence, the payment provider,
+// the issuer of invoices and the publisher of history events. Without a payment provider the checkout and the webhook
+// are disabled.
+func NewHandler(subs Storer, users user.Storer, roles role.Storer, provider Provider, invoices Invoicer, config *common.AuthConfig, ps *pubsub.PubSub[string, common.Event]) *Handler {
+	return &Handler{
+		subs:     subs,
+		users:    users,
+		roles:    roles,
+		provider: provider,
+		invoices: invoices,
+		config:   config,
+		ps:       ps,
+	}
+}
+
+// Plans lists the subscription plans. Users see the active plans, billing administrators see all of them.
+// @Summary List plans endpoint
+// @Schemes
+// @Description Lists the subscription plans
+// @Accept json
+// @Produce json
+// @Success 200 {array} billing.PlanView
+// @Failure 500 {object} common.StatusMessage
+// @Router /billing/plans [get]
+func (h *Handler) Plans(g *gin.Context) {
+	usr := currentUser(g)
+	plans, err := h.subs.Plans()
+	if err != nil {
+		log.WithError(err).Error("Failed to list plans")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+		return
+	}
+	res := make([]PlanView, 0)
+	for _, p := range plans {
+		if p.Active || usr.Can(role.PermBillingManage) {
+			res = append(res, p.AsView())
+		}
+	}
+	g.JSON(http.StatusOK, res)
+}
+
+// CreatePlan creates a new subscription plan for a price at the payment provider.
+// @Summary Plan create endpoint
+// @Schemes
+// @Description Creates a new subscription plan
+// @Accept json
+// @Produce json
+// @Param data body billing.PlanRequest true "The settings of the plan"
+// @Success 201 {object} billing.PlanView
+// @Failure 400 {object} common.StatusMessage
+// @Router /billing/plans [post]
+func (h *Handler) CreatePlan(g *gin.Context) {
+	var in PlanRequest
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+	if !h.validRole(g, in.RoleID) {
+		return
+	}
+	p := &Plan{ID: uuid.New(), CreatedAt: time.Now()}
+	p.Apply(in)
+	if err := h.subs.StorePlan(p); err != nil {
+		log.WithError(err).Error("Failed to store plan")
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Plan name or price is already in use!"})
+		return
+	}
+	g.JSON(http.StatusCreated, p.AsView())
+}
+
+// UpdatePlan updates the settings of a subscription plan. Deactivated plans are not offered for new subscriptions.
+// @Summary Plan update endpoint
+// @Schemes
+// @Description Updates a subscription plan
+// @Accept json
+// @Produce json
+// @Param id path string true "ID of the plan"
+// @Param data body billing.PlanRequest true "The settings of the plan"
+// @Success 200 {object} billing.PlanView
+// @Failure 400 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Router /billing/plans/:id [put]
+func (h *Handler) UpdatePlan(g *gin.Context) {
+	var in PlanRequest
+	id, err := uuid.Parse(g.Param("id"))
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "Plan not found!"})
+		return
+	}
+	p, err := h.subs.PlanByID(id)
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "Plan not found!"})
+		return
+	}
+	if err = g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+	if !h.validRole(g, in.RoleID) {
+		return
+	}
+	p.Apply(in)
+	if err = h.subs.UpdatePlan(p); err != nil {
+		log.WithError(err).Error("Failed to update plan")
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Plan name or price is already in use!"})
+		return
+	}
+	g.JSON(http.StatusOK, p.AsView())
+}
+
+// Subscription retrieves the latest subscription of the current user.
+// @Summary Subscription endpoint
+// @Schemes
+// @Description Gets the subscription of the current user
+// @Accept json
+// @Produce json
+// @Success 200 {object} billing.SubscriptionView
+// @Failure 404 {object} common.StatusMessage
+// @Router /billing/subscription [get]
+func (h *Handler) Subscription(g *gin.Context) {
+	usr := currentUser(g)
+	sub, err := h.subs.ByUser(usr.ID)
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "Subscription not found!"})
+		return
+	}
+	p, err := h.subs.PlanByID(sub.PlanID)
+	if err != nil {
+		log.WithError(err).Error("Failed to get plan of subscription")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+		return
+	}
+	g.JSON(http.StatusOK, sub.AsView(p))
+}
+
+// Checkout starts the subscription to a plan on the hosted checkout page of the payment provider. The free trial of the
+// plan is only offered to users never subscribed before.
+// @Summary Checkout endpoint
+// @Schemes
+// @Description Creates a checkout session at the payment provider, returns the URL to redirect to
+// @Accept json
+// @Produce json
+// @Param data body billing.CheckoutRequest true "The plan to subscribe to"
+// @Success 200 {object} billing.Redirect
+// @Failure 400 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 503 {object} common.StatusMessage
+// @Router /billing/checkout [post]
+func (h *Handler) Checkout(g *gin.Context) {
+	var in CheckoutRequest
+	if !h.enabled(g) {
+		return
+	}
+	usr := currentUser(g)
+	if err := g.ShouldBindJSON(&in); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.ValidationMessage(err))
+		return
+	}
+	p, err := h.subs.PlanByID(uuid.MustParse(in.PlanID))
+	if err != nil || !p.Active {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "Plan not found!"})
+		return
+	}
+
+	req := Checkout{
+		UserID:     usr.ID,
+		Email:      usr.Email,
+		PriceID:    p.PriceID,
+		TrialDays:  p.TrialDays,
+		SuccessURL: h.config.FrontendRoot + "/billing/success",
+		CancelURL:  h.config.FrontendRoot + "/billing",
+	}
+	sub, err := h.subs.ByUser(usr.ID)
+	switch {
+	case err == nil && sub.Status.Entitled():
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Already subscribed, change the plan in the customer portal!"})
+		return
+	case err == nil:
+		req.CustomerID = sub.CustomerID
+		req.TrialDays = 0
+	case !errors.Is(err, sql.ErrNoRows):
+		log.WithError(err).Error("Failed to get subscription of user")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+		return
+	}
+
+	url, err := h.provider.Checkout(g.Request.Context(), req)
+	if err != nil {
+		log.WithError(err).Error("Failed to create checkout session")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+		return
+	}
+	g.JSON(http.StatusOK, Redirect{URL: url})
+}
+
+// Portal opens the customer portal of the payment provider, where the user can change or cancel the subscription.
+// @Summary Customer portal endpoint
+// @Schemes
+// @Description Creates a customer portal session at the payment provider, returns the URL to redirect to
+// @Accept json
+// @Produce json
+// @Success 200 {object} billing.Redirect
+// @Failure 404 {object} common.StatusMessage
+// @Failure 503 {object} common.StatusMessage
+// @Router /billing/portal [post]
+func (h *Handler) Portal(g *gin.Context) {
+	if !h.enabled(g) {
+		return
+	}
+	usr := currentUser(g)
+	sub, err := h.subs.ByUser(usr.ID)
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "Subscription not found!"})
+		return
+	}
+	url, err := h.provider.Portal(g.Request.Context(), sub.CustomerID, h.config.FrontendRoot+"/billing")
+	if err != nil {
+		log.WithError(err).Error("Failed to create customer portal session")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+		return
+	}
+	g.JSON(http.StatusOK, Redirect{URL: url})
+}
+
+// Webhook receives the signed events of the payment provider. Changes of a subscription are mirrored to the
+// subscription of the user, the user gets the role of the plan while entitled to it, and the default role when the
+// subscription is canceled. The paid periods of active subscriptions are invoiced. Events older than the last recorded change are ignored, as the provider does not guarantee
+// the order of delivery. Errors are reported with status 500, so the provider retries the event.
+// @Summary Billing webhook endpoint
+// @Schemes
+// @Description Processes the webhook events of the payment provider
+// @Accept json
+// @Produce json
+// @Success 200 {object} common.StatusMessage
+// @Failure 400 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Failure 500 {object} common.StatusMessage
+// @Router /billing/webhook [post]
+func (h *Handler) Webhook(g *gin.Context) {
+	if h.provider == nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "Billing is not configured!"})
+		return
+	}
+	payload, err := g.GetRawData()
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Invalid event!"})
+		return
+	}
+	e, err := h.provider.ParseEvent(payload, g.GetHeader(h.provider.SignatureHeader()))
+	if err != nil {
+		log.WithError(err).Warn("Invalid billing webhook event")
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Invalid event!"})
+		return
+	}
+	if !e.IsSubscription() {
+		g.JSON(http.StatusOK, common.StatusMessage{Message: "Event ignored."})
+		return
+	}
+	p, err := h.subs.PlanByPrice(e.PriceID)
+	if err != nil {
+		log.WithError(err).WithField("price", e.PriceID).Warn("Subscription to a price without plan")
+		g.JSON(http.StatusOK, common.StatusMessage{Message: "Event ignored."})
+		return
+	}
+
+	sub, err := h.subs.ByProviderID(e.SubscriptionID)
+	isNew := errors.Is(err, sql.ErrNoRows)
+	switch {
+	case err == nil && sub.UpdatedAt.After(e.Created):
+		g.JSON(http.StatusOK, common.StatusMessage{Message: "Event ignored."})
+		return
+	case isNew && !e.UserID.Valid:
+		log.WithField("subscription", e.SubscriptionID).Warn("Subscription without user")
+		g.JSON(http.StatusOK, common.StatusMessage{Message: "Event ignored."})
+		return
+	case isNew:
+		sub = NewSubscription(e.UserID.UUID, e.SubscriptionID)
+	case err != nil:
+		h.failed(g, err, "Failed to get subscription")
+		return
+	}
+
+	from := sub.Status
+	sub.Apply(e, p)
+	if isNew {
+		err = h.subs.Store(sub)
+	} else {
+		err = h.subs.Update(sub)
+	}
+	if err != nil {
+		h.failed(g, err, "Failed to store subscription")
+		return
+	}
+	if err = h.entitle(sub, p); err != nil {
+		h.failed(g, err, "Failed to update role of subscriber")
+		return
+	}
+	if err = h.invoice(sub, p); err != nil {
+		h.failed(g, err, "Failed to issue invoice")
+		return
+	}
+
+	h.ps.Pub(common.Event{
+		ID:   uuid.New(),
+		Type: common.SubscriptionChanged,
+		Time: time.Now(),
+		Data: common.SubscriptionData{Plan: p.ID.String(), PlanName: p.Name, From: string(from), Status: string(sub.Status)},
+		User: sub.UserID,
+	}, common.HistoryTopic)
+	g.JSON(http.StatusOK, common.StatusMessage{Message: "Event processed."})
+}
+
+// entitle assigns the role matching the state of the subscription to the subscriber. Only the roles managed by the
+// subscriptions, the default role and the roles of the plans, are replaced, so the role of an administrator is kept.
+func (h *Handler) entitle(sub *Subscription, p *Plan) error {
+	if !sub.Status.Entitled() && sub.Status != Canceled {
+		return nil
+	}
+	usr, err := h.users.ByID(sub.UserID)
+	if err != nil {
+		return err
+	}
+	def, err := h.roles.Default()
+	if err != nil {
+		return err
+	}
+	managed, err := h.managed(usr.RoleID, def.ID)
+	if err != nil || !managed {
+		return err
+	}
+
+	assigned := def
+	if sub.Status.Entitled() {
+		if assigned, err = h.roles.ByID(p.RoleID); err != nil {
+			return err
+		}
+	}
+	if assigned.ID == usr.RoleID {
+		return nil
+	}
+	if err = h.users.SetRole(usr.ID, assigned.ID); err != nil {
+		return err
+	}
+
+	change := common.RoleChangeData{From: usr.RoleID.String(), To: assigned.ID.String(), ToName: assigned.DisplayName}
+	if usr.Role != nil {
+		change.FromName = usr.Role.DisplayName
+	}
+	h.ps.Pub(common.Event{
+		ID:   uuid.New(),
+		Type: common.RoleChanged,
+		Time: time.Now(),
+		Data: change,
+		User: usr.ID,
+	}, common.HistoryTopic)
+	return nil
+}
+
+// invoice issues the invoice of the current period of an active subscription of a paid plan.
+func (h *Handler) invoice(sub *Subscription, p *Plan) error {
+	if h.invoices == nil || sub.Status != Active || !sub.PeriodEnd.Valid || p.Amount == 0 {
+		return nil
+	}
+	usr, err := h.users.ByID(sub.UserID)
+	if err != nil {
+		return err
+	}
+	return h.invoices.Subscription(usr, sub.ID, sub.PeriodEnd.Time, p.Currency, invoice.Line{
+		Description: fmt.Sprintf("%v plan, %vly subscription until %v", p.Name, p.Interval, sub.PeriodEnd.Time.Format("2006-01-02")),
+		Quantity:    1,
+		UnitAmount:  p.Amount,
+	})
+}
+
+// managed returns whether the role in parameter is the default role or the role of a plan.
+func (h *Handler) managed(roleID uuid.UUID, defaultID uuid.UUID) (bool, error) {
+	if roleID == defaultID {
+		return true, nil
+	}
+	plans, err := h.subs.Plans()
+	if err != nil {
+		return false, err
+	}
+	for _, p := range plans {
+		if p.RoleID == roleID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (h *Handler) validRole(g *gin.Context, id string) bool {
+	if _, err := h.roles.ByID(uuid.MustParse(id)); err != nil {
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Role not found!"})
+		return false
+	}
+	return true
+}
+
+func (h *Handler) enabled(g *gin.Context) bool {
+	if h.provider == nil {
+		g.AbortWithStatusJSON(http.StatusServiceUnavailable, common.StatusMessage{Message: "Billing is not configured!"})
+		return false
+	}
+	return true
+}
+
+func (h *Handler) failed(g *gin.Context, err error, message string) {
+	log.WithError(err).Error(message)
+	g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+}
+
+func currentUser(g *gin.Context) *user.User {
+	u, _ := g.Get("user")
+	return u.(*user.User)
+}
@@ -0,0 +1,313 @@
+package billing
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cskr/pubsub/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/role"
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+	"github.com/inokone/go-micro-saas/internal/invoice"
+)
+
+// MockUserStorer is a mock of the user.Storer interface, implementing the methods the billing needs.
+type MockUserStorer struct {
+	mock.Mock
+	user.Storer
+}
+
+func (m *MockUserStorer) ByID(id uuid.UUID) (*user.User, error) {
+	args := m.Called(id)
+	return args.Get(0).(*user.User), args.Error(1)
+}
+
+func (m *MockUserStorer) SetRole(id uuid.UUID, roleID uuid.UUID) error {
+	args := m.Called(id, roleID)
+	return args.Error(0)
+}
+
+// MockRoleStorer is a mock of the role.Storer interface, implementing the methods the billing needs.
+type MockRoleStorer struct {
+	mock.Mock
+	role.Storer
+}
+
+func (m *MockRoleStorer) ByID(id uuid.UUID) (*role.Role, error) {
+	args := m.Called(id)
+	return args.Get(0).(*role.Role), args.Error(1)
+}
+
+func (m *MockRoleStorer) Default() (*role.Role, error) {
+	args := m.Called()
+	return args.Get(0).(*role.Role), args.Error(1)
+}
+
+// MockInvoicer is a mock of the Invoicer interface
+type MockInvoicer struct {
+	mock.Mock
+}
+
+func (m *MockInvoicer) Subscription(usr *user.User, subscriptionID uuid.UUID, periodEnd time.Time, currency string, lines ...invoice.Line) error {
+	args := m.Called(usr, subscriptionID, periodEnd, currency, lines)
+	return args.Error(0)
+}
+
+var (
+	freeRole = &role.Role{ID: uuid.New(), DisplayName: "Free"}
+	proRole  = &role.Role{ID: uuid.New(), DisplayName: "Pro"}
+	proPlan  = &Plan{ID: uuid.New(), Name: "Pro", RoleID: proRole.ID, PriceID: "price_pro", Active: true, TrialDays: 14}
+)
+
+func subscriber(r *role.Role) *user.User {
+	return &user.User{ID: uuid.New(), Email: "test@example.com", Role: r, RoleID: r.ID, Enabled: true}
+}
+
+func webhook(h *Handler, f *Fake, e Event) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/billing/webhook", h.Webhook)
+	payload, _ := json.Marshal(e)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/billing/webhook", bytes.NewReader(payload))
+	req.Header.Set(f.SignatureHeader(), f.Sign(payload))
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func asUser(handler gin.HandlerFunc, usr *user.User, method string, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(method, "/", func(g *gin.Context) {
+		g.Set("user", usr)
+	}, handler)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, "/", bytes.NewBufferString(body))
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestWebhookCreatesSubscriptionAndAssignsRoleOfPlan(t *testing.T) {
+	subs, users, roles, f := new(MockStorer), new(MockUserStorer), new(MockRoleStorer), NewFake("secret")
+	ps := pubsub.New[string, common.Event](2)
+	ch := ps.Sub(common.HistoryTopic)
+	h := NewHandler(subs, users, roles, f, nil, &common.AuthConfig{}, ps)
+	usr := subscriber(freeRole)
+
+	subs.On("PlanByPrice", "price_pro").Return(proPlan, nil)
+	subs.On("ByProviderID", "sub_1").Return((*Subscription)(nil), fmt.Errorf("failed to get subscription: %w", sql.ErrNoRows))
+	subs.On("Store", mock.MatchedBy(func(s *Subscription) bool {
+		return s.UserID == usr.ID && s.PlanID == proPlan.ID && s.Status == Trialing && s.CustomerID == "cus_1"
+	})).Return(nil)
+	users.On("ByID", usr.ID).Return(usr, nil)
+	roles.On("Default").Return(freeRole, nil)
+	roles.On("ByID", proRole.ID).Return(proRole, nil)
+	users.On("SetRole", usr.ID, proRole.ID).Return(nil)
+
+	w := webhook(h, f, Event{
+		Created:        time.Now(),
+		SubscriptionID: "sub_1",
+		CustomerID:     "cus_1",
+		PriceID:        "price_pro",
+		UserID:         uuid.NullUUID{UUID: usr.ID, Valid: true},
+		Status:         Trialing,
+	})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	subs.AssertExpectations(t)
+	users.AssertExpectations(t)
+	event := <-ch
+	assert.Equal(t, common.RoleChanged, event.Type)
+	assert.Equal(t, common.RoleChangeData{From: freeRole.ID.String(), FromName: "Free", To: proRole.ID.String(), ToName: "Pro"}, event.Data)
+	assert.False(t, event.Actor.Valid)
+	event = <-ch
+	assert.Equal(t, common.SubscriptionChanged, event.Type)
+	assert.Equal(t, usr.ID, event.User)
+	assert.Equal(t, common.SubscriptionData{Plan: proPlan.ID.String(), PlanName: "Pro", Status: "trialing"}, event.Data)
+}
+
+func TestWebhookCancellationRestoresDefaultRole(t *testing.T) {
+	subs, users, roles, f := new(MockStorer), new(MockUserStorer), new(MockRoleStorer), NewFake("secret")
+	ps := pubsub.New[string, common.Event](2)
+	ch := ps.Sub(common.HistoryTopic)
+	h := NewHandler(subs, users, roles, f, nil, &common.AuthConfig{}, ps)
+	usr := subscriber(proRole)
+	sub := NewSubscription(usr.ID, "sub_1")
+	sub.Status = Active
+	sub.UpdatedAt = time.Now().Add(-time.Hour)
+
+	subs.On("PlanByPrice", "price_pro").Return(proPlan, nil)
+	subs.On("ByProviderID", "sub_1").Return(sub, nil)
+	subs.On("Update", sub).Return(nil)
+	subs.On("Plans").Return([]Plan{*proPlan}, nil)
+	users.On("ByID", usr.ID).Return(usr, nil)
+	roles.On("Default").Return(freeRole, nil)
+	users.On("SetRole", usr.ID, freeRole.ID).Return(nil)
+
+	w := webhook(h, f, Event{Created: time.Now(), SubscriptionID: "sub_1", PriceID: "price_pro", Status: Canceled})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, Canceled, sub.Status)
+	users.AssertExpectations(t)
+	<-ch
+	event := <-ch
+	assert.Equal(t, common.SubscriptionData{Plan: proPlan.ID.String(), PlanName: "Pro", From: "active", Status: "canceled"}, event.Data)
+}
+
+func TestWebhookKeepsRoleNotManagedByPlans(t *testing.T) {
+	subs, users, roles, f := new(MockStorer), new(MockUserStorer), new(MockRoleStorer), NewFake("secret")
+	h := NewHandler(subs, users, roles, f, nil, &common.AuthConfig{}, pubsub.New[string, common.Event](1))
+	usr := subscriber(&role.Role{ID: uuid.New(), DisplayName: "Admin", Permissions: []string{role.PermRolesManage}})
+	sub := NewSubscription(usr.ID, "sub_1")
+
+	subs.On("PlanByPrice", "price_pro").Return(proPlan, nil)
+	subs.On("ByProviderID", "sub_1").Return(sub, nil)
+	subs.On("Update", sub).Return(nil)
+	subs.On("Plans").Return([]Plan{*proPlan}, nil)
+	users.On("ByID", usr.ID).Return(usr, nil)
+	roles.On("Default").Return(freeRole, nil)
+
+	w := webhook(h, f, Event{Created: time.Now(), SubscriptionID: "sub_1", PriceID: "price_pro", Status: Canceled})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	users.AssertNotCalled(t, "SetRole", mock.Anything, mock.Anything)
+}
+
+func TestWebhookInvoicesActivePeriod(t *testing.T) {
+	subs, users, roles, invoices, f := new(MockStorer), new(MockUserStorer), new(MockRoleStorer), new(MockInvoicer), NewFake("secret")
+	h := NewHandler(subs, users, roles, f, invoices, &common.AuthConfig{}, pubsub.New[string, common.Event](1))
+	usr := subscriber(proRole)
+	sub := NewSubscription(usr.ID, "sub_1")
+	sub.Status = Trialing
+	plan := *proPlan
+	plan.Amount, plan.Currency, plan.Interval = 900, "eur", "month"
+	periodEnd := time.Date(2026, 11, 16, 0, 0, 0, 0, time.UTC)
+
+	subs.On("PlanByPrice", "price_pro").Return(&plan, nil)
+	subs.On("ByProviderID", "sub_1").Return(sub, nil)
+	subs.On("Update", sub).Return(nil)
+	subs.On("Plans").Return([]Plan{plan}, nil)
+	users.On("ByID", usr.ID).Return(usr, nil)
+	roles.On("Default").Return(freeRole, nil)
+	roles.On("ByID", proRole.ID).Return(proRole, nil)
+	invoices.On("Subscription", usr, sub.ID, periodEnd, "eur", []invoice.Line{
+		{Description: "Pro plan, monthly subscription until 2026-11-16", Quantity: 1, UnitAmount: 900},
+	}).Return(nil)
+
+	w := webhook(h, f, Event{Created: time.Now(), SubscriptionID: "sub_1", PriceID: "price_pro", Status: Active, PeriodEnd: periodEnd})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	invoices.AssertExpectations(t)
+}
+
+func TestWebhookIgnoresOutdatedEvent(t *testing.T) {
+	subs, f := new(MockStorer), NewFake("secret")
+	h := NewHandler(subs, nil, nil, f, nil, &common.AuthConfig{}, nil)
+	sub := NewSubscription(uuid.New(), "sub_1")
+	sub.UpdatedAt = time.Now()
+
+	subs.On("PlanByPrice", "price_pro").Return(proPlan, nil)
+	subs.On("ByProviderID", "sub_1").Return(sub, nil)
+
+	w := webhook(h, f, Event{Created: time.Now().Add(-time.Minute), SubscriptionID: "sub_1", PriceID: "price_pro", Status: Canceled})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	subs.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestWebhook400ForInvalidSignature(t *testing.T) {
+	subs := new(MockStorer)
+	h := NewHandler(subs, nil, nil, NewFake("secret"), nil, &common.AuthConfig{}, nil)
+
+	w := webhook(h, NewFake("other"), Event{SubscriptionID: "sub_1"})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	subs.AssertNotCalled(t, "PlanByPrice", mock.Anything)
+}
+
+func TestCheckoutOffersTrialToNewSubscriber(t *testing.T) {
+	subs, f := new(MockStorer), NewFake("secret")
+	h := NewHandler(subs, nil, nil, f, nil, &common.AuthConfig{FrontendRoot: "http://localhost"}, nil)
+	usr := subscriber(freeRole)
+
+	subs.On("PlanByID", proPlan.ID).Return(proPlan, nil)
+	subs.On("ByUser", usr.ID).Return((*Subscription)(nil), fmt.Errorf("failed to get subscription: %w", sql.ErrNoRows))
+
+	w := asUser(h.Checkout, usr, http.MethodPost, fmt.Sprintf(`{"plan_id":"%v"}`, proPlan.ID))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var res Redirect
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Contains(t, res.URL, "http://localhost/billing/success?session_id=")
+	assert.Len(t, f.Checkouts, 1)
+	assert.Equal(t, 14, f.Checkouts[0].TrialDays)
+	assert.Equal(t, "test@example.com", f.Checkouts[0].Email)
+}
+
+func TestCheckoutReusesCustomerWithoutTrial(t *testing.T) {
+	subs, f := new(MockStorer), NewFake("secret")
+	h := NewHandler(subs, nil, nil, f, nil, &common.AuthConfig{}, nil)
+	usr := subscriber(freeRole)
+	sub := NewSubscription(usr.ID, "sub_1")
+	sub.Status = Canceled
+	sub.CustomerID = "cus_1"
+
+	subs.On("PlanByID", proPlan.ID).Return(proPlan, nil)
+	subs.On("ByUser", usr.ID).Return(sub, nil)
+
+	w := asUser(h.Checkout, usr, http.MethodPost, fmt.Sprintf(`{"plan_id":"%v"}`, proPlan.ID))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "cus_1", f.Checkouts[0].CustomerID)
+	assert.Zero(t, f.Checkouts[0].TrialDays)
+}
+
+func TestCheckout400ForActiveSubscription(t *testing.T) {
+	subs, f := new(MockStorer), NewFake("secret")
+	h := NewHandler(subs, nil, nil, f, nil, &common.AuthConfig{}, nil)
+	usr := subscriber(proRole)
+	sub := NewSubscription(usr.ID, "sub_1")
+	sub.Status = PastDue
+
+	subs.On("PlanByID", proPlan.ID).Return(proPlan, nil)
+	subs.On("ByUser", usr.ID).Return(sub, nil)
+
+	w := asUser(h.Checkout, usr, http.MethodPost, fmt.Sprintf(`{"plan_id":"%v"}`, proPlan.ID))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, f.Checkouts)
+}
+
+func TestCheckout503WithoutProvider(t *testing.T) {
+	h := NewHandler(new(MockStorer), nil, nil, nil, nil, &common.AuthConfig{}, nil)
+
+	w := asUser(h.Checkout, subscriber(freeRole), http.MethodPost, `{}`)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestPlansHidesInactivePlansFromUsers(t *testing.T) {
+	subs := new(MockStorer)
+	h := NewHandler(subs, nil, nil, nil, nil, &common.AuthConfig{}, nil)
+	legacy := Plan{ID: uuid.New(), Name: "Legacy", RoleID: proRole.ID}
+
+	subs.On("Plans").Return([]Plan{*proPlan, legacy}, nil)
+
+	w := asUser(h.Plans, subscriber(freeRole), http.MethodGet, "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var res []PlanView
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, []PlanView{proPlan.AsView()}, res)
+}
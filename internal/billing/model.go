@@ -0,0 +1,179 @@
+package billing
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+)
+
+// Status is the status of a subscription.
+type Status string
+
+const (
+	// Trialing is the status of a subscription in its free trial period.
+	Trialing Status = "trialing"
+	// Active is the status of a paid subscription.
+	Active Status = "active"
+	// PastDue is the status of a subscription with a failed payment, the user keeps the plan until it is canceled.
+	PastDue Status = "past_due"
+	// Canceled is the status of an ended subscription.
+	Canceled Status = "canceled"
+)
+
+// Entitled is a method of `Status` returning whether the subscriber is entitled to the role of the plan.
+func (s Status) Entitled() bool {
+	return s == Trialing || s == Active || s == PastDue
+}
+
+// Plan is a struct representing a subscription plan for database storage. The subscribers of the plan get the role of
+// the plan, with its permissions and limits.
+type Plan struct {
+	ID        uuid.UUID `db:"plan_id"`
+	Name      string    `db:"name"`
+	RoleID    uuid.UUID `db:"role_id"`
+	PriceID   string    `db:"price_id"`
+	Amount    int       `db:"amount"`
+	Currency  string    `db:"currency"`
+	Interval  string    `db:"billing_interval"`
+	TrialDays int       `db:"trial_days"`
+	Active    bool      `db:"active"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// AsView is a method of the `Plan` struct. It converts a `Plan` object into a `PlanView` object.
+func (p *Plan) AsView() PlanView {
+	return PlanView{
+		ID:        p.ID.String(),
+		Name:      p.Name,
+		RoleID:    p.RoleID.String(),
+		PriceID:   p.PriceID,
+		Amount:    p.Amount,
+		Currency:  p.Currency,
+		Interval:  p.Interval,
+		TrialDays: p.TrialDays,
+		Active:    p.Active,
+	}
+}
+
+// Apply is a method of the `Plan` struct. Sets the fields of the plan from the request in parameter.
+func (p *Plan) Apply(in PlanRequest) {
+	p.Name = in.Name
+	p.RoleID = uuid.MustParse(in.RoleID)
+	p.PriceID = in.PriceID
+	p.Amount = in.Amount
+	p.Currency = strings.ToLower(in.Currency)
+	p.Interval = in.Interval
+	p.TrialDays = in.TrialDays
+	p.Active = in.Active
+}
+
+// PlanView is the JSON representation of a subscription plan.
+type PlanView struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	RoleID    string `json:"role_id"`
+	PriceID   string `json:"price_id"`
+	Amount    int    `json:"amount"`
+	Currency  string `json:"currency"`
+	Interval  string `json:"interval"`
+	TrialDays int    `json:"trial_days"`
+	Active    bool   `json:"active"`
+}
+
+// PlanRequest is a struct for the message body of creating or updating a subscription plan. The amount is in the
+// smallest unit of the currency, e.g. cents.
+type PlanRequest struct {
+	Name      string `json:"name" binding:"required,max=100"`
+	RoleID    string `json:"role_id" binding:"required,uuid"`
+	PriceID   string `json:"price_id" binding:"required,max=255"`
+	Amount    int    `json:"amount" binding:"min=0"`
+	Currency  string `json:"currency" binding:"required,len=3"`
+	Interval  string `json:"interval" binding:"required,oneof=month year"`
+	TrialDays int    `json:"trial_days" binding:"min=0"`
+	Active    bool   `json:"active"`
+}
+
+// Subscription is a struct representing the subscription of a user to a plan for database storage, mirroring the
+// subscription at the payment provider.
+type Subscription struct {
+	ID                uuid.UUID `db:"subscription_id"`
+	UserID            uuid.UUID `db:"user_id"`
+	PlanID            uuid.UUID `db:"plan_id"`
+	Status            Status    `db:"status"`
+	CustomerID        string    `db:"customer_id"`
+	ProviderID        string    `db:"provider_id"`
+	PeriodEnd         null.Time `db:"current_period_end"`
+	CancelAtPeriodEnd bool      `db:"cancel_at_period_end"`
+	CreatedAt         time.Time `db:"created_at"`
+	UpdatedAt         time.Time `db:"updated_at"`
+}
+
+// NewSubscription creates a new `Subscription` of the user for the subscription at the payment provider.
+func NewSubscription(userID uuid.UUID, providerID string) *Subscription {
+	return &Subscription{
+		ID:         uuid.New(),
+		UserID:     userID,
+		ProviderID: providerID,
+		CreatedAt:  time.Now(),
+	}
+}
+
+// Apply is a method of the `Subscription` struct. Updates the subscription with the state reported in the webhook
+// event of the payment provider.
+func (s *Subscription) Apply(e *Event, plan *Plan) {
+	s.PlanID = plan.ID
+	s.Status = e.Status
+	s.CustomerID = e.CustomerID
+	s.PeriodEnd = null.NewTime(e.PeriodEnd, !e.PeriodEnd.IsZero())
+	s.CancelAtPeriodEnd = e.CancelAtPeriodEnd
+	s.UpdatedAt = e.Created
+}
+
+// AsView is a method of the `Subscription` struct. It converts a `Subscription` object of the plan provided into a
+// `SubscriptionView` object.
+func (s *Subscription) AsView(plan *Plan) SubscriptionView {
+	v := SubscriptionView{
+		ID:                s.ID.String(),
+		Plan:              plan.AsView(),
+		Status:            string(s.Status),
+		CancelAtPeriodEnd: s.CancelAtPeriodEnd,
+	}
+	if s.PeriodEnd.Valid {
+		v.PeriodEnd = int(s.PeriodEnd.Time.Unix())
+	}
+	return v
+}
+
+// SubscriptionView is the JSON representation of the subscription of the current user.
+type SubscriptionView struct {
+	ID                string   `json:"id"`
+	Plan              PlanView `json:"plan"`
+	Status            string   `json:"status"`
+	PeriodEnd         int      `json:"period_end,omitempty"`
+	CancelAtPeriodEnd bool     `json:"cancel_at_period_end"`
+}
+
+// CheckoutRequest is a struct for the message body of starting the checkout of a plan.
+type CheckoutRequest struct {
+	PlanID string `json:"plan_id" binding:"required,uuid"`
+}
+
+// Redirect is the JSON representation of a page of the payment provider to redirect the user to.
+type Redirect struct {
+	URL string `json:"url"`
+}
+
+// Storer is the interface for `Plan` and `Subscription` persistence
+type Storer interface {
+	StorePlan(plan *Plan) error
+	UpdatePlan(plan *Plan) error
+	PlanByID(id uuid.UUID) (*Plan, error)
+	PlanByPrice(priceID string) (*Plan, error)
+	Plans() ([]Plan, error)
+	Store(sub *Subscription) error
+	Update(sub *Subscription) error
+	ByUser(userID uuid.UUID) (*Subscription, error)
+	ByProviderID(providerID string) (*Subscription, error)
+}
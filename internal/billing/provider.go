@@ -0,0 +1,79 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+// ErrInvalidSignature is the error for a webhook request not signed by the payment provider.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Provider is the interface of the payment providers collecting the subscription fees.
+type Provider interface {
+	// Checkout creates a hosted checkout page of the provider for subscribing to a plan, returns the URL of the page.
+	Checkout(ctx context.Context, req Checkout) (string, error)
+	// Portal creates a session of the customer portal of the provider, where the customer can change or cancel the
+	// subscription and update the payment details. Returns the URL of the portal.
+	Portal(ctx context.Context, customerID string, returnURL string) (string, error)
+	// ParseEvent verifies the signature of a webhook request and parses its payload.
+	ParseEvent(payload []byte, signature string) (*Event, error)
+	// SignatureHeader is the name of the HTTP header carrying the signature of the webhook requests.
+	SignatureHeader() string
+}
+
+// Checkout is a struct with the parameters of a checkout at the payment provider.
+type Checkout struct {
+	UserID     uuid.UUID
+	Email      string
+	CustomerID string
+	PriceID    string
+	TrialDays  int
+	SuccessURL string
+	CancelURL  string
+}
+
+// Event is a struct representing a webhook event of the payment provider. Events not about subscriptions have no
+// subscription ID.
+type Event struct {
+	ID                string
+	Created           time.Time
+	SubscriptionID    string
+	CustomerID        string
+	PriceID           string
+	UserID            uuid.NullUUID
+	Status            Status
+	PeriodEnd         time.Time
+	CancelAtPeriodEnd bool
+}
+
+// IsSubscription is a method of `Event` returning whether the event is a change of a subscription.
+func (e *Event) IsSubscription() bool {
+	return e.SubscriptionID != ""
+}
+
+// NewProvider is a function creating the payment provider set in the configuration. Returns nil when billing is not
+// configured.
+func NewProvider(c *common.BillingConfig) (Provider, error) {
+	switch c.Provider {
+	case "":
+		return nil, nil
+	case "stripe":
+		if c.StripeKey == "" || c.WebhookSecret == "" {
+			return nil, errors.New("secret key and webhook secret of Stripe are required")
+		}
+		return NewStripe(c.StripeKey, c.WebhookSecret), nil
+	case "fake":
+		if c.WebhookSecret == "" {
+			return nil, errors.New("webhook secret of the fake provider is required")
+		}
+		return NewFake(c.WebhookSecret), nil
+	default:
+		return nil, fmt.Errorf("unknown billing provider %v", c.Provider)
+	}
+}
@@ -0,0 +1,119 @@
+package billing
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	selectPlans = `SELECT plan_id, name, role_id, price_id, amount, currency, billing_interval, trial_days, active, created_at
+		FROM microsaas.plans`
+	selectSubscriptions = `SELECT subscription_id, user_id, plan_id, status, customer_id, provider_id, current_period_end,
+		cancel_at_period_end, created_at, updated_at FROM microsaas.subscriptions`
+)
+
+// PostgresStorer is the `Storer` implementation based on sqlx library.
+type PostgresStorer struct {
+	db *sqlx.DB
+}
+
+// NewPostgresStorer creates a new `PostgresStorer` instance based on the sqlx library.
+func NewPostgresStorer(db *sqlx.DB) *PostgresStorer {
+	return &PostgresStorer{
+		db: db,
+	}
+}
+
+// StorePlan is a method of the `PostgresStorer` struct. Takes a `Plan` as parameter and persists it.
+func (s *PostgresStorer) StorePlan(plan *Plan) error {
+	query := `INSERT INTO microsaas.plans (plan_id, name, role_id, price_id, amount, currency, billing_interval, trial_days,
+		active, created_at) VALUES (:plan_id, :name, :role_id, :price_id, :amount, :currency, :billing_interval, :trial_days,
+		:active, :created_at)`
+	if _, err := s.db.NamedExec(query, plan); err != nil {
+		return fmt.Errorf("failed to store plan: %w", err)
+	}
+	return nil
+}
+
+// UpdatePlan is a method of the `PostgresStorer` struct. Takes a `Plan` as parameter and updates it.
+func (s *PostgresStorer) UpdatePlan(plan *Plan) error {
+	query := `UPDATE microsaas.plans SET name = :name, role_id = :role_id, price_id = :price_id, amount = :amount,
+		currency = :currency, billing_interval = :billing_interval, trial_days = :trial_days, active = :active
+		WHERE plan_id = :plan_id`
+	if _, err := s.db.NamedExec(query, plan); err != nil {
+		return fmt.Errorf("failed to update plan: %w", err)
+	}
+	return nil
+}
+
+// PlanByID is a method of the `PostgresStorer` struct. Takes an UUID as parameter to load a `Plan` from persistence.
+func (s *PostgresStorer) PlanByID(id uuid.UUID) (*Plan, error) {
+	var plan Plan
+	if err := s.db.Get(&plan, selectPlans+` WHERE plan_id = $1`, id); err != nil {
+		return nil, fmt.Errorf("failed to get plan by ID: %w", err)
+	}
+	return &plan, nil
+}
+
+// PlanByPrice is a method of the `PostgresStorer` struct. Takes the ID of a price at the payment provider as parameter
+// to load the `Plan` billed with it.
+func (s *PostgresStorer) PlanByPrice(priceID string) (*Plan, error) {
+	var plan Plan
+	if err := s.db.Get(&plan, selectPlans+` WHERE price_id = $1`, priceID); err != nil {
+		return nil, fmt.Errorf("failed to get plan by price: %w", err)
+	}
+	return &plan, nil
+}
+
+// Plans is a method of the `PostgresStorer` struct. Loads all `Plan` objects, the cheapest first.
+func (s *PostgresStorer) Plans() ([]Plan, error) {
+	var plans []Plan
+	if err := s.db.Select(&plans, selectPlans+` ORDER BY amount, name`); err != nil {
+		return nil, fmt.Errorf("failed to get plans: %w", err)
+	}
+	return plans, nil
+}
+
+// Store is a method of the `PostgresStorer` struct. Takes a `Subscription` as parameter and persists it.
+func (s *PostgresStorer) Store(sub *Subscription) error {
+	query := `INSERT INTO microsaas.subscriptions (subscription_id, user_id, plan_id, status, customer_id, provider_id,
+		current_period_end, cancel_at_period_end, created_at, updated_at) VALUES (:subscription_id, :user_id, :plan_id,
+		:status, :customer_id, :provider_id, :current_period_end, :cancel_at_period_end, :created_at, :updated_at)`
+	if _, err := s.db.NamedExec(query, sub); err != nil {
+		return fmt.Errorf("failed to store subscription: %w", err)
+	}
+	return nil
+}
+
+// Update is a method of the `PostgresStorer` struct. Takes a `Subscription` as parameter and updates its plan and
+// status.
+func (s *PostgresStorer) Update(sub *Subscription) error {
+	query := `UPDATE microsaas.subscriptions SET plan_id = :plan_id, status = :status, customer_id = :customer_id,
+		current_period_end = :current_period_end, cancel_at_period_end = :cancel_at_period_end, updated_at = :updated_at
+		WHERE subscription_id = :subscription_id`
+	if _, err := s.db.NamedExec(query, sub); err != nil {
+		return fmt.Errorf("failed to update subscription: %w", err)
+	}
+	return nil
+}
+
+// ByUser is a method of the `PostgresStorer` struct. Loads the latest `Subscription` of the user in parameter.
+func (s *PostgresStorer) ByUser(userID uuid.UUID) (*Subscription, error) {
+	var sub Subscription
+	if err := s.db.Get(&sub, selectSubscriptions+` WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1`, userID); err != nil {
+		return nil, fmt.Errorf("failed to get subscription of user: %w", err)
+	}
+	return &sub, nil
+}
+
+// ByProviderID is a method of the `PostgresStorer` struct. Takes the ID of a subscription at the payment provider as
+// parameter to load the `Subscription`.
+func (s *PostgresStorer) ByProviderID(providerID string) (*Subscription, error) {
+	var sub Subscription
+	if err := s.db.Get(&sub, selectSubscriptions+` WHERE provider_id = $1`, providerID); err != nil {
+		return nil, fmt.Errorf("failed to get subscription by provider ID: %w", err)
+	}
+	return &sub, nil
+}
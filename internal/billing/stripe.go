@@ -0,0 +1,216 @@
+package billing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// signatureTolerance is the maximum age of a webhook request of Stripe, older requests are rejected as replays.
+const signatureTolerance = 5 * time.Minute
+
+// Stripe is the `Provider` implementation for Stripe, using its REST API.
+type Stripe struct {
+	key           string
+	webhookSecret string
+	baseURL       string
+	client        *http.Client
+}
+
+// NewStripe creates a new `Stripe` provider with the secret API key and the signing secret of the webhook endpoint.
+func NewStripe(key string, webhookSecret string) *Stripe {
+	return &Stripe{
+		key:           key,
+		webhookSecret: webhookSecret,
+		baseURL:       "https://api.stripe.com",
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Checkout is a method of `Stripe` creating a Checkout Session in subscription mode. The ID of the user is set as
+// metadata of the subscription, so the webhook events of the subscription can be matched to the user.
+func (s *Stripe) Checkout(ctx context.Context, req Checkout) (string, error) {
+	form := url.Values{
+		"mode":                                 {"subscription"},
+		"line_items[0][price]":                 {req.PriceID},
+		"line_items[0][quantity]":              {"1"},
+		"success_url":                          {req.SuccessURL},
+		"cancel_url":                           {req.CancelURL},
+		"client_reference_id":                  {req.UserID.String()},
+		"subscription_data[metadata][user_id]": {req.UserID.String()},
+	}
+	if req.CustomerID != "" {
+		form.Set("customer", req.CustomerID)
+	} else {
+		form.Set("customer_email", req.Email)
+	}
+	if req.TrialDays > 0 {
+		form.Set("subscription_data[trial_period_days]", strconv.Itoa(req.TrialDays))
+	}
+	return s.session(ctx, "/v1/checkout/sessions", form)
+}
+
+// Portal is a method of `Stripe` creating a session of the Stripe customer portal.
+func (s *Stripe) Portal(ctx context.Context, customerID string, returnURL string) (string, error) {
+	return s.session(ctx, "/v1/billing_portal/sessions", url.Values{
+		"customer":   {customerID},
+		"return_url": {returnURL},
+	})
+}
+
+// SignatureHeader is a method of `Stripe` returning the header of the webhook signature.
+func (s *Stripe) SignatureHeader() string {
+	return "Stripe-Signature"
+}
+
+// ParseEvent is a method of `Stripe` verifying the `Stripe-Signature` header of a webhook request and parsing the
+// event. Only the `customer.subscription.*` events are parsed into subscription changes.
+func (s *Stripe) ParseEvent(payload []byte, signature string) (*Event, error) {
+	if err := s.verify(payload, signature, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var e stripeEvent
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, fmt.Errorf("failed to parse Stripe event: %w", err)
+	}
+	res := &Event{ID: e.ID, Created: time.Unix(e.Created, 0)}
+	if !strings.HasPrefix(e.Type, "customer.subscription.") {
+		return res, nil
+	}
+
+	var sub stripeSubscription
+	if err := json.Unmarshal(e.Data.Object, &sub); err != nil {
+		return nil, fmt.Errorf("failed to parse Stripe subscription: %w", err)
+	}
+	res.SubscriptionID = sub.ID
+	res.CustomerID = sub.Customer
+	res.Status = stripeStatus(sub.Status)
+	res.CancelAtPeriodEnd = sub.CancelAtPeriodEnd
+	if sub.CurrentPeriodEnd > 0 {
+		res.PeriodEnd = time.Unix(sub.CurrentPeriodEnd, 0)
+	}
+	if len(sub.Items.Data) > 0 {
+		res.PriceID = sub.Items.Data[0].Price.ID
+	}
+	if id, err := uuid.Parse(sub.Metadata["user_id"]); err == nil {
+		res.UserID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	return res, nil
+}
+
+// verify checks the signature of a webhook payload, the header is in the format of `t=<timestamp>,v1=<signature>`.
+func (s *Stripe) verify(payload []byte, header string, now time.Time) error {
+	var (
+		timestamp  string
+		signatures []string
+	)
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if now.Sub(time.Unix(t, 0)) > signatureTolerance {
+		return ErrInvalidSignature
+	}
+
+	expected := sign(s.webhookSecret, timestamp+"."+string(payload))
+	for _, sig := range signatures {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func (s *Stripe) session(ctx context.Context, path string, form url.Values) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create Stripe session: %w", err)
+	}
+	req.SetBasicAuth(s.key, "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Stripe session: %w", err)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		URL   string `json:"url"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to parse Stripe session: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to create Stripe session: %v %v", res.Status, body.Error.Message)
+	}
+	return body.URL, nil
+}
+
+type stripeEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+type stripeSubscription struct {
+	ID                string            `json:"id"`
+	Customer          string            `json:"customer"`
+	Status            string            `json:"status"`
+	CancelAtPeriodEnd bool              `json:"cancel_at_period_end"`
+	CurrentPeriodEnd  int64             `json:"current_period_end"`
+	Metadata          map[string]string `json:"metadata"`
+	Items             struct {
+		Data []struct {
+			Price struct {
+				ID string `json:"id"`
+			} `json:"price"`
+		} `json:"data"`
+	} `json:"items"`
+}
+
+// stripeStatus maps the status of a Stripe subscription to the status of the subscriptions of the application.
+func stripeStatus(status string) Status {
+	switch status {
+	case "trialing":
+		return Trialing
+	case "active":
+		return Active
+	case "past_due", "unpaid", "incomplete":
+		return PastDue
+	default:
+		return Canceled
+	}
+}
+
+func sign(secret string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,106 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+const subscriptionEvent = `{"id":"evt_1","type":"customer.subscription.updated","created":%d,"data":{"object":{
+	"id":"sub_1","customer":"cus_1","status":"%v","cancel_at_period_end":true,"current_period_end":1767225600,
+	"metadata":{"user_id":"%v"},"items":{"data":[{"price":{"id":"price_1"}}]}}}}`
+
+func stripeSignature(secret string, payload []byte, t time.Time) string {
+	ts := fmt.Sprint(t.Unix())
+	return fmt.Sprintf("t=%v,v1=%v", ts, sign(secret, ts+"."+string(payload)))
+}
+
+func TestStripeParseSubscriptionEvent(t *testing.T) {
+	s := NewStripe("sk_test", "whsec")
+	userID := uuid.New()
+	now := time.Now()
+	payload := []byte(fmt.Sprintf(subscriptionEvent, now.Unix(), "unpaid", userID))
+
+	e, err := s.ParseEvent(payload, stripeSignature("whsec", payload, now))
+
+	assert.NoError(t, err)
+	assert.True(t, e.IsSubscription())
+	assert.Equal(t, "sub_1", e.SubscriptionID)
+	assert.Equal(t, "cus_1", e.CustomerID)
+	assert.Equal(t, "price_1", e.PriceID)
+	assert.Equal(t, PastDue, e.Status)
+	assert.True(t, e.CancelAtPeriodEnd)
+	assert.Equal(t, uuid.NullUUID{UUID: userID, Valid: true}, e.UserID)
+	assert.Equal(t, int64(1767225600), e.PeriodEnd.Unix())
+}
+
+func TestStripeRejectsInvalidSignature(t *testing.T) {
+	s := NewStripe("sk_test", "whsec")
+	now := time.Now()
+	payload := []byte(fmt.Sprintf(subscriptionEvent, now.Unix(), "active", uuid.New()))
+
+	_, err := s.ParseEvent(payload, stripeSignature("other", payload, now))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = s.ParseEvent(payload, stripeSignature("whsec", payload, now.Add(-time.Hour)))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = s.ParseEvent(payload, "v1=abc")
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestStripeIgnoresOtherEvents(t *testing.T) {
+	s := NewStripe("sk_test", "whsec")
+	now := time.Now()
+	payload := []byte(fmt.Sprintf(`{"id":"evt_2","type":"invoice.paid","created":%d,"data":{"object":{}}}`, now.Unix()))
+
+	e, err := s.ParseEvent(payload, stripeSignature("whsec", payload, now))
+
+	assert.NoError(t, err)
+	assert.False(t, e.IsSubscription())
+}
+
+func TestStripeCheckout(t *testing.T) {
+	userID := uuid.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, _, _ := r.BasicAuth()
+		assert.Equal(t, "sk_test", key)
+		assert.Equal(t, "/v1/checkout/sessions", r.URL.Path)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "subscription", r.PostForm.Get("mode"))
+		assert.Equal(t, "price_1", r.PostForm.Get("line_items[0][price]"))
+		assert.Equal(t, "cus_1", r.PostForm.Get("customer"))
+		assert.Empty(t, r.PostForm.Get("customer_email"))
+		assert.Equal(t, userID.String(), r.PostForm.Get("subscription_data[metadata][user_id]"))
+		assert.Equal(t, "7", r.PostForm.Get("subscription_data[trial_period_days]"))
+		_, _ = w.Write([]byte(`{"url":"https://checkout.stripe.com/c/pay/cs_1"}`))
+	}))
+	defer server.Close()
+	s := NewStripe("sk_test", "whsec")
+	s.baseURL = server.URL
+
+	url, err := s.Checkout(context.Background(), Checkout{UserID: userID, CustomerID: "cus_1", PriceID: "price_1", TrialDays: 7})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://checkout.stripe.com/c/pay/cs_1", url)
+}
+
+func TestStripePortalError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":{"message":"No such customer"}}`))
+	}))
+	defer server.Close()
+	s := NewStripe("sk_test", "whsec")
+	s.baseURL = server.URL
+
+	_, err := s.Portal(context.Background(), "cus_1", "http://localhost/billing")
+
+	assert.ErrorContains(t, err, "No such customer")
+}
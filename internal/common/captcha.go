@@ -0,0 +1,109 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	hcaptchaURL  = "https://api.hcaptcha.com/siteverify"
+	turnstileURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+)
+
+// CaptchaVerifier is the interface of the captcha services, verifying the captcha token of the frontend proves the
+// request is sent by a human.
+type CaptchaVerifier interface {
+	Verify(ctx context.Context, token string) error
+}
+
+// NewCaptchaVerifier is a function creating the captcha verifier set in the configuration: reCAPTCHA Enterprise
+// (`recaptcha`), hCaptcha (`hcaptcha`), Cloudflare Turnstile (`turnstile`) or none (`none`) for local development and
+// tests.
+func NewCaptchaVerifier(c *AppConfig) (CaptchaVerifier, error) {
+	a := c.Auth
+	switch a.CaptchaProvider {
+	case "recaptcha":
+		return NewRecaptchaValidator(a.RecaptchaProjectID, a.RecaptchaKey, c.PathFor(a.RecaptchaAppCreds), a.CaptchaThreshold, a.CaptchaAction)
+	case "hcaptcha":
+		return NewSiteVerifier("hCaptcha", hcaptchaURL, a.CaptchaSecret, ""), nil
+	case "turnstile":
+		return NewSiteVerifier("Turnstile", turnstileURL, a.CaptchaSecret, a.CaptchaAction), nil
+	case "none":
+		log.Warn("Captcha verification is disabled, signup and sign in are not protected from bots!")
+		return NoCaptcha{}, nil
+	default:
+		return nil, fmt.Errorf("unknown captcha provider %v", a.CaptchaProvider)
+	}
+}
+
+// NoCaptcha is the `CaptchaVerifier` implementation accepting all tokens, for deployments without captcha.
+type NoCaptcha struct{}
+
+// Verify is a method of `NoCaptcha` accepting any token.
+func (NoCaptcha) Verify(_ context.Context, _ string) error {
+	return nil
+}
+
+// SiteVerifier is the `CaptchaVerifier` implementation for the captcha services with a `siteverify` endpoint taking
+// the secret key and the token, hCaptcha and Cloudflare Turnstile.
+type SiteVerifier struct {
+	name   string
+	url    string
+	secret string
+	action string
+	client *http.Client
+}
+
+// NewSiteVerifier creates a new `SiteVerifier` for the `siteverify` endpoint of a captcha service. Tokens of an action
+// other than the expected one are rejected, an empty action is not checked.
+func NewSiteVerifier(name string, url string, secret string, action string) *SiteVerifier {
+	return &SiteVerifier{
+		name:   name,
+		url:    url,
+		secret: secret,
+		action: action,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type siteVerifyResponse struct {
+	Success    bool     `json:"success"`
+	Action     string   `json:"action"`
+	Hostname   string   `json:"hostname"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+// Verify is a method of `SiteVerifier` verifying the captcha token from the frontend
+func (v *SiteVerifier) Verify(ctx context.Context, token string) error {
+	form := url.Values{"secret": {v.secret}, "response": {token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("error creating %v request: %v", v.name, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error verifying %v token: %v", v.name, err)
+	}
+	defer res.Body.Close()
+
+	var body siteVerifyResponse
+	if err = json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return fmt.Errorf("error parsing %v response: %v", v.name, err)
+	}
+	if !body.Success {
+		return fmt.Errorf("the %v token is invalid: %v", v.name, strings.Join(body.ErrorCodes, ", "))
+	}
+	if v.action != "" && body.Action != v.action {
+		return fmt.Errorf("the %v token is for another action: %v", v.name, body.Action)
+	}
+	return nil
+}
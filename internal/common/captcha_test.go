@@ -0,0 +1,59 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func siteVerifyServer(t *testing.T, response string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "secret", r.PostForm.Get("secret"))
+		assert.Equal(t, "token", r.PostForm.Get("response"))
+		_, _ = w.Write([]byte(response))
+	}))
+}
+
+func TestSiteVerifierAcceptsValidToken(t *testing.T) {
+	server := siteVerifyServer(t, `{"success":true,"action":"signup","hostname":"example.com"}`)
+	defer server.Close()
+
+	err := NewSiteVerifier("Turnstile", server.URL, "secret", "signup").Verify(context.Background(), "token")
+
+	assert.NoError(t, err)
+}
+
+func TestSiteVerifierRejectsInvalidToken(t *testing.T) {
+	server := siteVerifyServer(t, `{"success":false,"error-codes":["invalid-input-response"]}`)
+	defer server.Close()
+
+	err := NewSiteVerifier("hCaptcha", server.URL, "secret", "").Verify(context.Background(), "token")
+
+	assert.ErrorContains(t, err, "invalid-input-response")
+}
+
+func TestSiteVerifierRejectsTokenOfOtherAction(t *testing.T) {
+	server := siteVerifyServer(t, `{"success":true,"action":"signin"}`)
+	defer server.Close()
+
+	err := NewSiteVerifier("Turnstile", server.URL, "secret", "signup").Verify(context.Background(), "token")
+
+	assert.ErrorContains(t, err, "another action")
+}
+
+func TestNewCaptchaVerifier(t *testing.T) {
+	v, err := NewCaptchaVerifier(&AppConfig{Auth: &AuthConfig{CaptchaProvider: "none"}})
+	assert.NoError(t, err)
+	assert.NoError(t, v.Verify(context.Background(), ""))
+
+	v, err = NewCaptchaVerifier(&AppConfig{Auth: &AuthConfig{CaptchaProvider: "turnstile", CaptchaSecret: "secret"}})
+	assert.NoError(t, err)
+	assert.IsType(t, &SiteVerifier{}, v)
+
+	_, err = NewCaptchaVerifier(&AppConfig{Auth: &AuthConfig{CaptchaProvider: "unknown"}})
+	assert.Error(t, err)
+}
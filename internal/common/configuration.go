@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -11,9 +12,17 @@ import (
 
 const ConfigFolder = "/etc/microsaas/"
 
-// WebConfig is a configuration of the web application.
+// WebConfig is a configuration of the web application. The trusted proxies are the comma separated addresses or CIDR
+// ranges of the reverse proxies allowed to set the client address in the `X-Forwarded-For` header, none by default.
 type WebConfig struct {
-	Port int `mapstructure:"PORT"`
+	Port           int    `mapstructure:"PORT"`
+	TrustedProxies string `mapstructure:"TRUSTED_PROXIES"`
+}
+
+// Proxies is a method of `WebConfig` returning the trusted proxies, empty when the client address of the connection
+// is used for all requests.
+func (c WebConfig) Proxies() []string {
+	return strings.FieldsFunc(c.TrustedProxies, isListSeparator)
 }
 
 // RDBConfig is a configuration of the relational database.
@@ -36,20 +45,58 @@ func (c RDBConfig) String() string {
 
 // AuthConfig is a configuration of the authentication.
 type AuthConfig struct {
-	JWTSecret          string `mapstructure:"JWT_SIGN_SECRET"`
-	JWTExp             int    `mapstructure:"JWT_EXPIRATION_HOURS"`
-	JWTSecure          bool   `mapstructure:"JWT_COOKIE_SECURE"`
-	TLSCert            string `mapstructure:"TLS_CERT_PATH"`
-	TLSKey             string `mapstructure:"TLS_KEY_PATH"`
-	FrontendRoot       string `mapstructure:"FRONTEND_ROOT"`
-	BackendRoot        string `mapstructure:"BACKEND_ROOT"`
-	RecaptchaAppCreds  string `mapstructure:"GOOGLE_APPLICATION_CREDENTIALS"`
-	RecaptchaProjectID string `mapstructure:"GOOGLE_PROJECT_ID"`
-	RecaptchaKey       string `mapstructure:"GOOGLE_RECAPTCHA_KEY"`
-	GoogleKey          string `mapstructure:"GOOGLE_AUTH_KEY"`
-	GoogleSecret       string `mapstructure:"GOOGLE_AUTH_SECRET"`
-	FacebookKey        string `mapstructure:"FACEBOOK_AUTH_KEY"`
-	FacebookSecret     string `mapstructure:"FACEBOOK_AUTH_SECRET"`
+	JWTSecret          string  `mapstructure:"JWT_SIGN_SECRET"`
+	JWTSignKey         string  `mapstructure:"JWT_SIGN_KEY_PATH"`
+	JWTVerifyKeys      string  `mapstructure:"JWT_VERIFY_KEY_PATHS"`
+	JWTExp             int     `mapstructure:"JWT_EXPIRATION_HOURS"`
+	JWTAccessExp       int     `mapstructure:"JWT_ACCESS_EXPIRATION_MINUTES"`
+	JWTSecure          bool    `mapstructure:"JWT_COOKIE_SECURE"`
+	TLSCert            string  `mapstructure:"TLS_CERT_PATH"`
+	TLSKey             string  `mapstructure:"TLS_KEY_PATH"`
+	FrontendRoot       string  `mapstructure:"FRONTEND_ROOT"`
+	BackendRoot        string  `mapstructure:"BACKEND_ROOT"`
+	RecaptchaAppCreds  string  `mapstructure:"GOOGLE_APPLICATION_CREDENTIALS"`
+	RecaptchaProjectID string  `mapstructure:"GOOGLE_PROJECT_ID"`
+	RecaptchaKey       string  `mapstructure:"GOOGLE_RECAPTCHA_KEY"`
+	CaptchaProvider    string  `mapstructure:"CAPTCHA_PROVIDER"`
+	CaptchaSecret      string  `mapstructure:"CAPTCHA_SECRET"`
+	CaptchaThreshold   float32 `mapstructure:"CAPTCHA_THRESHOLD"`
+	CaptchaAction      string  `mapstructure:"CAPTCHA_ACTION"`
+	MagicLinkEnabled   bool    `mapstructure:"MAGIC_LINK_ENABLED"`
+	MagicLinkTTL       int     `mapstructure:"MAGIC_LINK_TTL_MINUTES"`
+	PasswordMinLength  int     `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordClasses    int     `mapstructure:"PASSWORD_CHARACTER_CLASSES"`
+	PasswordHistory    int     `mapstructure:"PASSWORD_HISTORY"`
+	PasswordBreachList string  `mapstructure:"PASSWORD_BREACH_LIST_PATH"`
+	PasswordAlgorithm  string  `mapstructure:"PASSWORD_HASH_ALGORITHM"`
+	BcryptCost         int     `mapstructure:"PASSWORD_BCRYPT_COST"`
+	Argon2Memory       int     `mapstructure:"PASSWORD_ARGON2_MEMORY_KB"`
+	Argon2Iterations   int     `mapstructure:"PASSWORD_ARGON2_ITERATIONS"`
+	Argon2Threads      int     `mapstructure:"PASSWORD_ARGON2_THREADS"`
+	ImpersonationTTL   int     `mapstructure:"IMPERSONATION_TTL_MINUTES"`
+	InvitationTTL      int     `mapstructure:"INVITATION_TTL_HOURS"`
+	LockoutBackoff     string  `mapstructure:"LOCKOUT_BACKOFF"`
+	Providers          []ProviderConfig
+}
+
+// ProviderConfig is a configuration of an OAuth2 / OpenID Connect identity provider for single sign-on, loaded from the
+// `OAUTH_<NAME>_*` variables of the providers listed in `OAUTH_PROVIDERS`. The endpoints are discovered from the issuer
+// or the discovery URL, or can be set one by one for providers without discovery.
+type ProviderConfig struct {
+	Name               string
+	DisplayName        string
+	ClientID           string
+	ClientSecret       string
+	Issuer             string
+	DiscoveryURL       string
+	AuthURL            string
+	TokenURL           string
+	UserInfoURL        string
+	Scopes             []string
+	EmailClaim         string
+	EmailVerifiedClaim string
+	FirstNameClaim     string
+	LastNameClaim      string
 }
 
 // MailConfig is a configuration of e-mail massaging.
@@ -68,6 +115,28 @@ type LogConfig struct {
 	PrettyLog bool   `mapstructure:"PRETTY_LOG"`
 }
 
+// BillingConfig is a configuration of the subscription billing.
+type BillingConfig struct {
+	Provider      string  `mapstructure:"BILLING_PROVIDER"`
+	WebhookSecret string  `mapstructure:"BILLING_WEBHOOK_SECRET"`
+	StripeKey     string  `mapstructure:"STRIPE_SECRET_KEY"`
+	IssuerName    string  `mapstructure:"INVOICE_ISSUER_NAME"`
+	IssuerAddress string  `mapstructure:"INVOICE_ISSUER_ADDRESS"`
+	IssuerTaxID   string  `mapstructure:"INVOICE_ISSUER_TAX_ID"`
+	TaxRate       float64 `mapstructure:"INVOICE_TAX_RATE"`
+}
+
+// RateLimitConfig is a configuration of the rate limits of the route groups, each group with comma separated rules
+// in the format of `<ip|subnet|user|email>:<limit>/<window>`, e.g. `ip:20/1m,email:10/15m`. The failures are the
+// limits of the failed sign in attempts of an IP address or subnet, in the same format.
+type RateLimitConfig struct {
+	Store    string `mapstructure:"RATE_LIMIT_STORE"`
+	Signin   string `mapstructure:"RATE_LIMIT_SIGNIN"`
+	Account  string `mapstructure:"RATE_LIMIT_ACCOUNT"`
+	Security string `mapstructure:"RATE_LIMIT_SECURITY"`
+	Failures string `mapstructure:"RATE_LIMIT_FAILURES"`
+}
+
 type AnalyticsConfig struct {
 	StatsigServerKey string `mapstructure:"STATSIG_SERVER_SECRET_KEY"`
 }
@@ -80,6 +149,8 @@ type AppConfig struct {
 	Mail      *MailConfig
 	Web       *WebConfig
 	Analytics *AnalyticsConfig
+	Billing   *BillingConfig
+	RateLimit *RateLimitConfig
 	Path      string
 }
 
@@ -106,6 +177,8 @@ func loadConfig(path string) (*AppConfig, error) {
 	var ml MailConfig
 	var wb WebConfig
 	var an AnalyticsConfig
+	var bl BillingConfig
+	var rl RateLimitConfig
 
 	for _, confPath := range configPaths(path) {
 		viper.AddConfigPath(confPath)
@@ -114,6 +187,28 @@ func loadConfig(path string) (*AppConfig, error) {
 	viper.SetConfigName("app")
 	viper.SetDefault("JWT_COOKIE_SECURE", true)
 	viper.SetDefault("JWT_EXPIRATION_HOURS", 24)
+	viper.SetDefault("JWT_ACCESS_EXPIRATION_MINUTES", 15)
+	viper.SetDefault("CAPTCHA_PROVIDER", "recaptcha")
+	viper.SetDefault("CAPTCHA_THRESHOLD", 0.5)
+	viper.SetDefault("MAGIC_LINK_ENABLED", false)
+	viper.SetDefault("MAGIC_LINK_TTL_MINUTES", 15)
+	viper.SetDefault("PASSWORD_MIN_LENGTH", 8)
+	viper.SetDefault("PASSWORD_CHARACTER_CLASSES", 0)
+	viper.SetDefault("PASSWORD_HISTORY", 0)
+	viper.SetDefault("PASSWORD_HASH_ALGORITHM", "argon2id")
+	viper.SetDefault("PASSWORD_BCRYPT_COST", 12)
+	viper.SetDefault("PASSWORD_ARGON2_MEMORY_KB", 19456)
+	viper.SetDefault("PASSWORD_ARGON2_ITERATIONS", 2)
+	viper.SetDefault("PASSWORD_ARGON2_THREADS", 1)
+	viper.SetDefault("IMPERSONATION_TTL_MINUTES", 30)
+	viper.SetDefault("INVITATION_TTL_HOURS", 168)
+	viper.SetDefault("LOCKOUT_BACKOFF", "0s,0s,10s,100s,1000s,1h")
+	viper.SetDefault("INVOICE_TAX_RATE", 0)
+	viper.SetDefault("RATE_LIMIT_STORE", "memory")
+	viper.SetDefault("RATE_LIMIT_SIGNIN", "ip:20/1m,email:10/15m")
+	viper.SetDefault("RATE_LIMIT_ACCOUNT", "ip:10/1h,email:3/1h")
+	viper.SetDefault("RATE_LIMIT_SECURITY", "user:10/15m")
+	viper.SetDefault("RATE_LIMIT_FAILURES", "ip:20/1h,subnet:100/1h")
 	viper.SetDefault("DB_SSL_MODE", "disable")
 	viper.SetDefault("PORT", 8080)
 	viper.SetDefault("IMG_STORE_USE_PRESIGNED", false)
@@ -124,11 +219,12 @@ func loadConfig(path string) (*AppConfig, error) {
 	if err != nil {
 		return nil, err
 	}
-	for _, config := range [6]any{&wb, &db, &au, &lg, &ml, &an} {
+	for _, config := range [8]any{&wb, &db, &au, &lg, &ml, &an, &bl, &rl} {
 		if err = viper.Unmarshal(config); err != nil {
 			return nil, err
 		}
 	}
+	au.Providers = loadProviders()
 	return &AppConfig{
 		DB:        &db,
 		Auth:      &au,
@@ -136,6 +232,38 @@ func loadConfig(path string) (*AppConfig, error) {
 		Mail:      &ml,
 		Web:       &wb,
 		Analytics: &an,
+		Billing:   &bl,
+		RateLimit: &rl,
 		Path:      path,
 	}, nil
 }
+
+// loadProviders is a function loading the identity providers listed in `OAUTH_PROVIDERS`. Each provider is configured
+// with the variables prefixed by its upper case name, e.g. `OAUTH_GITLAB_CLIENT_ID` for the provider "gitlab".
+func loadProviders() []ProviderConfig {
+	var providers []ProviderConfig
+	for _, name := range strings.FieldsFunc(viper.GetString("OAUTH_PROVIDERS"), isListSeparator) {
+		prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		providers = append(providers, ProviderConfig{
+			Name:               strings.ToLower(name),
+			DisplayName:        viper.GetString(prefix + "DISPLAY_NAME"),
+			ClientID:           viper.GetString(prefix + "CLIENT_ID"),
+			ClientSecret:       viper.GetString(prefix + "CLIENT_SECRET"),
+			Issuer:             viper.GetString(prefix + "ISSUER"),
+			DiscoveryURL:       viper.GetString(prefix + "DISCOVERY_URL"),
+			AuthURL:            viper.GetString(prefix + "AUTH_URL"),
+			TokenURL:           viper.GetString(prefix + "TOKEN_URL"),
+			UserInfoURL:        viper.GetString(prefix + "USERINFO_URL"),
+			Scopes:             strings.FieldsFunc(viper.GetString(prefix+"SCOPES"), isListSeparator),
+			EmailClaim:         viper.GetString(prefix + "EMAIL_CLAIM"),
+			EmailVerifiedClaim: viper.GetString(prefix + "EMAIL_VERIFIED_CLAIM"),
+			FirstNameClaim:     viper.GetString(prefix + "FIRST_NAME_CLAIM"),
+			LastNameClaim:      viper.GetString(prefix + "LAST_NAME_CLAIM"),
+		})
+	}
+	return providers
+}
+
+func isListSeparator(r rune) bool {
+	return r == ',' || r == ' '
+}
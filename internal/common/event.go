@@ -10,14 +10,36 @@ const (
 	HistoryTopic      = "history"
 	NotificationTopic = "notification"
 	EmailSent         = "email_sent"
+
+	ImpersonationStarted = "impersonation_started"
+	ImpersonationEnded   = "impersonation_ended"
+	ImpersonatedRequest  = "impersonated_request"
+
+	RoleChanged = "role_changed"
+
+	SubscriptionChanged = "subscription_changed"
+
+	// Security alerts of the account, published on the history and the notification topics, e-mailed to the user.
+	NewSignin                = "new_signin"
+	PasswordChanged          = "password_changed"
+	PasswordReset            = "password_reset"
+	TwoFactorEnabled         = "two_factor_enabled"
+	TwoFactorDisabled        = "two_factor_disabled"
+	RecoveryCodesRegenerated = "recovery_codes_regenerated"
+	IdentityLinked           = "identity_linked"
+	IdentityUnlinked         = "identity_unlinked"
+	PasskeyAdded             = "passkey_added"
+	PasskeyRemoved           = "passkey_removed"
+	AccountDisabled          = "account_disabled"
 )
 
 type Event struct {
-	ID   uuid.UUID   `json:"id"`
-	Type string      `json:"type"`
-	Time time.Time   `json:"time"`
-	Data interface{} `json:"data"`
-	User uuid.UUID   `json:"user"`
+	ID    uuid.UUID     `json:"id"`
+	Type  string        `json:"type"`
+	Time  time.Time     `json:"time"`
+	Data  interface{}   `json:"data"`
+	User  uuid.UUID     `json:"user"`
+	Actor uuid.NullUUID `json:"actor"`
 }
 
 type EmailData struct {
@@ -26,3 +48,37 @@ type EmailData struct {
 	Subject string `json:"subject"`
 	Body    string `json:"body"`
 }
+
+// RequestData is the event data of a request made by an administrator impersonating a user.
+type RequestData struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Status int    `json:"status"`
+	IP     string `json:"ip"`
+}
+
+// RoleChangeData is the event data of an administrator assigning a new role to a user.
+type RoleChangeData struct {
+	From     string `json:"from"`
+	FromName string `json:"from_name"`
+	To       string `json:"to"`
+	ToName   string `json:"to_name"`
+}
+
+// SubscriptionData is the event data of a change of the subscription of a user, reported by the payment provider.
+type SubscriptionData struct {
+	Plan     string `json:"plan"`
+	PlanName string `json:"plan_name"`
+	From     string `json:"from"`
+	Status   string `json:"status"`
+}
+
+// SecurityData is the event data of a security alert, with the e-mail address of the user to alert and the IP address
+// and device of the request making the change. The detail is the name of the identity provider or the passkey of sign in
+// method changes.
+type SecurityData struct {
+	Email  string `json:"email"`
+	IP     string `json:"ip"`
+	Device string `json:"device"`
+	Detail string `json:"detail,omitempty"`
+}
@@ -19,6 +19,12 @@ type Health struct {
 	Version string `json:"version"`
 }
 
+// Violations is an error of a value breaking one or more rules, with a human readable message for each rule.
+type Violations []string
+
+// Error is the string representation of `Violations`
+func (v Violations) Error() string { return strings.Join(v, " ") }
+
 // ValidationMessage is a function to convert Gin-Gonic validation errors to `StatusMessage`.
 func ValidationMessage(err error) StatusMessage {
 	return StatusMessage{
@@ -36,6 +42,8 @@ func ValidationMessages(err error) []string {
 		return out
 	} else if je, ok := err.(*json.UnmarshalTypeError); ok {
 		return []string{fmt.Sprintf("The field %s must be a %s", je.Field, je.Type.String())}
+	} else if v, ok := err.(Violations); ok {
+		return v
 	}
 	return nil
 }
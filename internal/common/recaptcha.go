@@ -10,20 +10,19 @@ import (
 	"google.golang.org/api/option"
 )
 
-const captchaThreshold = 0.5
-
 // RecaptchaValidator is a struct for validating captcha using Google's ReCapthca validator
 type RecaptchaValidator struct {
 	projectID    string
 	recaptchaKey string
+	threshold    float32
+	action       string
 	client       *recaptcha.Client
-	ctx          context.Context
 }
 
-// NewRecaptchaValidator is a function creating a new `RecaptchaValidator` based on the API secret
-func NewRecaptchaValidator(projectID string, recaptchaKey string, keyFile string) (*RecaptchaValidator, error) {
-	ctx := context.Background()
-	client, err := recaptcha.NewClient(ctx, option.WithCredentialsFile(keyFile))
+// NewRecaptchaValidator is a function creating a new `RecaptchaValidator` based on the API secret. Tokens with a risk
+// score below the threshold, or of an action other than the expected one, are rejected. An empty action is not checked.
+func NewRecaptchaValidator(projectID string, recaptchaKey string, keyFile string, threshold float32, action string) (*RecaptchaValidator, error) {
+	client, err := recaptcha.NewClient(context.Background(), option.WithCredentialsFile(keyFile))
 
 	if err != nil {
 		return nil, fmt.Errorf("error creating reCAPTCHA client: %v", err)
@@ -32,17 +31,19 @@ func NewRecaptchaValidator(projectID string, recaptchaKey string, keyFile string
 	return &RecaptchaValidator{
 		projectID:    projectID,
 		recaptchaKey: recaptchaKey,
+		threshold:    threshold,
+		action:       action,
 		client:       client,
-		ctx:          ctx,
 	}, nil
 }
 
 // Verify is a method of `RecaptchaValidator` verifying the captch token from the frontend
-func (v RecaptchaValidator) Verify(token string) error {
+func (v RecaptchaValidator) Verify(ctx context.Context, token string) error {
 	// Create an assessment request
 	event := &recaptchapb.Event{
-		Token:   token,
-		SiteKey: v.recaptchaKey,
+		Token:          token,
+		SiteKey:        v.recaptchaKey,
+		ExpectedAction: v.action,
 	}
 	assessment := &recaptchapb.Assessment{
 		Event: event,
@@ -53,7 +54,7 @@ func (v RecaptchaValidator) Verify(token string) error {
 	}
 
 	// Send the request and get the response
-	response, err := v.client.CreateAssessment(v.ctx, request)
+	response, err := v.client.CreateAssessment(ctx, request)
 	if err != nil {
 		return fmt.Errorf("error creating assessment: %v", err)
 	}
@@ -62,9 +63,12 @@ func (v RecaptchaValidator) Verify(token string) error {
 	if !response.TokenProperties.Valid {
 		return fmt.Errorf("the reCAPTCHA token is invalid: %v", response.TokenProperties.InvalidReason)
 	}
+	if v.action != "" && response.TokenProperties.Action != v.action {
+		return fmt.Errorf("the reCAPTCHA token is for another action: %v", response.TokenProperties.Action)
+	}
 
 	// Get the risk score and reasons
-	if response.RiskAnalysis.Score < captchaThreshold {
+	if response.RiskAnalysis.Score < v.threshold {
 		err := fmt.Errorf("the reCAPTCHA risk score is too low: %v", response.RiskAnalysis.Score)
 		log.WithError(err).WithField("reasons", response.RiskAnalysis.Reasons).Error("ReCAPTCHA failed")
 		return err
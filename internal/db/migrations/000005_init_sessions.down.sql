@@ -0,0 +1 @@
+DROP TABLE microsaas.sessions;
\ No newline at end of file
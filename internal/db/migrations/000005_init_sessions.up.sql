@@ -0,0 +1,13 @@
+CREATE TABLE microsaas.sessions (
+  session_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL references microsaas.users(user_id),
+  refresh_hash VARCHAR(64) NOT NULL,
+  ip_address VARCHAR(45),
+  user_agent VARCHAR(512),
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  last_seen_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  revoked_at TIMESTAMP WITHOUT TIME ZONE
+);
+
+CREATE INDEX idx_sessions_user_id ON microsaas.sessions(user_id);
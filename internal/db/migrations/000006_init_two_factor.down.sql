@@ -0,0 +1,2 @@
+DROP TABLE microsaas.recovery_codes;
+DROP TABLE microsaas.two_factor;
\ No newline at end of file
@@ -0,0 +1,16 @@
+CREATE TABLE microsaas.two_factor (
+  user_id UUID PRIMARY KEY references microsaas.users(user_id),
+  secret VARCHAR(64) NOT NULL,
+  enabled BOOLEAN DEFAULT false,
+  last_used_step BIGINT DEFAULT 0,
+  confirmed_at TIMESTAMP WITHOUT TIME ZONE,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE microsaas.recovery_codes (
+  user_id UUID NOT NULL references microsaas.users(user_id),
+  code_hash VARCHAR(64) NOT NULL,
+  used_at TIMESTAMP WITHOUT TIME ZONE,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  PRIMARY KEY (user_id, code_hash)
+);
@@ -0,0 +1,2 @@
+DROP TABLE microsaas.passkey_ceremonies;
+DROP TABLE microsaas.passkeys;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE microsaas.passkeys (
+  credential_id BYTEA PRIMARY KEY,
+  user_id UUID NOT NULL references microsaas.users(user_id),
+  name VARCHAR(100) NOT NULL,
+  credential JSONB NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  last_used_at TIMESTAMP WITHOUT TIME ZONE
+);
+
+CREATE INDEX idx_passkeys_user_id ON microsaas.passkeys(user_id);
+
+CREATE TABLE microsaas.passkey_ceremonies (
+  ceremony_id UUID PRIMARY KEY,
+  user_id UUID references microsaas.users(user_id),
+  kind VARCHAR(20) NOT NULL,
+  data JSONB NOT NULL,
+  expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
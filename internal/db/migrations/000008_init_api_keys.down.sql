@@ -0,0 +1 @@
+DROP TABLE microsaas.api_keys;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE microsaas.api_keys (
+  key_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL references microsaas.users(user_id),
+  name VARCHAR(100) NOT NULL,
+  key_hash VARCHAR(64) NOT NULL,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  expires_at TIMESTAMP WITHOUT TIME ZONE,
+  last_used_at TIMESTAMP WITHOUT TIME ZONE
+);
+
+CREATE INDEX idx_api_keys_user_id ON microsaas.api_keys(user_id);
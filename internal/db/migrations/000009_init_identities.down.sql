@@ -0,0 +1 @@
+DROP TABLE microsaas.identities;
\ No newline at end of file
@@ -0,0 +1,22 @@
+CREATE TABLE microsaas.identities (
+  identity_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL references microsaas.users(user_id),
+  provider VARCHAR(100) NOT NULL,
+  subject VARCHAR(255),
+  email VARCHAR(255) NOT NULL DEFAULT '',
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  last_used_at TIMESTAMP WITHOUT TIME ZONE,
+  UNIQUE (user_id, provider),
+  UNIQUE (provider, subject)
+);
+
+-- Existing users keep their single login method. The subject of single sign-on users is not known yet, it is filled in
+-- at their next sign in with the provider.
+INSERT INTO microsaas.identities (user_id, provider, subject, email, created_at)
+SELECT
+  user_id,
+  LOWER(source),
+  CASE WHEN source = 'credentials' THEN user_id::text END,
+  email,
+  created_at
+FROM microsaas.users;
@@ -0,0 +1 @@
+DROP TABLE microsaas.magic_links;
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE microsaas.magic_links (
+  link_id UUID PRIMARY KEY,
+  user_id UUID NOT NULL references microsaas.users(user_id),
+  token_hash VARCHAR(64) NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+  expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_magic_links_user_id ON microsaas.magic_links(user_id);
@@ -0,0 +1 @@
+DROP TABLE microsaas.password_history;
\ No newline at end of file
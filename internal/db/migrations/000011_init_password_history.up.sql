@@ -0,0 +1,14 @@
+CREATE TABLE microsaas.password_history (
+  history_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL references microsaas.users(user_id),
+  pass_hash VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_password_history_user_id ON microsaas.password_history(user_id, created_at);
+
+-- The current password of existing users is the first entry of their history.
+INSERT INTO microsaas.password_history (user_id, pass_hash, created_at)
+SELECT user_id, pass_hash, created_at
+FROM microsaas.users
+WHERE pass_hash IS NOT NULL AND pass_hash <> '';
@@ -0,0 +1,2 @@
+-- The actor is the administrator acting on behalf of the user of the event, empty when the user acted.
+ALTER TABLE microsaas.history_events ADD COLUMN actor_id UUID references microsaas.users(user_id);
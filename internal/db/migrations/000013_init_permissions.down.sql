@@ -0,0 +1 @@
+DROP TABLE microsaas.role_permissions;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE microsaas.role_permissions (
+  role_id UUID NOT NULL references microsaas.roles(role_id),
+  permission VARCHAR(100) NOT NULL,
+  PRIMARY KEY (role_id, permission)
+);
+
+-- The administrator role keeps its access to everything.
+INSERT INTO microsaas.role_permissions (role_id, permission) VALUES
+  ('b6d0a023-86db-4480-9dd9-532a4d4b1fbb', 'users:read'),
+  ('b6d0a023-86db-4480-9dd9-532a4d4b1fbb', 'users:write'),
+  ('b6d0a023-86db-4480-9dd9-532a4d4b1fbb', 'users:impersonate'),
+  ('b6d0a023-86db-4480-9dd9-532a4d4b1fbb', 'roles:manage'),
+  ('b6d0a023-86db-4480-9dd9-532a4d4b1fbb', 'history:read:any');
@@ -0,0 +1 @@
+ALTER TABLE microsaas.roles DROP COLUMN default_signup;
\ No newline at end of file
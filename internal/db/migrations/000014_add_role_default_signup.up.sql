@@ -0,0 +1,6 @@
+ALTER TABLE microsaas.roles ADD COLUMN default_signup BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- At most one role is assigned to the users signing up.
+CREATE UNIQUE INDEX roles_default_signup_idx ON microsaas.roles (default_signup) WHERE default_signup;
+
+UPDATE microsaas.roles SET default_signup = TRUE WHERE role_id = '0d83a7d4-24e3-4dd4-9b0a-d65379225abc';
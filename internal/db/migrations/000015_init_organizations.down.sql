@@ -0,0 +1,3 @@
+ALTER TABLE microsaas.sessions DROP COLUMN organization_id;
+DROP TABLE microsaas.memberships;
+DROP TABLE microsaas.organizations;
\ No newline at end of file
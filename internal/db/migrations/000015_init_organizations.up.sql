@@ -0,0 +1,23 @@
+CREATE TABLE microsaas.organizations (
+  organization_id UUID PRIMARY KEY,
+  name VARCHAR(100) NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE microsaas.memberships (
+  organization_id UUID NOT NULL references microsaas.organizations(organization_id) ON DELETE CASCADE,
+  user_id UUID NOT NULL references microsaas.users(user_id),
+  role VARCHAR(20) NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX memberships_user_id_idx ON microsaas.memberships (user_id);
+
+ALTER TABLE microsaas.sessions ADD COLUMN organization_id UUID NULL references microsaas.organizations(organization_id) ON DELETE SET NULL;
+
+-- Every existing user gets a personal organization, owned by the user and with the same ID, as new users do at signup.
+INSERT INTO microsaas.organizations (organization_id, name, created_at)
+  SELECT user_id, LEFT(email, 100), created_at FROM microsaas.users WHERE deleted_at IS NULL;
+INSERT INTO microsaas.memberships (organization_id, user_id, role, created_at)
+  SELECT user_id, user_id, 'owner', created_at FROM microsaas.users WHERE deleted_at IS NULL;
@@ -0,0 +1 @@
+DROP TABLE microsaas.invitations;
\ No newline at end of file
@@ -0,0 +1,16 @@
+CREATE TABLE microsaas.invitations (
+  invitation_id UUID PRIMARY KEY,
+  organization_id UUID NOT NULL references microsaas.organizations(organization_id) ON DELETE CASCADE,
+  email VARCHAR(255) NOT NULL,
+  role VARCHAR(20) NOT NULL,
+  token VARCHAR(100) NOT NULL,
+  invited_by UUID NOT NULL references microsaas.users(user_id),
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  accepted_at TIMESTAMP NULL,
+  declined_at TIMESTAMP NULL,
+  revoked_at TIMESTAMP NULL
+);
+
+CREATE INDEX invitations_organization_id_idx ON microsaas.invitations (organization_id);
+CREATE INDEX invitations_token_idx ON microsaas.invitations (token);
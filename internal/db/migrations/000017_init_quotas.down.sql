@@ -0,0 +1,2 @@
+DROP TABLE microsaas.usages;
+DROP TABLE microsaas.role_limits;
\ No newline at end of file
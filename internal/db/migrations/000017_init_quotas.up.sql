@@ -0,0 +1,32 @@
+CREATE TABLE microsaas.role_limits (
+  role_id UUID NOT NULL references microsaas.roles(role_id),
+  name VARCHAR(50) NOT NULL,
+  value INT NOT NULL,
+  PRIMARY KEY (role_id, name)
+);
+
+CREATE TABLE microsaas.usages (
+  subject_id UUID NOT NULL,
+  name VARCHAR(50) NOT NULL,
+  period_start TIMESTAMP NOT NULL,
+  used INT NOT NULL,
+  PRIMARY KEY (subject_id, name, period_start)
+);
+
+-- The administrator role is unlimited, the customer roles get the limits of a small and a free plan.
+INSERT INTO microsaas.role_limits (role_id, name, value) VALUES
+  ('b6d0a023-86db-4480-9dd9-532a4d4b1fbb', 'api_keys', -1),
+  ('b6d0a023-86db-4480-9dd9-532a4d4b1fbb', 'organizations', -1),
+  ('b6d0a023-86db-4480-9dd9-532a4d4b1fbb', 'invitations', -1),
+  ('3dae67da-21bd-4c1f-ac35-b3e79c4a4225', 'api_keys', 10),
+  ('3dae67da-21bd-4c1f-ac35-b3e79c4a4225', 'organizations', 5),
+  ('3dae67da-21bd-4c1f-ac35-b3e79c4a4225', 'invitations', 100),
+  ('0d83a7d4-24e3-4dd4-9b0a-d65379225abc', 'api_keys', 3),
+  ('0d83a7d4-24e3-4dd4-9b0a-d65379225abc', 'organizations', 2),
+  ('0d83a7d4-24e3-4dd4-9b0a-d65379225abc', 'invitations', 20);
+
+-- Resources created before the quotas count towards the limits.
+INSERT INTO microsaas.usages (subject_id, name, period_start, used)
+  SELECT user_id, 'api_keys', TIMESTAMP 'epoch', count(*) FROM microsaas.api_keys GROUP BY user_id;
+INSERT INTO microsaas.usages (subject_id, name, period_start, used)
+  SELECT user_id, 'organizations', TIMESTAMP 'epoch', count(*) FROM microsaas.memberships WHERE role = 'owner' GROUP BY user_id;
@@ -0,0 +1,3 @@
+DELETE FROM microsaas.role_permissions WHERE permission = 'billing:manage';
+DROP TABLE microsaas.subscriptions;
+DROP TABLE microsaas.plans;
\ No newline at end of file
@@ -0,0 +1,30 @@
+CREATE TABLE microsaas.plans (
+  plan_id UUID PRIMARY KEY,
+  name VARCHAR(100) UNIQUE NOT NULL,
+  role_id UUID NOT NULL references microsaas.roles(role_id),
+  price_id VARCHAR(255) UNIQUE NOT NULL,
+  amount INT NOT NULL,
+  currency VARCHAR(3) NOT NULL,
+  billing_interval VARCHAR(10) NOT NULL,
+  trial_days INT NOT NULL DEFAULT 0,
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE microsaas.subscriptions (
+  subscription_id UUID PRIMARY KEY,
+  user_id UUID NOT NULL references microsaas.users(user_id),
+  plan_id UUID NOT NULL references microsaas.plans(plan_id),
+  status VARCHAR(20) NOT NULL,
+  customer_id VARCHAR(255) NOT NULL,
+  provider_id VARCHAR(255) UNIQUE NOT NULL,
+  current_period_end TIMESTAMP NULL,
+  cancel_at_period_end BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX subscriptions_user_id_idx ON microsaas.subscriptions (user_id);
+
+INSERT INTO microsaas.role_permissions (role_id, permission) VALUES
+  ('b6d0a023-86db-4480-9dd9-532a4d4b1fbb', 'billing:manage');
@@ -0,0 +1,3 @@
+DROP TABLE microsaas.invoice_lines;
+DROP TABLE microsaas.invoices;
+DROP TABLE microsaas.invoice_sequences;
\ No newline at end of file
@@ -0,0 +1,39 @@
+CREATE TABLE microsaas.invoice_sequences (
+  year INT PRIMARY KEY,
+  last_sequence INT NOT NULL
+);
+
+CREATE TABLE microsaas.invoices (
+  invoice_id UUID PRIMARY KEY,
+  number VARCHAR(20) UNIQUE NOT NULL,
+  year INT NOT NULL,
+  sequence INT NOT NULL,
+  user_id UUID NOT NULL references microsaas.users(user_id),
+  subscription_id UUID NULL references microsaas.subscriptions(subscription_id),
+  period_end TIMESTAMP NULL,
+  issuer_name VARCHAR(255) NOT NULL,
+  issuer_address VARCHAR(500) NOT NULL,
+  issuer_tax_id VARCHAR(50) NOT NULL,
+  customer_name VARCHAR(255) NOT NULL,
+  customer_email VARCHAR(255) NOT NULL,
+  currency VARCHAR(3) NOT NULL,
+  tax_rate NUMERIC(5, 2) NOT NULL,
+  subtotal INT NOT NULL,
+  tax INT NOT NULL,
+  total INT NOT NULL,
+  issued_at TIMESTAMP NOT NULL,
+  UNIQUE (year, sequence),
+  UNIQUE (subscription_id, period_end)
+);
+
+CREATE INDEX invoices_user_id_idx ON microsaas.invoices (user_id);
+
+CREATE TABLE microsaas.invoice_lines (
+  invoice_id UUID NOT NULL references microsaas.invoices(invoice_id) ON DELETE CASCADE,
+  position INT NOT NULL,
+  description VARCHAR(255) NOT NULL,
+  quantity INT NOT NULL,
+  unit_amount INT NOT NULL,
+  amount INT NOT NULL,
+  PRIMARY KEY (invoice_id, position)
+);
@@ -0,0 +1 @@
+DROP TABLE microsaas.rate_limits;
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE microsaas.rate_limits (
+  key VARCHAR(100) PRIMARY KEY,
+  window_start TIMESTAMP NOT NULL,
+  hits INT NOT NULL
+);
+
+CREATE INDEX rate_limits_window_start_idx ON microsaas.rate_limits (window_start);
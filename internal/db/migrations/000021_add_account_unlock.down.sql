@@ -0,0 +1,3 @@
+DROP INDEX microsaas.idx_accounts_unlock_token;
+ALTER TABLE microsaas.accounts DROP COLUMN unlock_ttl;
+ALTER TABLE microsaas.accounts DROP COLUMN unlock_token;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- The unlock token is sent to the owner of a locked account, to lift the lock of sign in attempts of someone else.
+ALTER TABLE microsaas.accounts ADD COLUMN unlock_token VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE microsaas.accounts ADD COLUMN unlock_ttl TIMESTAMP WITHOUT TIME ZONE;
+
+CREATE INDEX idx_accounts_unlock_token ON microsaas.accounts(unlock_token);
@@ -0,0 +1 @@
+ALTER TABLE microsaas.sessions DROP COLUMN previous_hash;
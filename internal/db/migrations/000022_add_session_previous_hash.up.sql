@@ -0,0 +1,2 @@
+-- The hash of the refresh token replaced by the last rotation, so only a reused token revokes the session.
+ALTER TABLE microsaas.sessions ADD COLUMN previous_hash VARCHAR(64) NOT NULL DEFAULT '';
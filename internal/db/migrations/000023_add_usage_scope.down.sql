@@ -0,0 +1,3 @@
+ALTER TABLE microsaas.usages DROP CONSTRAINT usages_pkey;
+ALTER TABLE microsaas.usages DROP COLUMN scope;
+ALTER TABLE microsaas.usages ADD PRIMARY KEY (subject_id, name, period_start);
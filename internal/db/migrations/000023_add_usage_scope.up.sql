@@ -0,0 +1,5 @@
+-- The usage counters of users and organizations are kept apart, even when the IDs of the subjects are the same.
+ALTER TABLE microsaas.usages ADD COLUMN scope VARCHAR(20) NOT NULL DEFAULT 'user';
+UPDATE microsaas.usages SET scope = 'organization' WHERE name = 'invitations';
+ALTER TABLE microsaas.usages DROP CONSTRAINT usages_pkey;
+ALTER TABLE microsaas.usages ADD PRIMARY KEY (scope, subject_id, name, period_start);
@@ -5,8 +5,10 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/inokone/go-micro-saas/internal/auth/role"
 	"github.com/inokone/go-micro-saas/internal/auth/user"
 	"github.com/inokone/go-micro-saas/internal/common"
 )
@@ -25,14 +27,17 @@ func NewHandler(history Storer) *Handler {
 	}
 }
 
-// List is a method of `Handler`. Lists all history events for a user.
+// List is a method of `Handler`. Lists all history events for a user. The history of other users can only be listed
+// with the `history:read:any` permission.
 // @Summary List history events endpoint
 // @Schemes
 // @Description Lists all history events for a user
 // @Accept json
 // @Produce json
+// @Param id path string true "ID of the user"
 // @Success 200 {array} common.Event
 // @Failure 400 {object} common.StatusMessage
+// @Failure 403 {object} common.StatusMessage
 // @Failure 404 {object} common.StatusMessage
 // @Failure 500 {object} common.StatusMessage
 // @Router /users/:id/history [get]
@@ -43,7 +48,19 @@ func (h *Handler) List(g *gin.Context) {
 		return
 	}
 
-	events, err := h.history.List(usr.ID, historySize)
+	userID := usr.ID
+	if id := g.Param("id"); id != "" {
+		if userID, err = uuid.Parse(id); err != nil {
+			g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "User history not found!"})
+			return
+		}
+		if userID != usr.ID && !usr.Can(role.PermHistoryReadAny) {
+			g.AbortWithStatusJSON(http.StatusForbidden, common.StatusMessage{Message: "Not allowed!"})
+			return
+		}
+	}
+
+	events, err := h.history.List(userID, historySize)
 	if err != nil {
 		log.WithError(err).Error("Could not get history events, unknown error")
 		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "User history not found!"})
@@ -118,3 +118,37 @@ func TestListHistory404ForStorerError(t *testing.T) {
 
 	mockStorer.AssertExpectations(t)
 }
+
+func TestListHistoryOfOtherUserRequiresPermission(t *testing.T) {
+	mockStorer := new(MockStorer)
+	handler := NewHandler(mockStorer)
+	router := setupTestRouter(handler)
+	auditor := &user.User{
+		ID:   uuid.New(),
+		Role: &role.Role{ID: uuid.New(), Permissions: []string{role.PermHistoryReadAny}},
+	}
+
+	mockStorer.On("List", testUser.ID, historySize).Return([]common.Event{}, nil)
+
+	router.GET("/users/:id/history", func(c *gin.Context) {
+		if c.GetHeader("X-Auditor") != "" {
+			c.Set("user", auditor)
+		} else {
+			c.Set("user", &user.User{ID: uuid.New(), Role: &role.Role{ID: uuid.New()}})
+		}
+		handler.List(c)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users/"+testUser.ID.String()+"/history", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/users/"+testUser.ID.String()+"/history", nil)
+	req.Header.Set("X-Auditor", "true")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mockStorer.AssertNumberOfCalls(t, "List", 1)
+}
@@ -35,9 +35,10 @@ func (s *PostgresStorer) Store(event *common.Event) error {
 		"event_type":       event.Type,
 		"event_time":       event.Time,
 		"event_data":       data,
+		"actor_id":         event.Actor,
 	}
 
-	query := `INSERT INTO microsaas.history_events(history_event_id, user_id, event_type, event_time, event_data) VALUES (:history_event_id, :user_id, :event_type, :event_time, :event_data)`
+	query := `INSERT INTO microsaas.history_events(history_event_id, user_id, event_type, event_time, event_data, actor_id) VALUES (:history_event_id, :user_id, :event_type, :event_time, :event_data, :actor_id)`
 	_, err = s.db.NamedExec(query, values)
 	if err != nil {
 		return fmt.Errorf("failed to store history event: %w", err)
@@ -46,11 +47,12 @@ func (s *PostgresStorer) Store(event *common.Event) error {
 }
 
 type raw struct {
-	ID   uuid.UUID `json:"id" db:"history_event_id"`
-	Type string    `json:"type" db:"event_type"`
-	Time time.Time `json:"time" db:"event_time"`
-	Data string    `json:"data" db:"event_data"`
-	User uuid.UUID `json:"user" db:"user_id"`
+	ID    uuid.UUID     `json:"id" db:"history_event_id"`
+	Type  string        `json:"type" db:"event_type"`
+	Time  time.Time     `json:"time" db:"event_time"`
+	Data  string        `json:"data" db:"event_data"`
+	User  uuid.UUID     `json:"user" db:"user_id"`
+	Actor uuid.NullUUID `json:"actor" db:"actor_id"`
 }
 
 // List is a method of the `PostgresStorer` struct. Loads all history entries for the User in parameter.
@@ -60,7 +62,7 @@ func (s *PostgresStorer) List(user uuid.UUID, limit int) ([]common.Event, error)
 		raw []raw
 	)
 
-	query := `SELECT history_event_id, user_id, event_type, event_time, event_data FROM microsaas.history_events WHERE user_id = $1 order by event_time desc limit $2`
+	query := `SELECT history_event_id, user_id, event_type, event_time, event_data, actor_id FROM microsaas.history_events WHERE user_id = $1 order by event_time desc limit $2`
 	if err := s.db.Select(&raw, query, user, limit); err != nil {
 		return nil, fmt.Errorf("failed to list history events: %w", err)
 	}
@@ -72,11 +74,12 @@ func (s *PostgresStorer) List(user uuid.UUID, limit int) ([]common.Event, error)
 		}
 
 		res = append(res, common.Event{
-			ID:   e.ID,
-			Type: e.Type,
-			Time: e.Time,
-			Data: event,
-			User: e.User,
+			ID:    e.ID,
+			Type:  e.Type,
+			Time:  e.Time,
+			Data:  event,
+			User:  e.User,
+			Actor: e.Actor,
 		})
 
 	}
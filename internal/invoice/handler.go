@@ -0,0 +1,103 @@
+package invoice
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+// Handler is a struct for web handles related to the invoices of the current user.
+type Handler struct {
+	invoices Storer
+}
+
+// NewHandler creates a new `Handler`, based on the invoice persistence.
+func NewHandler(invoices Storer) *Handler {
+	return &Handler{
+		invoices: invoices,
+	}
+}
+
+// List lists the invoices issued to the current user, the latest first.
+// @Summary List invoices endpoint
+// @Schemes
+// @Description Lists the invoices of the current user
+// @Accept json
+// @Produce json
+// @Success 200 {array} invoice.View
+// @Failure 500 {object} common.StatusMessage
+// @Router /account/invoices [get]
+func (h *Handler) List(g *gin.Context) {
+	usr := currentUser(g)
+	invoices, err := h.invoices.ByUser(usr.ID)
+	if err != nil {
+		log.WithError(err).Error("Failed to list invoices")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+		return
+	}
+	res := make([]View, 0)
+	for _, inv := range invoices {
+		res = append(res, inv.AsView())
+	}
+	g.JSON(http.StatusOK, res)
+}
+
+// Download downloads an invoice of the current user as a PDF or, with the `format=html` query parameter, as an HTML
+// document.
+// @Summary Download invoice endpoint
+// @Schemes
+// @Description Downloads an invoice of the current user
+// @Produce application/pdf
+// @Produce text/html
+// @Param id path string true "ID of the invoice"
+// @Param format query string false "Format of the document, pdf (default) or html"
+// @Success 200 {file} file
+// @Failure 400 {object} common.StatusMessage
+// @Failure 404 {object} common.StatusMessage
+// @Router /account/invoices/:id [get]
+func (h *Handler) Download(g *gin.Context) {
+	var (
+		render      func(*Invoice) ([]byte, error)
+		contentType string
+	)
+	switch g.DefaultQuery("format", "pdf") {
+	case "pdf":
+		render, contentType = PDF, "application/pdf"
+	case "html":
+		render, contentType = HTML, "text/html; charset=utf-8"
+	default:
+		g.AbortWithStatusJSON(http.StatusBadRequest, common.StatusMessage{Message: "Unknown format, use pdf or html!"})
+		return
+	}
+
+	usr := currentUser(g)
+	id, err := uuid.Parse(g.Param("id"))
+	if err != nil {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "Invoice not found!"})
+		return
+	}
+	inv, err := h.invoices.ByID(id)
+	if err != nil || inv.UserID != usr.ID {
+		g.AbortWithStatusJSON(http.StatusNotFound, common.StatusMessage{Message: "Invoice not found!"})
+		return
+	}
+	doc, err := render(inv)
+	if err != nil {
+		log.WithError(err).Error("Failed to render invoice")
+		g.AbortWithStatusJSON(http.StatusInternalServerError, common.StatusMessage{Message: "Unknown error, please contact administrator!"})
+		return
+	}
+	name := inv.Number + "." + g.DefaultQuery("format", "pdf")
+	g.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+	g.Data(http.StatusOK, contentType, doc)
+}
+
+func currentUser(g *gin.Context) *user.User {
+	u, _ := g.Get("user")
+	return u.(*user.User)
+}
@@ -0,0 +1,92 @@
+package invoice
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+)
+
+func serve(handler gin.HandlerFunc, usr *user.User, route string, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(route, func(g *gin.Context) {
+		g.Set("user", usr)
+	}, handler)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestListInvoicesOfUser(t *testing.T) {
+	invoices := new(MockStorer)
+	h := NewHandler(invoices)
+	usr := &user.User{ID: uuid.New()}
+	inv := testInvoice()
+	inv.Lines = nil
+
+	invoices.On("ByUser", usr.ID).Return([]Invoice{*inv}, nil)
+
+	w := serve(h.List, usr, "/invoices", "/invoices")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var res []View
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, []View{inv.AsView()}, res)
+}
+
+func TestDownloadInvoiceAsPDF(t *testing.T) {
+	invoices := new(MockStorer)
+	h := NewHandler(invoices)
+	inv := testInvoice()
+	usr := &user.User{ID: inv.UserID}
+
+	invoices.On("ByID", inv.ID).Return(inv, nil)
+
+	w := serve(h.Download, usr, "/invoices/:id", "/invoices/"+inv.ID.String())
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="`+inv.Number+`.pdf"`, w.Header().Get("Content-Disposition"))
+}
+
+func TestDownloadInvoiceAsHTML(t *testing.T) {
+	invoices := new(MockStorer)
+	h := NewHandler(invoices)
+	inv := testInvoice()
+	usr := &user.User{ID: inv.UserID}
+
+	invoices.On("ByID", inv.ID).Return(inv, nil)
+
+	w := serve(h.Download, usr, "/invoices/:id", "/invoices/"+inv.ID.String()+"?format=html")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<h1>Invoice "+inv.Number+"</h1>")
+}
+
+func TestDownload404ForInvoiceOfOtherUser(t *testing.T) {
+	invoices := new(MockStorer)
+	h := NewHandler(invoices)
+	inv := testInvoice()
+
+	invoices.On("ByID", inv.ID).Return(inv, nil)
+
+	w := serve(h.Download, &user.User{ID: uuid.New()}, "/invoices/:id", "/invoices/"+inv.ID.String())
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDownload400ForUnknownFormat(t *testing.T) {
+	h := NewHandler(new(MockStorer))
+
+	w := serve(h.Download, &user.User{ID: uuid.New()}, "/invoices/:id", "/invoices/"+uuid.NewString()+"?format=docx")
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
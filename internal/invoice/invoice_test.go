@@ -0,0 +1,156 @@
+package invoice
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+	"github.com/inokone/go-micro-saas/internal/mail"
+)
+
+// MockStorer is a mock of the Storer interface
+type MockStorer struct {
+	mock.Mock
+}
+
+func (m *MockStorer) Store(inv *Invoice) error {
+	args := m.Called(inv)
+	if args.Error(0) == nil {
+		inv.Numbered(1)
+	}
+	return args.Error(0)
+}
+
+func (m *MockStorer) ByID(id uuid.UUID) (*Invoice, error) {
+	args := m.Called(id)
+	return args.Get(0).(*Invoice), args.Error(1)
+}
+
+func (m *MockStorer) ByUser(userID uuid.UUID) ([]Invoice, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]Invoice), args.Error(1)
+}
+
+func (m *MockStorer) Issued(subscriptionID uuid.UUID, periodEnd time.Time) (bool, error) {
+	args := m.Called(subscriptionID, periodEnd)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockMailer is a mock of the mail.Mailer interface, implementing the methods the invoices need.
+type MockMailer struct {
+	mock.Mock
+	mail.Mailer
+}
+
+func (m *MockMailer) Invoice(userID uuid.UUID, recipient string, number string, invoicesURL string, documents ...mail.Attachment) error {
+	args := m.Called(userID, recipient, number, invoicesURL, documents)
+	return args.Error(0)
+}
+
+func testInvoice() *Invoice {
+	issuer := Party{Name: "Acme Kft.", Address: "Budapest, Váci utca 1.", TaxID: "12345678-2-42"}
+	customer := Party{Name: "Test (User)", Email: "test@example.com"}
+	inv := NewInvoice(uuid.New(), issuer, customer, "EUR", 27,
+		Line{Description: "Pro plan", Quantity: 2, UnitAmount: 999},
+		Line{Description: "Setup", Quantity: 1, UnitAmount: 500})
+	inv.Numbered(42)
+	return inv
+}
+
+func TestNewInvoiceCalculatesTotals(t *testing.T) {
+	inv := testInvoice()
+
+	assert.Equal(t, "eur", inv.Currency)
+	assert.Equal(t, 2, inv.Lines[1].Position)
+	assert.Equal(t, inv.ID, inv.Lines[1].InvoiceID)
+	assert.Equal(t, 1998, inv.Lines[0].Amount)
+	assert.Equal(t, 2498, inv.Subtotal)
+	assert.Equal(t, 674, inv.Tax)
+	assert.Equal(t, 3172, inv.Total)
+}
+
+func TestNumberedUsesYearOfIssuing(t *testing.T) {
+	inv := &Invoice{IssuedAt: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)}
+	inv.Numbered(7)
+
+	assert.Equal(t, 2026, inv.Year)
+	assert.Equal(t, 7, inv.Sequence)
+	assert.Equal(t, "2026-000007", inv.Number)
+}
+
+func TestFormatAmount(t *testing.T) {
+	assert.Equal(t, "12.50 EUR", FormatAmount(1250, "eur"))
+	assert.Equal(t, "0.05 USD", FormatAmount(5, "usd"))
+	assert.Equal(t, "-1.00 USD", FormatAmount(-100, "usd"))
+	assert.Equal(t, "1250 JPY", FormatAmount(1250, "jpy"))
+}
+
+func TestHTMLRendersInvoice(t *testing.T) {
+	doc, err := HTML(testInvoice())
+
+	assert.NoError(t, err)
+	assert.Contains(t, string(doc), "Invoice 2026")
+	assert.Contains(t, string(doc), "31.72 EUR")
+	assert.Contains(t, string(doc), "Test (User)")
+}
+
+func TestPDFRendersInvoice(t *testing.T) {
+	inv := testInvoice()
+	doc, err := PDF(inv)
+
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(doc, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(doc, []byte("%%EOF\n")))
+	assert.Contains(t, string(doc), "(Invoice "+inv.Number+") Tj")
+	assert.Contains(t, string(doc), `(Test \(User\)) Tj`)
+	assert.Contains(t, string(doc), `(Budapest, V\341ci utca 1.) Tj`)
+	assert.Contains(t, string(doc), "(31.72 EUR) Tj")
+}
+
+func TestPDFString(t *testing.T) {
+	assert.Equal(t, `a\(b\)\\`, pdfString(`a(b)\`))
+	assert.Equal(t, `\351?`, pdfString("é€"))
+}
+
+func TestSubscriptionIssuesInvoiceOnce(t *testing.T) {
+	invoices, sender := new(MockStorer), new(MockMailer)
+	s := NewService(invoices, sender, &common.BillingConfig{IssuerName: "Acme", TaxRate: 20}, "http://localhost")
+	usr := &user.User{ID: uuid.New(), Email: "test@example.com", FirstName: "Test", LastName: "User"}
+	subID, periodEnd := uuid.New(), time.Now()
+
+	invoices.On("Issued", subID, periodEnd).Return(false, nil).Once()
+	invoices.On("Store", mock.MatchedBy(func(inv *Invoice) bool {
+		return inv.UserID == usr.ID && inv.CustomerName == "Test User" && inv.IssuerName == "Acme" &&
+			inv.SubscriptionID.UUID == subID && inv.Total == 1080
+	})).Return(nil)
+	sender.On("Invoice", usr.ID, "test@example.com", mock.Anything, "http://localhost/account/invoices", mock.MatchedBy(func(docs []mail.Attachment) bool {
+		return len(docs) == 2 && docs[0].ContentType == "application/pdf" && bytes.HasPrefix(docs[0].Content, []byte("%PDF"))
+	})).Return(nil)
+
+	err := s.Subscription(usr, subID, periodEnd, "usd", Line{Description: "Pro", Quantity: 1, UnitAmount: 900})
+	assert.NoError(t, err)
+	invoices.AssertExpectations(t)
+	sender.AssertExpectations(t)
+
+	invoices.On("Issued", subID, periodEnd).Return(true, nil)
+	err = s.Subscription(usr, subID, periodEnd, "usd", Line{Description: "Pro", Quantity: 1, UnitAmount: 900})
+	assert.NoError(t, err)
+	invoices.AssertNumberOfCalls(t, "Store", 1)
+}
+
+func TestIssueKeepsInvoiceWhenSendingFails(t *testing.T) {
+	invoices, sender := new(MockStorer), new(MockMailer)
+	s := NewService(invoices, sender, &common.BillingConfig{}, "")
+	inv := testInvoice()
+
+	invoices.On("Store", inv).Return(nil)
+	sender.On("Invoice", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(assert.AnError)
+
+	assert.NoError(t, s.Issue(inv))
+}
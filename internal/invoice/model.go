@@ -0,0 +1,165 @@
+package invoice
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+)
+
+// zeroDecimal are the currencies without minor units, their amounts are in the major unit.
+var zeroDecimal = map[string]bool{
+	"bif": true, "clp": true, "djf": true, "gnf": true, "jpy": true, "kmf": true, "krw": true, "mga": true,
+	"pyg": true, "rwf": true, "ugx": true, "vnd": true, "vuv": true, "xaf": true, "xof": true, "xpf": true,
+}
+
+// FormatAmount is a function formatting an amount in the minor units of the currency, e.g. `1250, "eur"` as
+// `12.50 EUR`.
+func FormatAmount(amount int, currency string) string {
+	code := strings.ToUpper(currency)
+	if zeroDecimal[strings.ToLower(currency)] {
+		return fmt.Sprintf("%d %v", amount, code)
+	}
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%v%d.%02d %v", sign, amount/100, amount%100, code)
+}
+
+// Party is a struct with the details of the issuer or the customer of an invoice.
+type Party struct {
+	Name    string
+	Address string
+	TaxID   string
+	Email   string
+}
+
+// Line is a struct representing a line item of an invoice for database storage. Amounts are in the minor units of the
+// currency of the invoice.
+type Line struct {
+	InvoiceID   uuid.UUID `db:"invoice_id"`
+	Position    int       `db:"position"`
+	Description string    `db:"description"`
+	Quantity    int       `db:"quantity"`
+	UnitAmount  int       `db:"unit_amount"`
+	Amount      int       `db:"amount"`
+}
+
+// Invoice is a struct representing an invoice issued to a user for database storage. The details of the issuer and
+// the customer are recorded at issuing, so later changes do not alter issued invoices. The number is assigned when
+// the invoice is stored, sequentially and without gaps within the year of issuing.
+type Invoice struct {
+	ID             uuid.UUID     `db:"invoice_id"`
+	Number         string        `db:"number"`
+	Year           int           `db:"year"`
+	Sequence       int           `db:"sequence"`
+	UserID         uuid.UUID     `db:"user_id"`
+	SubscriptionID uuid.NullUUID `db:"subscription_id"`
+	PeriodEnd      null.Time     `db:"period_end"`
+	IssuerName     string        `db:"issuer_name"`
+	IssuerAddress  string        `db:"issuer_address"`
+	IssuerTaxID    string        `db:"issuer_tax_id"`
+	CustomerName   string        `db:"customer_name"`
+	CustomerEmail  string        `db:"customer_email"`
+	Currency       string        `db:"currency"`
+	TaxRate        float64       `db:"tax_rate"`
+	Subtotal       int           `db:"subtotal"`
+	Tax            int           `db:"tax"`
+	Total          int           `db:"total"`
+	IssuedAt       time.Time     `db:"issued_at"`
+	Lines          []Line        `db:"-"`
+}
+
+// NewInvoice creates a new `Invoice` of the user from the issuer to the customer, with the line items and the tax
+// rate in percent provided. The amounts of the lines and the totals are calculated.
+func NewInvoice(userID uuid.UUID, issuer Party, customer Party, currency string, taxRate float64, lines ...Line) *Invoice {
+	inv := &Invoice{
+		ID:            uuid.New(),
+		UserID:        userID,
+		IssuerName:    issuer.Name,
+		IssuerAddress: issuer.Address,
+		IssuerTaxID:   issuer.TaxID,
+		CustomerName:  customer.Name,
+		CustomerEmail: customer.Email,
+		Currency:      strings.ToLower(currency),
+		TaxRate:       taxRate,
+		IssuedAt:      time.Now().UTC(),
+	}
+	for i, l := range lines {
+		l.InvoiceID = inv.ID
+		l.Position = i + 1
+		l.Amount = l.Quantity * l.UnitAmount
+		inv.Subtotal += l.Amount
+		inv.Lines = append(inv.Lines, l)
+	}
+	inv.Tax = int(math.Round(float64(inv.Subtotal) * taxRate / 100))
+	inv.Total = inv.Subtotal + inv.Tax
+	return inv
+}
+
+// Numbered is a method of the `Invoice` struct. Sets the number of the invoice from the sequence of the year of
+// issuing.
+func (i *Invoice) Numbered(sequence int) {
+	i.Year = i.IssuedAt.Year()
+	i.Sequence = sequence
+	i.Number = fmt.Sprintf("%d-%06d", i.Year, sequence)
+}
+
+// Amount is a method of the `Invoice` struct. Formats an amount in the currency of the invoice.
+func (i *Invoice) Amount(amount int) string {
+	return FormatAmount(amount, i.Currency)
+}
+
+// AsView is a method of the `Invoice` struct. It converts an `Invoice` object into a `View` object.
+func (i *Invoice) AsView() View {
+	v := View{
+		ID:       i.ID.String(),
+		Number:   i.Number,
+		IssuedAt: int(i.IssuedAt.Unix()),
+		Currency: i.Currency,
+		Subtotal: i.Subtotal,
+		Tax:      i.Tax,
+		Total:    i.Total,
+	}
+	for _, l := range i.Lines {
+		v.Lines = append(v.Lines, LineView{
+			Description: l.Description,
+			Quantity:    l.Quantity,
+			UnitAmount:  l.UnitAmount,
+			Amount:      l.Amount,
+		})
+	}
+	return v
+}
+
+// View is the JSON representation of an invoice, amounts in the minor units of the currency.
+type View struct {
+	ID       string     `json:"id"`
+	Number   string     `json:"number"`
+	IssuedAt int        `json:"issued_at"`
+	Currency string     `json:"currency"`
+	Subtotal int        `json:"subtotal"`
+	Tax      int        `json:"tax"`
+	Total    int        `json:"total"`
+	Lines    []LineView `json:"lines,omitempty"`
+}
+
+// LineView is the JSON representation of a line item of an invoice.
+type LineView struct {
+	Description string `json:"description"`
+	Quantity    int    `json:"quantity"`
+	UnitAmount  int    `json:"unit_amount"`
+	Amount      int    `json:"amount"`
+}
+
+// Storer is the interface for `Invoice` persistence
+type Storer interface {
+	Store(inv *Invoice) error
+	ByID(id uuid.UUID) (*Invoice, error)
+	ByUser(userID uuid.UUID) ([]Invoice, error)
+	Issued(subscriptionID uuid.UUID, periodEnd time.Time) (bool, error)
+}
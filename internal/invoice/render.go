@@ -0,0 +1,84 @@
+package invoice
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	htmltemplate "html/template"
+	"text/template"
+)
+
+//go:embed "invoice.html"
+var ht string
+
+//go:embed "invoice.pdf.tmpl"
+var pt string
+
+var (
+	htmlTemplate = htmltemplate.Must(htmltemplate.New("invoice").Parse(ht))
+	// pdfTemplate renders the content stream of the single page of the PDF document.
+	pdfTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{"pdf": pdfString}).Parse(pt))
+)
+
+// HTML is a function rendering the invoice in parameter as an HTML document.
+func HTML(inv *Invoice) ([]byte, error) {
+	var b bytes.Buffer
+	if err := htmlTemplate.Execute(&b, inv); err != nil {
+		return nil, fmt.Errorf("failed to render invoice: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// PDF is a function rendering the invoice in parameter as a single page A4 PDF document, set in the standard Helvetica
+// font, so no font has to be embedded.
+func PDF(inv *Invoice) ([]byte, error) {
+	var content bytes.Buffer
+	if err := pdfTemplate.Execute(&content, inv); err != nil {
+		return nil, fmt.Errorf("failed to render invoice: %w", err)
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> /Contents 4 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.Bytes()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Title (%s) /Producer (%s) >>", pdfString("Invoice "+inv.Number), pdfString(inv.IssuerName)),
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, o := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, len(objects), xref)
+	return b.Bytes(), nil
+}
+
+// pdfString escapes a text for a PDF string literal in the WinAnsi encoding of the standard fonts. Characters outside
+// of Latin-1 can not be set in the standard fonts, they are replaced with a question mark.
+func pdfString(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
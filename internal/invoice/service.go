@@ -0,0 +1,90 @@
+package invoice
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+	"github.com/inokone/go-micro-saas/internal/mail"
+)
+
+// Service is a struct for issuing invoices and sending them to the customers.
+type Service struct {
+	invoices Storer
+	sender   mail.Mailer
+	config   *common.BillingConfig
+	frontend string
+}
+
+// NewService creates a new `Service`, based on the invoice persistence, the mail sender, the billing configuration
+// with the details of the issuer, and the URL of the frontend.
+func NewService(invoices Storer, sender mail.Mailer, config *common.BillingConfig, frontend string) *Service {
+	return &Service{
+		invoices: invoices,
+		sender:   sender,
+		config:   config,
+		frontend: frontend,
+	}
+}
+
+// Issuer is a method of `Service` returning the details of the issuer of the invoices from the configuration.
+func (s *Service) Issuer() Party {
+	return Party{
+		Name:    s.config.IssuerName,
+		Address: s.config.IssuerAddress,
+		TaxID:   s.config.IssuerTaxID,
+	}
+}
+
+// Subscription is a method of `Service` issuing the invoice of a subscription period to the subscriber. A period is
+// invoiced only once, so repeated notifications of the payment provider do not issue more invoices.
+func (s *Service) Subscription(usr *user.User, subscriptionID uuid.UUID, periodEnd time.Time, currency string, lines ...Line) error {
+	issued, err := s.invoices.Issued(subscriptionID, periodEnd)
+	if err != nil || issued {
+		return err
+	}
+	inv := NewInvoice(usr.ID, s.Issuer(), Customer(usr), currency, s.config.TaxRate, lines...)
+	inv.SubscriptionID = uuid.NullUUID{UUID: subscriptionID, Valid: true}
+	inv.PeriodEnd = null.TimeFrom(periodEnd)
+	return s.Issue(inv)
+}
+
+// Issue is a method of `Service` numbering and storing the invoice, then sending it to the customer with the PDF and
+// HTML documents attached. An invoice failed to be sent is still issued, the customer can download it any time.
+func (s *Service) Issue(inv *Invoice) error {
+	if err := s.invoices.Store(inv); err != nil {
+		return err
+	}
+	if err := s.send(inv); err != nil {
+		log.WithError(err).WithField("invoice", inv.Number).Error("Failed to send invoice")
+	}
+	return nil
+}
+
+func (s *Service) send(inv *Invoice) error {
+	pdf, err := PDF(inv)
+	if err != nil {
+		return err
+	}
+	html, err := HTML(inv)
+	if err != nil {
+		return err
+	}
+	return s.sender.Invoice(inv.UserID, inv.CustomerEmail, inv.Number, s.frontend+"/account/invoices",
+		mail.Attachment{Name: inv.Number + ".pdf", ContentType: "application/pdf", Content: pdf},
+		mail.Attachment{Name: inv.Number + ".html", ContentType: "text/html", Content: html})
+}
+
+// Customer is a function returning the details of the user in parameter as the customer of an invoice.
+func Customer(usr *user.User) Party {
+	name := strings.TrimSpace(usr.FirstName + " " + usr.LastName)
+	if name == "" {
+		name = usr.Email
+	}
+	return Party{Name: name, Email: usr.Email}
+}
@@ -0,0 +1,99 @@
+package invoice
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const selectInvoices = `SELECT invoice_id, number, year, sequence, user_id, subscription_id, period_end, issuer_name,
+	issuer_address, issuer_tax_id, customer_name, customer_email, currency, tax_rate, subtotal, tax, total, issued_at
+	FROM microsaas.invoices`
+
+// PostgresStorer is the `Storer` implementation based on sqlx library.
+type PostgresStorer struct {
+	db *sqlx.DB
+}
+
+// NewPostgresStorer creates a new `PostgresStorer` instance based on the sqlx library.
+func NewPostgresStorer(db *sqlx.DB) *PostgresStorer {
+	return &PostgresStorer{
+		db: db,
+	}
+}
+
+// Store is a method of the `PostgresStorer` struct. Takes an `Invoice` as parameter, numbers it and persists it with
+// its lines. The counter of the year is incremented in the same transaction, the row lock of the counter serializes
+// concurrent invoices and a failed transaction rolls the counter back, so the numbers have no gaps.
+func (s *PostgresStorer) Store(inv *Invoice) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to store invoice: %w", err)
+	}
+	defer tx.Rollback()
+
+	var sequence int
+	query := `INSERT INTO microsaas.invoice_sequences (year, last_sequence) VALUES ($1, 1) ON CONFLICT (year)
+		DO UPDATE SET last_sequence = microsaas.invoice_sequences.last_sequence + 1 RETURNING last_sequence`
+	if err = tx.Get(&sequence, query, inv.IssuedAt.Year()); err != nil {
+		return fmt.Errorf("failed to number invoice: %w", err)
+	}
+	inv.Numbered(sequence)
+
+	query = `INSERT INTO microsaas.invoices (invoice_id, number, year, sequence, user_id, subscription_id, period_end,
+		issuer_name, issuer_address, issuer_tax_id, customer_name, customer_email, currency, tax_rate, subtotal, tax, total,
+		issued_at) VALUES (:invoice_id, :number, :year, :sequence, :user_id, :subscription_id, :period_end, :issuer_name,
+		:issuer_address, :issuer_tax_id, :customer_name, :customer_email, :currency, :tax_rate, :subtotal, :tax, :total,
+		:issued_at)`
+	if _, err = tx.NamedExec(query, inv); err != nil {
+		return fmt.Errorf("failed to store invoice: %w", err)
+	}
+	query = `INSERT INTO microsaas.invoice_lines (invoice_id, position, description, quantity, unit_amount, amount)
+		VALUES (:invoice_id, :position, :description, :quantity, :unit_amount, :amount)`
+	for _, l := range inv.Lines {
+		if _, err = tx.NamedExec(query, l); err != nil {
+			return fmt.Errorf("failed to store invoice line: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to store invoice: %w", err)
+	}
+	return nil
+}
+
+// ByID is a method of the `PostgresStorer` struct. Takes an UUID as parameter to load an `Invoice` with its lines.
+func (s *PostgresStorer) ByID(id uuid.UUID) (*Invoice, error) {
+	var inv Invoice
+	if err := s.db.Get(&inv, selectInvoices+` WHERE invoice_id = $1`, id); err != nil {
+		return nil, fmt.Errorf("failed to get invoice by ID: %w", err)
+	}
+	query := `SELECT invoice_id, position, description, quantity, unit_amount, amount FROM microsaas.invoice_lines
+		WHERE invoice_id = $1 ORDER BY position`
+	if err := s.db.Select(&inv.Lines, query, id); err != nil {
+		return nil, fmt.Errorf("failed to get lines of invoice: %w", err)
+	}
+	return &inv, nil
+}
+
+// ByUser is a method of the `PostgresStorer` struct. Loads the invoices of the user in parameter without their lines,
+// the latest first.
+func (s *PostgresStorer) ByUser(userID uuid.UUID) ([]Invoice, error) {
+	var invoices []Invoice
+	if err := s.db.Select(&invoices, selectInvoices+` WHERE user_id = $1 ORDER BY issued_at DESC`, userID); err != nil {
+		return nil, fmt.Errorf("failed to get invoices of user: %w", err)
+	}
+	return invoices, nil
+}
+
+// Issued is a method of the `PostgresStorer` struct. Returns whether an invoice of the subscription period ending at
+// the time in parameter is already issued.
+func (s *PostgresStorer) Issued(subscriptionID uuid.UUID, periodEnd time.Time) (bool, error) {
+	var issued bool
+	query := `SELECT EXISTS (SELECT 1 FROM microsaas.invoices WHERE subscription_id = $1 AND period_end = $2)`
+	if err := s.db.Get(&issued, query, subscriptionID, periodEnd); err != nil {
+		return false, fmt.Errorf("failed to check invoice of subscription: %w", err)
+	}
+	return issued, nil
+}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	_ "embed"
 	"errors"
+	"fmt"
 	"html/template"
+	"io"
 	"time"
 
 	"github.com/cskr/pubsub/v2"
@@ -18,6 +20,16 @@ import (
 const (
 	confirmation = "confirmation"
 	pwdReset     = "passwordreset"
+	magicLink    = "magiclink"
+	invitation   = "invitation"
+	invoice      = "invoice"
+	lockout      = "lockout"
+	newSignin    = "newsignin"
+	pwdChanged   = "passwordchanged"
+	pwdRestored  = "passwordrestored"
+	twoFactor    = "twofactor"
+	identity     = "identity"
+	disabled     = "accountdisabled"
 )
 
 //go:embed "confirmation.html"
@@ -26,6 +38,57 @@ var ct string
 //go:embed "passwordreset.html"
 var pt string
 
+//go:embed "magiclink.html"
+var mt string
+
+//go:embed "invitation.html"
+var it string
+
+//go:embed "invoice.html"
+var vt string
+
+//go:embed "lockout.html"
+var lt string
+
+//go:embed "newsignin.html"
+var nst string
+
+//go:embed "passwordchanged.html"
+var pct string
+
+//go:embed "passwordrestored.html"
+var prt string
+
+//go:embed "twofactor.html"
+var tft string
+
+//go:embed "identity.html"
+var idt string
+
+//go:embed "accountdisabled.html"
+var adt string
+
+// alert is the template, subject and texts of the e-mail of a security alert.
+type alert struct {
+	template string
+	subject  string
+	change   string
+}
+
+var alerts = map[string]alert{
+	common.NewSignin:                {template: newSignin, subject: "New Sign In"},
+	common.PasswordChanged:          {template: pwdChanged, subject: "Password Changed"},
+	common.PasswordReset:            {template: pwdRestored, subject: "Password Reset"},
+	common.TwoFactorEnabled:         {template: twoFactor, subject: "Two-Factor Authentication Enabled", change: "Two-factor authentication has been enabled"},
+	common.TwoFactorDisabled:        {template: twoFactor, subject: "Two-Factor Authentication Disabled", change: "Two-factor authentication has been disabled"},
+	common.RecoveryCodesRegenerated: {template: twoFactor, subject: "Recovery Codes Regenerated", change: "New two-factor recovery codes have been generated, the earlier ones no longer work,"},
+	common.IdentityLinked:           {template: identity, subject: "Sign In Method Linked", change: "linked to"},
+	common.IdentityUnlinked:         {template: identity, subject: "Sign In Method Unlinked", change: "unlinked from"},
+	common.PasskeyAdded:             {template: identity, subject: "Passkey Added", change: "added to"},
+	common.PasskeyRemoved:           {template: identity, subject: "Passkey Removed", change: "removed from"},
+	common.AccountDisabled:          {template: disabled, subject: "Account Deactivated"},
+}
+
 // Dialer is an interface for sending emails
 type Dialer interface {
 	DialAndSend(msg ...*mail.Message) error
@@ -36,6 +99,11 @@ type Mailer interface {
 	Send(r *SendRequest) error
 	EmailConfirmation(recipient string, confirmationURL string) error
 	PasswordReset(recipient string, resetURL string) error
+	MagicLink(recipient string, signinURL string) error
+	Invitation(recipient string, organization string, inviter string, invitationURL string) error
+	Invoice(userID uuid.UUID, recipient string, number string, invoicesURL string, documents ...Attachment) error
+	Lockout(userID uuid.UUID, recipient string, ip string, until time.Time, unlockURL string) error
+	SecurityAlert(userID uuid.UUID, alert string, data common.SecurityData, at time.Time) error
 }
 
 // Service is a struct for a service sending mails for our users.
@@ -47,12 +115,20 @@ type Service struct {
 }
 
 type SendRequest struct {
-	UserID    uuid.UUID
-	Recipient string
-	Subject   string
-	Template  string
-	Data      interface{}
-	App       string
+	UserID      uuid.UUID
+	Recipient   string
+	Subject     string
+	Template    string
+	Data        interface{}
+	App         string
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to an e-mail.
+type Attachment struct {
+	Name        string
+	ContentType string
+	Content     []byte
 }
 
 // NewService create a new `Service` entity based on the configuration.
@@ -74,6 +150,16 @@ func loadTemplates() map[string]*template.Template {
 	return map[string]*template.Template{
 		confirmation: mustLoadTemplate(ct),
 		pwdReset:     mustLoadTemplate(pt),
+		magicLink:    mustLoadTemplate(mt),
+		invitation:   mustLoadTemplate(it),
+		invoice:      mustLoadTemplate(vt),
+		lockout:      mustLoadTemplate(lt),
+		newSignin:    mustLoadTemplate(nst),
+		pwdChanged:   mustLoadTemplate(pct),
+		pwdRestored:  mustLoadTemplate(prt),
+		twoFactor:    mustLoadTemplate(tft),
+		identity:     mustLoadTemplate(idt),
+		disabled:     mustLoadTemplate(adt),
 	}
 }
 
@@ -91,9 +177,39 @@ type templateData struct {
 	App  string
 }
 
+type invitationData struct {
+	Link         string
+	App          string
+	Organization string
+	Inviter      string
+}
+
+type lockoutData struct {
+	Link  string
+	App   string
+	IP    string
+	Until string
+}
+
+type alertData struct {
+	App    string
+	Title  string
+	Change string
+	Detail string
+	IP     string
+	Device string
+	Time   string
+}
+
+type invoiceData struct {
+	Link   string
+	App    string
+	Number string
+}
+
 // Send is a method of `Service` sends an e-mail to the recipient email address with the subject and body provided as parameters
 // If SMTP server is not configured the service will not return error, just logs it as a warning.
-func (s *Service) send(recipient string, subject string, body string, userID uuid.UUID) error {
+func (s *Service) send(recipient string, subject string, body string, userID uuid.UUID, attachments ...Attachment) error {
 	if len(s.config.SMTPAddress) == 0 {
 		log.Warn("SMTP is not set up, failed to send the e-mail!")
 		return nil
@@ -104,6 +220,14 @@ func (s *Service) send(recipient string, subject string, body string, userID uui
 	m.SetHeader("To", recipient)
 	m.SetHeader("Subject", subject)
 	m.SetBody("text/html", body)
+	for _, a := range attachments {
+		content := a.Content
+		m.Attach(a.Name, mail.SetHeader(map[string][]string{"Content-Type": {a.ContentType}}),
+			mail.SetCopyFunc(func(w io.Writer) error {
+				_, err := w.Write(content)
+				return err
+			}))
+	}
 
 	// Send the email
 	err := s.dialer.DialAndSend(m)
@@ -138,7 +262,7 @@ func (s *Service) Send(r *SendRequest) error {
 	if err := t.Execute(&c, r.Data); err != nil {
 		return err
 	}
-	return s.send(r.Recipient, r.Subject, c.String(), r.UserID)
+	return s.send(r.Recipient, r.Subject, c.String(), r.UserID, r.Attachments...)
 }
 
 // EmailConfirmation is a method of `Service` sends an e-mail confirmation message to the recipient email address
@@ -168,3 +292,91 @@ func (s *Service) PasswordReset(recipient string, resetURL string) error {
 		},
 	})
 }
+
+// MagicLink is a method of `Service` sends a single-use sign in link to the recipient email address
+func (s *Service) MagicLink(recipient string, signinURL string) error {
+	return s.Send(&SendRequest{
+		UserID:    uuid.Nil,
+		Recipient: recipient,
+		Subject:   "Sign In Link",
+		Template:  magicLink,
+		Data: templateData{
+			Link: signinURL,
+			App:  s.config.ApplicationName,
+		},
+	})
+}
+
+// Invitation is a method of `Service` sends an invitation to join an organization to the recipient email address
+func (s *Service) Invitation(recipient string, organization string, inviter string, invitationURL string) error {
+	return s.Send(&SendRequest{
+		UserID:    uuid.Nil,
+		Recipient: recipient,
+		Subject:   "Invitation to " + organization,
+		Template:  invitation,
+		Data: invitationData{
+			Link:         invitationURL,
+			App:          s.config.ApplicationName,
+			Organization: organization,
+			Inviter:      inviter,
+		},
+	})
+}
+
+// Invoice is a method of `Service` sends an invoice to the recipient email address, with the documents of the invoice
+// attached
+func (s *Service) Invoice(userID uuid.UUID, recipient string, number string, invoicesURL string, documents ...Attachment) error {
+	return s.Send(&SendRequest{
+		UserID:    userID,
+		Recipient: recipient,
+		Subject:   "Invoice " + number,
+		Template:  invoice,
+		Data: invoiceData{
+			Link:   invoicesURL,
+			App:    s.config.ApplicationName,
+			Number: number,
+		},
+		Attachments: documents,
+	})
+}
+
+// Lockout is a method of `Service` sends a notification of an account locked for failed sign in attempts to the
+// recipient email address, with a link to unlock the account if the attempts were not made by the owner.
+func (s *Service) Lockout(userID uuid.UUID, recipient string, ip string, until time.Time, unlockURL string) error {
+	return s.Send(&SendRequest{
+		UserID:    userID,
+		Recipient: recipient,
+		Subject:   "Account Locked",
+		Template:  lockout,
+		Data: lockoutData{
+			Link:  unlockURL,
+			App:   s.config.ApplicationName,
+			IP:    ip,
+			Until: until.UTC().Format("2006-01-02 15:04 MST"),
+		},
+	})
+}
+
+// SecurityAlert is a method of `Service` sends the e-mail of a security alert of the account to the address of the user
+// in the alert data, with the IP address and device of the change.
+func (s *Service) SecurityAlert(userID uuid.UUID, alert string, data common.SecurityData, at time.Time) error {
+	a, ok := alerts[alert]
+	if !ok {
+		return fmt.Errorf("unknown security alert %v", alert)
+	}
+	return s.Send(&SendRequest{
+		UserID:    userID,
+		Recipient: data.Email,
+		Subject:   a.subject,
+		Template:  a.template,
+		Data: alertData{
+			App:    s.config.ApplicationName,
+			Title:  a.subject,
+			Change: a.change,
+			Detail: data.Detail,
+			IP:     data.IP,
+			Device: data.Device,
+			Time:   at.UTC().Format("2006-01-02 15:04 MST"),
+		},
+	})
+}
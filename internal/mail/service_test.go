@@ -1,7 +1,9 @@
 package mail
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/cskr/pubsub/v2"
 	"github.com/google/uuid"
@@ -124,3 +126,85 @@ func TestPasswordResetIsSent(t *testing.T) {
 
 	mockDialer.AssertCalled(t, "DialAndSend", mock.Anything)
 }
+
+func TestMagicLinkIsSent(t *testing.T) {
+	service, mockDialer, _ := setupTestService()
+	mockDialer.On("DialAndSend", mock.Anything).Return(nil)
+
+	err := service.MagicLink("test@example.com", "http://example.com/signin/magic-link?token=token")
+	assert.NoError(t, err)
+
+	mockDialer.AssertCalled(t, "DialAndSend", mock.Anything)
+}
+
+func TestInvitationIsSent(t *testing.T) {
+	service, mockDialer, _ := setupTestService()
+	mockDialer.On("DialAndSend", mock.Anything).Return(nil)
+
+	err := service.Invitation("test@example.com", "Acme", "Test User", "http://example.com/invitation?token=token")
+	assert.NoError(t, err)
+
+	mockDialer.AssertCalled(t, "DialAndSend", mock.MatchedBy(func(m *mail.Message) bool {
+		return m.GetHeader("Subject")[0] == "Invitation to Acme"
+	}))
+}
+
+func TestInvoiceIsSentWithAttachments(t *testing.T) {
+	service, mockDialer, _ := setupTestService()
+	mockDialer.On("DialAndSend", mock.Anything).Return(nil)
+
+	err := service.Invoice(uuid.New(), "test@example.com", "2026-000001", "http://example.com/account/invoices",
+		Attachment{Name: "2026-000001.pdf", ContentType: "application/pdf", Content: []byte("%PDF-1.4")})
+	assert.NoError(t, err)
+
+	mockDialer.AssertCalled(t, "DialAndSend", mock.MatchedBy(func(m *mail.Message) bool {
+		var b strings.Builder
+		_, err := m.WriteTo(&b)
+		return err == nil && m.GetHeader("Subject")[0] == "Invoice 2026-000001" &&
+			strings.Contains(b.String(), `filename="2026-000001.pdf"`) && strings.Contains(b.String(), "Content-Type: application/pdf")
+	}))
+}
+
+func TestLockoutIsSent(t *testing.T) {
+	service, mockDialer, _ := setupTestService()
+	mockDialer.On("DialAndSend", mock.Anything).Return(nil)
+
+	err := service.Lockout(uuid.New(), "test@example.com", "192.0.2.1", time.Now().Add(time.Hour), "http://example.com/account/unlock?token=token")
+	assert.NoError(t, err)
+
+	mockDialer.AssertCalled(t, "DialAndSend", mock.MatchedBy(func(m *mail.Message) bool {
+		var b strings.Builder
+		_, err := m.WriteTo(&b)
+		return err == nil && m.GetHeader("Subject")[0] == "Account Locked" && strings.Contains(b.String(), "192.0.2.1")
+	}))
+}
+
+func TestSecurityAlertIsSent(t *testing.T) {
+	service, mockDialer, _ := setupTestService()
+	mockDialer.On("DialAndSend", mock.Anything).Return(nil)
+
+	err := service.SecurityAlert(uuid.New(), common.IdentityLinked, common.SecurityData{
+		Email:  "test@example.com",
+		IP:     "192.0.2.1",
+		Device: "Firefox on Linux",
+		Detail: "google",
+	}, time.Now())
+	assert.NoError(t, err)
+
+	mockDialer.AssertCalled(t, "DialAndSend", mock.MatchedBy(func(m *mail.Message) bool {
+		var b strings.Builder
+		_, err := m.WriteTo(&b)
+		return err == nil && m.GetHeader("Subject")[0] == "Sign In Method Linked" && m.GetHeader("To")[0] == "test@example.com" &&
+			strings.Contains(b.String(), "google sign in method has been linked to")
+	}))
+}
+
+func TestSecurityAlertsHaveTemplates(t *testing.T) {
+	service, mockDialer, _ := setupTestService()
+	mockDialer.On("DialAndSend", mock.Anything).Return(nil)
+
+	for alert := range alerts {
+		assert.NoError(t, service.SecurityAlert(uuid.New(), alert, common.SecurityData{Email: "test@example.com"}, time.Now()), alert)
+	}
+	assert.Error(t, service.SecurityAlert(uuid.New(), "unknown", common.SecurityData{Email: "test@example.com"}, time.Now()))
+}
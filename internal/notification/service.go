@@ -2,6 +2,7 @@ package notification
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/inokone/go-micro-saas/internal/common"
 	"github.com/inokone/go-micro-saas/internal/mail"
@@ -40,9 +41,18 @@ func (s *Service) Start(ctx context.Context) {
 	}()
 }
 
+// Send is a method of `Service` delivering the notification of an event. The security alerts are e-mailed to the
+// user, the other events have no notification.
 func (s *Service) Send(event *common.Event) error {
 	switch event.Type {
-	// TODO: Handle notification types here
+	case common.NewSignin, common.PasswordChanged, common.PasswordReset, common.TwoFactorEnabled, common.TwoFactorDisabled,
+		common.RecoveryCodesRegenerated, common.IdentityLinked, common.IdentityUnlinked, common.PasskeyAdded, common.PasskeyRemoved,
+		common.AccountDisabled:
+		data, ok := event.Data.(common.SecurityData)
+		if !ok {
+			return fmt.Errorf("invalid data of %v event", event.Type)
+		}
+		return s.mailer.SecurityAlert(event.User, event.Type, data, event.Time)
 	default:
 		return nil
 	}
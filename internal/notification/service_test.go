@@ -33,6 +33,31 @@ func (m *MockMailService) PasswordReset(recipient string, resetURL string) error
 	return args.Error(0)
 }
 
+func (m *MockMailService) MagicLink(recipient string, signinURL string) error {
+	args := m.Called(recipient, signinURL)
+	return args.Error(0)
+}
+
+func (m *MockMailService) Invitation(recipient string, organization string, inviter string, invitationURL string) error {
+	args := m.Called(recipient, organization, inviter, invitationURL)
+	return args.Error(0)
+}
+
+func (m *MockMailService) Invoice(userID uuid.UUID, recipient string, number string, invoicesURL string, documents ...mail.Attachment) error {
+	args := m.Called(userID, recipient, number, invoicesURL, documents)
+	return args.Error(0)
+}
+
+func (m *MockMailService) Lockout(userID uuid.UUID, recipient string, ip string, until time.Time, unlockURL string) error {
+	args := m.Called(userID, recipient, ip, until, unlockURL)
+	return args.Error(0)
+}
+
+func (m *MockMailService) SecurityAlert(userID uuid.UUID, alert string, data common.SecurityData, at time.Time) error {
+	args := m.Called(userID, alert, data, at)
+	return args.Error(0)
+}
+
 func TestNewServiceInitsMembers(t *testing.T) {
 	mockMailer := new(MockMailService)
 	source := make(chan common.Event)
@@ -73,3 +98,33 @@ func TestGracefulShutdownConsumesEvents(t *testing.T) {
 	// Assert that the source channel is empty
 	assert.Empty(t, source)
 }
+
+func TestSendEmailsSecurityAlert(t *testing.T) {
+	mockMailer := new(MockMailService)
+	service := NewService(make(chan common.Event), mockMailer)
+	data := common.SecurityData{Email: "test@example.com", IP: "192.0.2.1", Device: "Firefox on Linux"}
+	event := common.Event{ID: uuid.New(), Type: common.PasswordChanged, Time: time.Now(), User: uuid.New(), Data: data}
+
+	mockMailer.On("SecurityAlert", event.User, common.PasswordChanged, data, event.Time).Return(nil)
+
+	assert.NoError(t, service.Send(&event))
+	mockMailer.AssertExpectations(t)
+}
+
+func TestSendRejectsSecurityAlertWithoutData(t *testing.T) {
+	mockMailer := new(MockMailService)
+	service := NewService(make(chan common.Event), mockMailer)
+	event := common.Event{ID: uuid.New(), Type: common.AccountDisabled, Time: time.Now(), User: uuid.New()}
+
+	assert.Error(t, service.Send(&event))
+	mockMailer.AssertNotCalled(t, "SecurityAlert", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSendIgnoresOtherEvents(t *testing.T) {
+	mockMailer := new(MockMailService)
+	service := NewService(make(chan common.Event), mockMailer)
+	event := common.Event{ID: uuid.New(), Type: common.RoleChanged, Time: time.Now(), User: uuid.New()}
+
+	assert.NoError(t, service.Send(&event))
+	mockMailer.AssertNotCalled(t, "SecurityAlert", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
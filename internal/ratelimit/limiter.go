@@ -0,0 +1,203 @@
+package ratelimit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+const (
+	// Signin is the route group of the sign in endpoints.
+	Signin = "signin"
+	// Account is the route group of the endpoints creating and recovering accounts, sending emails.
+	Account = "account"
+	// Security is the route group of the security settings of the signed in user.
+	Security = "security"
+	// Failures are the limits of the failed sign in attempts, counted by `Fail` and checked by `Blocked`.
+	Failures = "failures"
+
+	purgeInterval = 10 * time.Minute
+	maxBody       = 1 << 20
+)
+
+// Limiter is a struct for middleware limiting the rate of requests by the rules of the route groups.
+type Limiter struct {
+	store   Store
+	groups  map[string][]Rule
+	horizon time.Duration
+	mutex   sync.Mutex
+	purged  time.Time
+}
+
+// NewLimiter creates a new `Limiter` with the rules of the route groups in the configuration. The counters are kept in
+// memory, or in the Postgres store in parameter when configured.
+func NewLimiter(c *common.RateLimitConfig, postgres Store) (*Limiter, error) {
+	l := &Limiter{groups: make(map[string][]Rule), purged: time.Now()}
+	switch c.Store {
+	case "", "memory":
+		l.store = NewMemoryStore()
+	case "postgres":
+		l.store = postgres
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %v", c.Store)
+	}
+	for name, spec := range map[string]string{Signin: c.Signin, Account: c.Account, Security: c.Security, Failures: c.Failures} {
+		rules, err := ParseRules(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limits of %v: %w", name, err)
+		}
+		for _, r := range rules {
+			if name == Failures && r.Key != IP && r.Key != Subnet {
+				return nil, fmt.Errorf("invalid rate limits of %v: only ip and subnet rules are supported", name)
+			}
+		}
+		for _, r := range rules {
+			l.horizon = max(l.horizon, r.Window)
+		}
+		l.groups[name] = rules
+	}
+	return l, nil
+}
+
+// Group is a method of `Limiter` returning the middleware limiting the requests by the rules of the route group. The
+// requests over the limit of any rule are rejected with status 429 and a `Retry-After` header with the seconds until
+// the window of the rule ends. Rules by user are applied after the user is authenticated, so the middleware has to
+// follow the validation of the user. When the counters can not be reached, requests are let through.
+func (l *Limiter) Group(name string) gin.HandlerFunc {
+	rules := l.groups[name]
+	return func(g *gin.Context) {
+		if len(rules) == 0 {
+			return
+		}
+		now := time.Now()
+		l.purge(now)
+
+		var retry time.Duration
+		for _, r := range rules {
+			value := valueOf(g, r.Key)
+			if value == "" {
+				continue
+			}
+			start := r.Start(now)
+			hits, err := l.store.Hit(key(name, r, value), start)
+			if err != nil {
+				log.WithError(err).Error("Failed to count request for rate limiting")
+				continue
+			}
+			if wait := start.Add(r.Window).Sub(now); hits > r.Limit && wait > retry {
+				retry = wait
+			}
+		}
+		if retry > 0 {
+			g.Header("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			g.AbortWithStatusJSON(http.StatusTooManyRequests, common.StatusMessage{Message: "Too many requests, please try again later!"})
+		}
+	}
+}
+
+// Blocked is a method of `Limiter` returning how long the IP address is blocked for the failed sign in attempts of
+// the address or its subnet, zero when it is not blocked. When the counters can not be reached, the address is not
+// blocked.
+func (l *Limiter) Blocked(ip string) time.Duration {
+	now := time.Now()
+	var wait time.Duration
+	for _, r := range l.groups[Failures] {
+		start := r.Start(now)
+		failures, err := l.store.Count(key(Failures, r, addressOf(ip, r.Key)), start)
+		if err != nil {
+			log.WithError(err).Error("Failed to collect sign in failures")
+			continue
+		}
+		if w := start.Add(r.Window).Sub(now); failures >= r.Limit && w > wait {
+			wait = w
+		}
+	}
+	return wait
+}
+
+// Fail is a method of `Limiter` counting a failed sign in attempt of the IP address and its subnet.
+func (l *Limiter) Fail(ip string) {
+	now := time.Now()
+	l.purge(now)
+	for _, r := range l.groups[Failures] {
+		if _, err := l.store.Hit(key(Failures, r, addressOf(ip, r.Key)), r.Start(now)); err != nil {
+			log.WithError(err).Error("Failed to count sign in failure")
+		}
+	}
+}
+
+// purge deletes the expired counters in the background, at most once in the purge interval.
+func (l *Limiter) purge(now time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if now.Sub(l.purged) < purgeInterval {
+		return
+	}
+	l.purged = now
+	go func() {
+		if err := l.store.Purge(now.Add(-l.horizon)); err != nil {
+			log.WithError(err).Warn("Failed to purge rate limits")
+		}
+	}()
+}
+
+// key returns the key of the counter of a rule of the group, the value is hashed, so no email address is stored.
+func key(group string, r Rule, value string) string {
+	hash := sha256.Sum256([]byte(value))
+	return group + ":" + r.String() + ":" + hex.EncodeToString(hash[:16])
+}
+
+func valueOf(g *gin.Context, k Key) string {
+	switch k {
+	case IP, Subnet:
+		return addressOf(g.ClientIP(), k)
+	case User:
+		if u, ok := g.Get("user"); ok {
+			return u.(*user.User).ID.String()
+		}
+	case Email:
+		return email(g)
+	}
+	return ""
+}
+
+func addressOf(ip string, k Key) string {
+	if k == Subnet {
+		return SubnetOf(ip)
+	}
+	return ip
+}
+
+// email returns the email address in the JSON body of the request, leaving the body to be read by the handler.
+func email(g *gin.Context) string {
+	if g.Request.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(g.Request.Body, maxBody))
+	g.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), g.Request.Body))
+	if err != nil {
+		return ""
+	}
+	var in struct {
+		Email string `json:"email"`
+	}
+	if err = json.Unmarshal(body, &in); err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(in.Email))
+}
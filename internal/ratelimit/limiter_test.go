@@ -0,0 +1,179 @@
+package ratelimit
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/common"
+)
+
+// MockStore is a mock of the Store interface
+type MockStore struct {
+	mock.Mock
+}
+
+func (m *MockStore) Hit(key string, start time.Time) (int, error) {
+	args := m.Called(key, start)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockStore) Count(key string, start time.Time) (int, error) {
+	args := m.Called(key, start)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockStore) Purge(before time.Time) error {
+	args := m.Called(before)
+	return args.Error(0)
+}
+
+func newRouter(handlers ...gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/", append(handlers, func(g *gin.Context) {
+		body, _ := io.ReadAll(g.Request.Body)
+		g.Data(http.StatusOK, "application/json", body)
+	})...)
+	return router
+}
+
+func post(router *gin.Engine, ip string, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	req.RemoteAddr = ip + ":1234"
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGroupLimitsByIP(t *testing.T) {
+	l, err := NewLimiter(&common.RateLimitConfig{Signin: "ip:2/1h"}, nil)
+	assert.NoError(t, err)
+	router := newRouter(l.Group(Signin))
+
+	assert.Equal(t, http.StatusOK, post(router, "10.0.0.1", "").Code)
+	assert.Equal(t, http.StatusOK, post(router, "10.0.0.1", "").Code)
+	w := post(router, "10.0.0.1", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.Greater(t, retry, 0)
+	assert.LessOrEqual(t, retry, 3600)
+
+	assert.Equal(t, http.StatusOK, post(router, "10.0.0.2", "").Code)
+}
+
+func TestGroupIgnoresForgedForwardedFor(t *testing.T) {
+	for _, web := range []common.WebConfig{{}, {TrustedProxies: "10.0.0.9"}} {
+		l, err := NewLimiter(&common.RateLimitConfig{Signin: "ip:1/1h,subnet:1/1h"}, nil)
+		assert.NoError(t, err)
+		router := newRouter(l.Group(Signin))
+		assert.NoError(t, router.SetTrustedProxies(web.Proxies()))
+
+		forged := func(forwarded string) int {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(""))
+			req.RemoteAddr = "10.0.0.1:1234"
+			req.Header.Set("X-Forwarded-For", forwarded)
+			router.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		assert.Equal(t, http.StatusOK, forged("192.168.1.1"))
+		assert.Equal(t, http.StatusTooManyRequests, forged("172.16.5.5"))
+	}
+}
+
+func TestGroupLimitsByEmailKeepingBody(t *testing.T) {
+	l, err := NewLimiter(&common.RateLimitConfig{Account: "email:1/1h"}, nil)
+	assert.NoError(t, err)
+	router := newRouter(l.Group(Account))
+
+	w := post(router, "10.0.0.1", `{"email":"Test@Example.com"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"email":"Test@Example.com"}`, w.Body.String())
+
+	assert.Equal(t, http.StatusTooManyRequests, post(router, "10.0.0.2", `{"email":"test@example.com "}`).Code)
+	assert.Equal(t, http.StatusOK, post(router, "10.0.0.2", `{"email":"other@example.com"}`).Code)
+	assert.Equal(t, http.StatusOK, post(router, "10.0.0.2", `not json`).Code)
+}
+
+func TestGroupLimitsByUser(t *testing.T) {
+	l, err := NewLimiter(&common.RateLimitConfig{Security: "user:1/15m"}, nil)
+	assert.NoError(t, err)
+	usr := &user.User{ID: uuid.New()}
+	withUser := func(g *gin.Context) {
+		g.Set("user", usr)
+	}
+
+	assert.Equal(t, http.StatusOK, post(newRouter(l.Group(Security)), "10.0.0.1", "").Code)
+	router := newRouter(withUser, l.Group(Security))
+	assert.Equal(t, http.StatusOK, post(router, "10.0.0.1", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, post(router, "10.0.0.2", "").Code)
+}
+
+func TestGroupLetsRequestsThroughWhenStoreFails(t *testing.T) {
+	store := new(MockStore)
+	l, err := NewLimiter(&common.RateLimitConfig{Store: "postgres", Signin: "ip:1/1m"}, store)
+	assert.NoError(t, err)
+
+	store.On("Hit", mock.Anything, mock.Anything).Return(0, assert.AnError)
+
+	assert.Equal(t, http.StatusOK, post(newRouter(l.Group(Signin)), "10.0.0.1", "").Code)
+	store.AssertExpectations(t)
+}
+
+func TestNewLimiterRejectsInvalidConfiguration(t *testing.T) {
+	_, err := NewLimiter(&common.RateLimitConfig{Store: "redis"}, nil)
+	assert.Error(t, err)
+
+	_, err = NewLimiter(&common.RateLimitConfig{Account: "ip:ten/1h"}, nil)
+	assert.Error(t, err)
+
+	_, err = NewLimiter(&common.RateLimitConfig{Failures: "email:5/1h"}, nil)
+	assert.Error(t, err)
+}
+
+func TestBlockedAfterFailuresOfAddress(t *testing.T) {
+	l, err := NewLimiter(&common.RateLimitConfig{Failures: "ip:2/1h,subnet:3/1h"}, nil)
+	assert.NoError(t, err)
+
+	l.Fail("10.0.0.1")
+	assert.Zero(t, l.Blocked("10.0.0.1"))
+	l.Fail("10.0.0.1")
+	assert.Positive(t, l.Blocked("10.0.0.1"))
+	assert.Zero(t, l.Blocked("10.0.0.2"))
+	assert.Zero(t, l.Blocked("10.0.1.1"))
+}
+
+func TestBlockedAfterFailuresOfSubnet(t *testing.T) {
+	l, err := NewLimiter(&common.RateLimitConfig{Failures: "ip:2/1h,subnet:3/1h"}, nil)
+	assert.NoError(t, err)
+
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		l.Fail(ip)
+	}
+
+	assert.Positive(t, l.Blocked("10.0.0.4"))
+	assert.Zero(t, l.Blocked("10.0.1.1"))
+}
+
+func TestBlockedLetsAddressesThroughWhenStoreFails(t *testing.T) {
+	store := new(MockStore)
+	l, err := NewLimiter(&common.RateLimitConfig{Store: "postgres", Failures: "ip:1/1h"}, store)
+	assert.NoError(t, err)
+
+	store.On("Count", mock.Anything, mock.Anything).Return(0, assert.AnError)
+
+	assert.Zero(t, l.Blocked("10.0.0.1"))
+}
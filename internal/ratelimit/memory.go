@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+type counter struct {
+	start time.Time
+	hits  int
+}
+
+// MemoryStore is the in-memory `Store` implementation, for a single instance of the application.
+type MemoryStore struct {
+	mutex    sync.Mutex
+	counters map[string]*counter
+}
+
+// NewMemoryStore creates a new, empty `MemoryStore`.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters: make(map[string]*counter),
+	}
+}
+
+// Hit is a method of `MemoryStore` incrementing the counter of the key in the window.
+func (s *MemoryStore) Hit(key string, start time.Time) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	c, ok := s.counters[key]
+	if !ok || !c.start.Equal(start) {
+		c = &counter{start: start}
+		s.counters[key] = c
+	}
+	c.hits++
+	return c.hits, nil
+}
+
+// Count is a method of `MemoryStore` returning the counter of the key in the window.
+func (s *MemoryStore) Count(key string, start time.Time) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	c, ok := s.counters[key]
+	if !ok || !c.start.Equal(start) {
+		return 0, nil
+	}
+	return c.hits, nil
+}
+
+// Purge is a method of `MemoryStore` deleting the counters of the windows started before the time in parameter.
+func (s *MemoryStore) Purge(before time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for key, c := range s.counters {
+		if c.start.Before(before) {
+			delete(s.counters, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key is the kind of the key the requests are counted by.
+type Key string
+
+const (
+	// IP counts the requests of a client IP address.
+	IP Key = "ip"
+	// Subnet counts the requests of the /24 IPv4 or /64 IPv6 network of the client IP address.
+	Subnet Key = "subnet"
+	// User counts the requests of the signed in user, requests without a user are not counted.
+	User Key = "user"
+	// Email counts the requests for the email address in the JSON body of the request, e.g. the sign in attempts of
+	// an account from any number of IP addresses.
+	Email Key = "email"
+)
+
+// Rule is a struct representing a limit of requests by a key within a fixed time window.
+type Rule struct {
+	Key    Key
+	Limit  int
+	Window time.Duration
+}
+
+// String is a method of `Rule` returning the rule in the format it is configured, e.g. `ip:20/1m0s`.
+func (r Rule) String() string {
+	return fmt.Sprintf("%v:%d/%v", r.Key, r.Limit, r.Window)
+}
+
+// Start is a method of `Rule` returning the start of the window of the rule containing the time in parameter.
+func (r Rule) Start(t time.Time) time.Time {
+	return t.UTC().Truncate(r.Window)
+}
+
+// ParseRules is a function parsing the comma separated rules of a route group in the format of `<key>:<limit>/<window>`,
+// e.g. `ip:20/1m,email:5/15m`. The window is a Go duration. An empty specification has no rules.
+func ParseRules(spec string) ([]Rule, error) {
+	var rules []Rule
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, rest, ok := strings.Cut(part, ":")
+		limit, window, ok2 := strings.Cut(rest, "/")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid rate limit rule %v", part)
+		}
+		r := Rule{Key: Key(key)}
+		if r.Key != IP && r.Key != Subnet && r.Key != User && r.Key != Email {
+			return nil, fmt.Errorf("invalid key of rate limit rule %v", part)
+		}
+		var err error
+		if r.Limit, err = strconv.Atoi(limit); err != nil || r.Limit < 1 {
+			return nil, fmt.Errorf("invalid limit of rate limit rule %v", part)
+		}
+		if r.Window, err = time.ParseDuration(window); err != nil || r.Window < time.Second {
+			return nil, fmt.Errorf("invalid window of rate limit rule %v", part)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// SubnetOf is a function returning the /24 network of an IPv4 address or the /64 network of an IPv6 address, e.g.
+// `192.0.2.0/24`. Addresses which can not be parsed are returned as they are.
+func SubnetOf(ip string) string {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ip
+	}
+	if v4 := addr.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: addr.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
+
+// Store is the interface of the counters of the requests. The in-memory store serves a single instance of the
+// application, replicas share the Postgres store.
+type Store interface {
+	// Hit increments the counter of the key for the window starting at the time in parameter and returns the number
+	// of requests in the window. A counter of an earlier window of the key is restarted.
+	Hit(key string, start time.Time) (int, error)
+	// Count returns the number of requests of the key in the window starting at the time in parameter, without
+	// counting a new one.
+	Count(key string, start time.Time) (int, error)
+	// Purge deletes the counters of the windows started before the time in parameter.
+	Purge(before time.Time) error
+}
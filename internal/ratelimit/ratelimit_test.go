@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("ip:20/1m, email:5/15m,user:100/1h")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Rule{
+		{Key: IP, Limit: 20, Window: time.Minute},
+		{Key: Email, Limit: 5, Window: 15 * time.Minute},
+		{Key: User, Limit: 100, Window: time.Hour},
+	}, rules)
+}
+
+func TestParseRulesWithoutRules(t *testing.T) {
+	rules, err := ParseRules("")
+
+	assert.NoError(t, err)
+	assert.Empty(t, rules)
+}
+
+func TestParseRulesRejectsInvalidRules(t *testing.T) {
+	for _, spec := range []string{"ip", "ip:20", "host:20/1m", "ip:0/1m", "ip:x/1m", "ip:20/1d", "ip:20/1ms"} {
+		_, err := ParseRules(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestSubnetOf(t *testing.T) {
+	assert.Equal(t, "192.0.2.0/24", SubnetOf("192.0.2.17"))
+	assert.Equal(t, "2001:db8:1:2::/64", SubnetOf("2001:db8:1:2:3:4:5:6"))
+	assert.Equal(t, "unknown", SubnetOf("unknown"))
+}
+
+func TestRuleStartAlignsToWindow(t *testing.T) {
+	r := Rule{Key: IP, Limit: 1, Window: 15 * time.Minute}
+	start := r.Start(time.Date(2026, 10, 16, 10, 44, 59, 0, time.UTC))
+
+	assert.Equal(t, time.Date(2026, 10, 16, 10, 30, 0, 0, time.UTC), start)
+}
+
+func TestMemoryStoreRestartsCounterInNewWindow(t *testing.T) {
+	s := NewMemoryStore()
+	start := time.Now().Truncate(time.Minute)
+
+	hits, _ := s.Hit("key", start)
+	assert.Equal(t, 1, hits)
+	hits, _ = s.Hit("key", start)
+	assert.Equal(t, 2, hits)
+	hits, _ = s.Hit("other", start)
+	assert.Equal(t, 1, hits)
+	hits, _ = s.Hit("key", start.Add(time.Minute))
+	assert.Equal(t, 1, hits)
+}
+
+func TestMemoryStorePurgesExpiredCounters(t *testing.T) {
+	s := NewMemoryStore()
+	start := time.Now().Truncate(time.Minute)
+	_, _ = s.Hit("old", start.Add(-time.Hour))
+	_, _ = s.Hit("new", start)
+
+	assert.NoError(t, s.Purge(start.Add(-time.Minute)))
+
+	assert.Len(t, s.counters, 1)
+	assert.Contains(t, s.counters, "new")
+}
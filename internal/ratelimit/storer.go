@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// PostgresStorer is the `Store` implementation based on sqlx library, shared by the replicas of the application.
+type PostgresStorer struct {
+	db *sqlx.DB
+}
+
+// NewPostgresStorer creates a new `PostgresStorer` instance based on the sqlx library.
+func NewPostgresStorer(db *sqlx.DB) *PostgresStorer {
+	return &PostgresStorer{
+		db: db,
+	}
+}
+
+// Hit is a method of the `PostgresStorer` struct. Increments the counter of the key in the window with a single
+// atomic upsert, so concurrent requests of the replicas are all counted.
+func (s *PostgresStorer) Hit(key string, start time.Time) (int, error) {
+	var hits int
+	query := `INSERT INTO microsaas.rate_limits (key, window_start, hits) VALUES ($1, $2, 1) ON CONFLICT (key) DO UPDATE
+		SET hits = CASE WHEN microsaas.rate_limits.window_start = EXCLUDED.window_start THEN microsaas.rate_limits.hits + 1 ELSE 1 END,
+		window_start = EXCLUDED.window_start RETURNING hits`
+	if err := s.db.Get(&hits, query, key, start); err != nil {
+		return 0, fmt.Errorf("failed to count request: %w", err)
+	}
+	return hits, nil
+}
+
+// Count is a method of the `PostgresStorer` struct. Returns the counter of the key in the window, zero when the key
+// has no counter in the window.
+func (s *PostgresStorer) Count(key string, start time.Time) (int, error) {
+	var hits int
+	query := `SELECT hits FROM microsaas.rate_limits WHERE key = $1 AND window_start = $2`
+	err := s.db.Get(&hits, query, key, start)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get request count: %w", err)
+	}
+	return hits, nil
+}
+
+// Purge is a method of the `PostgresStorer` struct. Deletes the counters of the windows started before the time in
+// parameter.
+func (s *PostgresStorer) Purge(before time.Time) error {
+	if _, err := s.db.Exec(`DELETE FROM microsaas.rate_limits WHERE window_start < $1`, before); err != nil {
+		return fmt.Errorf("failed to purge rate limits: %w", err)
+	}
+	return nil
+}
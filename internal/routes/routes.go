@@ -5,86 +5,252 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/inokone/go-micro-saas/internal/auth"
 	"github.com/inokone/go-micro-saas/internal/auth/account"
+	"github.com/inokone/go-micro-saas/internal/auth/apikey"
+	"github.com/inokone/go-micro-saas/internal/auth/identity"
+	"github.com/inokone/go-micro-saas/internal/auth/invitation"
+	"github.com/inokone/go-micro-saas/internal/auth/magiclink"
+	"github.com/inokone/go-micro-saas/internal/auth/organization"
+	"github.com/inokone/go-micro-saas/internal/auth/passkey"
+	"github.com/inokone/go-micro-saas/internal/auth/password"
+	"github.com/inokone/go-micro-saas/internal/auth/provider"
+	"github.com/inokone/go-micro-saas/internal/auth/quota"
 	"github.com/inokone/go-micro-saas/internal/auth/role"
+	"github.com/inokone/go-micro-saas/internal/auth/session"
+	"github.com/inokone/go-micro-saas/internal/auth/twofactor"
 	"github.com/inokone/go-micro-saas/internal/auth/user"
+	"github.com/inokone/go-micro-saas/internal/billing"
 	"github.com/inokone/go-micro-saas/internal/common"
 	"github.com/inokone/go-micro-saas/internal/history"
+	"github.com/inokone/go-micro-saas/internal/invoice"
 	"github.com/inokone/go-micro-saas/internal/mail"
+	"github.com/inokone/go-micro-saas/internal/ratelimit"
 )
 
 // Storers is a struct to collect all `Storer` entities used by the application
 type Storers struct {
-	Users    user.Storer
-	Roles    role.Storer
-	Accounts account.Storer
-	History  history.Storer
+	Users      user.Storer
+	Roles      role.Storer
+	Accounts   account.Storer
+	History    history.Storer
+	Sessions   session.Storer
+	Factors    twofactor.Storer
+	Passkeys   passkey.Storer
+	Keys       apikey.Storer
+	Identities identity.Storer
+	MagicLinks magiclink.Storer
+	Passwords  password.Storer
+
+	Organizations organization.Storer
+	Invitations   invitation.Storer
+	Usages        quota.Storer
+	Subscriptions billing.Storer
+	Invoices      invoice.Storer
+	RateLimits    ratelimit.Store
+}
+
+// Services is a struct to collect the services shared by the handlers of all URLs, so their state, e.g. the failed
+// attempts counted by the rate limiter, is kept in one place
+type Services struct {
+	Keys      *auth.KeySet
+	JWT       *auth.JWTHandler
+	Mailer    *mail.Service
+	Passkeys  *passkey.Service
+	Providers *provider.Registry
+	Payments  billing.Provider
+	Limiter   *ratelimit.Limiter
 }
 
 // InitPrivate is a function to initialize handler mapping for URLs protected with CORS
-func InitPrivate(private *gin.RouterGroup, st Storers, c *common.AppConfig, ps *pubsub.PubSub[string, common.Event]) error {
-	rc, err := common.NewRecaptchaValidator(c.Auth.RecaptchaProjectID, c.Auth.RecaptchaKey, c.PathFor(c.Auth.RecaptchaAppCreds))
+func InitPrivate(private *gin.RouterGroup, st Storers, sv Services, c *common.AppConfig, ps *pubsub.PubSub[string, common.Event]) error {
+	rc, err := common.NewCaptchaVerifier(c)
+	if err != nil {
+		return err
+	}
+	policy, err := password.LoadPolicy(c, st.Passwords)
+	if err != nil {
+		return err
+	}
+	backoff, err := account.ParseBackoff(c.Auth.LockoutBackoff)
 	if err != nil {
 		return err
 	}
 
 	var (
-		mailer = mail.NewService(c.Mail, ps)
-		m      = auth.NewJWTHandler(st.Users, c.Auth)
-		a      = auth.NewHandler(st.Users, st.Accounts, m, rc)
-		ac     = account.NewHandler(st.Users, st.Accounts, mailer, c.Auth, rc)
-		u      = user.NewHandler(st.Users)
+		mailer = sv.Mailer
+		m      = sv.JWT
+		pks    = sv.Passkeys
+		rl     = sv.Limiter
+		a      = auth.NewHandler(st.Users, st.Accounts, st.Factors, st.Identities, st.MagicLinks, pks, m, mailer, c.Auth, rc, rl, backoff)
+		ac     = account.NewHandler(st.Users, st.Accounts, st.Identities, st.Roles, st.Invitations, st.Organizations, policy, mailer, c.Auth, rc, ps)
+		u      = user.NewHandler(st.Users, st.Roles, st.Sessions, ps)
+		s      = session.NewHandler(st.Sessions)
+		tf     = twofactor.NewHandler(st.Factors, c.Mail.ApplicationName, a.Lockout(), ps)
+		pk     = passkey.NewHandler(st.Passkeys, pks, ps)
+		k      = apikey.NewHandler(st.Keys)
+		id     = identity.NewHandler(st.Identities, ps)
 		r      = role.NewHandler(st.Roles)
 		h      = history.NewHandler(st.History)
+		org    = auth.NewOrganizationHandler(st.Organizations, m)
+		inv    = invitation.NewHandler(st.Invitations, st.Organizations, mailer, c.Auth)
+		q      = quota.NewHandler(quota.NewService(st.Usages), st.Users, st.Organizations)
+		is     = invoice.NewService(st.Invoices, mailer, c.Billing, c.Auth.FrontendRoot)
+		b      = billing.NewHandler(st.Subscriptions, st.Users, st.Roles, sv.Payments, is, c.Auth, ps)
+		iv     = invoice.NewHandler(st.Invoices)
 	)
 
+	o, err := auth.NewOAuthHandler(*c.Auth, sv.Providers, st.Users, st.Identities, st.Roles, a.Lockout(), m)
+	if err != nil {
+		return err
+	}
+
 	private.GET("healthcheck", common.Healthcheck)
 
 	g := private.Group("/auth")
 	{
-		g.POST("/signin", a.Signin)
+		g.POST("/signin", rl.Group(ratelimit.Signin), a.Signin)
+		g.POST("/signin/2fa", rl.Group(ratelimit.Signin), a.SigninSecondFactor)
+		g.POST("/passkey", a.PasskeyBegin)
+		g.POST("/passkey/verify", rl.Group(ratelimit.Signin), a.PasskeySignin)
+		g.POST("/magic-link", rl.Group(ratelimit.Account), a.MagicLinkRequest)
+		g.POST("/magic-link/verify", rl.Group(ratelimit.Signin), a.MagicLinkSignin)
+		g.POST("/refresh", a.Refresh)
 		g.GET("/signout", a.Signout)
+		g.GET("/impersonation", m.Validate, a.Impersonation)
+		g.DELETE("/impersonation", m.Validate, a.EndImpersonation)
 	}
 
-	g = private.Group("/account")
+	g = private.Group("/account", m.Scope(apikey.ScopeAccount))
 	{
-		g.POST("/signup", ac.Signup)
+		g.POST("/signup", rl.Group(ratelimit.Account), ac.Signup)
 		g.GET("/confirm", ac.Confirm)
-		g.PUT("/resend", ac.ResendConfirmation)
-		g.PUT("/recover", ac.Recover)
-		g.PUT("/password/reset", ac.ResetPassword)
-		g.PUT("/password/change", m.Validate, ac.ChangePassword)
+		g.PUT("/resend", rl.Group(ratelimit.Account), ac.ResendConfirmation)
+		g.PUT("/recover", rl.Group(ratelimit.Account), ac.Recover)
+		g.PUT("/password/reset", rl.Group(ratelimit.Account), ac.ResetPassword)
+		g.POST("/invitation/signup", rl.Group(ratelimit.Account), ac.InvitationSignup)
+		g.PUT("/invitation/accept", m.Validate, ac.AcceptInvitation)
+		g.PUT("/invitation/decline", ac.DeclineInvitation)
+		g.PUT("/unlock", rl.Group(ratelimit.Account), ac.Unlock)
 		g.GET("/profile", m.Validate, u.Profile)
+		g.GET("/usage", m.Organization, q.List)
+		g.GET("/invoices", m.Validate, iv.List)
+		g.GET("/invoices/:id", m.Validate, iv.Download)
+	}
+
+	// Security settings of the account can only be managed from a signed in session, API keys can not reach them.
+	g = private.Group("/account")
+	{
+		g.PUT("/password/change", m.Validate, m.RejectImpersonation, rl.Group(ratelimit.Security), ac.ChangePassword)
+		g.GET("/sessions", m.Validate, s.List)
+		g.DELETE("/sessions", m.Validate, m.RejectImpersonation, s.RevokeOthers)
+		g.DELETE("/sessions/:id", m.Validate, m.RejectImpersonation, s.Revoke)
+		g.GET("/2fa", m.Validate, tf.Status)
+		g.POST("/2fa", m.Validate, m.ValidateRecent, tf.Enroll)
+		g.DELETE("/2fa", m.Validate, m.ValidateRecent, rl.Group(ratelimit.Security), tf.Disable)
+		g.POST("/2fa/verify", m.Validate, m.ValidateRecent, rl.Group(ratelimit.Security), tf.Activate)
+		g.POST("/2fa/recovery-codes", m.Validate, m.ValidateRecent, rl.Group(ratelimit.Security), tf.RegenerateRecoveryCodes)
+		g.GET("/passkeys", m.Validate, pk.List)
+		g.POST("/passkeys", m.Validate, m.ValidateRecent, pk.BeginRegistration)
+		g.POST("/passkeys/verify", m.Validate, m.ValidateRecent, pk.FinishRegistration)
+		g.DELETE("/passkeys/:id", m.Validate, m.ValidateRecent, pk.Delete)
+		g.GET("/api-keys", m.Validate, k.List)
+		g.POST("/api-keys", m.Validate, m.RejectImpersonation, q.Consume(quota.APIKeys), k.Create)
+		g.DELETE("/api-keys/:id", m.Validate, q.Release(quota.APIKeys), k.Delete)
+		g.GET("/identities", m.Validate, id.List)
+		g.POST("/identities/:provider", m.Validate, m.ValidateRecent, o.Link)
+		g.DELETE("/identities/:id", m.Validate, m.ValidateRecent, id.Unlink)
 	}
 
-	g = private.Group("/users")
+	g = private.Group("/users", m.Scope(apikey.ScopeUsers))
 	{
-		g.GET("/", m.ValidateAdmin, u.List)
+		g.GET("/", m.Organization, u.List)
 		g.PUT("/:id", m.Validate, u.Update)
-		g.PATCH("/:id", m.ValidateAdmin, u.Patch)
-		g.PUT("/:id/enabled", m.ValidateAdmin, u.SetEnabled)
+		g.PATCH("/:id", m.RequirePermission(role.PermUsersWrite), u.Patch)
+		g.PUT("/:id/enabled", m.RequirePermission(role.PermUsersWrite), u.SetEnabled)
+		g.PUT("/:id/role", m.RequirePermission(role.PermUsersWrite), m.ValidateRecent, u.SetRole)
 		g.GET("/:id/history", m.Validate, h.List)
+		g.GET("/:id/sessions", m.RequirePermission(role.PermUsersRead), s.ListForUser)
+		g.DELETE("/:id/sessions", m.RequirePermission(role.PermUsersWrite), s.RevokeForUser)
+		g.GET("/:id/lockout", m.RequirePermission(role.PermUsersRead), ac.Lockout)
+		g.DELETE("/:id/lockout", m.RequirePermission(role.PermUsersWrite), ac.ClearLockout)
+		g.POST("/:id/impersonate", m.RequirePermission(role.PermUsersImpersonate), m.ValidateRecent, a.Impersonate)
 	}
 
-	g = private.Group("/roles", m.ValidateAdmin)
+	g = private.Group("/organizations")
+	{
+		g.GET("/", m.Organization, org.List)
+		g.POST("/", m.Validate, q.Consume(quota.Organizations), org.Create)
+		g.PUT("/:id/active", m.Validate, org.Switch)
+	}
+
+	// Routes of the active organization of the user, as selected for the session.
+	g = private.Group("/organization")
+	{
+		g.PUT("/", m.RequireOrganization(organization.RoleOwner, organization.RoleAdmin), org.Rename)
+		g.GET("/members", m.RequireOrganization(), org.Members)
+		g.PUT("/members/:id/role", m.RequireOrganization(organization.RoleOwner, organization.RoleAdmin), org.SetMemberRole)
+		g.DELETE("/members/:id", m.RequireOrganization(), org.RemoveMember)
+		g.GET("/invitations", m.RequireOrganization(organization.RoleOwner, organization.RoleAdmin), inv.List)
+		g.POST("/invitations", m.RequireOrganization(organization.RoleOwner, organization.RoleAdmin), q.Consume(quota.Invitations), inv.Create)
+		g.PUT("/invitations/:id/resend", m.RequireOrganization(organization.RoleOwner, organization.RoleAdmin), inv.Resend)
+		g.DELETE("/invitations/:id", m.RequireOrganization(organization.RoleOwner, organization.RoleAdmin), inv.Revoke)
+	}
+
+	g = private.Group("/roles", m.Scope(apikey.ScopeRoles), m.RequirePermission(role.PermRolesManage))
 	{
 		g.GET("/", r.List)
+		g.POST("/", r.Create)
 		g.PUT("/:id", r.Update)
+		g.DELETE("/:id", r.Delete)
+		g.PUT("/:id/name", r.Rename)
+		g.POST("/:id/clone", r.Clone)
+		g.PUT("/:id/default", r.SetDefault)
+		g.PUT("/:id/permissions", r.SetPermissions)
+		g.PUT("/:id/limits", r.SetLimits)
+	}
+
+	g = private.Group("/billing")
+	{
+		g.GET("/plans", m.Validate, b.Plans)
+		g.POST("/plans", m.RequirePermission(role.PermBillingManage), b.CreatePlan)
+		g.PUT("/plans/:id", m.RequirePermission(role.PermBillingManage), b.UpdatePlan)
+		g.GET("/subscription", m.Validate, b.Subscription)
+		g.POST("/checkout", m.Validate, b.Checkout)
+		g.POST("/portal", m.Validate, b.Portal)
 	}
 
 	return nil
 }
 
 // InitPublic is a function to initialize handler mapping for URLs not protected with CORS
-func InitPublic(public *gin.RouterGroup, st Storers, c *common.AppConfig) {
-	m := auth.NewJWTHandler(st.Users, c.Auth)
-	gt := auth.NewGoogleHandler(*c.Auth, st.Users, m)
-	ft := auth.NewFacebookHandler(*c.Auth, st.Users, m)
+func InitPublic(public *gin.RouterGroup, st Storers, sv Services, c *common.AppConfig, ps *pubsub.PubSub[string, common.Event]) error {
+	backoff, err := account.ParseBackoff(c.Auth.LockoutBackoff)
+	if err != nil {
+		return err
+	}
+	as := auth.NewService(st.Users, st.Accounts, st.Factors, sv.Passkeys, sv.JWT, sv.Limiter, backoff, sv.Mailer, c.Auth)
+	o, err := auth.NewOAuthHandler(*c.Auth, sv.Providers, st.Users, st.Identities, st.Roles, as, sv.JWT)
+	if err != nil {
+		return err
+	}
+	is := invoice.NewService(st.Invoices, sv.Mailer, c.Billing, c.Auth.FrontendRoot)
+	b := billing.NewHandler(st.Subscriptions, st.Users, st.Roles, sv.Payments, is, c.Auth, ps)
 
 	g := public.Group("/auth")
 	{
-		g.GET("/google/redirect", gt.Redirect)
-		g.GET("/google", gt.Signin)
-		g.GET("/facebook/redirect", ft.Redirect)
-		g.GET("/facebook", ft.Signin)
+		g.GET("/providers", o.List)
+		g.GET("/:provider", o.Signin)
+		g.GET("/:provider/redirect", o.Redirect)
 	}
+
+	// The payment provider signs the webhook events, it can not authenticate as a user.
+	g = public.Group("/billing")
+	{
+		g.POST("/webhook", b.Webhook)
+	}
+	return nil
+}
+
+// InitWellKnown is a function to initialize handler mapping for the well-known URLs of the application
+func InitWellKnown(wellKnown *gin.RouterGroup, ks *auth.KeySet) {
+	wellKnown.GET("/jwks.json", ks.JWKS)
 }